@@ -0,0 +1,26 @@
+// Command audit-verify checks the integrity of the audit log hash chain.
+// It exits with status 1 if any entry was altered, removed or reordered.
+package main
+
+import (
+	"erea-api/config"
+	"erea-api/handlers"
+	"log"
+	"os"
+)
+
+func main() {
+	config.InitRedis()
+
+	result, err := handlers.VerifyAuditChain(config.GetContext())
+	if err != nil {
+		log.Fatalf("감사 로그 검증 실패: %v", err)
+	}
+
+	if !result.Valid {
+		log.Printf("❌ 감사 로그 체인 손상: seq %d (%s)", result.BrokenAtSeq, result.Reason)
+		os.Exit(1)
+	}
+
+	log.Printf("✅ 감사 로그 체인 정상: %d개 항목, head %s", result.EntryCount, result.HeadHash)
+}
@@ -0,0 +1,23 @@
+// Command backfill-indexes builds the secondary indexes (per-user bid sets,
+// bid/deposit sorted sets, deposit ID lookup, status sets) for records that
+// were written before the indexes existed. It is safe to run more than once.
+package main
+
+import (
+	"erea-api/config"
+	"erea-api/handlers"
+	"log"
+)
+
+func main() {
+	config.InitRedis()
+
+	log.Println("보조 인덱스를 재구성하는 중...")
+	result, err := handlers.RebuildIndexes(config.GetContext())
+	if err != nil {
+		log.Fatalf("인덱스 재구성 실패: %v", err)
+	}
+
+	log.Printf("✅ 인덱스 재구성 완료: 사용자 %d, 부동산 %d, 경매 %d, 입찰 %d, 보증금 %d",
+		result.Users, result.Properties, result.Auctions, result.Bids, result.Deposits)
+}
@@ -0,0 +1,24 @@
+package config
+
+import "time"
+
+// 경매 카운트다운 설정
+var (
+	// AuctionTickInterval 진행 중인 경매마다 남은 시간(tick 이벤트)을 보내는 주기 (AUCTION_TICK_INTERVAL 환경변수, 기본 15초)
+	AuctionTickInterval = getEnvDuration("AUCTION_TICK_INTERVAL", 15*time.Second)
+
+	// AuctionClosingMilestones 마감 임박(closing_soon 이벤트)을 알리는 남은 시간 목록 (AUCTION_CLOSING_MILESTONES 환경변수, 쉼표로 구분, 기본 1h,10m,1m)
+	AuctionClosingMilestones = getEnvDurationList("AUCTION_CLOSING_MILESTONES", []time.Duration{time.Hour, 10 * time.Minute, time.Minute})
+)
+
+// 낙찰 잔금 정산 설정
+var (
+	// SettlementPeriod 경매 종료 후 낙찰자가 잔금을 정산해야 하는 기한 (SETTLEMENT_PERIOD 환경변수, 기본 7일)
+	SettlementPeriod = getEnvDuration("SETTLEMENT_PERIOD", 7*24*time.Hour)
+
+	// SettlementReminders 정산 기한 전 낙찰자에게 알림을 보내는 남은 시간 목록 (SETTLEMENT_REMINDERS 환경변수, 쉼표로 구분, 기본 72h,24h,1h)
+	SettlementReminders = getEnvDurationList("SETTLEMENT_REMINDERS", []time.Duration{72 * time.Hour, 24 * time.Hour, time.Hour})
+
+	// SettlementReminderInterval 정산 기한이 다가오는 낙찰을 확인하는 주기 (SETTLEMENT_REMINDER_INTERVAL 환경변수, 기본 5분)
+	SettlementReminderInterval = getEnvDuration("SETTLEMENT_REMINDER_INTERVAL", 5*time.Minute)
+)
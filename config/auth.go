@@ -0,0 +1,10 @@
+package config
+
+// AdminToken 관리자 부트스트랩 토큰 (ADMIN_TOKEN 환경변수)
+// 설정되면 이 토큰으로 관리자 API(API 키 발급 등)에 접근할 수 있습니다
+var AdminToken = getEnv("ADMIN_TOKEN", "")
+
+// GetAdminToken 관리자 부트스트랩 토큰을 반환합니다
+func GetAdminToken() string {
+	return AdminToken
+}
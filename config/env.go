@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getEnv 환경변수 값을 반환하고, 없으면 기본값을 반환합니다
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvDuration 기간(예: 10m, 24h) 환경변수 값을 반환하고, 없거나 잘못된 값이면 기본값을 반환합니다
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvInt64 정수 환경변수 값을 반환하고, 없거나 잘못된 값이면 기본값을 반환합니다
+func getEnvInt64(key string, defaultValue int64) int64 {
+	value, err := strconv.ParseInt(os.Getenv(key), 10, 64)
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvBool true/false 환경변수 값을 반환하고, 없거나 잘못된 값이면 기본값을 반환합니다
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvList 쉼표로 구분된 환경변수 값을 목록으로 반환하고, 없으면 기본값을 반환합니다
+func getEnvList(key string, defaultValue []string) []string {
+	var list []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
+		}
+	}
+	if len(list) == 0 {
+		return defaultValue
+	}
+	return list
+}
+
+// getEnvDurationList 쉼표로 구분된 기간 환경변수 값을 목록으로 반환하고, 없거나 잘못된 값이 있으면 기본값을 반환합니다
+func getEnvDurationList(key string, defaultValue []time.Duration) []time.Duration {
+	values := getEnvList(key, nil)
+	if len(values) == 0 {
+		return defaultValue
+	}
+
+	list := make([]time.Duration, 0, len(values))
+	for _, value := range values {
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			return defaultValue
+		}
+		list = append(list, duration)
+	}
+	return list
+}
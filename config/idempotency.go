@@ -0,0 +1,6 @@
+package config
+
+import "time"
+
+// IdempotencyTTL 멱등성 키로 저장한 첫 응답의 보관 기간 (IDEMPOTENCY_TTL 환경변수, 기본 24시간)
+var IdempotencyTTL = getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour)
@@ -0,0 +1,10 @@
+package config
+
+// 매물 일괄 가져오기 설정
+var (
+	// MaxImportSize 가져오기 파일의 최대 크기 (MAX_IMPORT_SIZE 환경변수, 바이트 단위, 기본 10MB)
+	MaxImportSize = getEnvInt64("MAX_IMPORT_SIZE", 10<<20)
+
+	// MaxImportRows 가져오기 파일 한 개의 최대 데이터 행 수 (MAX_IMPORT_ROWS 환경변수, 기본 1000행)
+	MaxImportRows = getEnvInt64("MAX_IMPORT_ROWS", 1000)
+)
@@ -0,0 +1,15 @@
+package config
+
+import "time"
+
+// 매물 임장 예약 설정
+var (
+	// InspectionReminderWindow 임장 시작 전 예약자에게 알림을 보내는 시간 (INSPECTION_REMINDER_WINDOW 환경변수, 기본 24시간 전)
+	InspectionReminderWindow = getEnvDuration("INSPECTION_REMINDER_WINDOW", 24*time.Hour)
+
+	// InspectionReminderInterval 곧 시작하는 임장을 확인하는 주기 (INSPECTION_REMINDER_INTERVAL 환경변수, 기본 5분)
+	InspectionReminderInterval = getEnvDuration("INSPECTION_REMINDER_INTERVAL", 5*time.Minute)
+
+	// MaxInspectionDuration 임장 시간대 하나의 최대 길이 (MAX_INSPECTION_DURATION 환경변수, 기본 8시간)
+	MaxInspectionDuration = getEnvDuration("MAX_INSPECTION_DURATION", 8*time.Hour)
+)
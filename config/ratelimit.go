@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit 토큰 버킷 한도 (Period 동안 Requests 개의 요청 허용, 최대 Burst 개까지 누적)
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// RateLimitPolicy 라우트 그룹별 한도 (익명 요청은 IP 기준, 인증된 요청은 API 키 기준)
+type RateLimitPolicy struct {
+	Name          string
+	Anonymous     RateLimit
+	Authenticated RateLimit
+}
+
+// 라우트 그룹별 기본 한도 (RATE_LIMIT_<NAME>_ANON / RATE_LIMIT_<NAME>_AUTH 환경변수로 변경 가능, 예: "20/1m")
+var (
+	DefaultRateLimit = loadRateLimitPolicy("default", "120/1m", "600/1m")
+	BidRateLimit     = loadRateLimitPolicy("bids", "20/1m", "120/1m")
+	StatsRateLimit   = loadRateLimitPolicy("stats", "30/1m", "120/1m")
+	SearchRateLimit  = loadRateLimitPolicy("search", "60/1m", "300/1m")
+)
+
+// TrustedProxies X-Forwarded-For / X-Real-IP 헤더를 믿을 프록시 IP 또는 CIDR 목록 (TRUSTED_PROXIES 환경변수, 쉼표 구분, 기본 없음).
+// 비어 있으면 헤더를 무시하고 연결한 주소를 클라이언트 IP로 사용하므로, 익명 요청이 헤더를 바꿔 한도를 피할 수 없습니다.
+var TrustedProxies = getEnvList("TRUSTED_PROXIES", nil)
+
+// Rate 초당 토큰 충전량을 반환합니다
+func (l RateLimit) Rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// String 한도를 "요청수/기간" 형식으로 반환합니다
+func (l RateLimit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// ParseRateLimit "요청수/기간[/버스트]" 형식(예: "20/1m", "100/1h/20")의 한도를 파싱합니다
+func ParseRateLimit(value string) (RateLimit, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q", value)
+	}
+
+	requests, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || requests <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid request count in rate limit %q", value)
+	}
+
+	period, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || period <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid period in rate limit %q", value)
+	}
+
+	burst := requests
+	if len(parts) == 3 {
+		burst, err = strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err != nil || burst <= 0 {
+			return RateLimit{}, fmt.Errorf("invalid burst in rate limit %q", value)
+		}
+	}
+
+	return RateLimit{Requests: requests, Period: period, Burst: burst}, nil
+}
+
+// loadRateLimitPolicy 환경변수에서 그룹 한도를 읽고, 없거나 잘못되면 기본값을 사용합니다
+func loadRateLimitPolicy(name, anonymous, authenticated string) RateLimitPolicy {
+	envPrefix := "RATE_LIMIT_" + strings.ToUpper(name)
+	return RateLimitPolicy{
+		Name:          name,
+		Anonymous:     loadRateLimit(envPrefix+"_ANON", anonymous),
+		Authenticated: loadRateLimit(envPrefix+"_AUTH", authenticated),
+	}
+}
+
+// loadRateLimit 환경변수 한도를 파싱하고, 실패하면 기본값을 사용합니다
+func loadRateLimit(key, defaultValue string) RateLimit {
+	if value := os.Getenv(key); value != "" {
+		limit, err := ParseRateLimit(value)
+		if err == nil {
+			return limit
+		}
+		log.Printf("%s 무시됨: %v", key, err)
+	}
+
+	limit, err := ParseRateLimit(defaultValue)
+	if err != nil {
+		log.Fatalf("기본 rate limit 설정 오류: %v", err)
+	}
+	return limit
+}
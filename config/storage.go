@@ -0,0 +1,26 @@
+package config
+
+// 업로드 파일 저장소 설정
+var (
+	// StorageBackend 파일 저장 백엔드 (STORAGE_BACKEND 환경변수: local 또는 s3, 기본 local)
+	StorageBackend = getEnv("STORAGE_BACKEND", "local")
+
+	// StorageLocalDir 로컬 백엔드의 저장 디렉터리 (STORAGE_LOCAL_DIR 환경변수)
+	StorageLocalDir = getEnv("STORAGE_LOCAL_DIR", "./data/uploads")
+
+	// S3 호환 백엔드 설정 (AWS S3, MinIO 등, 경로 방식 URL 사용)
+	StorageS3Endpoint  = getEnv("STORAGE_S3_ENDPOINT", "")
+	StorageS3Bucket    = getEnv("STORAGE_S3_BUCKET", "")
+	StorageS3Region    = getEnv("STORAGE_S3_REGION", "us-east-1")
+	StorageS3AccessKey = getEnv("STORAGE_S3_ACCESS_KEY", "")
+	StorageS3SecretKey = getEnv("STORAGE_S3_SECRET_KEY", "")
+
+	// MaxImageSize 사진 한 장의 최대 크기 (MAX_IMAGE_SIZE 환경변수, 바이트 단위, 기본 10MB)
+	MaxImageSize = getEnvInt64("MAX_IMAGE_SIZE", 10<<20)
+
+	// MaxDocumentSize 문서 한 건의 최대 크기 (MAX_DOCUMENT_SIZE 환경변수, 바이트 단위, 기본 20MB)
+	MaxDocumentSize = getEnvInt64("MAX_DOCUMENT_SIZE", 20<<20)
+
+	// MaxPhotosPerProperty 부동산당 최대 사진 수 (MAX_PHOTOS_PER_PROPERTY 환경변수, 기본 30장)
+	MaxPhotosPerProperty = getEnvInt64("MAX_PHOTOS_PER_PROPERTY", 30)
+)
@@ -0,0 +1,12 @@
+package config
+
+import "time"
+
+// 관심 매물 알림 설정
+var (
+	// ClosingNoticeWindow 관심 매물의 경매 마감을 미리 알리는 시간 (CLOSING_NOTICE_WINDOW 환경변수, 기본 1시간 전)
+	ClosingNoticeWindow = getEnvDuration("CLOSING_NOTICE_WINDOW", time.Hour)
+
+	// ClosingNoticeInterval 마감 임박 경매를 확인하는 주기 (CLOSING_NOTICE_INTERVAL 환경변수, 기본 1분)
+	ClosingNoticeInterval = getEnvDuration("CLOSING_NOTICE_INTERVAL", time.Minute)
+)
@@ -0,0 +1,65 @@
+package config
+
+import "time"
+
+// WebSocket 연결 설정
+var (
+	// WSWriteWait 메시지 하나를 클라이언트에 쓰는 최대 시간 (WS_WRITE_WAIT 환경변수, 기본 10초)
+	WSWriteWait = getEnvDuration("WS_WRITE_WAIT", 10*time.Second)
+
+	// WSPongWait 클라이언트의 pong 응답을 기다리는 시간, 넘기면 연결을 끊음 (WS_PONG_WAIT 환경변수, 기본 60초)
+	WSPongWait = getEnvDuration("WS_PONG_WAIT", 60*time.Second)
+
+	// WSPingPeriod ping 전송 주기, pong 대기 시간보다 짧아야 함 (pong 대기 시간의 90%)
+	WSPingPeriod = WSPongWait * 9 / 10
+
+	// WSMaxMessageSize 클라이언트가 보내는 메시지의 최대 크기 (WS_MAX_MESSAGE_SIZE 환경변수, 바이트 단위, 기본 4KB)
+	WSMaxMessageSize = getEnvInt64("WS_MAX_MESSAGE_SIZE", 4096)
+
+	// WSSendBuffer 클라이언트별 전송 대기열 크기, 가득 차면 느린 클라이언트로 보고 연결을 끊음 (WS_SEND_BUFFER 환경변수, 기본 256개)
+	WSSendBuffer = getEnvInt64("WS_SEND_BUFFER", 256)
+)
+
+// WebSocket 이벤트 재전송 설정
+var (
+	// WSReplaySize 토픽별로 보관하는 최근 이벤트 수, 재접속한 클라이언트에 다시 보낼 수 있는 범위 (WS_REPLAY_SIZE 환경변수, 기본 500개)
+	WSReplaySize = getEnvInt64("WS_REPLAY_SIZE", 500)
+
+	// WSReplayTTL 이벤트가 없는 토픽의 기록을 보관하는 기간 (WS_REPLAY_TTL 환경변수, 기본 24시간)
+	WSReplayTTL = getEnvDuration("WS_REPLAY_TTL", 24*time.Hour)
+)
+
+// WebSocket 인증 설정
+var (
+	// WSAllowedOrigins WebSocket 연결을 허용하는 Origin 목록 (WS_ALLOWED_ORIGINS 환경변수, 쉼표로 구분, 예: https://erea.io)
+	// 비어 있으면 같은 Origin(서버와 같은 호스트)만 허용하고, *가 있으면 모든 Origin을 허용합니다 (개발용)
+	WSAllowedOrigins = getEnvList("WS_ALLOWED_ORIGINS", nil)
+
+	// WSRequireAuth true면 토큰 없는 WebSocket 연결을 거부 (WS_REQUIRE_AUTH 환경변수, 기본 false)
+	// false면 토큰 없이 공개 토픽만 구독할 수 있습니다
+	WSRequireAuth = getEnvBool("WS_REQUIRE_AUTH", false)
+)
+
+// SSE 스트림 설정
+var (
+	// SSEKeepaliveInterval 이벤트가 없을 때 keepalive 주석을 보내는 주기, 프록시가 유휴 연결을 끊지 않도록 함 (SSE_KEEPALIVE_INTERVAL 환경변수, 기본 15초)
+	SSEKeepaliveInterval = getEnvDuration("SSE_KEEPALIVE_INTERVAL", 15*time.Second)
+)
+
+// 접속 현황 설정
+var (
+	// PresenceHeartbeatInterval 서버별 접속자 현황을 Redis에 갱신하는 주기 (PRESENCE_HEARTBEAT_INTERVAL 환경변수, 기본 15초)
+	PresenceHeartbeatInterval = getEnvDuration("PRESENCE_HEARTBEAT_INTERVAL", 15*time.Second)
+
+	// PresenceTTL 갱신이 멈춘 접속자를 현황에서 빼는 시간, 갱신 주기보다 길어야 함 (PRESENCE_TTL 환경변수, 기본 45초)
+	PresenceTTL = getEnvDuration("PRESENCE_TTL", 45*time.Second)
+)
+
+// WebSocket 압축 설정
+var (
+	// WSCompression 클라이언트가 지원하면 permessage-deflate로 메시지를 압축 (WS_COMPRESSION 환경변수, 기본 true)
+	WSCompression = getEnvBool("WS_COMPRESSION", true)
+
+	// WSCompressionMinSize 이보다 작은 메시지는 압축하지 않음, 압축 이득보다 CPU 비용이 큼 (WS_COMPRESSION_MIN_SIZE 환경변수, 바이트 단위, 기본 256)
+	WSCompressionMinSize = getEnvInt64("WS_COMPRESSION_MIN_SIZE", 256)
+)
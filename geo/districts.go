@@ -0,0 +1,103 @@
+package geo
+
+// districts is the offline geocoding table. Coordinates are approximate
+// centroids (district offices for si/gun/gu), accurate enough for map pins
+// and radius search but not for parcel-level positioning.
+var districts = []District{
+	// 서울특별시
+	{ID: "seoul", Level: LevelProvince, Names: []string{"Seoul", "서울", "서울시", "서울특별시"}, Point: Point{37.5665, 126.9780}},
+	{ID: "seoul/jongno-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Jongno-gu", "종로구"}, Point: Point{37.5735, 126.9790}},
+	{ID: "seoul/jung-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Jung-gu", "중구"}, Point: Point{37.5641, 126.9979}},
+	{ID: "seoul/yongsan-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Yongsan-gu", "용산구"}, Point: Point{37.5326, 126.9905}},
+	{ID: "seoul/seongdong-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Seongdong-gu", "성동구"}, Point: Point{37.5633, 127.0371}},
+	{ID: "seoul/gwangjin-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Gwangjin-gu", "광진구"}, Point: Point{37.5385, 127.0823}},
+	{ID: "seoul/dongdaemun-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Dongdaemun-gu", "동대문구"}, Point: Point{37.5744, 127.0396}},
+	{ID: "seoul/jungnang-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Jungnang-gu", "중랑구"}, Point: Point{37.6063, 127.0925}},
+	{ID: "seoul/seongbuk-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Seongbuk-gu", "성북구"}, Point: Point{37.5894, 127.0167}},
+	{ID: "seoul/gangbuk-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Gangbuk-gu", "강북구"}, Point: Point{37.6396, 127.0257}},
+	{ID: "seoul/dobong-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Dobong-gu", "도봉구"}, Point: Point{37.6688, 127.0471}},
+	{ID: "seoul/nowon-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Nowon-gu", "노원구"}, Point: Point{37.6542, 127.0568}},
+	{ID: "seoul/eunpyeong-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Eunpyeong-gu", "은평구"}, Point: Point{37.6027, 126.9291}},
+	{ID: "seoul/seodaemun-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Seodaemun-gu", "서대문구"}, Point: Point{37.5791, 126.9368}},
+	{ID: "seoul/mapo-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Mapo-gu", "마포구"}, Point: Point{37.5663, 126.9019}},
+	{ID: "seoul/yangcheon-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Yangcheon-gu", "양천구"}, Point: Point{37.5170, 126.8665}},
+	{ID: "seoul/gangseo-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Gangseo-gu", "강서구"}, Point: Point{37.5509, 126.8495}},
+	{ID: "seoul/guro-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Guro-gu", "구로구"}, Point: Point{37.4954, 126.8874}},
+	{ID: "seoul/geumcheon-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Geumcheon-gu", "금천구"}, Point: Point{37.4569, 126.8955}},
+	{ID: "seoul/yeongdeungpo-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Yeongdeungpo-gu", "영등포구"}, Point: Point{37.5264, 126.8962}},
+	{ID: "seoul/dongjak-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Dongjak-gu", "동작구"}, Point: Point{37.5124, 126.9393}},
+	{ID: "seoul/gwanak-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Gwanak-gu", "관악구"}, Point: Point{37.4784, 126.9516}},
+	{ID: "seoul/seocho-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Seocho-gu", "서초구"}, Point: Point{37.4837, 127.0324}},
+	{ID: "seoul/gangnam-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Gangnam-gu", "강남구"}, Point: Point{37.5172, 127.0473}},
+	{ID: "seoul/songpa-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Songpa-gu", "송파구"}, Point: Point{37.5145, 127.1059}},
+	{ID: "seoul/gangdong-gu", Parent: "seoul", Level: LevelDistrict, Names: []string{"Gangdong-gu", "강동구"}, Point: Point{37.5301, 127.1238}},
+
+	{ID: "seoul/gangnam-gu/sinsa-dong", Parent: "seoul/gangnam-gu", Level: LevelDong, Names: []string{"Sinsa-dong", "신사동"}, Point: Point{37.5240, 127.0227}},
+	{ID: "seoul/gangnam-gu/apgujeong-dong", Parent: "seoul/gangnam-gu", Level: LevelDong, Names: []string{"Apgujeong-dong", "압구정동"}, Point: Point{37.5271, 127.0286}},
+	{ID: "seoul/gangnam-gu/cheongdam-dong", Parent: "seoul/gangnam-gu", Level: LevelDong, Names: []string{"Cheongdam-dong", "청담동"}, Point: Point{37.5245, 127.0473}},
+	{ID: "seoul/gangnam-gu/nonhyeon-dong", Parent: "seoul/gangnam-gu", Level: LevelDong, Names: []string{"Nonhyeon-dong", "논현동"}, Point: Point{37.5110, 127.0290}},
+	{ID: "seoul/gangnam-gu/yeoksam-dong", Parent: "seoul/gangnam-gu", Level: LevelDong, Names: []string{"Yeoksam-dong", "역삼동"}, Point: Point{37.5006, 127.0364}},
+	{ID: "seoul/gangnam-gu/samseong-dong", Parent: "seoul/gangnam-gu", Level: LevelDong, Names: []string{"Samseong-dong", "삼성동"}, Point: Point{37.5088, 127.0631}},
+	{ID: "seoul/gangnam-gu/daechi-dong", Parent: "seoul/gangnam-gu", Level: LevelDong, Names: []string{"Daechi-dong", "대치동"}, Point: Point{37.4991, 127.0621}},
+	{ID: "seoul/seocho-gu/seocho-dong", Parent: "seoul/seocho-gu", Level: LevelDong, Names: []string{"Seocho-dong", "서초동"}, Point: Point{37.4919, 127.0076}},
+	{ID: "seoul/seocho-gu/banpo-dong", Parent: "seoul/seocho-gu", Level: LevelDong, Names: []string{"Banpo-dong", "반포동"}, Point: Point{37.5048, 127.0044}},
+	{ID: "seoul/songpa-gu/jamsil-dong", Parent: "seoul/songpa-gu", Level: LevelDong, Names: []string{"Jamsil-dong", "잠실동"}, Point: Point{37.5116, 127.0863}},
+	{ID: "seoul/mapo-gu/hapjeong-dong", Parent: "seoul/mapo-gu", Level: LevelDong, Names: []string{"Hapjeong-dong", "합정동"}, Point: Point{37.5497, 126.9137}},
+	{ID: "seoul/mapo-gu/seogyo-dong", Parent: "seoul/mapo-gu", Level: LevelDong, Names: []string{"Seogyo-dong", "서교동"}, Point: Point{37.5554, 126.9193}},
+	{ID: "seoul/mapo-gu/gongdeok-dong", Parent: "seoul/mapo-gu", Level: LevelDong, Names: []string{"Gongdeok-dong", "공덕동"}, Point: Point{37.5446, 126.9516}},
+	{ID: "seoul/yeongdeungpo-gu/yeouido-dong", Parent: "seoul/yeongdeungpo-gu", Level: LevelDong, Names: []string{"Yeouido-dong", "여의도동"}, Point: Point{37.5219, 126.9245}},
+	{ID: "seoul/yongsan-gu/itaewon-dong", Parent: "seoul/yongsan-gu", Level: LevelDong, Names: []string{"Itaewon-dong", "이태원동"}, Point: Point{37.5345, 126.9946}},
+	{ID: "seoul/yongsan-gu/hannam-dong", Parent: "seoul/yongsan-gu", Level: LevelDong, Names: []string{"Hannam-dong", "한남동"}, Point: Point{37.5347, 127.0026}},
+	{ID: "seoul/seongdong-gu/seongsu-dong", Parent: "seoul/seongdong-gu", Level: LevelDong, Names: []string{"Seongsu-dong", "성수동"}, Point: Point{37.5446, 127.0559}},
+	{ID: "seoul/eunpyeong-gu/sinsa-dong", Parent: "seoul/eunpyeong-gu", Level: LevelDong, Names: []string{"Sinsa-dong", "신사동"}, Point: Point{37.5985, 126.9123}},
+
+	{ID: "seoul/gangnam-station", Parent: "seoul/gangnam-gu", Level: LevelLandmark, Names: []string{"Gangnam Station", "강남역"}, Point: Point{37.4979, 127.0276}},
+	{ID: "seoul/seoul-station", Parent: "seoul/yongsan-gu", Level: LevelLandmark, Names: []string{"Seoul Station", "서울역"}, Point: Point{37.5547, 126.9707}},
+	{ID: "seoul/hongik-univ-station", Parent: "seoul/mapo-gu", Level: LevelLandmark, Names: []string{"Hongik University Station", "Hongdae", "홍대입구역", "홍대"}, Point: Point{37.5572, 126.9245}},
+	{ID: "seoul/jamsil-station", Parent: "seoul/songpa-gu", Level: LevelLandmark, Names: []string{"Jamsil Station", "잠실역"}, Point: Point{37.5133, 127.1001}},
+	{ID: "seoul/yeouido-station", Parent: "seoul/yeongdeungpo-gu", Level: LevelLandmark, Names: []string{"Yeouido Station", "여의도역"}, Point: Point{37.5215, 126.9243}},
+
+	// 경기도
+	{ID: "gyeonggi", Level: LevelProvince, Names: []string{"Gyeonggi-do", "경기도", "경기"}, Point: Point{37.2893, 127.0535}},
+	{ID: "gyeonggi/seongnam-si", Parent: "gyeonggi", Level: LevelCity, Names: []string{"Seongnam-si", "성남시"}, Point: Point{37.4200, 127.1267}},
+	{ID: "gyeonggi/seongnam-si/bundang-gu", Parent: "gyeonggi/seongnam-si", Level: LevelDistrict, Names: []string{"Bundang-gu", "분당구"}, Point: Point{37.3827, 127.1189}},
+	{ID: "gyeonggi/seongnam-si/bundang-gu/jeongja-dong", Parent: "gyeonggi/seongnam-si/bundang-gu", Level: LevelDong, Names: []string{"Jeongja-dong", "정자동"}, Point: Point{37.3670, 127.1082}},
+	{ID: "gyeonggi/seongnam-si/bundang-gu/seohyeon-dong", Parent: "gyeonggi/seongnam-si/bundang-gu", Level: LevelDong, Names: []string{"Seohyeon-dong", "서현동"}, Point: Point{37.3850, 127.1234}},
+	{ID: "gyeonggi/seongnam-si/bundang-gu/baekhyeon-dong", Parent: "gyeonggi/seongnam-si/bundang-gu", Level: LevelDong, Names: []string{"Baekhyeon-dong", "백현동"}, Point: Point{37.3894, 127.1110}},
+	{ID: "gyeonggi/pangyo-station", Parent: "gyeonggi/seongnam-si/bundang-gu", Level: LevelLandmark, Names: []string{"Pangyo Station", "판교역", "Pangyo", "판교"}, Point: Point{37.3948, 127.1112}},
+	{ID: "gyeonggi/suwon-si", Parent: "gyeonggi", Level: LevelCity, Names: []string{"Suwon-si", "수원시"}, Point: Point{37.2636, 127.0286}},
+	{ID: "gyeonggi/yongin-si", Parent: "gyeonggi", Level: LevelCity, Names: []string{"Yongin-si", "용인시"}, Point: Point{37.2411, 127.1776}},
+	{ID: "gyeonggi/goyang-si", Parent: "gyeonggi", Level: LevelCity, Names: []string{"Goyang-si", "고양시"}, Point: Point{37.6584, 126.8320}},
+	{ID: "gyeonggi/hwaseong-si", Parent: "gyeonggi", Level: LevelCity, Names: []string{"Hwaseong-si", "화성시"}, Point: Point{37.1995, 126.8311}},
+	{ID: "gyeonggi/bucheon-si", Parent: "gyeonggi", Level: LevelCity, Names: []string{"Bucheon-si", "부천시"}, Point: Point{37.5034, 126.7660}},
+	{ID: "gyeonggi/anyang-si", Parent: "gyeonggi", Level: LevelCity, Names: []string{"Anyang-si", "안양시"}, Point: Point{37.3943, 126.9568}},
+	{ID: "gyeonggi/hanam-si", Parent: "gyeonggi", Level: LevelCity, Names: []string{"Hanam-si", "하남시"}, Point: Point{37.5393, 127.2149}},
+
+	// 인천광역시
+	{ID: "incheon", Level: LevelProvince, Names: []string{"Incheon", "인천", "인천광역시"}, Point: Point{37.4563, 126.7052}},
+	{ID: "incheon/yeonsu-gu", Parent: "incheon", Level: LevelDistrict, Names: []string{"Yeonsu-gu", "연수구"}, Point: Point{37.4101, 126.6783}},
+	{ID: "incheon/yeonsu-gu/songdo-dong", Parent: "incheon/yeonsu-gu", Level: LevelDong, Names: []string{"Songdo-dong", "송도동"}, Point: Point{37.3838, 126.6560}},
+
+	// 부산광역시
+	{ID: "busan", Level: LevelProvince, Names: []string{"Busan", "부산", "부산광역시"}, Point: Point{35.1796, 129.0756}},
+	{ID: "busan/haeundae-gu", Parent: "busan", Level: LevelDistrict, Names: []string{"Haeundae-gu", "해운대구"}, Point: Point{35.1631, 129.1636}},
+	{ID: "busan/haeundae-gu/u-dong", Parent: "busan/haeundae-gu", Level: LevelDong, Names: []string{"U-dong", "우동"}, Point: Point{35.1660, 129.1480}},
+	{ID: "busan/suyeong-gu", Parent: "busan", Level: LevelDistrict, Names: []string{"Suyeong-gu", "수영구"}, Point: Point{35.1455, 129.1131}},
+	{ID: "busan/busanjin-gu", Parent: "busan", Level: LevelDistrict, Names: []string{"Busanjin-gu", "부산진구"}, Point: Point{35.1631, 129.0532}},
+	{ID: "busan/jung-gu", Parent: "busan", Level: LevelDistrict, Names: []string{"Jung-gu", "중구"}, Point: Point{35.1062, 129.0324}},
+	{ID: "busan/haeundae-station", Parent: "busan/haeundae-gu", Level: LevelLandmark, Names: []string{"Haeundae Station", "해운대역"}, Point: Point{35.1634, 129.1588}},
+
+	// Other metropolitan cities
+	{ID: "daegu", Level: LevelProvince, Names: []string{"Daegu", "대구", "대구광역시"}, Point: Point{35.8714, 128.6014}},
+	{ID: "daegu/suseong-gu", Parent: "daegu", Level: LevelDistrict, Names: []string{"Suseong-gu", "수성구"}, Point: Point{35.8582, 128.6306}},
+	{ID: "daejeon", Level: LevelProvince, Names: []string{"Daejeon", "대전", "대전광역시"}, Point: Point{36.3504, 127.3845}},
+	{ID: "daejeon/yuseong-gu", Parent: "daejeon", Level: LevelDistrict, Names: []string{"Yuseong-gu", "유성구"}, Point: Point{36.3624, 127.3563}},
+	{ID: "gwangju", Level: LevelProvince, Names: []string{"Gwangju", "광주", "광주광역시"}, Point: Point{35.1595, 126.8526}},
+	{ID: "ulsan", Level: LevelProvince, Names: []string{"Ulsan", "울산", "울산광역시"}, Point: Point{35.5384, 129.3114}},
+	{ID: "sejong", Level: LevelProvince, Names: []string{"Sejong", "세종", "세종시", "세종특별자치시"}, Point: Point{36.4800, 127.2890}},
+
+	// 제주특별자치도
+	{ID: "jeju", Level: LevelProvince, Names: []string{"Jeju-do", "제주도", "제주특별자치도"}, Point: Point{33.4890, 126.4983}},
+	{ID: "jeju/jeju-si", Parent: "jeju", Level: LevelCity, Names: []string{"Jeju-si", "제주시"}, Point: Point{33.4996, 126.5312}},
+	{ID: "jeju/seogwipo-si", Parent: "jeju", Level: LevelCity, Names: []string{"Seogwipo-si", "서귀포시"}, Point: Point{33.2541, 126.5600}},
+}
@@ -0,0 +1,143 @@
+// Package geo resolves Korean addresses to coordinates using an offline
+// table of administrative districts, so properties can be placed on a map
+// without calling an external geocoding service.
+package geo
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// earthRadiusKm is the mean Earth radius used for distance calculations
+const earthRadiusKm = 6371.0
+
+// Point is a WGS84 coordinate
+type Point struct {
+	Lat float64 `json:"latitude"`
+	Lng float64 `json:"longitude"`
+}
+
+// Valid reports whether the point lies within WGS84 bounds
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180
+}
+
+// Distance returns the great-circle distance between two points in km
+func Distance(a, b Point) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Level is the administrative level of a district
+type Level int
+
+// Administrative levels, from least to most specific
+const (
+	LevelProvince Level = iota + 1 // 도, 특별시, 광역시
+	LevelCity                      // 시, 군
+	LevelDistrict                  // 구
+	LevelDong                      // 동, 읍, 면
+	LevelLandmark                  // Stations and other well-known places
+)
+
+// District is one entry of the geocoding table
+type District struct {
+	ID     string
+	Parent string
+	Level  Level
+	Names  []string // Romanized and Korean names, first is the display name
+	Point  Point
+}
+
+// Name returns the display name of the district
+func (d District) Name() string {
+	return d.Names[0]
+}
+
+// byName indexes the table by lowercased name
+var byName = func() map[string][]*District {
+	index := make(map[string][]*District)
+	for i := range districts {
+		for _, name := range districts[i].Names {
+			key := strings.ToLower(name)
+			index[key] = append(index[key], &districts[i])
+		}
+	}
+	return index
+}()
+
+// byID indexes the table by ID
+var byID = func() map[string]*District {
+	index := make(map[string]*District, len(districts))
+	for i := range districts {
+		index[districts[i].ID] = &districts[i]
+	}
+	return index
+}()
+
+// Lookup finds a district or landmark by one of its names, e.g. "강남역" or
+// "Gangnam-gu". Ambiguous names resolve to the first table entry.
+func Lookup(name string) (District, bool) {
+	matches := byName[strings.ToLower(strings.TrimSpace(name))]
+	if len(matches) == 0 {
+		return District{}, false
+	}
+	return *matches[0], true
+}
+
+// LookupLevel is Lookup restricted to districts of the given level.
+func LookupLevel(name string, level Level) (District, bool) {
+	for _, d := range byName[strings.ToLower(strings.TrimSpace(name))] {
+		if d.Level == level {
+			return *d, true
+		}
+	}
+	return District{}, false
+}
+
+// Geocode resolves an address such as "Sinsa-dong, Gangnam-gu, Seoul" or
+// "서울특별시 강남구 역삼동 123-45" to the most specific district it names.
+// Names whose parents also appear in the address win over same-named
+// districts elsewhere (e.g. Sinsa-dong in Gangnam-gu vs Eunpyeong-gu).
+func Geocode(address string) (District, bool) {
+	names := make(map[*District]bool)
+	for _, part := range strings.Split(address, ",") {
+		part = strings.TrimSpace(part)
+		if matches := byName[strings.ToLower(part)]; len(matches) > 0 {
+			for _, d := range matches {
+				names[d] = true
+			}
+			continue
+		}
+		for _, word := range strings.FieldsFunc(part, unicode.IsSpace) {
+			for _, d := range byName[strings.ToLower(word)] {
+				names[d] = true
+			}
+		}
+	}
+
+	var best *District
+	bestScore := -1
+	for d := range names {
+		score := int(d.Level) * 100
+		for parent := byID[d.Parent]; parent != nil; parent = byID[parent.Parent] {
+			if names[parent] {
+				score += 10
+			}
+		}
+		// Break ties by ID so results are deterministic
+		if score > bestScore || (score == bestScore && d.ID < best.ID) {
+			best, bestScore = d, score
+		}
+	}
+
+	if best == nil {
+		return District{}, false
+	}
+	return *best, true
+}
@@ -0,0 +1,47 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGeocodeDisambiguatesByParent(t *testing.T) {
+	cases := map[string]string{
+		"Sinsa-dong, Gangnam-gu, Seoul":   "seoul/gangnam-gu/sinsa-dong",
+		"Sinsa-dong, Eunpyeong-gu, Seoul": "seoul/eunpyeong-gu/sinsa-dong",
+		"서울특별시 은평구 신사동 123-45":            "seoul/eunpyeong-gu/sinsa-dong",
+		"Jung-gu, Busan":                  "busan/jung-gu",
+		"서울 중구":                           "seoul/jung-gu",
+		"Gangnam-gu, Seoul, South Korea":  "seoul/gangnam-gu",
+		"Seoul":                           "seoul",
+	}
+	for address, want := range cases {
+		got, ok := Geocode(address)
+		if !ok || got.ID != want {
+			t.Errorf("Geocode(%q) = %q, %v; want %q", address, got.ID, ok, want)
+		}
+	}
+
+	// Without a parent to tell them apart the result is still deterministic
+	first, _ := Geocode("Sinsa-dong")
+	for i := 0; i < 10; i++ {
+		if again, _ := Geocode("Sinsa-dong"); again.ID != first.ID {
+			t.Fatalf("Geocode(Sinsa-dong) changed from %q to %q", first.ID, again.ID)
+		}
+	}
+
+	if _, ok := Geocode("Atlantis"); ok {
+		t.Error("Geocode resolved an unknown place")
+	}
+}
+
+func TestDistance(t *testing.T) {
+	seoul, _ := Lookup("Seoul")
+	busan, _ := Lookup("Busan")
+	if d := Distance(seoul.Point, busan.Point); math.Abs(d-325) > 15 {
+		t.Fatalf("Seoul to Busan is about 325km, got %.0f", d)
+	}
+	if d := Distance(seoul.Point, seoul.Point); d != 0 {
+		t.Fatalf("distance to itself is %v", d)
+	}
+}
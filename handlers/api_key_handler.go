@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"erea-api/config"
+	"erea-api/middleware"
+	"erea-api/models"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateAPIKey issues a new scoped API key. The plaintext key is only
+// returned in this response.
+func CreateAPIKey(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIKeyResponse{
+			Success: false,
+			Message: "Invalid request data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	for _, scope := range req.Scopes {
+		if !isKnownScope(scope) {
+			c.JSON(http.StatusBadRequest, models.APIKeyResponse{
+				Success: false,
+				Message: "Invalid request data",
+				Error:   fmt.Sprintf("unknown scope %q", scope),
+			})
+			return
+		}
+	}
+
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, models.APIKeyResponse{
+			Success: false,
+			Message: "Invalid request data",
+			Error:   "expires_at must be in the future",
+		})
+		return
+	}
+
+	prefix, secret, err := generateAPIKeySecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIKeyResponse{
+			Success: false,
+			Message: "Failed to generate API key",
+			Error:   err.Error(),
+		})
+		return
+	}
+	plaintext := prefix + "_" + secret
+
+	key := models.APIKey{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		Prefix:    prefix,
+		Hash:      middleware.HashAPIKey(plaintext),
+		Scopes:    req.Scopes,
+		UserID:    req.UserID,
+		CreatedAt: time.Now(),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if principal, ok := middleware.GetPrincipal(c); ok {
+		key.CreatedBy = principal.ID
+	}
+
+	keyJSON, err := key.ToJSON()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIKeyResponse{
+			Success: false,
+			Message: "Failed to encode API key data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	redis := config.GetRedisClient()
+	ctx := config.GetContext()
+
+	if err := redis.Set(ctx, "api_key:"+key.ID, keyJSON, 0).Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIKeyResponse{
+			Success: false,
+			Message: "Failed to save API key",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Index by hash for authentication and by ID for listing
+	redis.Set(ctx, "api_key_hash:"+key.Hash, key.ID, 0)
+	redis.SAdd(ctx, "api_keys", key.ID)
+
+	recordAudit(c, "api_key.create", "api_key", key.ID, nil, key)
+
+	c.JSON(http.StatusCreated, models.APIKeyResponse{
+		Success: true,
+		Message: "API key created successfully. Store the key now; it will not be shown again",
+		Data: models.CreatedAPIKey{
+			APIKey: key,
+			Key:    plaintext,
+		},
+	})
+}
+
+// GetAPIKeys retrieves all issued API keys (without secrets)
+func GetAPIKeys(c *gin.Context) {
+	redis := config.GetRedisClient()
+	ctx := config.GetContext()
+
+	keyIDs, err := redis.SMembers(ctx, "api_keys").Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIKeyResponse{
+			Success: false,
+			Message: "Failed to retrieve API keys",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	keys := []models.APIKey{}
+	for _, keyID := range keyIDs {
+		keyJSON, err := redis.Get(ctx, "api_key:"+keyID).Result()
+		if err != nil {
+			continue
+		}
+
+		var key models.APIKey
+		if err := key.FromJSON(keyJSON); err != nil {
+			continue
+		}
+		middleware.LoadAPIKeyLastUsed(&key)
+
+		keys = append(keys, key)
+	}
+
+	// Sort keys by creation time (newest first)
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	c.JSON(http.StatusOK, models.APIKeyResponse{
+		Success: true,
+		Message: "API keys retrieved successfully",
+		Data:    keys,
+	})
+}
+
+// GetAPIKey retrieves a specific API key by ID (without secret)
+func GetAPIKey(c *gin.Context) {
+	keyID := c.Param("id")
+	redis := config.GetRedisClient()
+	ctx := config.GetContext()
+
+	keyJSON, err := redis.Get(ctx, "api_key:"+keyID).Result()
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIKeyResponse{
+			Success: false,
+			Message: "API key not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	var key models.APIKey
+	if err := key.FromJSON(keyJSON); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIKeyResponse{
+			Success: false,
+			Message: "Failed to parse API key data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	middleware.LoadAPIKeyLastUsed(&key)
+
+	c.JSON(http.StatusOK, models.APIKeyResponse{
+		Success: true,
+		Message: "API key retrieved successfully",
+		Data:    key,
+	})
+}
+
+// RevokeAPIKey revokes an API key. The record is kept so its history
+// (prefix, last use) remains visible.
+func RevokeAPIKey(c *gin.Context) {
+	keyID := c.Param("id")
+	redis := config.GetRedisClient()
+	ctx := config.GetContext()
+
+	keyJSON, err := redis.Get(ctx, "api_key:"+keyID).Result()
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIKeyResponse{
+			Success: false,
+			Message: "API key not found",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	var key models.APIKey
+	if err := key.FromJSON(keyJSON); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIKeyResponse{
+			Success: false,
+			Message: "Failed to parse API key data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if key.RevokedAt != nil {
+		c.JSON(http.StatusBadRequest, models.APIKeyResponse{
+			Success: false,
+			Message: "API key is already revoked",
+		})
+		return
+	}
+
+	middleware.LoadAPIKeyLastUsed(&key)
+	before := key
+	now := time.Now()
+	key.RevokedAt = &now
+
+	updatedJSON, err := key.ToJSON()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIKeyResponse{
+			Success: false,
+			Message: "Failed to encode API key data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := redis.Set(ctx, "api_key:"+keyID, updatedJSON, 0).Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIKeyResponse{
+			Success: false,
+			Message: "Failed to revoke API key",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Drop the hash lookup so the key stops authenticating immediately
+	redis.Del(ctx, "api_key_hash:"+key.Hash)
+
+	recordAudit(c, "api_key.revoke", "api_key", key.ID, before, key)
+
+	c.JSON(http.StatusOK, models.APIKeyResponse{
+		Success: true,
+		Message: "API key revoked successfully",
+		Data:    key,
+	})
+}
+
+// generateAPIKeySecret returns a display prefix (e.g. erea_1a2b3c4d) and a
+// random secret
+func generateAPIKeySecret() (string, string, error) {
+	buf := make([]byte, 36)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	return middleware.APIKeyPrefix + hex.EncodeToString(buf[:4]), hex.EncodeToString(buf[4:]), nil
+}
+
+// isKnownScope reports whether scope is a grantable API key scope
+func isKnownScope(scope string) bool {
+	for _, s := range models.APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// actsForOtherUser reports whether the request is made with a key acting
+// for a user other than userID. Admins and keys that act for no user may
+// name any user.
+func actsForOtherUser(c *gin.Context, userID string) bool {
+	principal, ok := middleware.GetPrincipal(c)
+	return ok && principal.UserID != "" && principal.UserID != userID &&
+		!principal.HasScope(models.ScopeAdmin)
+}
+
+// actsForPropertyOwner reports whether principal is an admin or a key acting
+// for the owner of property
+func actsForPropertyOwner(principal *middleware.Principal, property models.Property) bool {
+	return principal.HasScope(models.ScopeAdmin) ||
+		(principal.UserID != "" && principal.UserID == property.OwnerID)
+}
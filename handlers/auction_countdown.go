@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"erea-api/config"
+	"erea-api/models"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// auctionTickKey claims one round of tick events, so only one server sends
+// it
+func auctionTickKey(round time.Time) string {
+	return fmt.Sprintf("auction_tick:%d", round.UnixMilli())
+}
+
+// auctionMilestoneKey marks an auction whose closing_soon event for a
+// milestone was sent
+func auctionMilestoneKey(auctionID string, milestone time.Duration) string {
+	return fmt.Sprintf("auction_milestone:%s:%d", auctionID, int64(milestone.Seconds()))
+}
+
+// AuctionCountdown is the data of tick and closing_soon events. Clients
+// should count down from EndsAt against ServerTime rather than their own
+// clock.
+type AuctionCountdown struct {
+	AuctionID        string    `json:"auction_id"`
+	PropertyID       string    `json:"property_id"`
+	EndsAt           time.Time `json:"ends_at"`
+	ServerTime       time.Time `json:"server_time"`
+	TimeRemaining    string    `json:"time_remaining"`
+	RemainingSeconds int64     `json:"remaining_seconds"`
+	Milestone        string    `json:"milestone,omitempty"` // For closing_soon, e.g. "10m0s"
+}
+
+// timeRemaining returns the time left until end, rounded to the second and
+// never negative
+func timeRemaining(end, now time.Time) time.Duration {
+	remaining := end.Sub(now).Round(time.Second)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// auctionEnd returns the ID and end time of a property's auction, or no ID
+// and the listing's end date if the property has no auction
+func auctionEnd(ctx context.Context, propertyID string, listingEnd time.Time) (string, time.Time) {
+	auctionID, err := config.GetRedisClient().Get(ctx, "property_auction:"+propertyID).Result()
+	if err != nil {
+		return "", listingEnd
+	}
+	auctions, err := loadAuctions(ctx, []string{auctionID})
+	if err != nil || len(auctions) == 0 {
+		return auctionID, listingEnd
+	}
+	return auctionID, auctions[0].EndTime
+}
+
+// RunAuctionCountdown sends a tick event to the clients following each
+// active auction every AuctionTickInterval, and a closing_soon event as the
+// auction passes each of AuctionClosingMilestones, until ctx is done. Each
+// round and milestone is sent once, even with several servers running it.
+func RunAuctionCountdown(ctx context.Context) {
+	ticker := time.NewTicker(config.AuctionTickInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := sendAuctionCountdowns(ctx, time.Now()); err != nil {
+			log.Printf("Failed to send auction countdowns: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendAuctionCountdowns sends one round of tick and closing_soon events
+func sendAuctionCountdowns(ctx context.Context, now time.Time) error {
+	rdb := config.GetRedisClient()
+
+	// Claim the round, so only one server sends it
+	round := now.Truncate(config.AuctionTickInterval)
+	claimed, err := rdb.SetNX(ctx, auctionTickKey(round), now.Unix(), config.AuctionTickInterval).Result()
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+
+	ids, err := rdb.ZRangeByScore(ctx, activeAuctionsByEndKey, &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(now.UnixNano(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return err
+	}
+	auctions, err := loadAuctions(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	milestones := append([]time.Duration(nil), config.AuctionClosingMilestones...)
+	sort.Slice(milestones, func(i, j int) bool { return milestones[i] < milestones[j] })
+
+	for _, auction := range auctions {
+		if auction.Status != activeAuctionStatus || !auction.EndTime.After(now) {
+			continue
+		}
+
+		remaining := timeRemaining(auction.EndTime, now)
+		countdown := AuctionCountdown{
+			AuctionID:        auction.ID,
+			PropertyID:       auction.PropertyID,
+			EndsAt:           auction.EndTime,
+			ServerTime:       now.UTC(),
+			TimeRemaining:    remaining.String(),
+			RemainingSeconds: int64(remaining.Seconds()),
+		}
+		broadcastVolatile(WebSocketMessage{
+			Type:    "tick",
+			Data:    countdown,
+			Message: "Auction countdown",
+		}, auctionTopic(auction.ID), propertyTopic(auction.PropertyID))
+
+		// Announce only the nearest milestone passed, so an auction found
+		// late doesn't get every larger one at once
+		for _, milestone := range milestones {
+			if remaining > milestone {
+				continue
+			}
+			claimed, err := rdb.SetNX(ctx, auctionMilestoneKey(auction.ID, milestone), now.Unix(), remaining+milestone).Result()
+			if err != nil {
+				return err
+			}
+			if claimed {
+				sendClosingSoon(auction, countdown, milestone)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// sendClosingSoon announces that an auction passed a closing milestone to
+// clients following it, its property or every auction
+func sendClosingSoon(auction models.Auction, countdown AuctionCountdown, milestone time.Duration) {
+	countdown.Milestone = milestone.String()
+	broadcast(WebSocketMessage{
+		Type:    "closing_soon",
+		Data:    countdown,
+		Message: fmt.Sprintf("Auction closes in %s", countdown.TimeRemaining),
+	}, auctionTopic(auction.ID), propertyTopic(auction.PropertyID), topicAuctions)
+}
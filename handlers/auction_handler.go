@@ -1,13 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"erea-api/config"
 	"erea-api/models"
-	"fmt"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 )
 
@@ -23,58 +25,57 @@ func CreateAuction(c *gin.Context) {
 		return
 	}
 
-	redis := config.GetRedisClient()
 	ctx := config.GetContext()
 
-	// Check if property exists
-	propertyJSON, err := redis.Get(ctx, "property:"+req.PropertyID).Result()
-	if err != nil {
-		c.JSON(http.StatusNotFound, models.AuctionResponse{
-			Success: false,
-			Message: "Property not found",
-			Error:   err.Error(),
-		})
-		return
+	// Create new auction
+	auction := models.Auction{
+		ID:           uuid.New().String(),
+		PropertyID:   req.PropertyID,
+		Status:       "Active",
+		StartTime:    time.Now(),
+		EndTime:      req.EndTime,
+		MinIncrement: req.MinIncrement,
+		ReservePrice: req.ReservePrice,
+		BidCount:     0,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
 	}
 
-	var property models.Property
-	if err := property.FromJSON(propertyJSON); err != nil {
-		c.JSON(http.StatusInternalServerError, models.AuctionResponse{
+	// Put the listing live and save the auction (also linking it to the
+	// property and adding it to the active auctions list) in one
+	// transaction. Only approved listings can go to auction, so a listing
+	// that is already live can't get a second one.
+	before, property, _, err := applyPropertyUpdate(ctx, req.PropertyID, callerID(c), propertyUpdate{
+		apply: func(_ *redis.Tx, property *models.Property) error {
+			if property.Status != models.PropertyStatusApproved {
+				return &reviewStateError{status: property.Status, allowed: []string{models.PropertyStatusApproved}}
+			}
+			property.Status = models.PropertyStatusActive
+			auction.CurrentHighest = property.StartingPrice
+			return nil
+		},
+		write: func(pipe redis.Pipeliner, _, _ models.Property) error {
+			return writeAuction(ctx, pipe, auction)
+		},
+		auction: true,
+	})
+	var stateErr *reviewStateError
+	switch {
+	case isNotFound(err):
+		c.JSON(http.StatusNotFound, models.AuctionResponse{
 			Success: false,
-			Message: "Failed to parse property data",
+			Message: "Property not found",
 			Error:   err.Error(),
 		})
 		return
-	}
-
-	// Create new auction
-	auction := models.Auction{
-		ID:             uuid.New().String(),
-		PropertyID:     req.PropertyID,
-		Status:         "Active",
-		StartTime:      time.Now(),
-		EndTime:        req.EndTime,
-		MinIncrement:   req.MinIncrement,
-		ReservePrice:   req.ReservePrice,
-		CurrentHighest: property.StartingPrice,
-		BidCount:       0,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
-	}
-
-	// Save auction to Redis
-	auctionJSON, err := auction.ToJSON()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.AuctionResponse{
+	case errors.As(err, &stateErr):
+		c.JSON(http.StatusConflict, models.AuctionResponse{
 			Success: false,
-			Message: "Failed to encode auction data",
+			Message: "Property must be approved before an auction can be created",
 			Error:   err.Error(),
 		})
 		return
-	}
-
-	auctionKey := fmt.Sprintf("auction:%s", auction.ID)
-	if err := redis.Set(ctx, auctionKey, auctionJSON, 0).Err(); err != nil {
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, models.AuctionResponse{
 			Success: false,
 			Message: "Failed to save auction",
@@ -83,11 +84,8 @@ func CreateAuction(c *gin.Context) {
 		return
 	}
 
-	// Link auction to property
-	redis.Set(ctx, "property_auction:"+req.PropertyID, auction.ID, 0)
-
-	// Add to active auctions list
-	redis.SAdd(ctx, "active_auctions", auction.ID)
+	recordAudit(c, "property.activate", "property", property.ID, before, property)
+	recordAudit(c, "auction.create", "auction", auction.ID, nil, auction)
 
 	c.JSON(http.StatusCreated, models.AuctionResponse{
 		Success: true,
@@ -129,62 +127,94 @@ func GetAuction(c *gin.Context) {
 	})
 }
 
-// GetActiveAuctions retrieves all active auctions
+// activeAuctionSortKeys are the sortable fields of the active auction list
+var activeAuctionSortKeys = listSortKeys{
+	"end_time":   activeAuctionsByEndKey,
+	"start_time": activeAuctionsByStartKey,
+}
+
+// GetActiveAuctions retrieves active auctions a page at a time, ending
+// soonest first by default. Supports property_id, min_price, max_price
+// (current highest bid) and from/to (end time) filters and sorting by
+// end_time or start_time.
 func GetActiveAuctions(c *gin.Context) {
-	redis := config.GetRedisClient()
-	ctx := config.GetContext()
+	var minPrice, maxPrice *int64
+	var from, to *time.Time
 
-	auctionIDs, err := redis.SMembers(ctx, "active_auctions").Result()
+	q, err := parseListQuery(c, activeAuctionSortKeys, "end_time")
+	if err == nil {
+		minPrice, err = queryInt64(c, "min_price")
+	}
+	if err == nil {
+		maxPrice, err = queryInt64(c, "max_price")
+	}
+	if err == nil {
+		from, to, err = queryTimeRange(c)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.AuctionResponse{
+		c.JSON(http.StatusBadRequest, models.AuctionResponse{
 			Success: false,
-			Message: "Failed to retrieve active auctions",
+			Message: "Invalid query parameters",
 			Error:   err.Error(),
 		})
 		return
 	}
 
-	var auctions []models.Auction
-	for _, auctionID := range auctionIDs {
-		auctionJSON, err := redis.Get(ctx, "auction:"+auctionID).Result()
-		if err != nil {
-			continue
-		}
+	propertyID := c.Query("property_id")
+	q.narrow("end_time", timeScore(from), timeScore(to))
 
-		var auction models.Auction
-		if err := auction.FromJSON(auctionJSON); err != nil {
-			continue
-		}
-
-		// Check if auction is still active
-		if time.Now().After(auction.EndTime) && auction.Status == "Active" {
-			// Auto-close expired auction
-			auction.Status = "Closed"
-			updatedJSON, _ := auction.ToJSON()
-			redis.Set(ctx, "auction:"+auctionID, updatedJSON, 0)
-			redis.SRem(ctx, "active_auctions", auctionID)
-			redis.SAdd(ctx, "closed_auctions", auctionID)
-		}
+	ctx := config.GetContext()
+	now := time.Now()
+	var expired []models.Auction
+
+	auctions, page, err := paginate(ctx, q, loadAuctions,
+		func(auction models.Auction) string { return auction.ID },
+		func(auction models.Auction) bool {
+			if auction.Status != "Active" {
+				return false
+			}
+			if now.After(auction.EndTime) {
+				expired = append(expired, auction)
+				return false
+			}
+			return (propertyID == "" || auction.PropertyID == propertyID) &&
+				inInt64Range(auction.CurrentHighest, minPrice, maxPrice) &&
+				inTimeRange(auction.EndTime, from, to)
+		})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.AuctionResponse{
+			Success: false,
+			Message: "Failed to retrieve active auctions",
+			Error:   err.Error(),
+		})
+		return
+	}
 
-		if auction.Status == "Active" {
-			auctions = append(auctions, auction)
-		}
+	// Auto-close expired auctions found while paging. This happens after the
+	// scan because closing removes them from the sorted set being read.
+	for _, auction := range expired {
+		before := auction
+		auction.Status = "Closed"
+		saveAuction(ctx, auction)
+		recordSystemAudit("auction.expire", "auction", auction.ID, before, auction)
 	}
 
 	c.JSON(http.StatusOK, models.AuctionResponse{
-		Success: true,
-		Message: "Active auctions retrieved successfully",
-		Data:    auctions,
+		Success:    true,
+		Message:    "Active auctions retrieved successfully",
+		Data:       auctions,
+		Pagination: page,
 	})
 }
 
-// CloseAuction manually closes an auction
+// CloseAuction manually closes an auction. The leading bid wins, and its
+// bidder gets SettlementPeriod to settle.
 func CloseAuction(c *gin.Context) {
 	auctionID := c.Param("id")
-	redis := config.GetRedisClient()
+	rdb := config.GetRedisClient()
 	ctx := config.GetContext()
 
-	auctionJSON, err := redis.Get(ctx, "auction:"+auctionID).Result()
+	auctionJSON, err := rdb.Get(ctx, "auction:"+auctionID).Result()
 	if err != nil {
 		c.JSON(http.StatusNotFound, models.AuctionResponse{
 			Success: false,
@@ -204,58 +234,37 @@ func CloseAuction(c *gin.Context) {
 		return
 	}
 
-	if auction.Status != "Active" {
-		c.JSON(http.StatusBadRequest, models.AuctionResponse{
-			Success: false,
-			Message: "Auction is not active",
-		})
-		return
-	}
-
-	// Find winning bid
-	propertyBidsKey := fmt.Sprintf("property_bids:%s", auction.PropertyID)
-	bidIDs, err := redis.SMembers(ctx, propertyBidsKey).Result()
-	if err == nil && len(bidIDs) > 0 {
-		var highestBid int64
-		var winnerID string
-
-		for _, bidID := range bidIDs {
-			bidJSON, err := redis.Get(ctx, "bid:"+bidID).Result()
-			if err != nil {
-				continue
-			}
-
-			var bid models.Bid
-			if err := bid.FromJSON(bidJSON); err != nil {
-				continue
+	// Close the auction (moving it from active to closed and recording the
+	// winner) and its property in one transaction. The auction is read and
+	// watched there, so a bid or another close landing first forces a
+	// retry against the auction as it is then.
+	var before models.Auction
+	propertyBefore, property, version, err := applyPropertyUpdate(ctx, auction.PropertyID, callerID(c), propertyUpdate{
+		apply: func(tx *redis.Tx, property *models.Property) error {
+			var err error
+			if before, auction, err = closingAuction(ctx, tx, auctionID); err != nil {
+				return err
 			}
-
-			if bid.Amount > highestBid && bid.Status == "Confirmed" {
-				highestBid = bid.Amount
-				winnerID = bid.BidderID
-			}
-		}
-
-		auction.WinnerID = winnerID
-		auction.WinningBid = highestBid
+			property.Status = models.PropertyStatusClosed
+			return nil
+		},
+		write: func(pipe redis.Pipeliner, _, _ models.Property) error {
+			return writeAuction(ctx, pipe, auction)
+		},
+		auction: true,
+	})
+	if isNotFound(err) {
+		// The property is gone; close the auction alone
+		before, auction, err = closeAuctionAlone(ctx, auctionID)
 	}
-
-	// Close auction
-	auction.Status = "Closed"
-	auction.UpdatedAt = time.Now()
-
-	// Save updated auction
-	updatedJSON, err := auction.ToJSON()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.AuctionResponse{
+	switch {
+	case errors.Is(err, errAuctionNotActive):
+		c.JSON(http.StatusBadRequest, models.AuctionResponse{
 			Success: false,
-			Message: "Failed to encode updated auction data",
-			Error:   err.Error(),
+			Message: "Auction is not active",
 		})
 		return
-	}
-
-	if err := redis.Set(ctx, "auction:"+auctionID, updatedJSON, 0).Err(); err != nil {
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, models.AuctionResponse{
 			Success: false,
 			Message: "Failed to close auction",
@@ -263,26 +272,18 @@ func CloseAuction(c *gin.Context) {
 		})
 		return
 	}
-
-	// Move from active to closed
-	redis.SRem(ctx, "active_auctions", auctionID)
-	redis.SAdd(ctx, "closed_auctions", auctionID)
-
-	// Update property status
-	propertyJSON, err := redis.Get(ctx, "property:"+auction.PropertyID).Result()
-	if err == nil {
-		var property models.Property
-		if property.FromJSON(propertyJSON) == nil {
-			property.Status = "Closed"
-			property.UpdatedAt = time.Now()
-			updatedPropertyJSON, _ := property.ToJSON()
-			redis.Set(ctx, "property:"+auction.PropertyID, updatedPropertyJSON, 0)
-		}
+	if version != nil {
+		recordAudit(c, "property.close", "property", property.ID, propertyBefore, property)
 	}
 
+	recordAudit(c, "auction.close", "auction", auction.ID, before, auction)
+
 	// Broadcast auction update via WebSocket
 	BroadcastAuctionUpdate(auction)
 
+	// Tell the winner what they owe and by when
+	notifySettlementDue(ctx, auction, property)
+
 	c.JSON(http.StatusOK, models.AuctionResponse{
 		Success: true,
 		Message: "Auction closed successfully",
@@ -290,13 +291,81 @@ func CloseAuction(c *gin.Context) {
 	})
 }
 
+// closingAuction watches and reads the auction in tx and returns it as it
+// is and as closed: won by its leading bid, if any, whose bidder has
+// SettlementPeriod to settle. It fails with errAuctionNotActive unless the
+// auction is active.
+func closingAuction(ctx context.Context, tx *redis.Tx, auctionID string) (models.Auction, models.Auction, error) {
+	var before models.Auction
+	if err := tx.Watch(ctx, "auction:"+auctionID).Err(); err != nil {
+		return before, before, err
+	}
+	auctionJSON, err := tx.Get(ctx, "auction:"+auctionID).Result()
+	if isNotFound(err) {
+		return before, before, errAuctionNotActive
+	}
+	if err != nil {
+		return before, before, err
+	}
+	if err := before.FromJSON(auctionJSON); err != nil {
+		return before, before, err
+	}
+	if before.Status != activeAuctionStatus {
+		return before, before, errAuctionNotActive
+	}
+
+	auction := before
+	auction.Status = closedAuctionStatus
+	auction.UpdatedAt = time.Now()
+	if auction.LeadingBidID != "" {
+		auction.WinnerID = auction.LeaderID
+		auction.WinningBid = auction.CurrentHighest
+		due := auction.UpdatedAt.Add(config.SettlementPeriod)
+		auction.SettlementDue = &due
+	}
+	return before, auction, nil
+}
+
+// closeAuctionAlone closes an auction whose property no longer exists,
+// retrying when the auction changes while it is being closed
+func closeAuctionAlone(ctx context.Context, auctionID string) (models.Auction, models.Auction, error) {
+	var before, auction models.Auction
+	var err error
+	for attempt := 0; attempt < propertyVersionMaxRetries; attempt++ {
+		err = config.GetRedisClient().Watch(ctx, func(tx *redis.Tx) error {
+			var err error
+			if before, auction, err = closingAuction(ctx, tx, auctionID); err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				return writeAuction(ctx, pipe, auction)
+			})
+			return err
+		})
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+	return before, auction, err
+}
+
 // GetAuctionStats retrieves auction statistics
 func GetAuctionStats(c *gin.Context) {
 	redis := config.GetRedisClient()
 	ctx := config.GetContext()
 
-	// Get all auction keys
-	auctionKeys, err := redis.Keys(ctx, "auction:*").Result()
+	// Get all auctions
+	auctionIDs, err := redis.SMembers(ctx, auctionsIndexKey).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.AuctionResponse{
+			Success: false,
+			Message: "Failed to retrieve auction statistics",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	auctions, err := loadAuctions(ctx, auctionIDs)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.AuctionResponse{
 			Success: false,
@@ -310,17 +379,7 @@ func GetAuctionStats(c *gin.Context) {
 	var totalVolume int64
 	var successfulAuctions int
 
-	for _, key := range auctionKeys {
-		auctionJSON, err := redis.Get(ctx, key).Result()
-		if err != nil {
-			continue
-		}
-
-		var auction models.Auction
-		if err := auction.FromJSON(auctionJSON); err != nil {
-			continue
-		}
-
+	for _, auction := range auctions {
 		totalAuctions++
 
 		switch auction.Status {
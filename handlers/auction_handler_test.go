@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erea-api/models"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func createTestAuction(t *testing.T, propertyID string) int {
+	t.Helper()
+	router := newTestRouter()
+	router.POST("/auctions", CreateAuction)
+	w := performJSON(t, router, http.MethodPost, "/auctions", models.CreateAuctionRequest{
+		PropertyID: propertyID,
+		EndTime:    time.Now().Add(time.Hour),
+	})
+	return w.Code
+}
+
+func TestCreateAuctionActivatesApprovedListingOnce(t *testing.T) {
+	mr := newTestRedis(t)
+	ctx := context.Background()
+	property := models.Property{ID: "p1", Title: "Listing", StartingPrice: 100, Status: models.PropertyStatusApproved}
+	if err := saveProperty(ctx, nil, property); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := createTestAuction(t, "p1"); code != http.StatusCreated {
+		t.Fatalf("creating the auction: status %d", code)
+	}
+	properties, err := loadProperties(ctx, []string{"p1"})
+	if err != nil || len(properties) != 1 || properties[0].Status != models.PropertyStatusActive {
+		t.Fatalf("expected the listing to be active, got %+v (%v)", properties, err)
+	}
+	auctionID, _ := mr.Get("property_auction:p1")
+	auctions, err := loadAuctions(ctx, []string{auctionID})
+	if err != nil || len(auctions) != 1 || auctions[0].CurrentHighest != 100 {
+		t.Fatalf("expected the auction to start at the starting price, got %+v (%v)", auctions, err)
+	}
+
+	// The listing is live, so a second auction is refused
+	if code := createTestAuction(t, "p1"); code != http.StatusConflict {
+		t.Fatalf("expected a second auction to be refused, got %d", code)
+	}
+	if active, _ := mr.SMembers(activeAuctionsKey); len(active) != 1 {
+		t.Fatalf("expected one active auction, got %v", active)
+	}
+}
+
+func TestCreateAuctionRefusesUnapprovedListing(t *testing.T) {
+	mr := newTestRedis(t)
+	property := models.Property{ID: "p1", Title: "Listing", Status: models.PropertyStatusPendingReview}
+	if err := saveProperty(context.Background(), nil, property); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := createTestAuction(t, "p1"); code != http.StatusConflict {
+		t.Fatalf("expected a listing under review to be refused, got %d", code)
+	}
+	if mr.Exists("property_auction:p1") {
+		t.Fatal("an auction was saved for a refused listing")
+	}
+	if code := createTestAuction(t, "missing"); code != http.StatusNotFound {
+		t.Fatalf("expected a missing listing to be refused with 404, got %d", code)
+	}
+}
+
+func TestCloseAuctionClosesOnceForTheLeadingBid(t *testing.T) {
+	mr := newTestRedis(t)
+	ctx := context.Background()
+	auction := seedAuction(t, "p1", 100)
+	for _, bid := range []struct {
+		bidder string
+		amount int64
+	}{{"alice", 150}, {"bob", 200}} {
+		if code := placeTestBid(t, "p1", bid.bidder, bid.amount); code != http.StatusCreated {
+			t.Fatalf("bid by %s: status %d", bid.bidder, code)
+		}
+	}
+
+	router := newTestRouter()
+	router.PUT("/auctions/:id/close", CloseAuction)
+	const closers = 8
+	codes := make(chan int, closers)
+	var wg sync.WaitGroup
+	for i := 0; i < closers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/auctions/"+auction.ID+"/close", nil))
+			codes <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	closed := 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			closed++
+		case http.StatusBadRequest:
+		default:
+			t.Fatalf("unexpected status %d", code)
+		}
+	}
+	if closed != 1 {
+		t.Fatalf("expected exactly one close to succeed, got %d", closed)
+	}
+
+	auctions, err := loadAuctions(ctx, []string{auction.ID})
+	if err != nil || len(auctions) != 1 {
+		t.Fatalf("loading the auction: %+v (%v)", auctions, err)
+	}
+	closedAuction := auctions[0]
+	if closedAuction.Status != closedAuctionStatus || closedAuction.WinnerID != "bob" ||
+		closedAuction.WinningBid != 200 || closedAuction.SettlementDue == nil {
+		t.Fatalf("expected bob to win at 200 with a settlement deadline, got %+v", closedAuction)
+	}
+
+	entries, _ := mr.List(auditLogKey)
+	closes := 0
+	for _, entryJSON := range entries {
+		var entry models.AuditEntry
+		if json.Unmarshal([]byte(entryJSON), &entry) == nil && entry.Action == "auction.close" {
+			closes++
+		}
+	}
+	if closes != 1 {
+		t.Fatalf("expected one auction.close audit entry, got %d", closes)
+	}
+	if inbox, _ := mr.ZMembers(userNotificationsKey("bob")); len(inbox) != 1 {
+		t.Fatalf("expected one settlement notice for the winner, got %v", inbox)
+	}
+}
@@ -0,0 +1,402 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erea-api/config"
+	"erea-api/middleware"
+	"erea-api/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	auditLogKey      = "audit_log"      // List of audit entries, oldest first
+	auditHeadKey     = "audit_log:head" // Seq and hash of the newest entry
+	auditMaxRetries  = 10
+	auditScanChunk   = 500
+	auditDefaultPage = 50
+	auditMaxPage     = 500
+)
+
+// auditHead is the chain tip stored at auditHeadKey
+type auditHead struct {
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// recordAudit appends an audit entry for a state change made by the request.
+// before/after are snapshots of the entity (nil when it did not exist).
+// Failures are logged rather than returned because the change is already stored.
+func recordAudit(c *gin.Context, action, entityType, entityID string, before, after interface{}) {
+	entry := models.AuditEntry{
+		Actor:      "anonymous",
+		ActorType:  "anonymous",
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		RequestID:  middleware.GetRequestID(c),
+		ClientIP:   c.ClientIP(),
+	}
+
+	if principal, ok := middleware.GetPrincipal(c); ok {
+		entry.Actor = principal.ID
+		entry.ActorType = principal.Type
+		if principal.UserID != "" {
+			entry.Actor = principal.UserID
+		}
+	}
+
+	appendAudit(entry, before, after)
+}
+
+// callerID returns the user the request acts for, the principal ID when the
+// principal acts for no user, or "" for anonymous requests
+func callerID(c *gin.Context) string {
+	principal, ok := middleware.GetPrincipal(c)
+	if !ok {
+		return ""
+	}
+	if principal.UserID != "" {
+		return principal.UserID
+	}
+	return principal.ID
+}
+
+// recordSystemAudit appends an audit entry for a change the server made on
+// its own (e.g. auto-closing an expired auction)
+func recordSystemAudit(action, entityType, entityID string, before, after interface{}) {
+	appendAudit(models.AuditEntry{
+		Actor:      "system",
+		ActorType:  "system",
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+	}, before, after)
+}
+
+// appendAudit attaches snapshots to the entry and appends it to the chain
+func appendAudit(entry models.AuditEntry, before, after interface{}) {
+	var err error
+	if entry.Before, err = auditSnapshot(before); err != nil {
+		log.Printf("Failed to snapshot audit state for %s %s: %v", entry.EntityType, entry.EntityID, err)
+	}
+	if entry.After, err = auditSnapshot(after); err != nil {
+		log.Printf("Failed to snapshot audit state for %s %s: %v", entry.EntityType, entry.EntityID, err)
+	}
+
+	if _, err := AppendAuditEntry(config.GetContext(), entry); err != nil {
+		log.Printf("Failed to record audit entry %s for %s %s: %v", entry.Action, entry.EntityType, entry.EntityID, err)
+	}
+}
+
+// auditSnapshot encodes an entity snapshot, keeping nil as absent
+func auditSnapshot(value interface{}) (json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+	return json.Marshal(value)
+}
+
+// AppendAuditEntry links the entry to the current chain tip and appends it.
+// The tip is watched so concurrent writers on any instance retry instead of
+// forking the chain.
+func AppendAuditEntry(ctx context.Context, entry models.AuditEntry) (models.AuditEntry, error) {
+	rdb := config.GetRedisClient()
+	entry.Timestamp = time.Now().UTC()
+
+	for attempt := 0; attempt < auditMaxRetries; attempt++ {
+		err := rdb.Watch(ctx, func(tx *redis.Tx) error {
+			head := auditHead{Hash: models.AuditGenesisHash}
+			headJSON, err := tx.Get(ctx, auditHeadKey).Result()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			if err == nil {
+				if err := json.Unmarshal([]byte(headJSON), &head); err != nil {
+					return fmt.Errorf("corrupt audit head: %v", err)
+				}
+			}
+
+			entry.Seq = head.Seq + 1
+			entry.PrevHash = head.Hash
+			entry.Hash = ""
+			if entry.Hash, err = entry.ComputeHash(); err != nil {
+				return err
+			}
+
+			entryJSON, err := entry.ToJSON()
+			if err != nil {
+				return err
+			}
+			newHeadJSON, err := json.Marshal(auditHead{Seq: entry.Seq, Hash: entry.Hash})
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.RPush(ctx, auditLogKey, entryJSON)
+				pipe.Set(ctx, auditHeadKey, newHeadJSON, 0)
+				pipe.RPush(ctx, auditEntityKey(entry.EntityType, entry.EntityID), entry.Seq)
+				return nil
+			})
+			return err
+		}, auditHeadKey)
+
+		if err == nil {
+			return entry, nil
+		}
+		if !errors.Is(err, redis.TxFailedErr) {
+			return entry, err
+		}
+	}
+
+	return entry, fmt.Errorf("audit log contention: gave up after %d attempts", auditMaxRetries)
+}
+
+// VerifyAuditChain recomputes every entry hash and checks that each entry
+// links to its predecessor and that the stored head matches the last entry
+func VerifyAuditChain(ctx context.Context) (models.AuditVerification, error) {
+	rdb := config.GetRedisClient()
+	result := models.AuditVerification{VerifiedAt: time.Now().UTC()}
+
+	total, err := rdb.LLen(ctx, auditLogKey).Result()
+	if err != nil {
+		return result, err
+	}
+
+	prevHash := models.AuditGenesisHash
+	var seq int64
+
+	for start := int64(0); start < total; start += auditScanChunk {
+		entriesJSON, err := rdb.LRange(ctx, auditLogKey, start, start+auditScanChunk-1).Result()
+		if err != nil {
+			return result, err
+		}
+
+		for _, entryJSON := range entriesJSON {
+			seq++
+
+			var entry models.AuditEntry
+			if err := entry.FromJSON(entryJSON); err != nil {
+				return brokenChain(result, seq, "entry is not valid JSON"), nil
+			}
+			if entry.Seq != seq {
+				return brokenChain(result, seq, fmt.Sprintf("expected seq %d, found %d", seq, entry.Seq)), nil
+			}
+			if entry.PrevHash != prevHash {
+				return brokenChain(result, seq, "prev_hash does not match previous entry"), nil
+			}
+
+			hash, err := entry.ComputeHash()
+			if err != nil {
+				return result, err
+			}
+			if hash != entry.Hash {
+				return brokenChain(result, seq, "entry content does not match its hash"), nil
+			}
+
+			prevHash = entry.Hash
+			result.EntryCount = seq
+			result.HeadHash = entry.Hash
+		}
+	}
+
+	// The head guards against truncating entries from the end of the log
+	headJSON, err := rdb.Get(ctx, auditHeadKey).Result()
+	if err != nil && err != redis.Nil {
+		return result, err
+	}
+	head := auditHead{Hash: models.AuditGenesisHash}
+	if err == nil {
+		if err := json.Unmarshal([]byte(headJSON), &head); err != nil {
+			return brokenChain(result, seq, "audit head is not valid JSON"), nil
+		}
+	}
+	if head.Seq != seq || head.Hash != prevHash {
+		return brokenChain(result, seq, fmt.Sprintf("head points at seq %d but log ends at seq %d", head.Seq, seq)), nil
+	}
+
+	result.Valid = true
+	return result, nil
+}
+
+// brokenChain marks a verification result as failed at seq
+func brokenChain(result models.AuditVerification, seq int64, reason string) models.AuditVerification {
+	result.Valid = false
+	result.BrokenAtSeq = seq
+	result.Reason = reason
+	return result
+}
+
+// GetAuditLog retrieves audit entries, newest first. Supports entity_type +
+// entity_id, actor, action, limit and before_seq query parameters.
+func GetAuditLog(c *gin.Context) {
+	entityType := c.Query("entity_type")
+	entityID := c.Query("entity_id")
+	actor := c.Query("actor")
+	action := c.Query("action")
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(auditDefaultPage)))
+	if err != nil || limit <= 0 {
+		limit = auditDefaultPage
+	}
+	if limit > auditMaxPage {
+		limit = auditMaxPage
+	}
+
+	var beforeSeq int64
+	if beforeStr := c.Query("before_seq"); beforeStr != "" {
+		beforeSeq, err = strconv.ParseInt(beforeStr, 10, 64)
+		if err != nil || beforeSeq <= 0 {
+			c.JSON(http.StatusBadRequest, models.AuditResponse{
+				Success: false,
+				Message: "Invalid before_seq",
+			})
+			return
+		}
+	}
+
+	if (entityType == "") != (entityID == "") {
+		c.JSON(http.StatusBadRequest, models.AuditResponse{
+			Success: false,
+			Message: "entity_type and entity_id must be given together",
+		})
+		return
+	}
+
+	ctx := config.GetContext()
+	matches := func(entry models.AuditEntry) bool {
+		return (actor == "" || entry.Actor == actor) && (action == "" || entry.Action == action)
+	}
+
+	var entries []models.AuditEntry
+	if entityType != "" {
+		entries, err = auditEntriesForEntity(ctx, entityType, entityID, beforeSeq, limit, matches)
+	} else {
+		entries, err = auditEntries(ctx, beforeSeq, limit, matches)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.AuditResponse{
+			Success: false,
+			Message: "Failed to retrieve audit log",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AuditResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d audit entries retrieved", len(entries)),
+		Data:    entries,
+	})
+}
+
+// VerifyAuditLog verifies the audit hash chain
+func VerifyAuditLog(c *gin.Context) {
+	result, err := VerifyAuditChain(config.GetContext())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.AuditResponse{
+			Success: false,
+			Message: "Failed to verify audit log",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	message := "Audit log chain is intact"
+	if !result.Valid {
+		message = "Audit log chain is broken"
+	}
+
+	c.JSON(http.StatusOK, models.AuditResponse{
+		Success: true,
+		Message: message,
+		Data:    result,
+	})
+}
+
+// auditEntries walks the log backwards from beforeSeq collecting matches
+func auditEntries(ctx context.Context, beforeSeq int64, limit int, matches func(models.AuditEntry) bool) ([]models.AuditEntry, error) {
+	rdb := config.GetRedisClient()
+
+	end, err := rdb.LLen(ctx, auditLogKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	// Seq n is stored at list index n-1
+	if beforeSeq > 0 && beforeSeq-1 < end {
+		end = beforeSeq - 1
+	}
+
+	entries := []models.AuditEntry{}
+	for end > 0 && len(entries) < limit {
+		start := end - auditScanChunk
+		if start < 0 {
+			start = 0
+		}
+
+		entriesJSON, err := rdb.LRange(ctx, auditLogKey, start, end-1).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		for i := len(entriesJSON) - 1; i >= 0 && len(entries) < limit; i-- {
+			var entry models.AuditEntry
+			if err := entry.FromJSON(entriesJSON[i]); err != nil {
+				continue
+			}
+			if matches(entry) {
+				entries = append(entries, entry)
+			}
+		}
+		end = start
+	}
+
+	return entries, nil
+}
+
+// auditEntriesForEntity collects matches from the per-entity seq index
+func auditEntriesForEntity(ctx context.Context, entityType, entityID string, beforeSeq int64, limit int, matches func(models.AuditEntry) bool) ([]models.AuditEntry, error) {
+	rdb := config.GetRedisClient()
+
+	seqs, err := rdb.LRange(ctx, auditEntityKey(entityType, entityID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []models.AuditEntry{}
+	for i := len(seqs) - 1; i >= 0 && len(entries) < limit; i-- {
+		seq, err := strconv.ParseInt(seqs[i], 10, 64)
+		if err != nil || (beforeSeq > 0 && seq >= beforeSeq) {
+			continue
+		}
+
+		entryJSON, err := rdb.LIndex(ctx, auditLogKey, seq-1).Result()
+		if err != nil {
+			continue
+		}
+
+		var entry models.AuditEntry
+		if err := entry.FromJSON(entryJSON); err != nil {
+			continue
+		}
+		if matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// auditEntityKey is the list of audit seqs touching one entity
+func auditEntityKey(entityType, entityID string) string {
+	return fmt.Sprintf("audit_entity:%s:%s", entityType, entityID)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"erea-api/config"
+	"erea-api/models"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func appendTestAudits(t *testing.T, n int) {
+	t.Helper()
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := AppendAuditEntry(context.Background(), models.AuditEntry{
+				Actor:      "system",
+				ActorType:  "system",
+				Action:     "test.append",
+				EntityType: "test",
+				EntityID:   fmt.Sprint(i),
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAuditChainConcurrentAppends(t *testing.T) {
+	newTestRedis(t)
+	appendTestAudits(t, 5)
+
+	result, err := VerifyAuditChain(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid || result.EntryCount != 5 {
+		t.Fatalf("expected a valid chain of 5 entries, got %+v", result)
+	}
+}
+
+func TestAuditChainDetectsTampering(t *testing.T) {
+	mr := newTestRedis(t)
+	appendTestAudits(t, 3)
+	ctx := context.Background()
+
+	// Rewriting an entry's content breaks its hash
+	entries, _ := mr.List(auditLogKey)
+	var entry models.AuditEntry
+	if err := entry.FromJSON(entries[1]); err != nil {
+		t.Fatal(err)
+	}
+	entry.Actor = "someone-else"
+	tampered, _ := entry.ToJSON()
+	rdb := config.GetRedisClient()
+	if err := rdb.LSet(ctx, auditLogKey, 1, tampered).Err(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := VerifyAuditChain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Valid || result.BrokenAtSeq != 2 {
+		t.Fatalf("expected the chain to break at seq 2, got %+v", result)
+	}
+
+	// Dropping the newest entry no longer matches the head
+	if err := rdb.LSet(ctx, auditLogKey, 1, entries[1]).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rdb.RPop(ctx, auditLogKey).Err(); err != nil {
+		t.Fatal(err)
+	}
+	result, err = VerifyAuditChain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Valid {
+		t.Fatalf("expected a truncated chain to fail verification, got %+v", result)
+	}
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"context"
 	"erea-api/config"
 	"erea-api/models"
+	"errors"
 	"fmt"
 	"net/http"
 	"sort"
@@ -10,9 +12,16 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 )
 
+// Errors a bid is rejected with, checked within the bid's transaction
+var (
+	errAuctionNotActive = errors.New("auction is not active")
+	errBidTooLow        = errors.New("bid must be higher than current price")
+)
+
 // PlaceBid creates a new bid for a property
 func PlaceBid(c *gin.Context) {
 	var req models.CreateBidRequest
@@ -25,47 +34,17 @@ func PlaceBid(c *gin.Context) {
 		return
 	}
 
-	redis := config.GetRedisClient()
-	ctx := config.GetContext()
-
-	// Check if property exists
-	propertyJSON, err := redis.Get(ctx, "property:"+req.PropertyID).Result()
-	if err != nil {
-		c.JSON(http.StatusNotFound, models.BidResponse{
+	// A key acting for a user bids only for that user
+	if actsForOtherUser(c, req.BidderID) {
+		c.JSON(http.StatusForbidden, models.BidResponse{
 			Success: false,
-			Message: "Property not found",
-			Error:   err.Error(),
+			Message: "Access denied",
+			Error:   "bidder_id must be the user the API key acts for",
 		})
 		return
 	}
 
-	var property models.Property
-	if err := property.FromJSON(propertyJSON); err != nil {
-		c.JSON(http.StatusInternalServerError, models.BidResponse{
-			Success: false,
-			Message: "Failed to parse property data",
-			Error:   err.Error(),
-		})
-		return
-	}
-
-	// Check if auction is still active
-	if property.Status != "Active" || time.Now().After(property.EndDate) {
-		c.JSON(http.StatusBadRequest, models.BidResponse{
-			Success: false,
-			Message: "Auction is not active",
-		})
-		return
-	}
-
-	// Check if bid is higher than current price
-	if req.Amount <= property.CurrentPrice {
-		c.JSON(http.StatusBadRequest, models.BidResponse{
-			Success: false,
-			Message: "Bid must be higher than current price",
-		})
-		return
-	}
+	ctx := config.GetContext()
 
 	// Create new bid
 	bid := models.Bid{
@@ -73,7 +52,7 @@ func PlaceBid(c *gin.Context) {
 		PropertyID:    req.PropertyID,
 		BidderID:      req.BidderID,
 		Amount:        req.Amount,
-		Status:        "Pending",
+		Status:        "Confirmed",
 		IsEncrypted:   req.IsEncrypted,
 		EncryptedData: req.EncryptedData,
 		CreatedAt:     time.Now(),
@@ -83,19 +62,73 @@ func PlaceBid(c *gin.Context) {
 	// Simulate blockchain transaction hash
 	bid.TxHash = fmt.Sprintf("0x%s", uuid.New().String()[:32])
 
-	// Save bid to Redis
-	bidJSON, err := bid.ToJSON()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.BidResponse{
+	// The auction and the leading bid this one displaces, as read within
+	// the bid's transaction
+	var auction *models.Auction
+	var previous *models.Bid
+
+	// Raise the property's current price and save the confirmed bid (also
+	// adding it to the property's and bidder's bid indexes) as one new
+	// version, checking the auction against the price it commits over
+	propertyBefore, property, _, err := applyPropertyUpdate(ctx, req.PropertyID, callerID(c), propertyUpdate{
+		apply: func(tx *redis.Tx, property *models.Property) error {
+			var err error
+			auction, previous, err = watchAuctionLeader(ctx, tx, property.ID)
+			if err != nil {
+				return err
+			}
+
+			// Check if auction is still active. Its end time, not the
+			// listing's end date, is what the countdown shows.
+			if property.Status != models.PropertyStatusActive || auction == nil ||
+				auction.Status != activeAuctionStatus || !time.Now().Before(auction.EndTime) {
+				return errAuctionNotActive
+			}
+
+			// Check if bid is higher than current price
+			if req.Amount <= property.CurrentPrice {
+				return errBidTooLow
+			}
+
+			property.CurrentPrice = req.Amount
+			return nil
+		},
+		write: func(pipe redis.Pipeliner, _, _ models.Property) error {
+			if err := writeBid(ctx, pipe, bid); err != nil {
+				return err
+			}
+
+			// The bid leads the auction now
+			leading := *auction
+			leading.CurrentHighest = bid.Amount
+			leading.BidCount++
+			leading.LeadingBidID = bid.ID
+			leading.LeaderID = bid.BidderID
+			leading.UpdatedAt = bid.CreatedAt
+			return writeAuction(ctx, pipe, leading)
+		},
+	})
+	switch {
+	case isNotFound(err):
+		c.JSON(http.StatusNotFound, models.BidResponse{
 			Success: false,
-			Message: "Failed to encode bid data",
+			Message: "Property not found",
 			Error:   err.Error(),
 		})
 		return
-	}
-
-	bidKey := fmt.Sprintf("bid:%s", bid.ID)
-	if err := redis.Set(ctx, bidKey, bidJSON, 0).Err(); err != nil {
+	case errors.Is(err, errAuctionNotActive):
+		c.JSON(http.StatusBadRequest, models.BidResponse{
+			Success: false,
+			Message: "Auction is not active",
+		})
+		return
+	case errors.Is(err, errBidTooLow):
+		c.JSON(http.StatusBadRequest, models.BidResponse{
+			Success: false,
+			Message: "Bid must be higher than current price",
+		})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, models.BidResponse{
 			Success: false,
 			Message: "Failed to save bid",
@@ -104,27 +137,27 @@ func PlaceBid(c *gin.Context) {
 		return
 	}
 
-	// Add bid to property's bid list
-	propertyBidsKey := fmt.Sprintf("property_bids:%s", req.PropertyID)
-	redis.SAdd(ctx, propertyBidsKey, bid.ID)
+	recordAudit(c, "bid.create", "bid", bid.ID, nil, bid)
+	recordAudit(c, "property.update_price", "property", property.ID, propertyBefore, property)
 
-	// Update property's current price
-	property.CurrentPrice = req.Amount
-	property.UpdatedAt = time.Now()
+	// Broadcast bid update via WebSocket
+	BroadcastBidUpdate(req.PropertyID, bid)
 
-	updatedPropertyJSON, err := property.ToJSON()
-	if err == nil {
-		redis.Set(ctx, "property:"+req.PropertyID, updatedPropertyJSON, 0)
+	// Tell the previous leader they were outbid, unless they raised their own bid
+	outbid := ""
+	if previous != nil && previous.BidderID != bid.BidderID {
+		outbid = previous.BidderID
+		notifyOutbid(ctx, *previous, bid, property, *auction)
 	}
 
-	// Update bid status to confirmed
-	bid.Status = "Confirmed"
-	bid.UpdatedAt = time.Now()
-	confirmedBidJSON, _ := bid.ToJSON()
-	redis.Set(ctx, bidKey, confirmedBidJSON, 0)
-
-	// Broadcast bid update via WebSocket
-	BroadcastBidUpdate(req.PropertyID, bid)
+	// Notify users watching the property, except the bidder and the one just outbid
+	notifyWatchers(ctx, property.ID, models.Notification{
+		Type:       models.NotificationWatchlistBid,
+		Title:      fmt.Sprintf("New bid on %q", property.Title),
+		Message:    fmt.Sprintf("A bid of %s was placed", formatWon(bid.Amount)),
+		EntityType: "property",
+		EntityID:   property.ID,
+	}, bid.BidderID, outbid)
 
 	c.JSON(http.StatusCreated, models.BidResponse{
 		Success: true,
@@ -149,8 +182,7 @@ func GetBidHistory(c *gin.Context) {
 	}
 
 	// Get all bid IDs for this property
-	propertyBidsKey := fmt.Sprintf("property_bids:%s", propertyID)
-	bidIDs, err := redis.SMembers(ctx, propertyBidsKey).Result()
+	bidIDs, err := redis.SMembers(ctx, propertyBidsKey(propertyID)).Result()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.BidResponse{
 			Success: false,
@@ -203,50 +235,64 @@ func GetBidHistory(c *gin.Context) {
 	})
 }
 
-// GetUserBids retrieves all bids for a specific user
+// GetUserBids retrieves a user's bids a page at a time. Supports status,
+// property_id, min_amount, max_amount and from/to (creation date) filters
+// and sorting by created_at or amount.
 func GetUserBids(c *gin.Context) {
 	userID := c.Param("id")
-	redis := config.GetRedisClient()
-	ctx := config.GetContext()
 
-	// Get all bid keys
-	bidKeys, err := redis.Keys(ctx, "bid:*").Result()
+	var minAmount, maxAmount *int64
+	var from, to *time.Time
+
+	q, err := parseListQuery(c, listSortKeys{
+		"created_at": userBidsKey(userID),
+		"amount":     userBidsByAmountKey(userID),
+	}, "-created_at")
+	if err == nil {
+		minAmount, err = queryInt64(c, "min_amount")
+	}
+	if err == nil {
+		maxAmount, err = queryInt64(c, "max_amount")
+	}
+	if err == nil {
+		from, to, err = queryTimeRange(c)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.BidResponse{
+		c.JSON(http.StatusBadRequest, models.BidResponse{
 			Success: false,
-			Message: "Failed to retrieve user bids",
+			Message: "Invalid query parameters",
 			Error:   err.Error(),
 		})
 		return
 	}
 
-	var userBids []models.Bid
-
-	for _, key := range bidKeys {
-		bidJSON, err := redis.Get(ctx, key).Result()
-		if err != nil {
-			continue
-		}
-
-		var bid models.Bid
-		if err := bid.FromJSON(bidJSON); err != nil {
-			continue
-		}
-
-		if bid.BidderID == userID {
-			userBids = append(userBids, bid)
-		}
+	status := c.Query("status")
+	propertyID := c.Query("property_id")
+	q.narrow("amount", int64Score(minAmount), int64Score(maxAmount))
+	q.narrow("created_at", timeScore(from), timeScore(to))
+
+	userBids, page, err := paginate(config.GetContext(), q, loadBids,
+		func(bid models.Bid) string { return bid.ID },
+		func(bid models.Bid) bool {
+			return (status == "" || bid.Status == status) &&
+				(propertyID == "" || bid.PropertyID == propertyID) &&
+				inInt64Range(bid.Amount, minAmount, maxAmount) &&
+				inTimeRange(bid.CreatedAt, from, to)
+		})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.BidResponse{
+			Success: false,
+			Message: "Failed to retrieve user bids",
+			Error:   err.Error(),
+		})
+		return
 	}
 
-	// Sort bids by creation time (newest first)
-	sort.Slice(userBids, func(i, j int) bool {
-		return userBids[i].CreatedAt.After(userBids[j].CreatedAt)
-	})
-
 	c.JSON(http.StatusOK, models.BidResponse{
-		Success: true,
-		Message: "User bids retrieved successfully",
-		Data:    userBids,
+		Success:    true,
+		Message:    "User bids retrieved successfully",
+		Data:       userBids,
+		Pagination: page,
 	})
 }
 
@@ -320,23 +366,14 @@ func UpdateBidStatus(c *gin.Context) {
 		return
 	}
 
+	before := bid
 	bid.Status = status
 	if txHash != "" {
 		bid.TxHash = txHash
 	}
 	bid.UpdatedAt = time.Now()
 
-	updatedBidJSON, err := bid.ToJSON()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.BidResponse{
-			Success: false,
-			Message: "Failed to encode updated bid data",
-			Error:   err.Error(),
-		})
-		return
-	}
-
-	if err := redis.Set(ctx, "bid:"+bidID, updatedBidJSON, 0).Err(); err != nil {
+	if err := saveBid(ctx, bid); err != nil {
 		c.JSON(http.StatusInternalServerError, models.BidResponse{
 			Success: false,
 			Message: "Failed to update bid status",
@@ -345,6 +382,8 @@ func UpdateBidStatus(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, "bid.update_status", "bid", bid.ID, before, bid)
+
 	c.JSON(http.StatusOK, models.BidResponse{
 		Success: true,
 		Message: "Bid status updated successfully",
@@ -361,7 +400,7 @@ func GetTopBids(c *gin.Context) {
 	if limitStr != "" {
 		var err error
 		limit, err = strconv.Atoi(limitStr)
-		if err != nil {
+		if err != nil || limit <= 0 {
 			limit = 10
 		}
 		hasLimit = true
@@ -372,7 +411,13 @@ func GetTopBids(c *gin.Context) {
 	redis := config.GetRedisClient()
 	ctx := config.GetContext()
 
-	bidKeys, err := redis.Keys(ctx, "bid:*").Result()
+	// Confirmed bids ranked by amount (highest first); limit only if specified
+	stop := int64(-1)
+	if hasLimit {
+		stop = int64(limit) - 1
+	}
+
+	bidIDs, err := redis.ZRevRange(ctx, bidsByAmountKey, 0, stop).Result()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.BidResponse{
 			Success: false,
@@ -382,37 +427,91 @@ func GetTopBids(c *gin.Context) {
 		return
 	}
 
-	var bids []models.Bid
+	bids, err := loadBids(ctx, bidIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.BidResponse{
+			Success: false,
+			Message: "Failed to retrieve bids",
+			Error:   err.Error(),
+		})
+		return
+	}
 
-	for _, key := range bidKeys {
-		bidJSON, err := redis.Get(ctx, key).Result()
-		if err != nil {
-			continue
-		}
+	c.JSON(http.StatusOK, models.BidResponse{
+		Success: true,
+		Message: "Top bids retrieved successfully",
+		Data:    bids,
+	})
+}
 
-		var bid models.Bid
-		if err := bid.FromJSON(bidJSON); err != nil {
-			continue
-		}
+// watchAuctionLeader watches and loads a property's auction and its
+// leading bid through tx, so a bid commits only against the leader it
+// displaces. It returns a nil auction if the property has none, and a nil
+// bid if the auction has no bids yet.
+func watchAuctionLeader(ctx context.Context, tx *redis.Tx, propertyID string) (*models.Auction, *models.Bid, error) {
+	if err := tx.Watch(ctx, "property_auction:"+propertyID).Err(); err != nil {
+		return nil, nil, err
+	}
+	auctionID, err := tx.Get(ctx, "property_auction:"+propertyID).Result()
+	if isNotFound(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
 
-		if bid.Status == "Confirmed" {
-			bids = append(bids, bid)
-		}
+	if err := tx.Watch(ctx, "auction:"+auctionID).Err(); err != nil {
+		return nil, nil, err
+	}
+	auctionJSON, err := tx.Get(ctx, "auction:"+auctionID).Result()
+	if isNotFound(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var auction models.Auction
+	if err := auction.FromJSON(auctionJSON); err != nil {
+		return nil, nil, err
+	}
+	if auction.LeadingBidID == "" {
+		return &auction, nil, nil
 	}
 
-	// Sort by amount (highest first)
-	sort.Slice(bids, func(i, j int) bool {
-		return bids[i].Amount > bids[j].Amount
-	})
+	bidJSON, err := tx.Get(ctx, "bid:"+auction.LeadingBidID).Result()
+	if isNotFound(err) {
+		return &auction, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var leader models.Bid
+	if err := leader.FromJSON(bidJSON); err != nil {
+		return nil, nil, err
+	}
+	return &auction, &leader, nil
+}
 
-	// Limit results (only if limit is specified)
-	if hasLimit && len(bids) > limit {
-		bids = bids[:limit]
+// notifyOutbid tells the bidder whose leading bid was beaten, with an outbid
+// event on their private websocket topic and a notification
+func notifyOutbid(ctx context.Context, previous, bid models.Bid, property models.Property, auction models.Auction) {
+	alert := OutbidAlert{
+		PropertyID:    property.ID,
+		AuctionID:     auction.ID,
+		PropertyTitle: property.Title,
+		YourBid:       previous.Amount,
+		NewBid:        bid.Amount,
+		EndsAt:        auction.EndTime,
+		TimeRemaining: timeRemaining(auction.EndTime, time.Now()).String(),
 	}
 
-	c.JSON(http.StatusOK, models.BidResponse{
-		Success: true,
-		Message: "Top bids retrieved successfully",
-		Data:    bids,
+	BroadcastOutbid(previous.BidderID, alert)
+	notifyUser(ctx, models.Notification{
+		UserID:     previous.BidderID,
+		Type:       models.NotificationBidOutbid,
+		Title:      fmt.Sprintf("You've been outbid on %q", property.Title),
+		Message:    fmt.Sprintf("A bid of %s beat your %s. Bidding closes at %s.", formatWon(bid.Amount), formatWon(previous.Amount), alert.EndsAt.Format(noticeTimeLayout)),
+		EntityType: "property",
+		EntityID:   property.ID,
 	})
 }
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"erea-api/middleware"
+	"erea-api/models"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// seedAuction stores an active property with an active auction ending in
+// an hour
+func seedAuction(t *testing.T, propertyID string, startingPrice int64) models.Auction {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now()
+	property := models.Property{
+		ID:            propertyID,
+		Title:         "Test listing " + propertyID,
+		Location:      "Seoul",
+		Type:          "Apartment",
+		StartingPrice: startingPrice,
+		CurrentPrice:  startingPrice,
+		Status:        models.PropertyStatusActive,
+		EndDate:       now.Add(time.Hour),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := saveProperty(ctx, nil, property); err != nil {
+		t.Fatal(err)
+	}
+	auction := models.Auction{
+		ID:             "auction-" + propertyID,
+		PropertyID:     propertyID,
+		Status:         activeAuctionStatus,
+		StartTime:      now,
+		EndTime:        now.Add(time.Hour),
+		CurrentHighest: startingPrice,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := saveAuction(ctx, auction); err != nil {
+		t.Fatal(err)
+	}
+	return auction
+}
+
+func placeTestBid(t *testing.T, propertyID, bidderID string, amount int64) int {
+	t.Helper()
+	router := newTestRouter()
+	router.POST("/bids", PlaceBid)
+	w := performJSON(t, router, http.MethodPost, "/bids", models.CreateBidRequest{
+		PropertyID: propertyID,
+		BidderID:   bidderID,
+		Amount:     amount,
+	})
+	return w.Code
+}
+
+func TestPlaceBidNotifiesDisplacedLeader(t *testing.T) {
+	mr := newTestRedis(t)
+	auction := seedAuction(t, "p1", 100)
+
+	if code := placeTestBid(t, "p1", "alice", 150); code != http.StatusCreated {
+		t.Fatalf("first bid: status %d", code)
+	}
+	if inbox, _ := mr.ZMembers(userNotificationsKey("alice")); len(inbox) != 0 {
+		t.Fatalf("the first bidder displaced nobody, got notifications %v", inbox)
+	}
+
+	// Raising one's own bid doesn't notify
+	if code := placeTestBid(t, "p1", "alice", 160); code != http.StatusCreated {
+		t.Fatalf("raised bid: status %d", code)
+	}
+	if inbox, _ := mr.ZMembers(userNotificationsKey("alice")); len(inbox) != 0 {
+		t.Fatalf("raising a bid notified its bidder: %v", inbox)
+	}
+
+	outbid := newTestClient(hub, 8, userTopic("alice"))
+	hub.register(outbid)
+	defer hub.unregister(outbid)
+
+	if code := placeTestBid(t, "p1", "bob", 200); code != http.StatusCreated {
+		t.Fatalf("second bid: status %d", code)
+	}
+
+	inbox, _ := mr.ZMembers(userNotificationsKey("alice"))
+	if len(inbox) != 1 {
+		t.Fatalf("expected one outbid notification for alice, got %v", inbox)
+	}
+	if inbox, _ := mr.ZMembers(userNotificationsKey("bob")); len(inbox) != 0 {
+		t.Fatalf("the new leader was notified: %v", inbox)
+	}
+
+	var sawOutbid bool
+	for len(outbid.send) > 0 {
+		if message, _ := receive(t, outbid); message.Type == "outbid" {
+			sawOutbid = true
+		}
+	}
+	if !sawOutbid {
+		t.Fatal("no outbid event on alice's topic")
+	}
+
+	// The auction records the new leader
+	auctions, err := loadAuctions(context.Background(), []string{auction.ID})
+	if err != nil || len(auctions) != 1 {
+		t.Fatalf("loading auction: %v", err)
+	}
+	leading := auctions[0]
+	if leading.LeaderID != "bob" || leading.CurrentHighest != 200 || leading.BidCount != 3 {
+		t.Fatalf("unexpected leader in auction %+v", leading)
+	}
+}
+
+func TestPlaceBidRejectsLowBid(t *testing.T) {
+	newTestRedis(t)
+	seedAuction(t, "p1", 100)
+
+	if code := placeTestBid(t, "p1", "alice", 100); code != http.StatusBadRequest {
+		t.Fatalf("expected a bid at the current price to be rejected, got %d", code)
+	}
+	if code := placeTestBid(t, "missing", "alice", 500); code != http.StatusNotFound {
+		t.Fatalf("expected a bid on a missing property to be rejected, got %d", code)
+	}
+}
+
+func TestPlaceBidFollowsAuctionEndTime(t *testing.T) {
+	newTestRedis(t)
+	ctx := context.Background()
+
+	// The auction ended although the listing's end date hasn't passed
+	auction := seedAuction(t, "p1", 100)
+	auction.EndTime = time.Now().Add(-time.Minute)
+	if err := saveAuction(ctx, auction); err != nil {
+		t.Fatal(err)
+	}
+	if code := placeTestBid(t, "p1", "alice", 150); code != http.StatusBadRequest {
+		t.Fatalf("expected a bid after the auction's end to be rejected, got %d", code)
+	}
+
+	// The auction runs past the listing's end date
+	auction = seedAuction(t, "p2", 100)
+	before, property, _, err := updatePropertyVersioned(ctx, "p2", "", func(property *models.Property) error {
+		property.EndDate = time.Now().Add(-time.Minute)
+		return nil
+	})
+	if err != nil || before.EndDate.Equal(property.EndDate) {
+		t.Fatalf("moving the listing's end date: %v", err)
+	}
+	if code := placeTestBid(t, "p2", "alice", 150); code != http.StatusCreated {
+		t.Fatalf("expected a bid before the auction's end to be accepted, got %d", code)
+	}
+
+	// A listing without an auction takes no bids
+	if err := saveProperty(ctx, nil, models.Property{ID: "p3", Status: models.PropertyStatusActive, EndDate: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if code := placeTestBid(t, "p3", "alice", 150); code != http.StatusBadRequest {
+		t.Fatalf("expected a bid without an auction to be rejected, got %d", code)
+	}
+}
+
+func TestPlaceBidBindsUserKeys(t *testing.T) {
+	newTestRedis(t)
+	seedAuction(t, "p1", 100)
+	aliceKey := issueTestKey(t, "alice", models.ScopeBidsWrite)
+	machineKey := issueTestKey(t, "", models.ScopeBidsWrite)
+
+	router := newTestRouter()
+	router.Use(middleware.Authenticate())
+	router.POST("/bids", PlaceBid)
+	bid := func(apiKey, bidderID string, amount int64) int {
+		return performJSONWithKey(t, router, http.MethodPost, "/bids", apiKey, models.CreateBidRequest{
+			PropertyID: "p1",
+			BidderID:   bidderID,
+			Amount:     amount,
+		}).Code
+	}
+
+	if code := bid(aliceKey, "bob", 150); code != http.StatusForbidden {
+		t.Fatalf("bid for another user: expected 403, got %d", code)
+	}
+	if code := bid(aliceKey, "alice", 150); code != http.StatusCreated {
+		t.Fatalf("bid for the key's user: expected 201, got %d", code)
+	}
+	if code := bid(machineKey, "bob", 200); code != http.StatusCreated {
+		t.Fatalf("bid with a key acting for no user: expected 201, got %d", code)
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"erea-api/config"
 	"erea-api/models"
+	"log"
 	"net/http"
 	"time"
 
@@ -12,7 +13,6 @@ import (
 
 // CreateDemoData creates demo data for testing
 func CreateDemoData(c *gin.Context) {
-	redis := config.GetRedisClient()
 	ctx := config.GetContext()
 
 	// Demo users
@@ -45,11 +45,11 @@ func CreateDemoData(c *gin.Context) {
 
 	// Save demo users
 	for _, user := range users {
-		userJSON, _ := user.ToJSON()
-		redis.Set(ctx, "user:"+user.ID, userJSON, 0)
+		saveUser(ctx, user)
 	}
 
 	// Demo properties (matching EREA frontend data)
+	submittedAt := time.Now()
 	properties := []models.Property{
 		{
 			ID:            uuid.New().String(),
@@ -62,7 +62,8 @@ func CreateDemoData(c *gin.Context) {
 			CurrentPrice:  650000000,
 			ImageURL:      "/api/placeholder/300/200",
 			Features:      []string{"Near Subway Station", "24/7 Security", "Parking Available", "Modern Facilities"},
-			Status:        "Active",
+			Attributes:    map[string]interface{}{"floor": 12, "total_floors": 20, "rooms": 1, "bathrooms": 1, "build_year": 2018, "parking_spaces": 1, "residential_use": true},
+			Status:        models.PropertyStatusActive,
 			EndDate:       time.Now().Add(10 * 24 * time.Hour), // 10 days from now
 			CreatedAt:     time.Now(),
 			UpdatedAt:     time.Now(),
@@ -79,7 +80,8 @@ func CreateDemoData(c *gin.Context) {
 			CurrentPrice:  920000000,
 			ImageURL:      "/api/placeholder/300/200",
 			Features:      []string{"School District", "Park View", "Underground Parking", "Elevator"},
-			Status:        "Active",
+			Attributes:    map[string]interface{}{"floor": 8, "total_floors": 25, "rooms": 3, "bathrooms": 2, "build_year": 1993, "parking_spaces": 1, "facing": "south"},
+			Status:        models.PropertyStatusActive,
 			EndDate:       time.Now().Add(8 * 24 * time.Hour), // 8 days from now
 			CreatedAt:     time.Now(),
 			UpdatedAt:     time.Now(),
@@ -96,7 +98,8 @@ func CreateDemoData(c *gin.Context) {
 			CurrentPrice:  380000000,
 			ImageURL:      "/api/placeholder/300/200",
 			Features:      []string{"High Foot Traffic", "Corner Location", "Restaurant Permitted", "Night Business"},
-			Status:        "Closed",
+			Attributes:    map[string]interface{}{"zoning": "commercial", "floor": 1, "total_floors": 5, "build_year": 2005},
+			Status:        models.PropertyStatusClosed,
 			EndDate:       time.Now().Add(-2 * 24 * time.Hour), // 2 days ago
 			CreatedAt:     time.Now().Add(-10 * 24 * time.Hour),
 			UpdatedAt:     time.Now(),
@@ -113,7 +116,8 @@ func CreateDemoData(c *gin.Context) {
 			CurrentPrice:  1350000000,
 			ImageURL:      "/api/placeholder/300/200",
 			Features:      []string{"Ocean View", "Private Garden", "Resort Amenities", "Tourist Zone"},
-			Status:        "Active",
+			Attributes:    map[string]interface{}{"land_area": 420.0, "total_floors": 2, "rooms": 4, "bathrooms": 3, "build_year": 2015, "parking_spaces": 2, "facing": "south"},
+			Status:        models.PropertyStatusActive,
 			EndDate:       time.Now().Add(11 * 24 * time.Hour), // 11 days from now
 			CreatedAt:     time.Now(),
 			UpdatedAt:     time.Now(),
@@ -130,7 +134,9 @@ func CreateDemoData(c *gin.Context) {
 			CurrentPrice:  780000000,
 			ImageURL:      "/api/placeholder/300/200",
 			Features:      []string{"Beach Access", "Ocean View", "Resort Facilities", "Investment Property"},
-			Status:        "Pending",
+			Attributes:    map[string]interface{}{"floor": 30, "total_floors": 45, "rooms": 2, "bathrooms": 2, "build_year": 2019, "parking_spaces": 1, "facing": "southeast"},
+			Status:        models.PropertyStatusPendingReview, // In the review queue, ready to approve and auction
+			SubmittedAt:   &submittedAt,
 			EndDate:       time.Now().Add(15 * 24 * time.Hour), // 15 days from now
 			CreatedAt:     time.Now(),
 			UpdatedAt:     time.Now(),
@@ -138,20 +144,20 @@ func CreateDemoData(c *gin.Context) {
 		},
 	}
 
-	// Save demo properties
-	for _, property := range properties {
-		propertyJSON, _ := property.ToJSON()
-		redis.Set(ctx, "property:"+property.ID, propertyJSON, 0)
-		redis.SAdd(ctx, "properties", property.ID)
+	// Save demo properties, placing them on the map from their addresses
+	for i := range properties {
+		geocodeProperty(&properties[i])
+		saveProperty(ctx, nil, properties[i])
 	}
 
 	// Create demo auctions for active properties
+	auctionCount := 0
 	for _, property := range properties {
-		if property.Status == "Active" {
+		if property.Status == models.PropertyStatusActive {
 			auction := models.Auction{
 				ID:             uuid.New().String(),
 				PropertyID:     property.ID,
-				Status:         "Active",
+				Status:         activeAuctionStatus,
 				StartTime:      property.CreatedAt,
 				EndTime:        property.EndDate,
 				MinIncrement:   10000000, // 10M KRW minimum increment
@@ -162,17 +168,15 @@ func CreateDemoData(c *gin.Context) {
 				UpdatedAt:      time.Now(),
 			}
 
-			auctionJSON, _ := auction.ToJSON()
-			redis.Set(ctx, "auction:"+auction.ID, auctionJSON, 0)
-			redis.Set(ctx, "property_auction:"+property.ID, auction.ID, 0)
-			redis.SAdd(ctx, "active_auctions", auction.ID)
+			saveAuction(ctx, auction)
+			auctionCount++
 		}
 	}
 
 	// Create demo bids
 	bidCount := 0
 	for _, property := range properties {
-		if property.Status == "Active" || property.Status == "Closed" {
+		if property.Status == models.PropertyStatusActive || property.Status == models.PropertyStatusClosed {
 			// Create multiple bids per property
 			bidAmounts := []int64{
 				property.StartingPrice + 10000000,
@@ -193,21 +197,25 @@ func CreateDemoData(c *gin.Context) {
 					UpdatedAt:   time.Now(),
 				}
 
-				bidJSON, _ := bid.ToJSON()
-				redis.Set(ctx, "bid:"+bid.ID, bidJSON, 0)
-				redis.SAdd(ctx, "property_bids:"+property.ID, bid.ID)
+				saveBid(ctx, bid)
 				bidCount++
 			}
 		}
 	}
 
+	recordAudit(c, "demo.create", "demo", "", nil, gin.H{
+		"users_created":      len(users),
+		"properties_created": len(properties),
+		"bids_created":       bidCount,
+	})
+
 	response := gin.H{
 		"success": true,
 		"message": "Demo data created successfully",
 		"data": gin.H{
 			"users_created":      len(users),
 			"properties_created": len(properties),
-			"auctions_created":   3, // Active properties count
+			"auctions_created":   auctionCount,
 			"bids_created":       bidCount,
 		},
 	}
@@ -220,24 +228,62 @@ func ClearDemoData(c *gin.Context) {
 	redis := config.GetRedisClient()
 	ctx := config.GetContext()
 
-	// Get all keys to delete
-	patterns := []string{"user:*", "property:*", "auction:*", "bid:*", "property_bids:*", "property_auction:*"}
-	deletedCount := 0
+	// Collect keys to delete from the indexes
+	userIDs, _ := redis.SMembers(ctx, usersIndexKey).Result()
+	propertyIDs, _ := redis.SMembers(ctx, propertiesIndexKey).Result()
+	auctionIDs, _ := redis.SMembers(ctx, auctionsIndexKey).Result()
+	bidIDs, _ := redis.ZRange(ctx, bidsByTimeKey, 0, -1).Result()
 
-	for _, pattern := range patterns {
-		keys, err := redis.Keys(ctx, pattern).Result()
-		if err != nil {
-			continue
+	var keys []string
+	for _, userID := range userIDs {
+		keys = append(keys, userKeyPrefix+userID, userBidsKey(userID), userBidsByAmountKey(userID), userWonAuctionsKey(userID), userInspectionsKey(userID))
+		if err := deleteUserWatches(ctx, userID); err != nil {
+			log.Printf("Failed to delete watchlist of user %s: %v", userID, err)
+		}
+	}
+	properties, _ := loadProperties(ctx, propertyIDs)
+	for _, property := range properties {
+		keys = append(keys, propertyStatusKey(property.Status))
+		for term := range propertySearchTerms(property) {
+			keys = append(keys, searchTermKey(term))
+		}
+	}
+	for _, propertyID := range propertyIDs {
+		keys = append(keys, "property:"+propertyID, propertyBidsKey(propertyID), "property_auction:"+propertyID, propertyReviewsKey(propertyID), propertyVersionsKey(propertyID))
+		if err := deletePropertyMedia(ctx, propertyID); err != nil {
+			log.Printf("Failed to delete media of property %s: %v", propertyID, err)
+		}
+		if err := deletePropertyWatchers(ctx, propertyID); err != nil {
+			log.Printf("Failed to delete watchers of property %s: %v", propertyID, err)
+		}
+		if err := deletePropertyInspections(ctx, propertyID); err != nil {
+			log.Printf("Failed to delete inspections of property %s: %v", propertyID, err)
 		}
+	}
+	for _, auctionID := range auctionIDs {
+		keys = append(keys, "auction:"+auctionID)
+	}
+	for _, bidID := range bidIDs {
+		keys = append(keys, "bid:"+bidID)
+	}
 
-		if len(keys) > 0 {
-			deleted, _ := redis.Del(ctx, keys...).Result()
-			deletedCount += int(deleted)
+	deletedCount := 0
+	for start := 0; start < len(keys); start += indexScanBatch {
+		end := start + indexScanBatch
+		if end > len(keys) {
+			end = len(keys)
 		}
+		deleted, _ := redis.Del(ctx, keys[start:end]...).Result()
+		deletedCount += int(deleted)
 	}
 
-	// Clear sets
-	redis.Del(ctx, "properties", "active_auctions", "closed_auctions")
+	// Clear index sets
+	redis.Del(ctx, usersIndexKey, usersByCreatedKey, propertiesIndexKey, propertiesByCreatedKey, propertiesByPriceKey, propertiesByEndDateKey, propertiesGeoKey, reviewQueueKey,
+		auctionsIndexKey, activeAuctionsKey, activeAuctionsByEndKey, activeAuctionsByStartKey, closedAuctionsKey, bidsByTimeKey, bidsByAmountKey)
+
+	recordAudit(c, "demo.clear", "demo", "", nil, gin.H{
+		"deleted_keys": deletedCount,
+	})
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -253,17 +299,17 @@ func GetDemoStatus(c *gin.Context) {
 	redis := config.GetRedisClient()
 	ctx := config.GetContext()
 
-	userKeys, _ := redis.Keys(ctx, "user:*").Result()
-	propertyKeys, _ := redis.Keys(ctx, "property:*").Result()
-	auctionKeys, _ := redis.Keys(ctx, "auction:*").Result()
-	bidKeys, _ := redis.Keys(ctx, "bid:*").Result()
+	usersCount, _ := redis.SCard(ctx, usersIndexKey).Result()
+	propertiesCount, _ := redis.SCard(ctx, propertiesIndexKey).Result()
+	auctionsCount, _ := redis.SCard(ctx, auctionsIndexKey).Result()
+	bidsCount, _ := redis.ZCard(ctx, bidsByTimeKey).Result()
 
 	status := gin.H{
-		"demo_data_exists": len(userKeys) > 0 || len(propertyKeys) > 0,
-		"users_count":      len(userKeys),
-		"properties_count": len(propertyKeys),
-		"auctions_count":   len(auctionKeys),
-		"bids_count":       len(bidKeys),
+		"demo_data_exists": usersCount > 0 || propertiesCount > 0,
+		"users_count":      usersCount,
+		"properties_count": propertiesCount,
+		"auctions_count":   auctionsCount,
+		"bids_count":       bidsCount,
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -24,6 +24,16 @@ func CreateDeposit(c *gin.Context) {
 		return
 	}
 
+	// A key acting for a user pays deposits only for that user
+	if actsForOtherUser(c, req.UserID) {
+		c.JSON(http.StatusForbidden, models.DepositResponse{
+			Success: false,
+			Message: "Access denied",
+			Error:   "user_id must be the user the API key acts for",
+		})
+		return
+	}
+
 	redis := config.GetRedisClient()
 	ctx := config.GetContext()
 
@@ -62,22 +72,17 @@ func CreateDeposit(c *gin.Context) {
 	}
 
 	// Check if user already has a confirmed deposit for this property
-	existingDeposits, err := redis.Keys(ctx, fmt.Sprintf("deposit:*:%s:%s", req.PropertyID, req.UserID)).Result()
+	existingIDs, err := redis.SInter(ctx, "user_deposits:"+req.UserID, "property_deposits:"+req.PropertyID).Result()
 	if err == nil {
-		for _, key := range existingDeposits {
-			depositJSON, err := redis.Get(ctx, key).Result()
-			if err == nil {
-				var existingDeposit models.Deposit
-				if err := existingDeposit.FromJSON(depositJSON); err == nil {
-					if existingDeposit.Status == "Confirmed" {
-						c.JSON(http.StatusConflict, models.DepositResponse{
-							Success: false,
-							Message: "You already have a confirmed deposit for this property",
-							Error:   "Duplicate deposit",
-						})
-						return
-					}
-				}
+		existingDeposits, _ := loadDeposits(ctx, existingIDs)
+		for _, existingDeposit := range existingDeposits {
+			if existingDeposit.Status == "Confirmed" {
+				c.JSON(http.StatusConflict, models.DepositResponse{
+					Success: false,
+					Message: "You already have a confirmed deposit for this property",
+					Error:   "Duplicate deposit",
+				})
+				return
 			}
 		}
 	}
@@ -95,19 +100,8 @@ func CreateDeposit(c *gin.Context) {
 		UpdatedAt:  time.Now(),
 	}
 
-	// Save to Redis
-	depositJSON, err := deposit.ToJSON()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.DepositResponse{
-			Success: false,
-			Message: "Failed to serialize deposit data",
-			Error:   err.Error(),
-		})
-		return
-	}
-
-	key := fmt.Sprintf("deposit:%s:%s:%s", deposit.ID, deposit.PropertyID, deposit.UserID)
-	if err := redis.Set(ctx, key, depositJSON, 0).Err(); err != nil {
+	// Save to Redis (also adds it to the user's and property's deposit lists)
+	if err := saveDeposit(ctx, deposit); err != nil {
 		c.JSON(http.StatusInternalServerError, models.DepositResponse{
 			Success: false,
 			Message: "Failed to save deposit",
@@ -116,13 +110,8 @@ func CreateDeposit(c *gin.Context) {
 		return
 	}
 
-	// Add to user's deposit list
-	userDepositKey := fmt.Sprintf("user_deposits:%s", req.UserID)
-	redis.SAdd(ctx, userDepositKey, deposit.ID)
-
-	// Add to property's deposit list
-	propertyDepositKey := fmt.Sprintf("property_deposits:%s", req.PropertyID)
-	redis.SAdd(ctx, propertyDepositKey, deposit.ID)
+	recordAudit(c, "deposit.create", "deposit", deposit.ID, nil, deposit)
+	BroadcastDepositUpdate(deposit)
 
 	c.JSON(http.StatusCreated, models.DepositResponse{
 		Success: true,
@@ -131,45 +120,20 @@ func CreateDeposit(c *gin.Context) {
 	})
 }
 
-// GetAllDeposits retrieves all deposits
-func GetAllDeposits(c *gin.Context) {
-	redis := config.GetRedisClient()
-	ctx := config.GetContext()
-
-	keys, err := redis.Keys(ctx, "deposit:*").Result()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.DepositListResponse{
-			Success: false,
-			Message: "Failed to retrieve deposits",
-			Error:   err.Error(),
-		})
-		return
-	}
-
-	var deposits []models.Deposit
-	for _, key := range keys {
-		depositJSON, err := redis.Get(ctx, key).Result()
-		if err != nil {
-			continue
-		}
-
-		var deposit models.Deposit
-		if err := deposit.FromJSON(depositJSON); err != nil {
-			continue
-		}
-
-		deposits = append(deposits, deposit)
-	}
+// depositSortKeys are the sortable fields of deposit lists
+var depositSortKeys = listSortKeys{
+	"created_at": depositsByTimeKey,
+	"amount":     depositsByAmountKey,
+}
 
-	c.JSON(http.StatusOK, models.DepositListResponse{
-		Success: true,
-		Message: "Deposits retrieved successfully",
-		Data:    deposits,
-		Total:   len(deposits),
-	})
+// GetAllDeposits retrieves deposits a page at a time. Supports status,
+// user_id, property_id, token_type, min_amount, max_amount and from/to
+// (creation date) filters and sorting by created_at or amount.
+func GetAllDeposits(c *gin.Context) {
+	listDeposits(c, c.Query("user_id"))
 }
 
-// GetUserDeposits retrieves deposits for a specific user
+// GetUserDeposits retrieves deposits for a specific user, paginated like GetAllDeposits
 func GetUserDeposits(c *gin.Context) {
 	userID := c.Param("userId")
 	if userID == "" {
@@ -181,47 +145,64 @@ func GetUserDeposits(c *gin.Context) {
 		return
 	}
 
-	redis := config.GetRedisClient()
-	ctx := config.GetContext()
+	listDeposits(c, userID)
+}
+
+// listDeposits writes one page of deposits matching the query filters
+func listDeposits(c *gin.Context, userID string) {
+	var minAmount, maxAmount *int64
+	var from, to *time.Time
 
-	// Get user's deposit IDs
-	userDepositKey := fmt.Sprintf("user_deposits:%s", userID)
-	depositIDs, err := redis.SMembers(ctx, userDepositKey).Result()
+	q, err := parseListQuery(c, depositSortKeys, "-created_at")
+	if err == nil {
+		minAmount, err = queryInt64(c, "min_amount")
+	}
+	if err == nil {
+		maxAmount, err = queryInt64(c, "max_amount")
+	}
+	if err == nil {
+		from, to, err = queryTimeRange(c)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.DepositListResponse{
+		c.JSON(http.StatusBadRequest, models.DepositListResponse{
 			Success: false,
-			Message: "Failed to retrieve user deposits",
+			Message: "Invalid query parameters",
 			Error:   err.Error(),
 		})
 		return
 	}
 
-	var deposits []models.Deposit
-	for _, depositID := range depositIDs {
-		// Find the deposit key by pattern matching
-		keys, err := redis.Keys(ctx, fmt.Sprintf("deposit:%s:*", depositID)).Result()
-		if err != nil || len(keys) == 0 {
-			continue
-		}
-
-		depositJSON, err := redis.Get(ctx, keys[0]).Result()
-		if err != nil {
-			continue
-		}
-
-		var deposit models.Deposit
-		if err := deposit.FromJSON(depositJSON); err != nil {
-			continue
-		}
-
-		deposits = append(deposits, deposit)
+	status := c.Query("status")
+	propertyID := c.Query("property_id")
+	tokenType := c.Query("token_type")
+	q.narrow("amount", int64Score(minAmount), int64Score(maxAmount))
+	q.narrow("created_at", timeScore(from), timeScore(to))
+
+	deposits, page, err := paginate(config.GetContext(), q, loadDeposits,
+		func(deposit models.Deposit) string { return deposit.ID },
+		func(deposit models.Deposit) bool {
+			return (userID == "" || deposit.UserID == userID) &&
+				(propertyID == "" || deposit.PropertyID == propertyID) &&
+				(status == "" || deposit.Status == status) &&
+				(tokenType == "" || deposit.TokenType == tokenType) &&
+				inInt64Range(deposit.Amount, minAmount, maxAmount) &&
+				inTimeRange(deposit.CreatedAt, from, to)
+		})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.DepositListResponse{
+			Success: false,
+			Message: "Failed to retrieve deposits",
+			Error:   err.Error(),
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, models.DepositListResponse{
-		Success: true,
-		Message: "User deposits retrieved successfully",
-		Data:    deposits,
-		Total:   len(deposits),
+		Success:    true,
+		Message:    "Deposits retrieved successfully",
+		Data:       deposits,
+		Total:      len(deposits),
+		Pagination: page,
 	})
 }
 
@@ -237,12 +218,11 @@ func GetDeposit(c *gin.Context) {
 		return
 	}
 
-	redis := config.GetRedisClient()
 	ctx := config.GetContext()
 
-	// Find the deposit key by pattern matching
-	keys, err := redis.Keys(ctx, fmt.Sprintf("deposit:%s:*", depositID)).Result()
-	if err != nil || len(keys) == 0 {
+	// Find the deposit through the deposit ID index
+	deposit, _, err := getDepositByID(ctx, depositID)
+	if isNotFound(err) {
 		c.JSON(http.StatusNotFound, models.DepositResponse{
 			Success: false,
 			Message: "Deposit not found",
@@ -250,22 +230,10 @@ func GetDeposit(c *gin.Context) {
 		})
 		return
 	}
-
-	depositJSON, err := redis.Get(ctx, keys[0]).Result()
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.DepositResponse{
-			Success: false,
-			Message: "Deposit not found",
-			Error:   err.Error(),
-		})
-		return
-	}
-
-	var deposit models.Deposit
-	if err := deposit.FromJSON(depositJSON); err != nil {
 		c.JSON(http.StatusInternalServerError, models.DepositResponse{
 			Success: false,
-			Message: "Failed to parse deposit data",
+			Message: "Failed to retrieve deposit",
 			Error:   err.Error(),
 		})
 		return
@@ -304,12 +272,11 @@ func UpdateDepositStatus(c *gin.Context) {
 		return
 	}
 
-	redis := config.GetRedisClient()
 	ctx := config.GetContext()
 
-	// Find the deposit key by pattern matching
-	keys, err := redis.Keys(ctx, fmt.Sprintf("deposit:%s:*", depositID)).Result()
-	if err != nil || len(keys) == 0 {
+	// Find the deposit through the deposit ID index
+	deposit, _, err := getDepositByID(ctx, depositID)
+	if isNotFound(err) {
 		c.JSON(http.StatusNotFound, models.DepositResponse{
 			Success: false,
 			Message: "Deposit not found",
@@ -317,28 +284,17 @@ func UpdateDepositStatus(c *gin.Context) {
 		})
 		return
 	}
-
-	depositJSON, err := redis.Get(ctx, keys[0]).Result()
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.DepositResponse{
-			Success: false,
-			Message: "Deposit not found",
-			Error:   err.Error(),
-		})
-		return
-	}
-
-	var deposit models.Deposit
-	if err := deposit.FromJSON(depositJSON); err != nil {
 		c.JSON(http.StatusInternalServerError, models.DepositResponse{
 			Success: false,
-			Message: "Failed to parse deposit data",
+			Message: "Failed to retrieve deposit",
 			Error:   err.Error(),
 		})
 		return
 	}
 
 	// Update deposit
+	before := deposit
 	deposit.Status = req.Status
 	if req.TxHash != "" {
 		deposit.TxHash = req.TxHash
@@ -346,17 +302,7 @@ func UpdateDepositStatus(c *gin.Context) {
 	deposit.UpdatedAt = time.Now()
 
 	// Save updated deposit
-	updatedJSON, err := deposit.ToJSON()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.DepositResponse{
-			Success: false,
-			Message: "Failed to serialize updated deposit",
-			Error:   err.Error(),
-		})
-		return
-	}
-
-	if err := redis.Set(ctx, keys[0], updatedJSON, 0).Err(); err != nil {
+	if err := saveDeposit(ctx, deposit); err != nil {
 		c.JSON(http.StatusInternalServerError, models.DepositResponse{
 			Success: false,
 			Message: "Failed to update deposit",
@@ -365,6 +311,9 @@ func UpdateDepositStatus(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, "deposit.update_status", "deposit", deposit.ID, before, deposit)
+	BroadcastDepositUpdate(deposit)
+
 	c.JSON(http.StatusOK, models.DepositResponse{
 		Success: true,
 		Message: "Deposit status updated successfully",
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"erea-api/middleware"
+	"erea-api/models"
+	"net/http"
+	"testing"
+)
+
+func TestCreateDepositBindsUserKeys(t *testing.T) {
+	newTestRedis(t)
+	seedAuction(t, "p1", 1000)
+	aliceKey := issueTestKey(t, "alice", models.ScopeDepositsWrite)
+	adminKey := issueTestKey(t, "alice", models.ScopeAdmin)
+
+	router := newTestRouter()
+	router.Use(middleware.Authenticate())
+	router.POST("/deposits", CreateDeposit)
+	deposit := func(apiKey, userID string) int {
+		return performJSONWithKey(t, router, http.MethodPost, "/deposits", apiKey, models.CreateDepositRequest{
+			PropertyID: "p1",
+			UserID:     userID,
+			Amount:     100,
+			TokenType:  "EERC",
+		}).Code
+	}
+
+	if code := deposit(aliceKey, "bob"); code != http.StatusForbidden {
+		t.Fatalf("deposit for another user: expected 403, got %d", code)
+	}
+	if code := deposit(aliceKey, "alice"); code != http.StatusCreated {
+		t.Fatalf("deposit for the key's user: expected 201, got %d", code)
+	}
+	if code := deposit(adminKey, "bob"); code != http.StatusCreated {
+		t.Fatalf("deposit by an admin: expected 201, got %d", code)
+	}
+}
@@ -136,6 +136,11 @@ func MintEERCTokens(c *gin.Context) {
 
 	fmt.Printf("✅ ZK mint completed successfully. TxHash: %s\n", mintResponse.TxHash)
 
+	recordAudit(c, "eerc.mint", "eerc_tx", mintResponse.TxHash, nil, gin.H{
+		"amount":  req.Amount,
+		"tx_hash": mintResponse.TxHash,
+	})
+
 	// 성공 응답 (실제 ZK mint 결과 사용)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -215,6 +220,11 @@ func TransferEERCTokens(c *gin.Context) {
 
 	fmt.Printf("✅ ZK transfer completed successfully. TxHash: %s\n", transferResponse.TxHash)
 
+	recordAudit(c, "eerc.transfer", "eerc_tx", transferResponse.TxHash, nil, gin.H{
+		"amount":  req.Amount,
+		"tx_hash": transferResponse.TxHash,
+	})
+
 	// 성공 응답 (실제 ZK transfer 결과 사용)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
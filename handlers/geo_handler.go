@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"context"
+	"erea-api/config"
+	"erea-api/geo"
+	"erea-api/models"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	propertiesGeoKey      = "properties_geo" // GEO set of property coordinates
+	defaultSearchRadiusKm = 3.0
+	maxSearchRadiusKm     = 50.0
+	geoRadiusSlackKm      = 0.01
+	geoDistanceSort       = "distance"
+)
+
+// indexPropertyLocation adds a property to the GEO index, or removes it when
+// it has no coordinates
+func indexPropertyLocation(ctx context.Context, pipe redis.Pipeliner, property models.Property) {
+	if property.Latitude == nil || property.Longitude == nil {
+		pipe.ZRem(ctx, propertiesGeoKey, property.ID)
+		return
+	}
+	pipe.GeoAdd(ctx, propertiesGeoKey, &redis.GeoLocation{
+		Name:      property.ID,
+		Latitude:  *property.Latitude,
+		Longitude: *property.Longitude,
+	})
+}
+
+// geocodeProperty fills in missing coordinates from the property's location
+// using the offline district table. It reports whether coordinates were set.
+func geocodeProperty(property *models.Property) bool {
+	if property.Latitude != nil && property.Longitude != nil {
+		return false
+	}
+
+	district, ok := geo.Geocode(property.Location)
+	if !ok {
+		return false
+	}
+
+	lat, lng := district.Point.Lat, district.Point.Lng
+	property.Latitude = &lat
+	property.Longitude = &lng
+	return true
+}
+
+// validateCoordinates checks that latitude and longitude are given together
+func validateCoordinates(latitude, longitude *float64) error {
+	if (latitude == nil) != (longitude == nil) {
+		return fmt.Errorf("latitude and longitude must be given together")
+	}
+	return nil
+}
+
+// GetNearbyProperties finds properties within radius_km (default 3, max 50)
+// of lat/lng or of a named place such as "강남역" or "Gangnam-gu", nearest
+// first. Supports type, status and attr.* attribute filters and limit.
+func GetNearbyProperties(c *gin.Context) {
+	center, err := geoSearchCenter(c)
+	if err == nil && !center.Valid() {
+		err = fmt.Errorf("lat/lng out of range")
+	}
+
+	radiusKm := defaultSearchRadiusKm
+	if err == nil {
+		if value := c.Query("radius_km"); value != "" {
+			radiusKm, err = strconv.ParseFloat(value, 64)
+			if err != nil || radiusKm <= 0 || radiusKm > maxSearchRadiusKm {
+				err = fmt.Errorf("radius_km must be greater than 0 and at most %g", maxSearchRadiusKm)
+			}
+		}
+	}
+
+	limit, limitErr := parseLimit(c)
+	if err == nil {
+		err = limitErr
+	}
+	var keep func(models.Property) bool
+	if err == nil {
+		keep, err = geoPropertyFilter(c)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.PropertyResponse{
+			Success: false,
+			Message: "Invalid query parameters",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx := config.GetContext()
+	locations, err := config.GetRedisClient().GeoRadius(ctx, propertiesGeoKey, center.Lng, center.Lat, &redis.GeoRadiusQuery{
+		Radius:   radiusKm,
+		Unit:     "km",
+		WithDist: true,
+		Sort:     "ASC",
+	}).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+			Success: false,
+			Message: "Failed to search nearby properties",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	respondGeoHits(c, ctx, limit, locations, keep)
+}
+
+// GetPropertiesInBounds finds properties inside the bounding box given by
+// min_lat, min_lng, max_lat and max_lng (e.g. the visible map area), nearest
+// to the box center first. Supports type, status and attr.* attribute
+// filters and limit.
+func GetPropertiesInBounds(c *gin.Context) {
+	var bounds [4]float64
+	var err error
+	for i, name := range []string{"min_lat", "min_lng", "max_lat", "max_lng"} {
+		if bounds[i], err = strconv.ParseFloat(c.Query(name), 64); err != nil {
+			err = fmt.Errorf("%s is required and must be a number", name)
+			break
+		}
+	}
+
+	southWest := geo.Point{Lat: bounds[0], Lng: bounds[1]}
+	northEast := geo.Point{Lat: bounds[2], Lng: bounds[3]}
+	if err == nil && (!southWest.Valid() || !northEast.Valid() || southWest.Lat >= northEast.Lat || southWest.Lng >= northEast.Lng) {
+		err = fmt.Errorf("bounds must be valid coordinates with min_lat < max_lat and min_lng < max_lng")
+	}
+
+	limit, limitErr := parseLimit(c)
+	if err == nil {
+		err = limitErr
+	}
+	var keep func(models.Property) bool
+	if err == nil {
+		keep, err = geoPropertyFilter(c)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.PropertyResponse{
+			Success: false,
+			Message: "Invalid query parameters",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Search the circle around the box, then keep what is inside the box.
+	// GEORADIUS (rather than GEOSEARCH BYBOX) keeps Redis < 6.2 supported.
+	center := geo.Point{Lat: (southWest.Lat + northEast.Lat) / 2, Lng: (southWest.Lng + northEast.Lng) / 2}
+	radiusKm := math.Max(geo.Distance(center, southWest), geo.Distance(center, northEast))
+
+	ctx := config.GetContext()
+	locations, err := config.GetRedisClient().GeoRadius(ctx, propertiesGeoKey, center.Lng, center.Lat, &redis.GeoRadiusQuery{
+		Radius:    radiusKm + geoRadiusSlackKm,
+		Unit:      "km",
+		WithCoord: true,
+		WithDist:  true,
+		Sort:      "ASC",
+	}).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+			Success: false,
+			Message: "Failed to search properties in bounds",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	inside := locations[:0]
+	for _, location := range locations {
+		if location.Latitude >= southWest.Lat && location.Latitude <= northEast.Lat &&
+			location.Longitude >= southWest.Lng && location.Longitude <= northEast.Lng {
+			inside = append(inside, location)
+		}
+	}
+
+	respondGeoHits(c, ctx, limit, inside, keep)
+}
+
+// geoSearchCenter reads the search center from lat/lng or a place name
+func geoSearchCenter(c *gin.Context) (geo.Point, error) {
+	if place := c.Query("place"); place != "" {
+		district, ok := geo.Lookup(place)
+		if !ok {
+			district, ok = geo.Geocode(place)
+		}
+		if !ok {
+			return geo.Point{}, fmt.Errorf("unknown place %q", place)
+		}
+		return district.Point, nil
+	}
+
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+	if errLat != nil || errLng != nil {
+		return geo.Point{}, fmt.Errorf("lat and lng, or place, are required")
+	}
+	return geo.Point{Lat: lat, Lng: lng}, nil
+}
+
+// geoPropertyFilter builds the type/status/attribute filter shared by map
+// searches. Without a status only public listings match.
+func geoPropertyFilter(c *gin.Context) (func(models.Property) bool, error) {
+	attributeFilters, err := queryAttributeFilters(c)
+	if err != nil {
+		return nil, err
+	}
+
+	propertyType := c.Query("type")
+	status := c.Query("status")
+	return func(property models.Property) bool {
+		return (propertyType == "" || property.Type == propertyType) &&
+			((status == "" && models.IsPublicStatus(property.Status)) || property.Status == status) &&
+			models.MatchAttributes(property.Attributes, attributeFilters)
+	}, nil
+}
+
+// respondGeoHits loads the properties for locations (nearest first) and
+// writes up to limit matches. Map searches are bounded by area, so there
+// is no cursor; has_more tells the client to zoom in or narrow the radius.
+func respondGeoHits(c *gin.Context, ctx context.Context, limit int, locations []redis.GeoLocation, keep func(models.Property) bool) {
+	distances := make(map[string]float64, len(locations))
+	ids := make([]string, len(locations))
+	for i, location := range locations {
+		ids[i] = location.Name
+		distances[location.Name] = location.Dist
+	}
+
+	hits := []models.PropertyGeoHit{}
+	hasMore := false
+	for start := 0; start < len(ids) && !hasMore; start += indexScanBatch {
+		end := start + indexScanBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		properties, err := loadProperties(ctx, ids[start:end])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+				Success: false,
+				Message: "Failed to retrieve properties",
+				Error:   err.Error(),
+			})
+			return
+		}
+
+		for _, property := range properties {
+			if !keep(property) {
+				continue
+			}
+			if len(hits) == limit {
+				hasMore = true
+				break
+			}
+			hits = append(hits, models.PropertyGeoHit{Property: property, DistanceKm: distances[property.ID]})
+		}
+	}
+
+	c.JSON(http.StatusOK, models.PropertyResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d properties found", len(hits)),
+		Data:    hits,
+		Pagination: &models.Pagination{
+			Limit:   limit,
+			Sort:    geoDistanceSort,
+			HasMore: hasMore,
+		},
+	})
+}
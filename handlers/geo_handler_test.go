@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erea-api/models"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func geoSearchTest(t *testing.T, path string) []string {
+	t.Helper()
+	router := newTestRouter()
+	router.GET("/nearby", GetNearbyProperties)
+	router.GET("/within", GetPropertiesInBounds)
+	w := performJSON(t, router, http.MethodGet, path, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: status %d: %s", path, w.Code, w.Body.String())
+	}
+	var response struct {
+		Data []models.PropertyGeoHit `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, hit := range response.Data {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func seedGeoListings(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+	for _, listing := range []struct {
+		id, status string
+		lat, lng   float64
+	}{
+		{"gangnam", models.PropertyStatusActive, 37.4980, 127.0276},   // 강남역
+		{"yeoksam", models.PropertyStatusApproved, 37.5006, 127.0364}, // ~0.8km away
+		{"draft", models.PropertyStatusDraft, 37.4990, 127.0280},
+		{"jamsil", models.PropertyStatusActive, 37.5133, 127.1001}, // ~6.5km away
+	} {
+		lat, lng := listing.lat, listing.lng
+		property := models.Property{ID: listing.id, Type: "Apartment", Status: listing.status, Latitude: &lat, Longitude: &lng}
+		if err := saveProperty(ctx, nil, property); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGetNearbyPropertiesFindsPublicListingsWithinRadius(t *testing.T) {
+	newTestRedis(t)
+	seedGeoListings(t)
+
+	if got, want := geoSearchTest(t, "/nearby?lat=37.4980&lng=127.0276&radius_km=2"), []string{"gangnam", "yeoksam"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := geoSearchTest(t, "/nearby?lat=37.4980&lng=127.0276&radius_km=10"), []string{"gangnam", "yeoksam", "jamsil"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := geoSearchTest(t, "/nearby?lat=37.4980&lng=127.0276&radius_km=2&status="+models.PropertyStatusDraft), []string{"draft"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestGetPropertiesInBoundsFindsPublicListingsInsideTheBox(t *testing.T) {
+	newTestRedis(t)
+	seedGeoListings(t)
+
+	box := "/within?min_lat=37.49&min_lng=127.02&max_lat=37.51&max_lng=127.04"
+	got := geoSearchTest(t, box)
+	if len(got) != 2 || got[0] == "draft" || got[1] == "draft" {
+		t.Fatalf("expected gangnam and yeoksam, got %v", got)
+	}
+	if got, want := geoSearchTest(t, box+"&status="+models.PropertyStatusApproved), []string{"yeoksam"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := geoSearchTest(t, box+"&status="+models.PropertyStatusDraft), []string{"draft"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
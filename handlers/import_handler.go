@@ -0,0 +1,860 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"erea-api/config"
+	"erea-api/models"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/korean"
+)
+
+const (
+	importFeatureSeparator = ";"
+	importUnzipSizeFactor  = 10 // XLSX files may unzip to at most this many times MaxImportSize
+	importApprovalComment  = "Approved by bulk import"
+	exportSheetName        = "Properties"
+	xlsxContentType        = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	maxExcelSerialDate     = 2958466 // 10000-01-01
+)
+
+// importContentTypes are the sniffed content types accepted for imports,
+// with the format they are read as
+var importContentTypes = map[string]string{
+	"text/plain":      "csv",
+	"application/zip": "xlsx",
+}
+
+// requiredImportColumns must be present in the header row of an import
+// file. Column names match the JSON fields of CreatePropertyRequest.
+var requiredImportColumns = []string{"title", "location", "type", "area", "starting_price", "end_date", "owner_id"}
+
+// importTimeLayouts are the date formats accepted in import files, besides
+// Excel serial dates. Dates without a zone are read in server local time.
+var importTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006.01.02",
+	"2006/01/02",
+}
+
+// exportColumns are the columns of property exports: the property, its
+// auction and a column per attribute. Property and attribute columns match
+// the import columns, so an edited export can be imported again.
+var exportColumns = append([]string{
+	"id", "title", "location", "description", "type", "area", "starting_price", "current_price",
+	"image_url", "features", "status", "end_date", "owner_id", "latitude", "longitude", "created_at",
+	"auction_id", "auction_status", "auction_start_time", "auction_end_time", "min_increment",
+	"reserve_price", "current_highest", "bid_count", "winner_id", "winning_bid",
+}, models.AttributeNames()...)
+
+// exportAuctionColumns is the number of auction columns in exports
+const exportAuctionColumns = 10
+
+// createPropertyColumns maps CreatePropertyRequest field names to import
+// columns, for validation messages
+var createPropertyColumns = jsonFieldNames(reflect.TypeOf(models.CreatePropertyRequest{}))
+
+// sheetRow is one row of an import file with its spreadsheet row number
+type sheetRow struct {
+	number int
+	cells  []string
+}
+
+// importRecord is a data row of an import file keyed by column name
+type importRecord struct {
+	number int
+	values map[string]string
+}
+
+// propertyImport is a validated row ready to be stored
+type propertyImport struct {
+	row      int
+	property models.Property
+	auction  *models.Auction
+}
+
+// ImportProperties creates listings in bulk from a CSV or XLSX file in the
+// multipart field "file". Each row is validated like a create request;
+// rows with auction_end_time also get an active auction. Nothing is stored
+// unless every row is valid, and with dry_run=true nothing is stored at all.
+func ImportProperties(c *gin.Context) {
+	dryRun := false
+	if value := c.Query("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, models.PropertyResponse{
+				Success: false,
+				Message: "Invalid query parameters",
+				Error:   "dry_run must be true or false",
+			})
+			return
+		}
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.MaxImportSize+multipartOverhead)
+	file, err := c.FormFile("file")
+	var data []byte
+	var contentType string
+	if err == nil {
+		data, contentType, err = readUpload(file, config.MaxImportSize, importContentTypes)
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.Is(err, errFileTooLarge) || errors.As(err, &maxBytesErr) {
+		c.JSON(http.StatusRequestEntityTooLarge, models.PropertyResponse{
+			Success: false,
+			Message: "Invalid import file",
+			Error:   fmt.Sprintf("import files are limited to %d bytes", config.MaxImportSize),
+		})
+		return
+	}
+
+	var records []importRecord
+	if err == nil {
+		records, err = readImportRecords(data, importContentTypes[contentType])
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.PropertyResponse{
+			Success: false,
+			Message: "Invalid import file",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	result := models.PropertyImportResult{
+		DryRun:     dryRun,
+		Rows:       len(records),
+		Errors:     []models.ImportRowError{},
+		Properties: []models.ImportedProperty{},
+	}
+	imports := make([]propertyImport, 0, len(records))
+	for _, record := range records {
+		item, problems := parseImportRecord(record)
+		if len(problems) > 0 {
+			result.Errors = append(result.Errors, models.ImportRowError{Row: record.number, Errors: problems})
+			continue
+		}
+		imports = append(imports, item)
+		result.Properties = append(result.Properties, models.ImportedProperty{Row: item.row, Property: item.property, Auction: item.auction})
+	}
+	result.ValidRows = len(imports)
+
+	if dryRun {
+		message := "Dry run passed, no properties were created"
+		if len(result.Errors) > 0 {
+			message = fmt.Sprintf("Dry run found %d invalid rows, no properties were created", len(result.Errors))
+		}
+		c.JSON(http.StatusOK, models.PropertyResponse{
+			Success: true,
+			Message: message,
+			Data:    result,
+		})
+		return
+	}
+
+	if len(result.Errors) > 0 {
+		c.JSON(http.StatusBadRequest, models.PropertyResponse{
+			Success: false,
+			Message: "Import file has invalid rows, no properties were created",
+			Error:   fmt.Sprintf("%d of %d rows are invalid", len(result.Errors), result.Rows),
+			Data:    result,
+		})
+		return
+	}
+
+	ctx := config.GetContext()
+	actor := callerID(c)
+	for i, item := range imports {
+		if err := storePropertyImport(ctx, item, actor); err != nil {
+			result.Properties = result.Properties[:i]
+			c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+				Success: false,
+				Message: fmt.Sprintf("Failed to save row %d, earlier rows were created", item.row),
+				Error:   err.Error(),
+				Data:    result,
+			})
+			return
+		}
+
+		result.PropertiesCreated++
+		recordAudit(c, "property.import", "property", item.property.ID, nil, item.property)
+		if item.auction != nil {
+			result.AuctionsCreated++
+			recordAudit(c, "auction.create", "auction", item.auction.ID, nil, *item.auction)
+			notifySavedSearches(ctx, item.property)
+		}
+	}
+
+	c.JSON(http.StatusCreated, models.PropertyResponse{
+		Success: true,
+		Message: "Properties imported successfully",
+		Data:    result,
+	})
+}
+
+// ExportProperties downloads properties with their auction results, oldest
+// first, as CSV (the default) or XLSX with format=xlsx. Supports status,
+// type and owner_id filters.
+func ExportProperties(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, models.PropertyResponse{
+			Success: false,
+			Message: "Invalid query parameters",
+			Error:   "format must be csv or xlsx",
+		})
+		return
+	}
+
+	status := c.Query("status")
+	propertyType := c.Query("type")
+	ownerID := c.Query("owner_id")
+	rows, err := exportPropertyRows(config.GetContext(), func(property models.Property) bool {
+		return (status == "" || property.Status == status) &&
+			(propertyType == "" || property.Type == propertyType) &&
+			(ownerID == "" || property.OwnerID == ownerID)
+	})
+
+	var data []byte
+	contentType := "text/csv; charset=utf-8"
+	if err == nil && format == "xlsx" {
+		data, err = encodeXLSX(rows)
+		contentType = xlsxContentType
+	} else if err == nil {
+		data, err = encodeCSV(rows)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+			Success: false,
+			Message: "Failed to export properties",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	recordAudit(c, "property.export", "property", "", nil, gin.H{
+		"format":  format,
+		"rows":    len(rows),
+		"filters": gin.H{"status": status, "type": propertyType, "owner_id": ownerID},
+	})
+
+	filename := "properties-" + time.Now().Format("20060102-150405") + "." + format
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// readImportRecords reads the data rows of an import file. The first
+// non-blank row is the header; blank rows are skipped.
+func readImportRecords(data []byte, format string) ([]importRecord, error) {
+	var rows []sheetRow
+	var err error
+	if format == "xlsx" {
+		rows, err = readXLSXRows(data)
+	} else {
+		rows, err = readCSVRows(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var columns []string
+	var records []importRecord
+	for _, row := range rows {
+		if isBlankRow(row.cells) {
+			continue
+		}
+
+		if columns == nil {
+			columns, err = importHeader(row.cells)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if int64(len(records)) >= config.MaxImportRows {
+			return nil, fmt.Errorf("import files are limited to %d rows", config.MaxImportRows)
+		}
+		values := make(map[string]string, len(columns))
+		for i, column := range columns {
+			if i < len(row.cells) && column != "" {
+				values[column] = unescapeImportCell(strings.TrimSpace(row.cells[i]))
+			}
+		}
+		records = append(records, importRecord{number: row.number, values: values})
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("file has no data rows")
+	}
+	return records, nil
+}
+
+// importHeader normalizes the header row and checks the required columns
+// are present
+func importHeader(cells []string) ([]string, error) {
+	columns := make([]string, len(cells))
+	seen := make(map[string]bool, len(cells))
+	for i, cell := range cells {
+		column := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(cell, "\ufeff")))
+		column = strings.NewReplacer(" ", "_", "-", "_").Replace(column)
+		if column != "" && seen[column] {
+			return nil, fmt.Errorf("duplicate column %q", column)
+		}
+		seen[column] = true
+		columns[i] = column
+	}
+
+	var missing []string
+	for _, column := range requiredImportColumns {
+		if !seen[column] {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required columns: %s", strings.Join(missing, ", "))
+	}
+	return columns, nil
+}
+
+// readCSVRows reads a CSV file. Files that aren't valid UTF-8 are read as
+// EUC-KR, which Excel uses when saving CSV on Korean Windows.
+func readCSVRows(data []byte) ([]sheetRow, error) {
+	if !utf8.Valid(data) {
+		decoded, err := korean.EUCKR.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("file is neither UTF-8 nor EUC-KR text")
+		}
+		data = decoded
+	}
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rows []sheetRow
+	for {
+		cells, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CSV: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, sheetRow{number: line, cells: cells})
+	}
+}
+
+// readXLSXRows reads the active sheet of an XLSX workbook with raw cell
+// values, so numbers keep full precision and dates are serial numbers
+func readXLSXRows(data []byte) ([]sheetRow, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(data), excelize.Options{
+		UnzipSizeLimit: config.MaxImportSize * importUnzipSizeFactor,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read XLSX: %v", err)
+	}
+	defer f.Close()
+
+	cells, err := f.GetRows(f.GetSheetName(f.GetActiveSheetIndex()), excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read XLSX: %v", err)
+	}
+
+	rows := make([]sheetRow, len(cells))
+	for i := range cells {
+		rows[i] = sheetRow{number: i + 1, cells: cells[i]}
+	}
+	return rows, nil
+}
+
+// parseImportRecord turns a data row into a listing, applying the same
+// rules as CreateProperty, and an auction when auction_end_time is set.
+// It returns every problem found in the row.
+func parseImportRecord(record importRecord) (propertyImport, []string) {
+	p := importRowParser{values: record.values, failed: map[string]bool{}}
+	req := models.CreatePropertyRequest{
+		Title:         p.values["title"],
+		Location:      p.values["location"],
+		Description:   p.values["description"],
+		Type:          p.values["type"],
+		Area:          p.float("area"),
+		StartingPrice: p.int64("starting_price"),
+		ImageURL:      p.values["image_url"],
+		Features:      p.list("features"),
+		EndDate:       p.time("end_date"),
+		OwnerID:       p.values["owner_id"],
+		Latitude:      p.optionalFloat("latitude"),
+		Longitude:     p.optionalFloat("longitude"),
+		Submit:        p.bool("submit"),
+	}
+	req.Attributes = p.attributes(req.Type)
+
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		p.validationProblems(err)
+	}
+	if _, ok := models.PropertyTypeSchemaOf(req.Type); ok {
+		attributes, problems := models.ValidateAttributes(req.Type, req.Attributes)
+		for _, problem := range problems {
+			// Name attributes by their column, and don't report unreadable
+			// values as missing
+			problem = strings.TrimPrefix(problem, "attributes.")
+			if name, _, _ := strings.Cut(problem, " "); !p.failed[name] {
+				p.problems = append(p.problems, problem)
+			}
+		}
+		req.Attributes = attributes
+	}
+	if err := validateCoordinates(req.Latitude, req.Longitude); err != nil && !p.failed["latitude"] && !p.failed["longitude"] {
+		p.problems = append(p.problems, err.Error())
+	}
+
+	auctionEnd := p.time("auction_end_time")
+	minIncrement := p.int64("min_increment")
+	reservePrice := p.int64("reserve_price")
+	hasAuction := p.values["auction_end_time"] != ""
+	switch {
+	case !hasAuction && (p.values["min_increment"] != "" || p.values["reserve_price"] != ""):
+		p.problems = append(p.problems, "auction_end_time is required for an auction")
+	case hasAuction && !p.failed["auction_end_time"] && !auctionEnd.After(time.Now()):
+		p.problems = append(p.problems, "auction_end_time must be in the future")
+	}
+	if minIncrement < 0 {
+		p.problems = append(p.problems, "min_increment must be at least 0")
+	}
+	if reservePrice < 0 {
+		p.problems = append(p.problems, "reserve_price must be at least 0")
+	}
+
+	if len(p.problems) > 0 {
+		return propertyImport{}, p.problems
+	}
+
+	item := propertyImport{row: record.number, property: newProperty(req)}
+	if hasAuction {
+		// Imported auctions skip the review queue: the listing is approved
+		// by the importing admin and goes live with its auction
+		now := time.Now()
+		item.property.Status = models.PropertyStatusActive
+		item.property.SubmittedAt = &now
+		item.property.ApprovedAt = &now
+		item.property.ReviewComment = importApprovalComment
+		item.auction = &models.Auction{
+			ID:             uuid.New().String(),
+			PropertyID:     item.property.ID,
+			Status:         activeAuctionStatus,
+			StartTime:      now,
+			EndTime:        auctionEnd,
+			MinIncrement:   minIncrement,
+			ReservePrice:   reservePrice,
+			CurrentHighest: item.property.StartingPrice,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+	}
+	return item, nil
+}
+
+// storePropertyImport stores an imported listing with its first version,
+// review history and auction in one transaction
+func storePropertyImport(ctx context.Context, item propertyImport, actor string) error {
+	_, err := config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if err := writeNewProperty(ctx, pipe, item.property, actor); err != nil {
+			return err
+		}
+		if item.auction == nil {
+			return nil
+		}
+		review := newPropertyReview(item.property.ID, models.ReviewActionApprove, importApprovalComment, actor, models.PropertyStatusDraft, models.PropertyStatusApproved)
+		if err := appendPropertyReview(ctx, pipe, review); err != nil {
+			return err
+		}
+		return writeAuction(ctx, pipe, *item.auction)
+	})
+	return err
+}
+
+// importRowParser reads typed values from an import row, collecting a
+// problem for each value that can't be read
+type importRowParser struct {
+	values   map[string]string
+	problems []string
+	failed   map[string]bool // Columns with unreadable values
+}
+
+func (p *importRowParser) fail(column, format string, value string) {
+	p.problems = append(p.problems, fmt.Sprintf("%s: "+format, column, value))
+	p.failed[column] = true
+}
+
+func (p *importRowParser) float(column string) float64 {
+	if n := p.optionalFloat(column); n != nil {
+		return *n
+	}
+	return 0
+}
+
+func (p *importRowParser) optionalFloat(column string) *float64 {
+	value := strings.ReplaceAll(p.values[column], ",", "")
+	if value == "" {
+		return nil
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		p.fail(column, "invalid number %q", p.values[column])
+		return nil
+	}
+	return &n
+}
+
+func (p *importRowParser) int64(column string) int64 {
+	value := strings.ReplaceAll(p.values[column], ",", "")
+	if value == "" {
+		return 0
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n
+	}
+	// XLSX cells may hold whole numbers in float notation
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n != math.Trunc(n) || math.Abs(n) > 1<<53 {
+		p.fail(column, "invalid whole number %q", p.values[column])
+		return 0
+	}
+	return int64(n)
+}
+
+func (p *importRowParser) bool(column string) bool {
+	value := p.values[column]
+	if value == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		p.fail(column, "invalid boolean %q, expected true or false", value)
+	}
+	return b
+}
+
+func (p *importRowParser) list(column string) []string {
+	var items []string
+	for _, item := range strings.Split(p.values[column], importFeatureSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (p *importRowParser) time(column string) time.Time {
+	value := p.values[column]
+	if value == "" {
+		return time.Time{}
+	}
+
+	// Excel serial date, as read from XLSX cells formatted as dates
+	if serial, err := strconv.ParseFloat(value, 64); err == nil && serial > 0 && serial < maxExcelSerialDate {
+		if t, err := excelize.ExcelDateToTime(serial, false); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+		}
+	}
+	for _, layout := range importTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t
+		}
+	}
+	p.fail(column, "invalid date %q, expected YYYY-MM-DD or RFC 3339", value)
+	return time.Time{}
+}
+
+// attributes reads the columns named after the attributes of propertyType
+func (p *importRowParser) attributes(propertyType string) map[string]interface{} {
+	schema, ok := models.PropertyTypeSchemaOf(propertyType)
+	if !ok {
+		return nil
+	}
+
+	attributes := make(map[string]interface{})
+	for _, spec := range schema.Attributes {
+		text := p.values[spec.Name]
+		if text == "" {
+			continue
+		}
+		value, err := spec.ParseValue(text)
+		if err != nil {
+			p.problems = append(p.problems, fmt.Sprintf("%s: %q %v", spec.Name, text, err))
+			p.failed[spec.Name] = true
+			continue
+		}
+		attributes[spec.Name] = value
+	}
+	return attributes
+}
+
+// validationProblems describes binding validation errors by import column,
+// skipping columns whose values already failed to parse
+func (p *importRowParser) validationProblems(err error) {
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		p.problems = append(p.problems, err.Error())
+		return
+	}
+
+	for _, fieldError := range fieldErrors {
+		column := createPropertyColumns[fieldError.StructField()]
+		if p.failed[column] {
+			continue
+		}
+		switch fieldError.Tag() {
+		case "required":
+			p.problems = append(p.problems, column+" is required")
+		case "min", "gte":
+			p.problems = append(p.problems, column+" must be at least "+fieldError.Param())
+		case "max", "lte":
+			p.problems = append(p.problems, column+" must be at most "+fieldError.Param())
+		case "oneof":
+			p.problems = append(p.problems, column+" must be one of: "+strings.ReplaceAll(fieldError.Param(), " ", ", "))
+		default:
+			p.problems = append(p.problems, column+" is invalid")
+		}
+	}
+}
+
+// exportPropertyRows builds the export table: a header row, then each kept
+// property with its auction, oldest first
+func exportPropertyRows(ctx context.Context, keep func(models.Property) bool) ([][]interface{}, error) {
+	rdb := config.GetRedisClient()
+	header := make([]interface{}, len(exportColumns))
+	for i, column := range exportColumns {
+		header[i] = column
+	}
+	rows := [][]interface{}{header}
+
+	for start := int64(0); ; start += indexScanBatch {
+		ids, err := rdb.ZRange(ctx, propertiesByCreatedKey, start, start+indexScanBatch-1).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return rows, nil
+		}
+
+		properties, err := loadProperties(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		kept := properties[:0]
+		for _, property := range properties {
+			if keep(property) {
+				kept = append(kept, property)
+			}
+		}
+
+		auctions, err := loadPropertyAuctions(ctx, kept)
+		if err != nil {
+			return nil, err
+		}
+		for _, property := range kept {
+			rows = append(rows, exportPropertyRow(property, auctions[property.ID]))
+		}
+	}
+}
+
+// loadPropertyAuctions loads the current auction of each property that has
+// one, keyed by property ID
+func loadPropertyAuctions(ctx context.Context, properties []models.Property) (map[string]models.Auction, error) {
+	byProperty := make(map[string]models.Auction, len(properties))
+	if len(properties) == 0 {
+		return byProperty, nil
+	}
+
+	keys := make([]string, len(properties))
+	for i, property := range properties {
+		keys[i] = "property_auction:" + property.ID
+	}
+	values, err := config.GetRedisClient().MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var auctionIDs []string
+	for _, value := range values {
+		if auctionID, ok := value.(string); ok {
+			auctionIDs = append(auctionIDs, auctionID)
+		}
+	}
+	auctions, err := loadAuctions(ctx, auctionIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, auction := range auctions {
+		byProperty[auction.PropertyID] = auction
+	}
+	return byProperty, nil
+}
+
+// exportPropertyRow lays out a property, its auction and its attributes in
+// exportColumns order. Missing values are nil.
+func exportPropertyRow(property models.Property, auction models.Auction) []interface{} {
+	row := []interface{}{
+		property.ID, property.Title, property.Location, property.Description, property.Type,
+		property.Area, property.StartingPrice, property.CurrentPrice, property.ImageURL,
+		strings.Join(property.Features, importFeatureSeparator+" "), property.Status,
+		exportTime(property.EndDate), property.OwnerID, exportFloat(property.Latitude),
+		exportFloat(property.Longitude), exportTime(property.CreatedAt),
+	}
+	if auction.ID == "" {
+		row = append(row, make([]interface{}, exportAuctionColumns)...)
+	} else {
+		var winnerID, winningBid interface{}
+		if auction.WinnerID != "" {
+			winnerID, winningBid = auction.WinnerID, auction.WinningBid
+		}
+		row = append(row,
+			auction.ID, auction.Status, exportTime(auction.StartTime), exportTime(auction.EndTime),
+			auction.MinIncrement, auction.ReservePrice, auction.CurrentHighest, auction.BidCount,
+			winnerID, winningBid,
+		)
+	}
+
+	for _, name := range models.AttributeNames() {
+		row = append(row, property.Attributes[name])
+	}
+	return row
+}
+
+func exportTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Format(time.RFC3339)
+}
+
+func exportFloat(n *float64) interface{} {
+	if n == nil {
+		return nil
+	}
+	return *n
+}
+
+// encodeCSV writes rows as UTF-8 CSV with a byte order mark, so Excel
+// shows Korean text correctly
+func encodeCSV(rows [][]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff")
+	writer := csv.NewWriter(&buf)
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			switch v := value.(type) {
+			case nil:
+			case string:
+				record[i] = escapeCSVCell(v)
+			case float64:
+				record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// encodeXLSX writes rows to a single-sheet workbook
+func encodeXLSX(rows [][]interface{}) ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := f.SetSheetName("Sheet1", exportSheetName); err != nil {
+		return nil, err
+	}
+
+	stream, err := f.NewStreamWriter(exportSheetName)
+	if err != nil {
+		return nil, err
+	}
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return nil, err
+		}
+		if err := stream.SetRow(cell, row); err != nil {
+			return nil, err
+		}
+	}
+	if err := stream.Flush(); err != nil {
+		return nil, err
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// escapeCSVCell prefixes text that spreadsheet programs would run as a
+// formula with an apostrophe; unescapeImportCell reverses it on import
+func escapeCSVCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func unescapeImportCell(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune("=+-@", rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// isBlankRow reports whether every cell of a row is empty
+func isBlankRow(cells []string) bool {
+	for _, cell := range cells {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonFieldNames maps the field names of a struct type to their JSON names
+func jsonFieldNames(t reflect.Type) map[string]string {
+	names := make(map[string]string, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name = field.Name
+		}
+		names[field.Name] = name
+	}
+	return names
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"erea-api/models"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func importTestCSV(t *testing.T, query string, rows ...string) (int, models.PropertyImportResult) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "listings.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte(strings.Join(rows, "\n") + "\n"))
+	form.Close()
+
+	router := newTestRouter()
+	router.POST("/import", ImportProperties)
+	req := httptest.NewRequest(http.MethodPost, "/import?"+query, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response struct {
+		Data models.PropertyImportResult `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	return w.Code, response.Data
+}
+
+func TestImportDryRunAndRowErrors(t *testing.T) {
+	mr := newTestRedis(t)
+	endDate := time.Now().Add(48 * time.Hour).Format("2006-01-02")
+	auctionEnd := time.Now().Add(24 * time.Hour).Format(time.RFC3339)
+	header := "title,location,type,area,starting_price,end_date,owner_id,auction_end_time,zoning,rooms"
+	valid := "Gangnam apartment,\"Gangnam-gu, Seoul\",Apartment,84.5,\"900,000,000\"," + endDate + ",owner," + auctionEnd + ",,3"
+	badNumbers := "Mapo officetel,\"Mapo-gu, Seoul\",Officetel,abc,100," + endDate + ",,,,"
+	missingZoning := "Hongdae shop,\"Mapo-gu, Seoul\",Commercial,30,100," + endDate + ",owner,,,"
+
+	code, result := importTestCSV(t, "dry_run=true", header, valid, badNumbers, missingZoning)
+	if code != http.StatusOK || !result.DryRun || result.Rows != 3 || result.ValidRows != 1 {
+		t.Fatalf("unexpected dry run result %d %+v", code, result)
+	}
+	if len(result.Errors) != 2 || result.Errors[0].Row != 3 || result.Errors[1].Row != 4 {
+		t.Fatalf("expected errors on rows 3 and 4, got %+v", result.Errors)
+	}
+	problems := strings.Join(result.Errors[0].Errors, "; ")
+	if !strings.Contains(problems, "area") || !strings.Contains(problems, "owner_id") {
+		t.Fatalf("expected row 3 to report area and owner_id, got %q", problems)
+	}
+	if !strings.Contains(strings.Join(result.Errors[1].Errors, "; "), "zoning") {
+		t.Fatalf("expected row 4 to report zoning, got %v", result.Errors[1].Errors)
+	}
+	if len(result.Properties) != 1 || result.Properties[0].Auction == nil {
+		t.Fatalf("expected a preview of the valid row with its auction, got %+v", result.Properties)
+	}
+	if len(mr.Keys()) != 0 {
+		t.Fatalf("the dry run stored %v", mr.Keys())
+	}
+
+	// A file with invalid rows stores nothing
+	if code, _ := importTestCSV(t, "", header, valid, badNumbers); code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid file to be refused, got %d", code)
+	}
+	if members, _ := mr.SMembers(propertiesIndexKey); len(members) != 0 {
+		t.Fatalf("an invalid file created %v", members)
+	}
+
+	code, result = importTestCSV(t, "", header, valid)
+	if code != http.StatusCreated || result.PropertiesCreated != 1 || result.AuctionsCreated != 1 {
+		t.Fatalf("unexpected import result %d %+v", code, result)
+	}
+	imported := result.Properties[0].Property
+	if imported.Status != models.PropertyStatusActive || imported.StartingPrice != 900000000 {
+		t.Fatalf("unexpected imported listing %+v", imported)
+	}
+	if ok, _ := mr.SIsMember(activeAuctionsKey, result.Properties[0].Auction.ID); !ok {
+		t.Fatal("the imported auction isn't active")
+	}
+}
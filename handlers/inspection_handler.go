@@ -0,0 +1,1074 @@
+package handlers
+
+import (
+	"context"
+	"erea-api/config"
+	"erea-api/middleware"
+	"erea-api/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	inspectionSlotKeyPrefix    = "inspection_slot:"
+	inspectionBookingKeyPrefix = "inspection_booking:"
+	inspectionSlotsByStartKey  = "inspection_slots_by_start" // Sorted set of open slot IDs by start time
+	inspectionMaxRetries       = 5
+)
+
+// propertyInspectionSlotsKey is the sorted set of a property's slot IDs by
+// start time
+func propertyInspectionSlotsKey(propertyID string) string {
+	return "property_inspection_slots:" + propertyID
+}
+
+// inspectionSlotBookingsKey is the sorted set of a slot's booking IDs,
+// cancelled ones included, by booking time
+func inspectionSlotBookingsKey(slotID string) string {
+	return "inspection_slot_bookings:" + slotID
+}
+
+// userInspectionsKey is the sorted set of a user's booking IDs by the start
+// time of the booked slot
+func userInspectionsKey(userID string) string {
+	return "user_inspections:" + userID
+}
+
+// inspectionReminderKey marks a slot whose bookers were reminded
+func inspectionReminderKey(slotID string) string {
+	return "inspection_reminder:" + slotID
+}
+
+// inspectionConflictError is returned when a slot or booking clashes with
+// the current schedule
+type inspectionConflictError struct {
+	reason string
+}
+
+func (e *inspectionConflictError) Error() string {
+	return e.reason
+}
+
+// CreateInspectionSlot publishes a time the property can be inspected.
+// Slots of one property may not overlap. Only the owner's API key and
+// admins may publish slots.
+func CreateInspectionSlot(c *gin.Context) {
+	var req models.CreateInspectionSlotRequest
+	err := c.ShouldBindJSON(&req)
+	if err == nil {
+		err = validateInspectionTimes(req.StartTime, req.EndTime, time.Now())
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.InspectionResponse{
+			Success: false,
+			Message: "Invalid request data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx := config.GetContext()
+	properties, err := loadProperties(ctx, []string{c.Param("id")})
+	if err == nil && len(properties) == 0 {
+		c.JSON(http.StatusNotFound, models.InspectionResponse{
+			Success: false,
+			Message: "Property not found",
+		})
+		return
+	}
+	if err == nil && !authorizeInspectionOwner(c, properties[0]) {
+		return
+	}
+	if err == nil && !acceptsInspections(properties[0]) {
+		c.JSON(http.StatusConflict, models.InspectionResponse{
+			Success: false,
+			Message: "Inspections cannot be scheduled for this listing",
+			Error:   fmt.Sprintf("listing is %s", properties[0].Status),
+		})
+		return
+	}
+
+	now := time.Now()
+	slot := models.InspectionSlot{
+		ID:         uuid.New().String(),
+		PropertyID: c.Param("id"),
+		StartTime:  req.StartTime,
+		EndTime:    req.EndTime,
+		Capacity:   req.Capacity,
+		Note:       req.Note,
+		Status:     models.InspectionSlotOpen,
+		CreatedBy:  callerID(c),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err == nil {
+		err = createInspectionSlot(ctx, slot)
+	}
+
+	var conflictErr *inspectionConflictError
+	switch {
+	case errors.As(err, &conflictErr):
+		c.JSON(http.StatusConflict, models.InspectionResponse{
+			Success: false,
+			Message: "Inspection slot overlaps another slot",
+			Error:   err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.InspectionResponse{
+			Success: false,
+			Message: "Failed to create inspection slot",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	recordAudit(c, "inspection_slot.create", "inspection_slot", slot.ID, nil, slot)
+
+	c.JSON(http.StatusCreated, models.InspectionResponse{
+		Success: true,
+		Message: "Inspection slot created successfully",
+		Data:    slot,
+	})
+}
+
+// GetInspectionSlots lists a property's open inspection slots that haven't
+// ended, earliest first. include_past=true adds past and cancelled slots.
+func GetInspectionSlots(c *gin.Context) {
+	ctx := config.GetContext()
+	properties, err := loadProperties(ctx, []string{c.Param("id")})
+	if err == nil && len(properties) == 0 {
+		c.JSON(http.StatusNotFound, models.InspectionResponse{
+			Success: false,
+			Message: "Property not found",
+		})
+		return
+	}
+
+	var slots []models.InspectionSlot
+	if err == nil {
+		slots, err = loadPropertyInspectionSlots(ctx, c.Param("id"), c.Query("include_past") != "true")
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.InspectionResponse{
+			Success: false,
+			Message: "Failed to retrieve inspection slots",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.InspectionResponse{
+		Success: true,
+		Message: "Inspection slots retrieved successfully",
+		Data:    slots,
+	})
+}
+
+// CancelInspectionSlot cancels an inspection slot together with its
+// bookings, notifying the users who booked it. Only the owner's API key and
+// admins may cancel slots.
+func CancelInspectionSlot(c *gin.Context) {
+	ctx := config.GetContext()
+	properties, err := loadProperties(ctx, []string{c.Param("id")})
+	if err == nil && len(properties) == 0 {
+		c.JSON(http.StatusNotFound, models.InspectionResponse{
+			Success: false,
+			Message: "Property not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.InspectionResponse{
+			Success: false,
+			Message: "Failed to cancel inspection slot",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if !authorizeInspectionOwner(c, properties[0]) {
+		return
+	}
+
+	before, slot, cancelled, err := cancelInspectionSlot(ctx, properties[0].ID, c.Param("slotId"))
+
+	var conflictErr *inspectionConflictError
+	switch {
+	case isNotFound(err):
+		c.JSON(http.StatusNotFound, models.InspectionResponse{
+			Success: false,
+			Message: "Inspection slot not found",
+		})
+		return
+	case errors.As(err, &conflictErr):
+		c.JSON(http.StatusConflict, models.InspectionResponse{
+			Success: false,
+			Message: "Inspection slot cannot be cancelled",
+			Error:   err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.InspectionResponse{
+			Success: false,
+			Message: "Failed to cancel inspection slot",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	recordAudit(c, "inspection_slot.cancel", "inspection_slot", slot.ID, before, slot)
+
+	title := strconv.Quote(properties[0].Title)
+	for _, booking := range cancelled {
+		notifyUser(ctx, models.Notification{
+			UserID:     booking.UserID,
+			Type:       models.NotificationInspectionCancelled,
+			Title:      fmt.Sprintf("Inspection of %s was cancelled", title),
+			Message:    fmt.Sprintf("The seller cancelled the inspection on %s. Please book another time.", slot.StartTime.Format(noticeTimeLayout)),
+			EntityType: "inspection_slot",
+			EntityID:   slot.ID,
+		})
+	}
+
+	c.JSON(http.StatusOK, models.InspectionResponse{
+		Success: true,
+		Message: "Inspection slot cancelled successfully",
+		Data:    slot,
+	})
+}
+
+// GetInspectionBookings is the owner's view of who booked each of a
+// property's open inspection slots. Bookings carry user contact details,
+// so only the owner's API key and admins may see them.
+func GetInspectionBookings(c *gin.Context) {
+	ctx := config.GetContext()
+	properties, err := loadProperties(ctx, []string{c.Param("id")})
+	if err == nil && len(properties) == 0 {
+		c.JSON(http.StatusNotFound, models.InspectionResponse{
+			Success: false,
+			Message: "Property not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.InspectionResponse{
+			Success: false,
+			Message: "Failed to retrieve inspection bookings",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if !authorizeInspectionOwner(c, properties[0]) {
+		return
+	}
+
+	slots, err := loadPropertyInspectionSlots(ctx, properties[0].ID, c.Query("include_past") != "true")
+	var schedule []models.InspectionSlotBookings
+	if err == nil {
+		schedule, err = loadInspectionSchedule(ctx, slots)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.InspectionResponse{
+			Success: false,
+			Message: "Failed to retrieve inspection bookings",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.InspectionResponse{
+		Success: true,
+		Message: "Inspection bookings retrieved successfully",
+		Data:    schedule,
+	})
+}
+
+// BookInspection books a place in an inspection slot for a user. A user
+// can't book a full or started slot, or two slots that overlap.
+func BookInspection(c *gin.Context) {
+	var req models.BookInspectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.InspectionResponse{
+			Success: false,
+			Message: "Invalid request data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// A key acting for a user books only for that user
+	if actsForOtherUser(c, req.UserID) {
+		c.JSON(http.StatusForbidden, models.InspectionResponse{
+			Success: false,
+			Message: "Access denied",
+			Error:   "user_id must be the user the API key acts for",
+		})
+		return
+	}
+
+	ctx := config.GetContext()
+	users, err := loadUsers(ctx, []string{req.UserID})
+	var properties []models.Property
+	if err == nil {
+		properties, err = loadProperties(ctx, []string{c.Param("id")})
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.InspectionResponse{
+			Success: false,
+			Message: "Failed to book inspection",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if len(users) == 0 || len(properties) == 0 {
+		message := "User not found"
+		if len(properties) == 0 {
+			message = "Property not found"
+		}
+		c.JSON(http.StatusNotFound, models.InspectionResponse{
+			Success: false,
+			Message: message,
+		})
+		return
+	}
+	property := properties[0]
+	if property.OwnerID == req.UserID {
+		c.JSON(http.StatusConflict, models.InspectionResponse{
+			Success: false,
+			Message: "Owners cannot book inspections of their own property",
+		})
+		return
+	}
+
+	slot, booking, err := bookInspection(ctx, property.ID, c.Param("slotId"), req.UserID, time.Now())
+
+	var conflictErr *inspectionConflictError
+	switch {
+	case isNotFound(err):
+		c.JSON(http.StatusNotFound, models.InspectionResponse{
+			Success: false,
+			Message: "Inspection slot not found",
+		})
+		return
+	case errors.As(err, &conflictErr):
+		c.JSON(http.StatusConflict, models.InspectionResponse{
+			Success: false,
+			Message: "Inspection slot cannot be booked",
+			Error:   err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.InspectionResponse{
+			Success: false,
+			Message: "Failed to book inspection",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	recordAudit(c, "inspection.book", "inspection_booking", booking.ID, nil, booking)
+
+	notifyUser(ctx, models.Notification{
+		UserID:     property.OwnerID,
+		Type:       models.NotificationInspectionBooked,
+		Title:      fmt.Sprintf("New inspection booking for %q", property.Title),
+		Message:    fmt.Sprintf("%s booked the inspection on %s (%d of %d places taken)", users[0].Name, slot.StartTime.Format(noticeTimeLayout), slot.Booked, slot.Capacity),
+		EntityType: "inspection_slot",
+		EntityID:   slot.ID,
+	})
+
+	c.JSON(http.StatusCreated, models.InspectionResponse{
+		Success: true,
+		Message: "Inspection booked successfully",
+		Data:    booking,
+	})
+}
+
+// GetUserInspections retrieves a user's inspection bookings by slot start
+// time. Supports status and upcoming=true filters and cursor pagination.
+func GetUserInspections(c *gin.Context) {
+	q, err := parseListQuery(c, listSortKeys{"start_time": userInspectionsKey(c.Param("id"))}, "start_time")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.InspectionResponse{
+			Success: false,
+			Message: "Invalid query parameters",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	now := time.Now()
+	upcoming := c.Query("upcoming") == "true"
+	if upcoming {
+		from := float64(now.UnixNano())
+		q.narrow("start_time", &from, nil)
+	}
+
+	status := c.Query("status")
+	bookings, page, err := paginate(config.GetContext(), q, loadInspectionBookings,
+		func(booking models.InspectionBooking) string { return booking.ID },
+		func(booking models.InspectionBooking) bool {
+			return (status == "" || booking.Status == status) &&
+				(!upcoming || booking.StartTime.After(now))
+		})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.InspectionResponse{
+			Success: false,
+			Message: "Failed to retrieve inspection bookings",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.InspectionResponse{
+		Success:    true,
+		Message:    "Inspection bookings retrieved successfully",
+		Data:       bookings,
+		Pagination: page,
+	})
+}
+
+// CancelInspectionBooking cancels one of a user's bookings before the slot
+// starts, freeing the place and notifying the property owner. A key acting
+// for a user cancels only that user's bookings.
+func CancelInspectionBooking(c *gin.Context) {
+	if actsForOtherUser(c, c.Param("id")) {
+		c.JSON(http.StatusForbidden, models.InspectionResponse{
+			Success: false,
+			Message: "Access denied",
+			Error:   "requires a key acting for the user or the " + models.ScopeAdmin + " scope",
+		})
+		return
+	}
+
+	ctx := config.GetContext()
+	before, booking, err := cancelInspectionBooking(ctx, c.Param("id"), c.Param("bookingId"), time.Now())
+
+	var conflictErr *inspectionConflictError
+	switch {
+	case isNotFound(err):
+		c.JSON(http.StatusNotFound, models.InspectionResponse{
+			Success: false,
+			Message: "Inspection booking not found",
+		})
+		return
+	case errors.As(err, &conflictErr):
+		c.JSON(http.StatusConflict, models.InspectionResponse{
+			Success: false,
+			Message: "Inspection booking cannot be cancelled",
+			Error:   err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.InspectionResponse{
+			Success: false,
+			Message: "Failed to cancel inspection booking",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	recordAudit(c, "inspection.cancel", "inspection_booking", booking.ID, before, booking)
+
+	if properties, err := loadProperties(ctx, []string{booking.PropertyID}); err == nil && len(properties) > 0 {
+		notifyUser(ctx, models.Notification{
+			UserID:     properties[0].OwnerID,
+			Type:       models.NotificationInspectionCancelled,
+			Title:      fmt.Sprintf("Inspection booking cancelled for %q", properties[0].Title),
+			Message:    fmt.Sprintf("A booking for the inspection on %s was cancelled", booking.StartTime.Format(noticeTimeLayout)),
+			EntityType: "inspection_slot",
+			EntityID:   booking.SlotID,
+		})
+	}
+
+	c.JSON(http.StatusOK, models.InspectionResponse{
+		Success: true,
+		Message: "Inspection booking cancelled successfully",
+		Data:    booking,
+	})
+}
+
+// authorizeInspectionOwner checks that the caller may manage the property's
+// inspections: the owner's API key or an admin. It writes the error
+// response when not.
+func authorizeInspectionOwner(c *gin.Context, property models.Property) bool {
+	principal, ok := middleware.GetPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.InspectionResponse{
+			Success: false,
+			Message: "Authentication required",
+			Error:   "managing inspections requires an API key",
+		})
+		return false
+	}
+	if !actsForPropertyOwner(principal, property) {
+		c.JSON(http.StatusForbidden, models.InspectionResponse{
+			Success: false,
+			Message: "Access denied",
+			Error:   "requires the property owner's key or the " + models.ScopeAdmin + " scope",
+		})
+		return false
+	}
+	return true
+}
+
+// validateInspectionTimes checks a slot's time range
+func validateInspectionTimes(start, end, now time.Time) error {
+	switch {
+	case !end.After(start):
+		return fmt.Errorf("end_time must be after start_time")
+	case !start.After(now):
+		return fmt.Errorf("start_time must be in the future")
+	case end.Sub(start) > config.MaxInspectionDuration:
+		return fmt.Errorf("an inspection slot can last at most %s", config.MaxInspectionDuration)
+	}
+	return nil
+}
+
+// acceptsInspections reports whether a listing may get inspection slots.
+// Rejected and closed listings can't.
+func acceptsInspections(property models.Property) bool {
+	return property.Status != models.PropertyStatusRejected && property.Status != models.PropertyStatusClosed
+}
+
+// createInspectionSlot stores a new slot unless it overlaps an open slot of
+// the same property. The property's slots are watched, so two overlapping
+// slots can't be created concurrently.
+func createInspectionSlot(ctx context.Context, slot models.InspectionSlot) error {
+	rdb := config.GetRedisClient()
+	slotsKey := propertyInspectionSlotsKey(slot.PropertyID)
+
+	for attempt := 0; attempt < inspectionMaxRetries; attempt++ {
+		err := rdb.Watch(ctx, func(tx *redis.Tx) error {
+			ids, err := tx.ZRange(ctx, slotsKey, 0, -1).Result()
+			if err != nil {
+				return err
+			}
+			existing, err := loadInspectionSlots(ctx, ids)
+			if err != nil {
+				return err
+			}
+			for _, other := range existing {
+				if other.Status == models.InspectionSlotOpen && other.Overlaps(slot.StartTime, slot.EndTime) {
+					return &inspectionConflictError{reason: fmt.Sprintf("overlaps slot %s from %s to %s", other.ID,
+						other.StartTime.Format(time.RFC3339), other.EndTime.Format(time.RFC3339))}
+				}
+			}
+
+			slotJSON, err := slot.ToJSON()
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(ctx, inspectionSlotKeyPrefix+slot.ID, slotJSON, 0)
+				pipe.ZAdd(ctx, slotsKey, &redis.Z{Score: float64(slot.StartTime.UnixNano()), Member: slot.ID})
+				pipe.ZAdd(ctx, inspectionSlotsByStartKey, &redis.Z{Score: float64(slot.StartTime.UnixNano()), Member: slot.ID})
+				return nil
+			})
+			return err
+		}, slotsKey)
+
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("inspection slot creation conflicted with concurrent updates")
+}
+
+// bookInspection books a slot for a user. The slot and the user's bookings
+// are watched, so capacity and overlap checks hold under concurrent
+// bookings.
+func bookInspection(ctx context.Context, propertyID, slotID, userID string, now time.Time) (models.InspectionSlot, models.InspectionBooking, error) {
+	rdb := config.GetRedisClient()
+	slotKey := inspectionSlotKeyPrefix + slotID
+	var slot models.InspectionSlot
+	var booking models.InspectionBooking
+
+	for attempt := 0; attempt < inspectionMaxRetries; attempt++ {
+		err := rdb.Watch(ctx, func(tx *redis.Tx) error {
+			slotJSON, err := tx.Get(ctx, slotKey).Result()
+			if err != nil {
+				return err
+			}
+			if err := slot.FromJSON(slotJSON); err != nil {
+				return err
+			}
+			if slot.PropertyID != propertyID {
+				return redis.Nil
+			}
+			switch {
+			case slot.Status != models.InspectionSlotOpen:
+				return &inspectionConflictError{reason: "slot is cancelled"}
+			case !slot.StartTime.After(now):
+				return &inspectionConflictError{reason: "slot has already started"}
+			case slot.Booked >= slot.Capacity:
+				return &inspectionConflictError{reason: fmt.Sprintf("slot is full (%d places)", slot.Capacity)}
+			}
+
+			ids, err := tx.ZRange(ctx, userInspectionsKey(userID), 0, -1).Result()
+			if err != nil {
+				return err
+			}
+			existing, err := loadInspectionBookings(ctx, ids)
+			if err != nil {
+				return err
+			}
+			for _, other := range existing {
+				if other.Status != models.InspectionBookingBooked {
+					continue
+				}
+				if other.SlotID == slot.ID {
+					return &inspectionConflictError{reason: "user has already booked this slot"}
+				}
+				if slot.Overlaps(other.StartTime, other.EndTime) {
+					return &inspectionConflictError{reason: fmt.Sprintf("overlaps the user's booking %s from %s to %s", other.ID,
+						other.StartTime.Format(time.RFC3339), other.EndTime.Format(time.RFC3339))}
+				}
+			}
+
+			booking = models.InspectionBooking{
+				ID:         uuid.New().String(),
+				SlotID:     slot.ID,
+				PropertyID: slot.PropertyID,
+				UserID:     userID,
+				StartTime:  slot.StartTime,
+				EndTime:    slot.EndTime,
+				Status:     models.InspectionBookingBooked,
+				CreatedAt:  now,
+			}
+			slot.Booked++
+			slot.UpdatedAt = now
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				if err := writeInspectionSlot(ctx, pipe, slot); err != nil {
+					return err
+				}
+				if err := writeInspectionBooking(ctx, pipe, booking); err != nil {
+					return err
+				}
+				pipe.ZAdd(ctx, inspectionSlotBookingsKey(slot.ID), &redis.Z{Score: float64(now.UnixNano()), Member: booking.ID})
+				pipe.ZAdd(ctx, userInspectionsKey(userID), &redis.Z{Score: float64(slot.StartTime.UnixNano()), Member: booking.ID})
+				return nil
+			})
+			return err
+		}, slotKey, userInspectionsKey(userID))
+
+		if !errors.Is(err, redis.TxFailedErr) {
+			return slot, booking, err
+		}
+	}
+	return slot, booking, fmt.Errorf("inspection booking conflicted with concurrent updates")
+}
+
+// cancelInspectionBooking cancels a user's booking and frees its place in
+// the slot
+func cancelInspectionBooking(ctx context.Context, userID, bookingID string, now time.Time) (models.InspectionBooking, models.InspectionBooking, error) {
+	rdb := config.GetRedisClient()
+	bookingKey := inspectionBookingKeyPrefix + bookingID
+	var before, booking models.InspectionBooking
+
+	for attempt := 0; attempt < inspectionMaxRetries; attempt++ {
+		err := rdb.Watch(ctx, func(tx *redis.Tx) error {
+			bookingJSON, err := tx.Get(ctx, bookingKey).Result()
+			if err != nil {
+				return err
+			}
+			if err := before.FromJSON(bookingJSON); err != nil {
+				return err
+			}
+			if before.UserID != userID {
+				return redis.Nil
+			}
+			switch {
+			case before.Status != models.InspectionBookingBooked:
+				return &inspectionConflictError{reason: "booking is already cancelled"}
+			case !before.StartTime.After(now):
+				return &inspectionConflictError{reason: "inspection has already started"}
+			}
+
+			slotKey := inspectionSlotKeyPrefix + before.SlotID
+			if err := tx.Watch(ctx, slotKey).Err(); err != nil {
+				return err
+			}
+			var slot models.InspectionSlot
+			slotJSON, err := tx.Get(ctx, slotKey).Result()
+			if err != nil {
+				return err
+			}
+			if err := slot.FromJSON(slotJSON); err != nil {
+				return err
+			}
+
+			booking = before
+			booking.Status = models.InspectionBookingCancelled
+			booking.CancelledAt = &now
+			if slot.Booked > 0 {
+				slot.Booked--
+			}
+			slot.UpdatedAt = now
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				if err := writeInspectionSlot(ctx, pipe, slot); err != nil {
+					return err
+				}
+				return writeInspectionBooking(ctx, pipe, booking)
+			})
+			return err
+		}, bookingKey)
+
+		if !errors.Is(err, redis.TxFailedErr) {
+			return before, booking, err
+		}
+	}
+	return before, booking, fmt.Errorf("inspection booking cancellation conflicted with concurrent updates")
+}
+
+// cancelInspectionSlot cancels an open slot of a property and its active
+// bookings, returning the bookings it cancelled
+func cancelInspectionSlot(ctx context.Context, propertyID, slotID string) (models.InspectionSlot, models.InspectionSlot, []models.InspectionBooking, error) {
+	rdb := config.GetRedisClient()
+	slotKey := inspectionSlotKeyPrefix + slotID
+	var before, slot models.InspectionSlot
+	var cancelled []models.InspectionBooking
+
+	for attempt := 0; attempt < inspectionMaxRetries; attempt++ {
+		cancelled = nil
+		err := rdb.Watch(ctx, func(tx *redis.Tx) error {
+			slotJSON, err := tx.Get(ctx, slotKey).Result()
+			if err != nil {
+				return err
+			}
+			if err := before.FromJSON(slotJSON); err != nil {
+				return err
+			}
+			if before.PropertyID != propertyID {
+				return redis.Nil
+			}
+			if before.Status != models.InspectionSlotOpen {
+				return &inspectionConflictError{reason: "slot is already cancelled"}
+			}
+
+			ids, err := tx.ZRange(ctx, inspectionSlotBookingsKey(slotID), 0, -1).Result()
+			if err != nil {
+				return err
+			}
+			bookings, err := loadInspectionBookings(ctx, ids)
+			if err != nil {
+				return err
+			}
+
+			now := time.Now()
+			slot = before
+			slot.Status = models.InspectionSlotCancelled
+			slot.Booked = 0
+			slot.UpdatedAt = now
+			for _, booking := range bookings {
+				if booking.Status == models.InspectionBookingBooked {
+					booking.Status = models.InspectionBookingCancelled
+					booking.CancelledAt = &now
+					cancelled = append(cancelled, booking)
+				}
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				if err := writeInspectionSlot(ctx, pipe, slot); err != nil {
+					return err
+				}
+				for _, booking := range cancelled {
+					if err := writeInspectionBooking(ctx, pipe, booking); err != nil {
+						return err
+					}
+				}
+				pipe.ZRem(ctx, inspectionSlotsByStartKey, slot.ID)
+				return nil
+			})
+			return err
+		}, slotKey, inspectionSlotBookingsKey(slotID))
+
+		if !errors.Is(err, redis.TxFailedErr) {
+			return before, slot, cancelled, err
+		}
+	}
+	return before, slot, nil, fmt.Errorf("inspection slot cancellation conflicted with concurrent updates")
+}
+
+// writeInspectionSlot queues the write of a slot record
+func writeInspectionSlot(ctx context.Context, pipe redis.Pipeliner, slot models.InspectionSlot) error {
+	slotJSON, err := slot.ToJSON()
+	if err != nil {
+		return err
+	}
+	pipe.Set(ctx, inspectionSlotKeyPrefix+slot.ID, slotJSON, 0)
+	return nil
+}
+
+// writeInspectionBooking queues the write of a booking record
+func writeInspectionBooking(ctx context.Context, pipe redis.Pipeliner, booking models.InspectionBooking) error {
+	booking.User = nil
+	bookingJSON, err := booking.ToJSON()
+	if err != nil {
+		return err
+	}
+	pipe.Set(ctx, inspectionBookingKeyPrefix+booking.ID, bookingJSON, 0)
+	return nil
+}
+
+// RunInspectionReminders reminds users of their inspections, checking every
+// InspectionReminderInterval for slots starting within
+// InspectionReminderWindow until ctx is done. Each slot is reminded once,
+// even with several servers running it.
+func RunInspectionReminders(ctx context.Context) {
+	ticker := time.NewTicker(config.InspectionReminderInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := sendInspectionReminders(ctx, time.Now()); err != nil {
+			log.Printf("Failed to send inspection reminders: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendInspectionReminders notifies the bookers of open slots starting
+// within InspectionReminderWindow of now. Slots that have started are
+// dropped from the schedule index.
+func sendInspectionReminders(ctx context.Context, now time.Time) error {
+	rdb := config.GetRedisClient()
+	nowScore := strconv.FormatInt(now.UnixNano(), 10)
+	if err := rdb.ZRemRangeByScore(ctx, inspectionSlotsByStartKey, "-inf", nowScore).Err(); err != nil {
+		return err
+	}
+	ids, err := rdb.ZRangeByScore(ctx, inspectionSlotsByStartKey, &redis.ZRangeBy{
+		Min: nowScore,
+		Max: strconv.FormatInt(now.Add(config.InspectionReminderWindow).UnixNano(), 10),
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	slots, err := loadInspectionSlots(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, slot := range slots {
+		if slot.Status != models.InspectionSlotOpen || slot.Booked == 0 {
+			continue
+		}
+		// Claim the reminder, so only one server sends it
+		claimed, err := rdb.SetNX(ctx, inspectionReminderKey(slot.ID), now.Unix(), slot.StartTime.Sub(now)+config.InspectionReminderWindow).Result()
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		schedule, err := loadInspectionSchedule(ctx, []models.InspectionSlot{slot})
+		if err != nil {
+			return err
+		}
+		properties, err := loadProperties(ctx, []string{slot.PropertyID})
+		if err != nil {
+			return err
+		}
+		if len(properties) == 0 {
+			continue
+		}
+
+		message := fmt.Sprintf("Your inspection at %s starts at %s", properties[0].Location, slot.StartTime.Format(noticeTimeLayout))
+		if slot.Note != "" {
+			message += ". " + slot.Note
+		}
+		for _, booking := range schedule[0].Bookings {
+			notifyUser(ctx, models.Notification{
+				UserID:     booking.UserID,
+				Type:       models.NotificationInspectionReminder,
+				Title:      fmt.Sprintf("Upcoming inspection of %q", properties[0].Title),
+				Message:    message,
+				EntityType: "inspection_slot",
+				EntityID:   slot.ID,
+			})
+		}
+	}
+	return nil
+}
+
+// loadPropertyInspectionSlots loads a property's slots by start time. With
+// upcomingOnly, cancelled slots and slots that have ended are left out.
+func loadPropertyInspectionSlots(ctx context.Context, propertyID string, upcomingOnly bool) ([]models.InspectionSlot, error) {
+	ids, err := config.GetRedisClient().ZRange(ctx, propertyInspectionSlotsKey(propertyID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	slots, err := loadInspectionSlots(ctx, ids)
+	if err != nil || !upcomingOnly {
+		return slots, err
+	}
+
+	now := time.Now()
+	upcoming := []models.InspectionSlot{}
+	for _, slot := range slots {
+		if slot.Status == models.InspectionSlotOpen && slot.EndTime.After(now) {
+			upcoming = append(upcoming, slot)
+		}
+	}
+	return upcoming, nil
+}
+
+// loadInspectionSchedule pairs slots with their active bookings and the
+// users who made them
+func loadInspectionSchedule(ctx context.Context, slots []models.InspectionSlot) ([]models.InspectionSlotBookings, error) {
+	rdb := config.GetRedisClient()
+	schedule := make([]models.InspectionSlotBookings, 0, len(slots))
+	for _, slot := range slots {
+		ids, err := rdb.ZRange(ctx, inspectionSlotBookingsKey(slot.ID), 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+		bookings, err := loadInspectionBookings(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+
+		active := []models.InspectionBooking{}
+		var userIDs []string
+		for _, booking := range bookings {
+			if booking.Status == models.InspectionBookingBooked {
+				active = append(active, booking)
+				userIDs = append(userIDs, booking.UserID)
+			}
+		}
+		users, err := loadUsers(ctx, userIDs)
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[string]models.User, len(users))
+		for _, user := range users {
+			byID[user.ID] = user
+		}
+		for i := range active {
+			if user, ok := byID[active[i].UserID]; ok {
+				active[i].User = &user
+			}
+		}
+
+		schedule = append(schedule, models.InspectionSlotBookings{Slot: slot, Bookings: active})
+	}
+	return schedule, nil
+}
+
+// deletePropertyInspections removes a deleted property's slots and the
+// bookings made for them
+func deletePropertyInspections(ctx context.Context, propertyID string) error {
+	rdb := config.GetRedisClient()
+	slotIDs, err := rdb.ZRange(ctx, propertyInspectionSlotsKey(propertyID), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+
+	var bookings []models.InspectionBooking
+	for _, slotID := range slotIDs {
+		ids, err := rdb.ZRange(ctx, inspectionSlotBookingsKey(slotID), 0, -1).Result()
+		if err != nil {
+			return err
+		}
+		slotBookings, err := loadInspectionBookings(ctx, ids)
+		if err != nil {
+			return err
+		}
+		bookings = append(bookings, slotBookings...)
+	}
+
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, booking := range bookings {
+			pipe.Del(ctx, inspectionBookingKeyPrefix+booking.ID)
+			pipe.ZRem(ctx, userInspectionsKey(booking.UserID), booking.ID)
+		}
+		for _, slotID := range slotIDs {
+			pipe.Del(ctx, inspectionSlotKeyPrefix+slotID, inspectionSlotBookingsKey(slotID), inspectionReminderKey(slotID))
+			pipe.ZRem(ctx, inspectionSlotsByStartKey, slotID)
+		}
+		pipe.Del(ctx, propertyInspectionSlotsKey(propertyID))
+		return nil
+	})
+	return err
+}
+
+// cancelUserInspections cancels a deleted user's upcoming bookings so their
+// places are freed
+func cancelUserInspections(ctx context.Context, userID string) error {
+	now := time.Now()
+	ids, err := config.GetRedisClient().ZRangeByScore(ctx, userInspectionsKey(userID), &redis.ZRangeBy{
+		Min: strconv.FormatInt(now.UnixNano(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return err
+	}
+	bookings, err := loadInspectionBookings(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, booking := range bookings {
+		if booking.Status != models.InspectionBookingBooked {
+			continue
+		}
+		_, _, err := cancelInspectionBooking(ctx, userID, booking.ID, now)
+		var conflictErr *inspectionConflictError
+		if err != nil && !errors.As(err, &conflictErr) && !isNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadInspectionSlots loads slots by ID, preserving order and skipping
+// missing records
+func loadInspectionSlots(ctx context.Context, ids []string) ([]models.InspectionSlot, error) {
+	records, err := loadRecords(ctx, inspectionSlotKeyPrefix, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := []models.InspectionSlot{}
+	for _, record := range records {
+		var slot models.InspectionSlot
+		if slot.FromJSON(record) == nil {
+			slots = append(slots, slot)
+		}
+	}
+	return slots, nil
+}
+
+// loadInspectionBookings loads bookings by ID, preserving order and
+// skipping missing records
+func loadInspectionBookings(ctx context.Context, ids []string) ([]models.InspectionBooking, error) {
+	records, err := loadRecords(ctx, inspectionBookingKeyPrefix, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	bookings := []models.InspectionBooking{}
+	for _, record := range records {
+		var booking models.InspectionBooking
+		if booking.FromJSON(record) == nil {
+			bookings = append(bookings, booking)
+		}
+	}
+	return bookings, nil
+}
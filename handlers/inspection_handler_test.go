@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erea-api/middleware"
+	"erea-api/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// createTestSlot creates a slot with the key of "owner", who owns the
+// seeded listings
+func createTestSlot(t *testing.T, propertyID string, start time.Time, duration time.Duration, capacity int) (int, models.InspectionSlot) {
+	t.Helper()
+	return createTestSlotWithKey(t, propertyID, issueTestKey(t, "owner"), start, duration, capacity)
+}
+
+func createTestSlotWithKey(t *testing.T, propertyID, apiKey string, start time.Time, duration time.Duration, capacity int) (int, models.InspectionSlot) {
+	t.Helper()
+	router := newTestRouter()
+	router.Use(middleware.Authenticate())
+	router.POST("/properties/:id/inspections", CreateInspectionSlot)
+	w := performJSONWithKey(t, router, http.MethodPost, "/properties/"+propertyID+"/inspections", apiKey, models.CreateInspectionSlotRequest{
+		StartTime: start,
+		EndTime:   start.Add(duration),
+		Capacity:  capacity,
+	})
+	var response struct {
+		Data models.InspectionSlot `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response.Data
+}
+
+func bookTestSlot(t *testing.T, propertyID, slotID, userID string) int {
+	t.Helper()
+	router := newTestRouter()
+	router.POST("/properties/:id/inspections/:slotId/bookings", BookInspection)
+	return performJSON(t, router, http.MethodPost, "/properties/"+propertyID+"/inspections/"+slotID+"/bookings",
+		models.BookInspectionRequest{UserID: userID}).Code
+}
+
+func seedInspectionListings(t *testing.T, users ...string) {
+	t.Helper()
+	ctx := context.Background()
+	for _, id := range []string{"p1", "p2"} {
+		if err := saveProperty(ctx, nil, models.Property{ID: id, Title: "Listing", OwnerID: "owner", Status: models.PropertyStatusApproved}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range users {
+		if err := saveUser(ctx, models.User{ID: id, Name: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestInspectionSlotsDontOverlap(t *testing.T) {
+	newTestRedis(t)
+	seedInspectionListings(t)
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+
+	if code, _ := createTestSlot(t, "p1", start, time.Hour, 2); code != http.StatusCreated {
+		t.Fatalf("first slot: status %d", code)
+	}
+	if code, _ := createTestSlot(t, "p1", start.Add(30*time.Minute), time.Hour, 2); code != http.StatusConflict {
+		t.Fatalf("overlapping slot: expected 409, got %d", code)
+	}
+	if code, _ := createTestSlot(t, "p1", start.Add(time.Hour), time.Hour, 2); code != http.StatusCreated {
+		t.Fatalf("back-to-back slot: expected 201, got %d", code)
+	}
+	if code, _ := createTestSlot(t, "p2", start, time.Hour, 2); code != http.StatusCreated {
+		t.Fatalf("slot of another listing: expected 201, got %d", code)
+	}
+	if code, _ := createTestSlot(t, "p1", time.Now().Add(-time.Hour), time.Hour, 2); code != http.StatusBadRequest {
+		t.Fatalf("past slot: expected 400, got %d", code)
+	}
+}
+
+func TestInspectionBookingCapacityAndOverlap(t *testing.T) {
+	newTestRedis(t)
+	seedInspectionListings(t, "u1", "u2", "u3", "owner")
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	_, slot := createTestSlot(t, "p1", start, time.Hour, 2)
+	_, other := createTestSlot(t, "p2", start.Add(30*time.Minute), time.Hour, 5)
+
+	for _, step := range []struct {
+		propertyID, slotID, userID string
+		code                       int
+	}{
+		{"p1", slot.ID, "u1", http.StatusCreated},
+		{"p1", slot.ID, "u1", http.StatusConflict}, // Already booked
+		{"p1", slot.ID, "owner", http.StatusConflict},
+		{"p1", slot.ID, "u2", http.StatusCreated},
+		{"p1", slot.ID, "u3", http.StatusConflict},  // Full
+		{"p2", other.ID, "u1", http.StatusConflict}, // Overlaps u1's booking
+		{"p2", other.ID, "u3", http.StatusCreated},
+		{"p2", slot.ID, "u3", http.StatusNotFound}, // Slot of another listing
+	} {
+		if code := bookTestSlot(t, step.propertyID, step.slotID, step.userID); code != step.code {
+			t.Fatalf("%s booking %s: expected %d, got %d", step.userID, step.slotID, step.code, code)
+		}
+	}
+}
+
+func TestInspectionBookingCapacityUnderConcurrency(t *testing.T) {
+	newTestRedis(t)
+	users := make([]string, 8)
+	for i := range users {
+		users[i] = fmt.Sprintf("u%d", i)
+	}
+	seedInspectionListings(t, users...)
+	_, slot := createTestSlot(t, "p1", time.Now().Add(24*time.Hour), time.Hour, 3)
+
+	var wg sync.WaitGroup
+	results := make(chan error, len(users))
+	for _, userID := range users {
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			_, _, err := bookInspection(context.Background(), "p1", slot.ID, userID, time.Now())
+			results <- err
+		}(userID)
+	}
+	wg.Wait()
+	close(results)
+
+	booked := 0
+	for err := range results {
+		var conflictErr *inspectionConflictError
+		switch {
+		case err == nil:
+			booked++
+		case !errors.As(err, &conflictErr) && err.Error() != "inspection booking conflicted with concurrent updates":
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if booked != 3 {
+		t.Fatalf("expected the 3 places to be booked, got %d", booked)
+	}
+	slots, err := loadInspectionSlots(context.Background(), []string{slot.ID})
+	if err != nil || len(slots) != 1 || slots[0].Booked != 3 {
+		t.Fatalf("expected the slot to record 3 bookings, got %+v (%v)", slots, err)
+	}
+}
+
+func TestInspectionSlotsAreManagedByTheOwner(t *testing.T) {
+	newTestRedis(t)
+	seedInspectionListings(t)
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	otherKey := issueTestKey(t, "someone-else")
+
+	if code, _ := createTestSlotWithKey(t, "p1", "", start, time.Hour, 2); code != http.StatusUnauthorized {
+		t.Fatalf("anonymous slot: expected 401, got %d", code)
+	}
+	if code, _ := createTestSlotWithKey(t, "p1", otherKey, start, time.Hour, 2); code != http.StatusForbidden {
+		t.Fatalf("slot by another user: expected 403, got %d", code)
+	}
+	if code, _ := createTestSlotWithKey(t, "p1", issueTestKey(t, "", models.ScopeAdmin), start, time.Hour, 2); code != http.StatusCreated {
+		t.Fatalf("slot by an admin: expected 201, got %d", code)
+	}
+	_, slot := createTestSlot(t, "p1", start.Add(2*time.Hour), time.Hour, 2)
+
+	router := newTestRouter()
+	router.Use(middleware.Authenticate())
+	router.DELETE("/properties/:id/inspections/:slotId", CancelInspectionSlot)
+	cancel := func(apiKey string) int {
+		return performJSONWithKey(t, router, http.MethodDelete, "/properties/p1/inspections/"+slot.ID, apiKey, nil).Code
+	}
+	if code := cancel(""); code != http.StatusUnauthorized {
+		t.Fatalf("anonymous cancel: expected 401, got %d", code)
+	}
+	if code := cancel(otherKey); code != http.StatusForbidden {
+		t.Fatalf("cancel by another user: expected 403, got %d", code)
+	}
+	if code := cancel(issueTestKey(t, "owner")); code != http.StatusOK {
+		t.Fatalf("cancel by the owner: expected 200, got %d", code)
+	}
+}
+
+func TestInspectionBookingsBindUserKeys(t *testing.T) {
+	newTestRedis(t)
+	seedInspectionListings(t, "u1", "u2")
+	_, slot := createTestSlot(t, "p1", time.Now().Add(24*time.Hour), time.Hour, 5)
+	u1Key := issueTestKey(t, "u1")
+
+	router := newTestRouter()
+	router.Use(middleware.Authenticate())
+	router.POST("/properties/:id/inspections/:slotId/bookings", BookInspection)
+	router.DELETE("/users/:id/inspections/:bookingId", CancelInspectionBooking)
+	book := func(userID, apiKey string) *httptest.ResponseRecorder {
+		return performJSONWithKey(t, router, http.MethodPost, "/properties/p1/inspections/"+slot.ID+"/bookings", apiKey,
+			models.BookInspectionRequest{UserID: userID})
+	}
+
+	if w := book("u2", u1Key); w.Code != http.StatusForbidden {
+		t.Fatalf("booking for another user: expected 403, got %d", w.Code)
+	}
+	w := book("u1", u1Key)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("booking for oneself: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Data models.InspectionBooking `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	// The booking can't be cancelled with another user's key, even through
+	// that user's path
+	for _, userID := range []string{"u1", "u2"} {
+		path := "/users/" + userID + "/inspections/" + response.Data.ID
+		if w := performJSONWithKey(t, router, http.MethodDelete, path, issueTestKey(t, "u2"), nil); w.Code == http.StatusOK {
+			t.Fatalf("DELETE %s with u2's key: expected a refusal, got 200", path)
+		}
+	}
+	if w := performJSONWithKey(t, router, http.MethodDelete, "/users/u1/inspections/"+response.Data.ID, u1Key, nil); w.Code != http.StatusOK {
+		t.Fatalf("cancelling one's own booking: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,814 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"erea-api/config"
+	"erea-api/middleware"
+	"erea-api/models"
+	"erea-api/storage"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	mediaKeyPrefix         = "media:"
+	maxPhotosPerUpload     = 10
+	maxImagePixels         = 50_000_000 // Rejects decompression bombs before decoding
+	thumbnailMaxEdge       = 320
+	thumbnailQuality       = 80
+	multipartOverhead      = 1 << 20
+	placeholderImagePrefix = "/api/placeholder/"
+)
+
+// photoContentTypes are the sniffed content types accepted for photos, with
+// the extension used for their blob keys
+var photoContentTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// documentContentTypes are the sniffed content types accepted for documents
+// (PDFs and scans)
+var documentContentTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+}
+
+// errFileTooLarge is returned when an uploaded file exceeds its size limit
+var errFileTooLarge = errors.New("file too large")
+
+// propertyMediaKey is the sorted set of a property's media IDs of one kind,
+// scored by upload time
+func propertyMediaKey(propertyID, kind string) string {
+	return "property_media:" + propertyID + ":" + kind
+}
+
+// mediaUpload is a validated file waiting to be stored
+type mediaUpload struct {
+	media     models.PropertyMedia
+	data      []byte
+	thumbnail []byte
+}
+
+// UploadPropertyPhotos uploads up to 10 photos in the multipart field
+// "files". Each photo is sniffed (JPEG, PNG, GIF or WebP), size-checked and
+// stored with a JPEG thumbnail. The first photo replaces a placeholder
+// image_url. Either every photo is stored or none is. Only the property
+// owner and keys with documents:write may upload.
+func UploadPropertyPhotos(c *gin.Context) {
+	property, ok := propertyForMedia(c)
+	if !ok || !authorizeMediaWrite(c, property) {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.MaxImageSize*maxPhotosPerUpload+multipartOverhead)
+	form, err := c.MultipartForm()
+	if err != nil {
+		respondUploadError(c, "Invalid upload", err)
+		return
+	}
+
+	files := form.File["files"]
+	if len(files) == 0 || len(files) > maxPhotosPerUpload {
+		c.JSON(http.StatusBadRequest, models.MediaResponse{
+			Success: false,
+			Message: "Invalid upload",
+			Error:   fmt.Sprintf("files must contain between 1 and %d photos", maxPhotosPerUpload),
+		})
+		return
+	}
+
+	redis := config.GetRedisClient()
+	ctx := config.GetContext()
+
+	existing, err := redis.ZCard(ctx, propertyMediaKey(property.ID, models.MediaKindPhoto)).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.MediaResponse{
+			Success: false,
+			Message: "Failed to count property photos",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if existing+int64(len(files)) > config.MaxPhotosPerProperty {
+		c.JSON(http.StatusBadRequest, models.MediaResponse{
+			Success: false,
+			Message: "Too many photos",
+			Error:   fmt.Sprintf("a property can have at most %d photos", config.MaxPhotosPerProperty),
+		})
+		return
+	}
+
+	uploads := make([]mediaUpload, 0, len(files))
+	for _, file := range files {
+		upload, err := readPhoto(c, property.ID, file)
+		if err != nil {
+			respondUploadError(c, "Invalid photo "+file.Filename, err)
+			return
+		}
+		uploads = append(uploads, upload)
+	}
+
+	media, err := storeMedia(ctx, uploads)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.MediaResponse{
+			Success: false,
+			Message: "Failed to store photos",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	_, _, _, err = updatePropertyVersioned(ctx, property.ID, callerID(c), func(property *models.Property) error {
+		if property.ImageURL == "" || strings.HasPrefix(property.ImageURL, placeholderImagePrefix) {
+			property.ImageURL = media[0].URL
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to set image_url of property %s: %v", property.ID, err)
+	}
+
+	for _, m := range media {
+		recordAudit(c, "media.upload", "media", m.ID, nil, m)
+	}
+
+	c.JSON(http.StatusCreated, models.MediaResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d photos uploaded successfully", len(media)),
+		Data:    media,
+	})
+}
+
+// UploadPropertyDocument uploads a legal document in the multipart field
+// "file" with a document_type of registry_certificate, appraisal_report or
+// other. Registry certificates and appraisal reports are restricted unless
+// restricted=false is given. Only the property owner and keys with
+// documents:write may upload.
+func UploadPropertyDocument(c *gin.Context) {
+	property, ok := propertyForMedia(c)
+	if !ok || !authorizeMediaWrite(c, property) {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.MaxDocumentSize+multipartOverhead)
+	file, err := c.FormFile("file")
+	if err != nil {
+		respondUploadError(c, "Invalid upload", err)
+		return
+	}
+
+	documentType := c.PostForm("document_type")
+	if !isKnownDocumentType(documentType) {
+		c.JSON(http.StatusBadRequest, models.MediaResponse{
+			Success: false,
+			Message: "Invalid upload",
+			Error:   "document_type must be one of: " + strings.Join(models.DocumentTypes, ", "),
+		})
+		return
+	}
+
+	restricted := documentType != models.DocumentTypeOther
+	if value := c.PostForm("restricted"); value != "" {
+		if restricted, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, models.MediaResponse{
+				Success: false,
+				Message: "Invalid upload",
+				Error:   "restricted must be true or false",
+			})
+			return
+		}
+	}
+
+	data, contentType, err := readUpload(file, config.MaxDocumentSize, documentContentTypes)
+	if err != nil {
+		respondUploadError(c, "Invalid document "+file.Filename, err)
+		return
+	}
+
+	upload := mediaUpload{
+		media: newMedia(c, property.ID, models.MediaKindDocument, file.Filename, contentType, int64(len(data))),
+		data:  data,
+	}
+	upload.media.DocumentType = documentType
+	upload.media.Restricted = restricted
+
+	ctx := config.GetContext()
+	media, err := storeMedia(ctx, []mediaUpload{upload})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.MediaResponse{
+			Success: false,
+			Message: "Failed to store document",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	recordAudit(c, "media.upload", "media", media[0].ID, nil, media[0])
+
+	c.JSON(http.StatusCreated, models.MediaResponse{
+		Success: true,
+		Message: "Document uploaded successfully",
+		Data:    media[0],
+	})
+}
+
+// GetPropertyMedia lists a property's photos and documents, oldest first.
+// Supports kind=photo|document. Restricted documents are only listed for
+// callers allowed to download them.
+func GetPropertyMedia(c *gin.Context) {
+	property, ok := propertyForMedia(c)
+	if !ok {
+		return
+	}
+
+	kinds := []string{models.MediaKindPhoto, models.MediaKindDocument}
+	if kind := c.Query("kind"); kind != "" {
+		if kind != models.MediaKindPhoto && kind != models.MediaKindDocument {
+			c.JSON(http.StatusBadRequest, models.MediaResponse{
+				Success: false,
+				Message: "Invalid query parameters",
+				Error:   "kind must be photo or document",
+			})
+			return
+		}
+		kinds = []string{kind}
+	}
+
+	redis := config.GetRedisClient()
+	ctx := config.GetContext()
+	canReadRestricted := canReadRestrictedMedia(c, property)
+
+	media := []models.PropertyMedia{}
+	for _, kind := range kinds {
+		ids, err := redis.ZRange(ctx, propertyMediaKey(property.ID, kind), 0, -1).Result()
+		if err == nil {
+			var loaded []models.PropertyMedia
+			if loaded, err = loadMedia(ctx, ids); err == nil {
+				for _, m := range loaded {
+					if !m.Restricted || canReadRestricted {
+						media = append(media, m)
+					}
+				}
+			}
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.MediaResponse{
+				Success: false,
+				Message: "Failed to retrieve property media",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, models.MediaResponse{
+		Success: true,
+		Message: "Property media retrieved successfully",
+		Data:    media,
+	})
+}
+
+// DownloadMedia streams a photo or document. Restricted documents require
+// the documents:read scope or an API key acting for the property owner.
+func DownloadMedia(c *gin.Context) {
+	media, ok := mediaForDownload(c)
+	if !ok {
+		return
+	}
+	serveBlob(c, media, media.BlobKey, media.ContentType, media.FileName)
+}
+
+// GetMediaThumbnail streams the JPEG thumbnail of a photo
+func GetMediaThumbnail(c *gin.Context) {
+	media, ok := mediaForDownload(c)
+	if !ok {
+		return
+	}
+	if media.ThumbnailKey == "" {
+		c.JSON(http.StatusNotFound, models.MediaResponse{
+			Success: false,
+			Message: "Media has no thumbnail",
+		})
+		return
+	}
+	serveBlob(c, media, media.ThumbnailKey, "image/jpeg", strings.TrimSuffix(media.FileName, path.Ext(media.FileName))+"_thumb.jpg")
+}
+
+// DeleteMedia deletes a photo or document and its stored files. Deleting the
+// photo used as the property's image_url moves it to the next photo. Only
+// the property owner and keys with documents:write may delete.
+func DeleteMedia(c *gin.Context) {
+	ctx := config.GetContext()
+	media, err := getMedia(ctx, c.Param("id"))
+	if err != nil {
+		respondMediaLookupError(c, err)
+		return
+	}
+
+	var property models.Property
+	if propertyJSON, err := config.GetRedisClient().Get(ctx, "property:"+media.PropertyID).Result(); err == nil {
+		property.FromJSON(propertyJSON)
+	}
+	if !authorizeMediaWrite(c, property) {
+		return
+	}
+
+	if err := deleteMediaRecords(ctx, []models.PropertyMedia{media}); err != nil {
+		c.JSON(http.StatusInternalServerError, models.MediaResponse{
+			Success: false,
+			Message: "Failed to delete media",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	_, _, _, err = updatePropertyVersioned(ctx, media.PropertyID, callerID(c), func(property *models.Property) error {
+		if property.ImageURL != media.URL {
+			return nil
+		}
+		property.ImageURL = ""
+		ids, err := config.GetRedisClient().ZRange(ctx, propertyMediaKey(property.ID, models.MediaKindPhoto), 0, 0).Result()
+		if err == nil && len(ids) > 0 {
+			if next, err := getMedia(ctx, ids[0]); err == nil {
+				property.ImageURL = next.URL
+			}
+		}
+		return nil
+	})
+	if err != nil && !isNotFound(err) {
+		log.Printf("Failed to reset image_url of property %s: %v", media.PropertyID, err)
+	}
+
+	recordAudit(c, "media.delete", "media", media.ID, media, nil)
+
+	c.JSON(http.StatusOK, models.MediaResponse{
+		Success: true,
+		Message: "Media deleted successfully",
+	})
+}
+
+// deletePropertyMedia removes every photo and document of a property,
+// e.g. when the property itself is deleted
+func deletePropertyMedia(ctx context.Context, propertyID string) error {
+	rdb := config.GetRedisClient()
+	var media []models.PropertyMedia
+	for _, kind := range []string{models.MediaKindPhoto, models.MediaKindDocument} {
+		ids, err := rdb.ZRange(ctx, propertyMediaKey(propertyID, kind), 0, -1).Result()
+		if err != nil {
+			return err
+		}
+		loaded, err := loadMedia(ctx, ids)
+		if err != nil {
+			return err
+		}
+		media = append(media, loaded...)
+	}
+	if len(media) == 0 {
+		return nil
+	}
+	return deleteMediaRecords(ctx, media)
+}
+
+// propertyForMedia loads the property named by the :id parameter, writing
+// the error response when it cannot
+func propertyForMedia(c *gin.Context) (models.Property, bool) {
+	var property models.Property
+	propertyJSON, err := config.GetRedisClient().Get(config.GetContext(), "property:"+c.Param("id")).Result()
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.MediaResponse{
+			Success: false,
+			Message: "Property not found",
+			Error:   err.Error(),
+		})
+		return property, false
+	}
+	if err := property.FromJSON(propertyJSON); err != nil {
+		c.JSON(http.StatusInternalServerError, models.MediaResponse{
+			Success: false,
+			Message: "Failed to parse property data",
+			Error:   err.Error(),
+		})
+		return property, false
+	}
+	return property, true
+}
+
+// mediaForDownload loads the media named by the :id parameter and checks
+// that the caller may download it, writing the error response when not
+func mediaForDownload(c *gin.Context) (models.PropertyMedia, bool) {
+	ctx := config.GetContext()
+	media, err := getMedia(ctx, c.Param("id"))
+	if err != nil {
+		respondMediaLookupError(c, err)
+		return media, false
+	}
+	if !media.Restricted {
+		return media, true
+	}
+
+	if _, ok := middleware.GetPrincipal(c); !ok {
+		c.JSON(http.StatusUnauthorized, models.MediaResponse{
+			Success: false,
+			Message: "Authentication required",
+			Error:   "restricted documents require an API key",
+		})
+		return media, false
+	}
+
+	var property models.Property
+	if propertyJSON, err := config.GetRedisClient().Get(ctx, "property:"+media.PropertyID).Result(); err == nil {
+		property.FromJSON(propertyJSON)
+	}
+	if !canReadRestrictedMedia(c, property) {
+		c.JSON(http.StatusForbidden, models.MediaResponse{
+			Success: false,
+			Message: "Access denied",
+			Error:   "requires the " + models.ScopeDocumentsRead + " scope or the property owner's key",
+		})
+		return media, false
+	}
+	return media, true
+}
+
+// canReadRestrictedMedia reports whether the caller may see restricted
+// documents of the property: admins, keys with documents:read and keys
+// acting for the property owner
+func canReadRestrictedMedia(c *gin.Context, property models.Property) bool {
+	principal, ok := middleware.GetPrincipal(c)
+	if !ok {
+		return false
+	}
+	return principal.HasScope(models.ScopeDocumentsRead) ||
+		(principal.UserID != "" && principal.UserID == property.OwnerID)
+}
+
+// authorizeMediaWrite checks that the caller may upload or delete photos
+// and documents of the property: admins, keys with documents:write and keys
+// acting for the property owner. It writes the error response when not.
+func authorizeMediaWrite(c *gin.Context, property models.Property) bool {
+	principal, ok := middleware.GetPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.MediaResponse{
+			Success: false,
+			Message: "Authentication required",
+			Error:   "uploading or deleting media requires an API key",
+		})
+		return false
+	}
+	if principal.HasScope(models.ScopeDocumentsWrite) ||
+		(principal.UserID != "" && principal.UserID == property.OwnerID) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, models.MediaResponse{
+		Success: false,
+		Message: "Access denied",
+		Error:   "requires the " + models.ScopeDocumentsWrite + " scope or the property owner's key",
+	})
+	return false
+}
+
+// serveBlob streams a stored file with its content type and file name
+func serveBlob(c *gin.Context, media models.PropertyMedia, key, contentType, fileName string) {
+	store, err := storage.GetStore()
+	var body io.ReadCloser
+	if err == nil {
+		body, err = store.Open(c.Request.Context(), key)
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, models.MediaResponse{
+			Success: false,
+			Message: "Media file not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.MediaResponse{
+			Success: false,
+			Message: "Failed to read media file",
+			Error:   err.Error(),
+		})
+		return
+	}
+	defer body.Close()
+
+	disposition := "inline"
+	cacheControl := "public, max-age=86400"
+	if media.Kind == models.MediaKindDocument {
+		disposition = "attachment"
+	}
+	if media.Restricted {
+		cacheControl = "private, no-store"
+	}
+
+	c.DataFromReader(http.StatusOK, -1, contentType, body, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType(disposition, map[string]string{"filename": fileName}),
+		"Cache-Control":          cacheControl,
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// respondUploadError writes the response for an unreadable or rejected upload
+func respondUploadError(c *gin.Context, message string, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.Is(err, errFileTooLarge) || errors.As(err, &maxBytesErr) {
+		c.JSON(http.StatusRequestEntityTooLarge, models.MediaResponse{
+			Success: false,
+			Message: message,
+			Error:   fmt.Sprintf("photos are limited to %d bytes and documents to %d bytes", config.MaxImageSize, config.MaxDocumentSize),
+		})
+		return
+	}
+	c.JSON(http.StatusBadRequest, models.MediaResponse{
+		Success: false,
+		Message: message,
+		Error:   err.Error(),
+	})
+}
+
+// respondMediaLookupError writes the response for a failed media lookup
+func respondMediaLookupError(c *gin.Context, err error) {
+	if isNotFound(err) {
+		c.JSON(http.StatusNotFound, models.MediaResponse{
+			Success: false,
+			Message: "Media not found",
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, models.MediaResponse{
+		Success: false,
+		Message: "Failed to retrieve media",
+		Error:   err.Error(),
+	})
+}
+
+// readUpload reads an uploaded file of at most maxSize bytes and sniffs its
+// content type, which must be one of allowed. The client's Content-Type
+// header is ignored.
+func readUpload(file *multipart.FileHeader, maxSize int64, allowed map[string]string) ([]byte, string, error) {
+	if file.Size > maxSize {
+		return nil, "", errFileTooLarge
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, "", errFileTooLarge
+	}
+	if len(data) == 0 {
+		return nil, "", fmt.Errorf("file is empty")
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if _, ok := allowed[contentType]; !ok {
+		types := make([]string, 0, len(allowed))
+		for t := range allowed {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		return nil, "", fmt.Errorf("unsupported file type %s, expected one of: %s", contentType, strings.Join(types, ", "))
+	}
+	return data, contentType, nil
+}
+
+// readPhoto validates an uploaded photo and renders its thumbnail
+func readPhoto(c *gin.Context, propertyID string, file *multipart.FileHeader) (mediaUpload, error) {
+	data, contentType, err := readUpload(file, config.MaxImageSize, photoContentTypes)
+	if err != nil {
+		return mediaUpload{}, err
+	}
+
+	imageConfig, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return mediaUpload{}, fmt.Errorf("cannot decode image: %v", err)
+	}
+	if imageConfig.Width*imageConfig.Height > maxImagePixels {
+		return mediaUpload{}, fmt.Errorf("image is %dx%d, larger than %d pixels", imageConfig.Width, imageConfig.Height, maxImagePixels)
+	}
+
+	thumbnail, err := renderThumbnail(data)
+	if err != nil {
+		return mediaUpload{}, fmt.Errorf("cannot decode image: %v", err)
+	}
+
+	upload := mediaUpload{
+		media:     newMedia(c, propertyID, models.MediaKindPhoto, file.Filename, contentType, int64(len(data))),
+		data:      data,
+		thumbnail: thumbnail,
+	}
+	upload.media.Width = imageConfig.Width
+	upload.media.Height = imageConfig.Height
+	upload.media.ThumbnailKey = "properties/" + propertyID + "/thumbnails/" + upload.media.ID + ".jpg"
+	upload.media.ThumbnailURL = "/api/v1/media/" + upload.media.ID + "/thumbnail"
+	return upload, nil
+}
+
+// renderThumbnail scales an image to fit within thumbnailMaxEdge and encodes
+// it as JPEG. Smaller images are re-encoded at their own size.
+func renderThumbnail(data []byte) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > thumbnailMaxEdge || height > thumbnailMaxEdge {
+		if width >= height {
+			width, height = thumbnailMaxEdge, max(1, height*thumbnailMaxEdge/width)
+		} else {
+			width, height = max(1, width*thumbnailMaxEdge/height), thumbnailMaxEdge
+		}
+	}
+
+	// JPEG has no alpha, so draw onto white
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// newMedia creates the record of an upload by the current caller
+func newMedia(c *gin.Context, propertyID, kind, fileName, contentType string, size int64) models.PropertyMedia {
+	id := uuid.New().String()
+	extensions := photoContentTypes
+	if kind == models.MediaKindDocument {
+		extensions = documentContentTypes
+	}
+
+	media := models.PropertyMedia{
+		ID:          id,
+		PropertyID:  propertyID,
+		Kind:        kind,
+		FileName:    path.Base(strings.ReplaceAll(fileName, "\\", "/")),
+		ContentType: contentType,
+		Size:        size,
+		URL:         "/api/v1/media/" + id + "/download",
+		BlobKey:     "properties/" + propertyID + "/" + kind + "s/" + id + extensions[contentType],
+		UploadedBy:  callerID(c),
+		CreatedAt:   time.Now(),
+	}
+	return media
+}
+
+// storeMedia writes the files to blob storage, then the records and
+// indexes in one transaction. Files already written are removed when a
+// later step fails.
+func storeMedia(ctx context.Context, uploads []mediaUpload) ([]models.PropertyMedia, error) {
+	store, err := storage.GetStore()
+	if err != nil {
+		return nil, err
+	}
+
+	var written []string
+	cleanup := func() {
+		for _, key := range written {
+			if err := store.Delete(ctx, key); err != nil {
+				log.Printf("Failed to remove blob %s: %v", key, err)
+			}
+		}
+	}
+
+	media := make([]models.PropertyMedia, len(uploads))
+	for i, upload := range uploads {
+		if err := store.Put(ctx, upload.media.BlobKey, upload.data, upload.media.ContentType); err != nil {
+			cleanup()
+			return nil, err
+		}
+		written = append(written, upload.media.BlobKey)
+
+		if upload.thumbnail != nil {
+			if err := store.Put(ctx, upload.media.ThumbnailKey, upload.thumbnail, "image/jpeg"); err != nil {
+				cleanup()
+				return nil, err
+			}
+			written = append(written, upload.media.ThumbnailKey)
+		}
+		media[i] = upload.media
+	}
+
+	_, err = config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, m := range media {
+			mediaJSON, err := m.ToJSON()
+			if err != nil {
+				return err
+			}
+			pipe.Set(ctx, mediaKeyPrefix+m.ID, mediaJSON, 0)
+			pipe.ZAdd(ctx, propertyMediaKey(m.PropertyID, m.Kind), &redis.Z{Score: float64(m.CreatedAt.UnixNano()), Member: m.ID})
+		}
+		return nil
+	})
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	return media, nil
+}
+
+// deleteMediaRecords removes media records and their index entries, then
+// their files. Files that cannot be removed are logged and left behind.
+func deleteMediaRecords(ctx context.Context, media []models.PropertyMedia) error {
+	_, err := config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, m := range media {
+			pipe.Del(ctx, mediaKeyPrefix+m.ID)
+			pipe.ZRem(ctx, propertyMediaKey(m.PropertyID, m.Kind), m.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	store, err := storage.GetStore()
+	if err != nil {
+		log.Printf("Failed to open blob storage: %v", err)
+		return nil
+	}
+	for _, m := range media {
+		for _, key := range []string{m.BlobKey, m.ThumbnailKey} {
+			if key == "" {
+				continue
+			}
+			if err := store.Delete(ctx, key); err != nil {
+				log.Printf("Failed to remove blob %s: %v", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// getMedia loads one media record
+func getMedia(ctx context.Context, mediaID string) (models.PropertyMedia, error) {
+	var media models.PropertyMedia
+	mediaJSON, err := config.GetRedisClient().Get(ctx, mediaKeyPrefix+mediaID).Result()
+	if err != nil {
+		return media, err
+	}
+	err = media.FromJSON(mediaJSON)
+	return media, err
+}
+
+// loadMedia loads media records by ID, preserving order and skipping missing records
+func loadMedia(ctx context.Context, ids []string) ([]models.PropertyMedia, error) {
+	records, err := loadRecords(ctx, mediaKeyPrefix, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	media := []models.PropertyMedia{}
+	for _, record := range records {
+		var m models.PropertyMedia
+		if m.FromJSON(record) == nil {
+			media = append(media, m)
+		}
+	}
+	return media, nil
+}
+
+// isKnownDocumentType reports whether documentType is accepted for uploads
+func isKnownDocumentType(documentType string) bool {
+	for _, t := range models.DocumentTypes {
+		if t == documentType {
+			return true
+		}
+	}
+	return false
+}
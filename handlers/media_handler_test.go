@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"erea-api/config"
+	"erea-api/middleware"
+	"erea-api/models"
+	"erea-api/storage"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTestStore stores blobs in a temporary directory for the duration of a
+// test
+func useTestStore(t *testing.T) {
+	t.Helper()
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := storage.Store
+	storage.Store = store
+	t.Cleanup(func() { storage.Store = previous })
+}
+
+func uploadTestDocument(t *testing.T, router http.Handler, propertyID, apiKey string) *httptest.ResponseRecorder {
+	t.Helper()
+	return uploadTestFile(t, router, "/properties/"+propertyID+"/documents", "file", "registry.pdf",
+		[]byte("%PDF-1.4\n%test document\n"), map[string]string{"document_type": "other"}, apiKey)
+}
+
+// uploadTestFile posts data as the multipart file field, along with the
+// given form fields
+func uploadTestFile(t *testing.T, router http.Handler, target, field, fileName string, data []byte, fields map[string]string, apiKey string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	part, err := form.CreateFormFile(field, fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, target, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// newMediaRouter serves the media routes for a draft listing p1 owned by
+// "owner" and returns the owner's key
+func newMediaRouter(t *testing.T) (*gin.Engine, string) {
+	t.Helper()
+	newTestRedis(t)
+	useTestStore(t)
+	property := models.Property{ID: "p1", Title: "Listing", OwnerID: "owner", Status: models.PropertyStatusDraft}
+	if err := saveProperty(context.Background(), nil, property); err != nil {
+		t.Fatal(err)
+	}
+
+	router := newTestRouter()
+	router.Use(middleware.Authenticate())
+	router.POST("/properties/:id/photos", UploadPropertyPhotos)
+	router.POST("/properties/:id/documents", UploadPropertyDocument)
+	router.GET("/properties/:id/media", GetPropertyMedia)
+	router.GET("/media/:id/download", DownloadMedia)
+	router.GET("/media/:id/thumbnail", GetMediaThumbnail)
+	return router, issueTestKey(t, "owner")
+}
+
+// testPNG encodes a width x height PNG
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xc0
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func getWithKey(router http.Handler, target, apiKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestMediaWriteRequiresOwnerOrScope(t *testing.T) {
+	newTestRedis(t)
+	useTestStore(t)
+	property := models.Property{ID: "p1", Title: "Listing", OwnerID: "owner", Status: models.PropertyStatusDraft}
+	if err := saveProperty(context.Background(), nil, property); err != nil {
+		t.Fatal(err)
+	}
+	ownerKey := issueTestKey(t, "owner")
+	otherKey := issueTestKey(t, "someone-else")
+	writerKey := issueTestKey(t, "", models.ScopeDocumentsWrite)
+
+	router := newTestRouter()
+	router.Use(middleware.Authenticate())
+	router.POST("/properties/:id/documents", UploadPropertyDocument)
+	router.DELETE("/media/:id", DeleteMedia)
+
+	if w := uploadTestDocument(t, router, "p1", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous upload: expected 401, got %d", w.Code)
+	}
+	if w := uploadTestDocument(t, router, "p1", otherKey); w.Code != http.StatusForbidden {
+		t.Fatalf("upload by another user: expected 403, got %d", w.Code)
+	}
+	if w := uploadTestDocument(t, router, "p1", writerKey); w.Code != http.StatusCreated {
+		t.Fatalf("upload with %s: expected 201, got %d", models.ScopeDocumentsWrite, w.Code)
+	}
+	w := uploadTestDocument(t, router, "p1", ownerKey)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload by the owner: expected 201, got %d", w.Code)
+	}
+	var response struct {
+		Data models.PropertyMedia `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	deleteMedia := func(apiKey string) int {
+		req := httptest.NewRequest(http.MethodDelete, "/media/"+response.Data.ID, nil)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := deleteMedia(""); code != http.StatusUnauthorized {
+		t.Fatalf("anonymous delete: expected 401, got %d", code)
+	}
+	if code := deleteMedia(otherKey); code != http.StatusForbidden {
+		t.Fatalf("delete by another user: expected 403, got %d", code)
+	}
+	if code := deleteMedia(ownerKey); code != http.StatusOK {
+		t.Fatalf("delete by the owner: expected 200, got %d", code)
+	}
+}
+
+func TestUploadSniffsContentAndEnforcesSizeLimits(t *testing.T) {
+	router, ownerKey := newMediaRouter(t)
+
+	// The content decides the type, not the file name or the part header
+	w := uploadTestFile(t, router, "/properties/p1/photos", "files", "photo.jpg", []byte("just some text"), nil, ownerKey)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "unsupported file type text/plain") {
+		t.Fatalf("text posing as a photo: expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	w = uploadTestFile(t, router, "/properties/p1/documents", "file", "scan.pdf", testPNG(t, 10, 10),
+		map[string]string{"document_type": "other"}, ownerKey)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("PNG scan as a document: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Data models.PropertyMedia `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Data.ContentType != "image/png" || !strings.HasSuffix(response.Data.BlobKey, ".png") {
+		t.Fatalf("expected the document to be stored as image/png, got %s at %s", response.Data.ContentType, response.Data.BlobKey)
+	}
+
+	previous := config.MaxDocumentSize
+	config.MaxDocumentSize = 16
+	t.Cleanup(func() { config.MaxDocumentSize = previous })
+	w = uploadTestFile(t, router, "/properties/p1/documents", "file", "big.pdf", []byte("%PDF-1.4\n"+strings.Repeat("x", 32)),
+		map[string]string{"document_type": "other"}, ownerKey)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized document: expected 413, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUploadPhotoRendersThumbnail(t *testing.T) {
+	router, ownerKey := newMediaRouter(t)
+
+	w := uploadTestFile(t, router, "/properties/p1/photos", "files", "wide.png", testPNG(t, 800, 400), nil, ownerKey)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Data []models.PropertyMedia `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	photo := response.Data[0]
+	if photo.Width != 800 || photo.Height != 400 || photo.ContentType != "image/png" {
+		t.Fatalf("unexpected photo record %+v", photo)
+	}
+
+	w = getWithKey(router, photo.ThumbnailURL[len("/api/v1"):], "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("thumbnail: expected a JPEG, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	thumbnail, err := jpeg.DecodeConfig(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thumbnail.Width != thumbnailMaxEdge || thumbnail.Height != thumbnailMaxEdge/2 {
+		t.Fatalf("expected a %dx%d thumbnail, got %dx%d", thumbnailMaxEdge, thumbnailMaxEdge/2, thumbnail.Width, thumbnail.Height)
+	}
+}
+
+func TestRestrictedDocumentsNeedOwnerOrScope(t *testing.T) {
+	router, ownerKey := newMediaRouter(t)
+	otherKey := issueTestKey(t, "someone-else")
+	readerKey := issueTestKey(t, "", models.ScopeDocumentsRead)
+
+	w := uploadTestFile(t, router, "/properties/p1/documents", "file", "registry.pdf", []byte("%PDF-1.4\n%registry\n"),
+		map[string]string{"document_type": models.DocumentTypeRegistryCertificate}, ownerKey)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Data models.PropertyMedia `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if !response.Data.Restricted {
+		t.Fatal("expected registry certificates to be restricted by default")
+	}
+	download := "/media/" + response.Data.ID + "/download"
+
+	for _, tc := range []struct {
+		name   string
+		apiKey string
+		want   int
+	}{
+		{"anonymous", "", http.StatusUnauthorized},
+		{"another user", otherKey, http.StatusForbidden},
+		{"the owner", ownerKey, http.StatusOK},
+		{models.ScopeDocumentsRead, readerKey, http.StatusOK},
+	} {
+		w := getWithKey(router, download, tc.apiKey)
+		if w.Code != tc.want {
+			t.Fatalf("download by %s: expected %d, got %d", tc.name, tc.want, w.Code)
+		}
+		if w.Code == http.StatusOK && w.Header().Get("Cache-Control") != "private, no-store" {
+			t.Fatalf("restricted download cached as %q", w.Header().Get("Cache-Control"))
+		}
+	}
+
+	// Listings leave out what the caller cannot download
+	if w := getWithKey(router, "/properties/p1/media", otherKey); strings.Contains(w.Body.String(), response.Data.ID) {
+		t.Fatalf("restricted document listed for another user: %s", w.Body.String())
+	}
+	if w := getWithKey(router, "/properties/p1/media", ownerKey); !strings.Contains(w.Body.String(), response.Data.ID) {
+		t.Fatalf("restricted document not listed for the owner: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"context"
+	"erea-api/config"
+	"erea-api/models"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	notificationKeyPrefix   = "notification:"
+	maxNotificationsPerUser = 500                 // Older notifications are dropped from the inbox
+	notificationTTL         = 90 * 24 * time.Hour // Records outlive their inbox entry at most this long
+)
+
+// userNotificationsKey is the sorted set of a user's notification IDs by
+// creation time
+func userNotificationsKey(userID string) string {
+	return "notifications:" + userID
+}
+
+// userUnreadNotificationsKey is the set of a user's unread notification IDs
+func userUnreadNotificationsKey(userID string) string {
+	return "notifications_unread:" + userID
+}
+
+// notifyUser stores a notification in the user's inbox and pushes it to
+// clients following the user's topic. Failures are logged rather than
+// returned because the change being reported is already stored.
+func notifyUser(ctx context.Context, notification models.Notification) {
+	if notification.UserID == "" {
+		return
+	}
+	notification.ID = uuid.New().String()
+	notification.CreatedAt = time.Now()
+
+	notificationJSON, err := notification.ToJSON()
+	if err != nil {
+		log.Printf("Failed to encode notification for user %s: %v", notification.UserID, err)
+		return
+	}
+
+	inboxKey := userNotificationsKey(notification.UserID)
+	unreadKey := userUnreadNotificationsKey(notification.UserID)
+	_, err = config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, notificationKeyPrefix+notification.ID, notificationJSON, notificationTTL)
+		pipe.ZAdd(ctx, inboxKey, &redis.Z{Score: float64(notification.CreatedAt.UnixNano()), Member: notification.ID})
+		pipe.SAdd(ctx, unreadKey, notification.ID)
+		pipe.ZRemRangeByRank(ctx, inboxKey, 0, -maxNotificationsPerUser-1)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to store notification for user %s: %v", notification.UserID, err)
+		return
+	}
+
+	// Push it to the user's open connections
+	BroadcastNotification(notification)
+}
+
+// GetUserNotifications retrieves a user's notifications, newest first.
+// Supports unread=true, type and from/to filters and cursor pagination, and
+// returns the unread count.
+func GetUserNotifications(c *gin.Context) {
+	userID := c.Param("id")
+
+	var from, to *time.Time
+	unreadOnly := false
+	q, err := parseListQuery(c, listSortKeys{"created_at": userNotificationsKey(userID)}, "-created_at")
+	if err == nil {
+		from, to, err = queryTimeRange(c)
+	}
+	if err == nil && c.Query("unread") != "" {
+		unreadOnly, err = strconv.ParseBool(c.Query("unread"))
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NotificationResponse{
+			Success: false,
+			Message: "Invalid query parameters",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	notificationType := c.Query("type")
+	q.narrow("created_at", timeScore(from), timeScore(to))
+
+	notifications, page, err := paginate(config.GetContext(), q, loadNotifications,
+		func(notification models.Notification) string { return notification.ID },
+		func(notification models.Notification) bool {
+			return (!unreadOnly || notification.ReadAt == nil) &&
+				(notificationType == "" || notification.Type == notificationType) &&
+				inTimeRange(notification.CreatedAt, from, to)
+		})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NotificationResponse{
+			Success: false,
+			Message: "Failed to retrieve notifications",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	unread, err := config.GetRedisClient().SCard(config.GetContext(), userUnreadNotificationsKey(userID)).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NotificationResponse{
+			Success: false,
+			Message: "Failed to count unread notifications",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NotificationResponse{
+		Success: true,
+		Message: "Notifications retrieved successfully",
+		Data: models.NotificationList{
+			UnreadCount:   unread,
+			Notifications: notifications,
+		},
+		Pagination: page,
+	})
+}
+
+// MarkNotificationRead marks one of a user's notifications as read
+func MarkNotificationRead(c *gin.Context) {
+	userID := c.Param("id")
+	notificationID := c.Param("notificationId")
+	ctx := config.GetContext()
+
+	notifications, err := loadNotifications(ctx, []string{notificationID})
+	if err == nil && (len(notifications) == 0 || notifications[0].UserID != userID) {
+		c.JSON(http.StatusNotFound, models.NotificationResponse{
+			Success: false,
+			Message: "Notification not found",
+		})
+		return
+	}
+	if err == nil {
+		err = markNotificationsRead(ctx, userID, notifications)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NotificationResponse{
+			Success: false,
+			Message: "Failed to update notification",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NotificationResponse{
+		Success: true,
+		Message: "Notification marked as read",
+		Data:    notifications[0],
+	})
+}
+
+// MarkAllNotificationsRead marks every unread notification of a user as read
+func MarkAllNotificationsRead(c *gin.Context) {
+	userID := c.Param("id")
+	ctx := config.GetContext()
+
+	ids, err := config.GetRedisClient().SMembers(ctx, userUnreadNotificationsKey(userID)).Result()
+	var notifications []models.Notification
+	if err == nil {
+		notifications, err = loadNotifications(ctx, ids)
+	}
+	if err == nil {
+		err = markNotificationsRead(ctx, userID, notifications)
+	}
+	if err == nil {
+		// Drop IDs whose records have expired
+		err = config.GetRedisClient().Del(ctx, userUnreadNotificationsKey(userID)).Err()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NotificationResponse{
+			Success: false,
+			Message: "Failed to update notifications",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NotificationResponse{
+		Success: true,
+		Message: "All notifications marked as read",
+		Data:    gin.H{"updated": len(notifications)},
+	})
+}
+
+// markNotificationsRead sets read_at on unread notifications, updating the
+// slices in place, and removes them from the unread set
+func markNotificationsRead(ctx context.Context, userID string, notifications []models.Notification) error {
+	now := time.Now()
+	_, err := config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i := range notifications {
+			if notifications[i].ReadAt != nil {
+				continue
+			}
+			notifications[i].ReadAt = &now
+			notificationJSON, err := notifications[i].ToJSON()
+			if err != nil {
+				return err
+			}
+			pipe.Set(ctx, notificationKeyPrefix+notifications[i].ID, notificationJSON, redis.KeepTTL)
+			pipe.SRem(ctx, userUnreadNotificationsKey(userID), notifications[i].ID)
+		}
+		return nil
+	})
+	return err
+}
+
+// loadNotifications loads notifications by ID, preserving order and skipping missing records
+func loadNotifications(ctx context.Context, ids []string) ([]models.Notification, error) {
+	records, err := loadRecords(ctx, notificationKeyPrefix, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := []models.Notification{}
+	for _, record := range records {
+		var notification models.Notification
+		if notification.FromJSON(record) == nil {
+			notifications = append(notifications, notification)
+		}
+	}
+	return notifications, nil
+}
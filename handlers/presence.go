@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erea-api/config"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Presence of websocket and event stream clients across instances. Each
+// set is a sorted set scored by when its member expires; instances add
+// their clients as they connect and subscribe, remove them as they leave,
+// and refresh them every PresenceHeartbeatInterval, so an instance that dies
+// stops counting after PresenceTTL. Members are "{instance}/{member}", so an
+// instance leaving removes only its own; a user connected to several
+// instances is counted once.
+const (
+	presenceConnectionsKey = "presence:connections" // Connection IDs
+	presenceUsersKey       = "presence:users"       // IDs of signed-in users with a connection
+)
+
+// presenceCountScript counts the distinct live members of a presence set,
+// ignoring the instance part. KEYS: the set; ARGV: the current time (Unix
+// ms), exclusive.
+var presenceCountScript = redis.NewScript(`
+local members = redis.call('ZRANGEBYSCORE', KEYS[1], ARGV[1], '+inf')
+local seen, count = {}, 0
+for _, member in ipairs(members) do
+	local id = string.match(member, '^[^/]*/(.*)$') or member
+	if not seen[id] then
+		seen[id] = true
+		count = count + 1
+	end
+end
+return count
+`)
+
+// presenceMember returns a presence set member as written by an instance
+func presenceMember(instance, member string) string {
+	return instance + "/" + member
+}
+
+// presencePropertyKey holds the viewers of a property: "user:{id}" for
+// signed-in clients, "conn:{id}" for anonymous ones, per instance
+func presencePropertyKey(propertyID string) string {
+	return "presence:property:" + propertyID
+}
+
+// presenceCountKey holds the viewer count last announced for a property
+func presenceCountKey(propertyID string) string {
+	return "presence_count:" + propertyID
+}
+
+// PropertyWatchers is the data of a watching event
+type PropertyWatchers struct {
+	PropertyID string `json:"property_id"`
+	Watching   int64  `json:"watching"`
+}
+
+// presenceOp adds a member to or removes it from a presence set
+type presenceOp struct {
+	key    string
+	member string
+	join   bool
+
+	// For property viewers, so the new count is announced
+	propertyID string
+
+	// Viewer that joined, sent the count even if it didn't change
+	client *Client
+}
+
+// userID returns the ID of the client's user, "" for anonymous clients
+func (c *Client) userID() string {
+	if c.principal == nil {
+		return ""
+	}
+	return c.principal.UserID
+}
+
+// viewer returns the member a client counts as among a property's viewers,
+// so a user with several tabs open counts once
+func (c *Client) viewer() string {
+	if user := c.userID(); user != "" {
+		return "user:" + user
+	}
+	return "conn:" + c.id
+}
+
+// addConnection records a newly registered client. The mutex must be held.
+func (h *Hub) addConnection(client *Client) {
+	h.presence = append(h.presence, presenceOp{key: presenceConnectionsKey, member: client.id, join: true})
+	if user := client.userID(); user != "" {
+		h.users[user]++
+		if h.users[user] == 1 {
+			h.presence = append(h.presence, presenceOp{key: presenceUsersKey, member: user, join: true})
+		}
+	}
+}
+
+// removeConnection records a client leaving. Its user stays online while
+// another of their clients is connected. The mutex must be held.
+func (h *Hub) removeConnection(client *Client) {
+	h.presence = append(h.presence, presenceOp{key: presenceConnectionsKey, member: client.id})
+	if user := client.userID(); user != "" {
+		h.users[user]--
+		if h.users[user] <= 0 {
+			delete(h.users, user)
+			h.presence = append(h.presence, presenceOp{key: presenceUsersKey, member: user})
+		}
+	}
+}
+
+// addViewer records a client subscribing to a property. The mutex must be
+// held.
+func (h *Hub) addViewer(client *Client, propertyID string) {
+	viewer := client.viewer()
+	if h.viewers[propertyID] == nil {
+		h.viewers[propertyID] = make(map[string]int)
+	}
+	h.viewers[propertyID][viewer]++
+	h.presence = append(h.presence, presenceOp{
+		key:        presencePropertyKey(propertyID),
+		member:     viewer,
+		join:       true,
+		propertyID: propertyID,
+		client:     client,
+	})
+}
+
+// removeViewer records a client leaving a property. A user stays a viewer
+// while another of their clients follows it. The mutex must be held.
+func (h *Hub) removeViewer(client *Client, propertyID string) {
+	viewer := client.viewer()
+	viewers := h.viewers[propertyID]
+	viewers[viewer]--
+	if viewers[viewer] > 0 {
+		return
+	}
+	delete(viewers, viewer)
+	if len(viewers) == 0 {
+		delete(h.viewers, propertyID)
+	}
+	h.presence = append(h.presence, presenceOp{
+		key:        presencePropertyKey(propertyID),
+		member:     viewer,
+		propertyID: propertyID,
+	})
+}
+
+// flushPresence writes the presence changes recorded so far to Redis and
+// announces the properties whose viewer count changed. The mutex must not
+// be held.
+func (h *Hub) flushPresence() {
+	ctx := config.GetContext()
+
+	// Changes are written in the order they happened
+	h.presenceMutex.Lock()
+	h.mutex.Lock()
+	ops := h.presence
+	h.presence = nil
+	h.mutex.Unlock()
+	err := writePresence(ctx, h.id, ops, false)
+	h.presenceMutex.Unlock()
+
+	if err != nil {
+		log.Printf("Failed to update presence: %v", err)
+		return
+	}
+	h.announceWatchers(ctx, ops)
+}
+
+// writePresence applies an instance's presence changes in one round trip.
+// With expire, members whose heartbeats stopped are removed from the sets
+// too.
+func writePresence(ctx context.Context, instance string, ops []presenceOp, expire bool) error {
+	if len(ops) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	expiry := float64(now.Add(config.PresenceTTL).UnixMilli())
+	expired := "(" + strconv.FormatInt(now.UnixMilli(), 10)
+	cleaned := make(map[string]bool)
+
+	pipe := config.GetRedisClient().Pipeline()
+	for _, op := range ops {
+		if expire && !cleaned[op.key] {
+			cleaned[op.key] = true
+			pipe.ZRemRangeByScore(ctx, op.key, "-inf", expired)
+		}
+		if op.member == "" {
+			continue
+		}
+		member := presenceMember(instance, op.member)
+		if !op.join {
+			pipe.ZRem(ctx, op.key, member)
+			continue
+		}
+		pipe.ZAdd(ctx, op.key, &redis.Z{Score: expiry, Member: member})
+		pipe.Expire(ctx, op.key, config.PresenceTTL)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// presenceCount returns the number of distinct live members of a presence
+// set across instances
+func presenceCount(ctx context.Context, key string) (int64, error) {
+	live := "(" + strconv.FormatInt(time.Now().UnixMilli(), 10)
+	return presenceCountScript.Run(ctx, config.GetRedisClient(), []string{key}, live).Int64()
+}
+
+// announceWatchers sends a watching event to the subscribers of each
+// property in ops whose viewer count changed since it was last announced,
+// by any instance. Viewers that joined get the count even if it didn't.
+// presenceMutex must not be held.
+func (h *Hub) announceWatchers(ctx context.Context, ops []presenceOp) {
+	joined := make(map[string][]*Client)
+	for _, op := range ops {
+		if op.propertyID == "" {
+			continue
+		}
+		if _, exists := joined[op.propertyID]; !exists {
+			joined[op.propertyID] = nil
+		}
+		if op.client != nil {
+			joined[op.propertyID] = append(joined[op.propertyID], op.client)
+		}
+	}
+
+	rdb := config.GetRedisClient()
+	for propertyID, clients := range joined {
+		count, err := presenceCount(ctx, presencePropertyKey(propertyID))
+		if err != nil {
+			log.Printf("Failed to count viewers of %s: %v", propertyID, err)
+			continue
+		}
+
+		pipe := rdb.Pipeline()
+		previousCmd := pipe.GetSet(ctx, presenceCountKey(propertyID), count)
+		pipe.Expire(ctx, presenceCountKey(propertyID), config.PresenceTTL)
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			log.Printf("Failed to record viewers of %s: %v", propertyID, err)
+			continue
+		}
+
+		messageJSON, err := json.Marshal(watchingMessage(propertyID, count))
+		if err != nil {
+			log.Printf("Failed to marshal watching: %v", err)
+			continue
+		}
+		if previous, err := previousCmd.Int64(); err != nil || previous != count {
+			h.fanOut(ctx, messageJSON, nil, propertyTopic(propertyID))
+			continue
+		}
+		for _, client := range clients {
+			h.sendTo(client, messageJSON)
+		}
+	}
+}
+
+// watchingMessage returns a watching event for a property's viewer count
+func watchingMessage(propertyID string, count int64) WebSocketMessage {
+	message := fmt.Sprintf("%d people watching", count)
+	if count == 1 {
+		message = "1 person watching"
+	}
+	return WebSocketMessage{
+		Type:    "watching",
+		Data:    PropertyWatchers{PropertyID: propertyID, Watching: count},
+		Message: message,
+	}
+}
+
+// RunPresence refreshes the presence of this instance's clients every
+// PresenceHeartbeatInterval, dropping members whose instance stopped
+// refreshing them, until ctx is done
+func RunPresence(ctx context.Context) {
+	ticker := time.NewTicker(config.PresenceHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := hub.refreshPresence(ctx); err != nil {
+			log.Printf("Failed to refresh presence: %v", err)
+		}
+	}
+}
+
+// refreshPresence writes every client of the hub to the presence sets
+// again and announces viewer counts that changed as other members expired
+func (h *Hub) refreshPresence(ctx context.Context) error {
+	ops := []presenceOp{
+		// Cleaned even when this instance has no clients
+		{key: presenceConnectionsKey},
+		{key: presenceUsersKey},
+	}
+
+	// Hold off other changes, so none is overwritten by this snapshot. The
+	// lock is released before announcing: announcing can evict a slow client,
+	// which flushes presence and takes the lock again.
+	h.presenceMutex.Lock()
+	h.mutex.RLock()
+	for client := range h.clients {
+		ops = append(ops, presenceOp{key: presenceConnectionsKey, member: client.id, join: true})
+	}
+	for user := range h.users {
+		ops = append(ops, presenceOp{key: presenceUsersKey, member: user, join: true})
+	}
+	for propertyID, viewers := range h.viewers {
+		for viewer := range viewers {
+			ops = append(ops, presenceOp{
+				key:        presencePropertyKey(propertyID),
+				member:     viewer,
+				join:       true,
+				propertyID: propertyID,
+			})
+		}
+	}
+	h.mutex.RUnlock()
+	err := writePresence(ctx, h.id, ops, true)
+	h.presenceMutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+	h.announceWatchers(ctx, ops)
+	return nil
+}
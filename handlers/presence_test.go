@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"erea-api/middleware"
+	"testing"
+	"time"
+)
+
+func TestRefreshPresenceEvictsSlowViewer(t *testing.T) {
+	mr := newTestRedis(t)
+	h := newHub()
+
+	// Registering announces one viewer, which fills the client's buffer
+	slow := newTestClient(h, 1, propertyTopic("p1"))
+	h.register(slow)
+	if len(slow.send) != 1 {
+		t.Fatalf("expected the watching event to be queued, got %d messages", len(slow.send))
+	}
+
+	// Forget the announced count, so the refresh announces it again to the
+	// full buffer and evicts the client
+	mr.Del(presenceCountKey("p1"))
+
+	done := make(chan error, 1)
+	go func() { done <- h.refreshPresence(context.Background()) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("refreshPresence: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("refreshPresence deadlocked evicting a slow client")
+	}
+
+	h.mutex.RLock()
+	registered, closed := h.clients[slow], slow.closed
+	h.mutex.RUnlock()
+	if registered || !closed {
+		t.Fatalf("expected the slow client to be evicted, registered=%v closed=%v", registered, closed)
+	}
+
+	// The hub still takes new clients
+	registerDone := make(chan struct{})
+	go func() {
+		h.register(newTestClient(h, 8, propertyTopic("p1")))
+		close(registerDone)
+	}()
+	select {
+	case <-registerDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("register blocked after the eviction")
+	}
+
+	if members, _ := mr.ZMembers(presencePropertyKey("p1")); len(members) != 1 {
+		t.Fatalf("expected one viewer left, got %v", members)
+	}
+}
+
+func TestPresenceCountsUserOnceAcrossInstances(t *testing.T) {
+	newTestRedis(t)
+	ctx := context.Background()
+	a, b := newHub(), newHub()
+
+	user := &middleware.Principal{ID: "k1", Type: middleware.PrincipalAPIKey, UserID: "u1"}
+	onA := newTestClient(a, 8, propertyTopic("p1"))
+	onA.principal = user
+	onB := newTestClient(b, 8, propertyTopic("p1"))
+	onB.principal = user
+	a.register(onA)
+	b.register(onB)
+
+	count := func(key string) int64 {
+		t.Helper()
+		n, err := presenceCount(ctx, key)
+		if err != nil {
+			t.Fatalf("presenceCount: %v", err)
+		}
+		return n
+	}
+	if online, watching := count(presenceUsersKey), count(presencePropertyKey("p1")); online != 1 || watching != 1 {
+		t.Fatalf("expected the user counted once, got online=%d watching=%d", online, watching)
+	}
+
+	// Leaving one instance keeps the user online and watching through the
+	// other
+	a.unregister(onA)
+	if online, watching := count(presenceUsersKey), count(presencePropertyKey("p1")); online != 1 || watching != 1 {
+		t.Fatalf("expected the user still counted, got online=%d watching=%d", online, watching)
+	}
+
+	b.unregister(onB)
+	if online, watching := count(presenceUsersKey), count(presencePropertyKey("p1")); online != 0 || watching != 0 {
+		t.Fatalf("expected nobody left, got online=%d watching=%d", online, watching)
+	}
+}
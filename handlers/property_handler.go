@@ -1,51 +1,34 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"erea-api/config"
 	"erea-api/models"
+	"errors"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 )
 
-// GetAllProperties retrieves all properties from Redis
-func GetAllProperties(c *gin.Context) {
-	redis := config.GetRedisClient()
-	ctx := config.GetContext()
-
-	// Get all property IDs
-	keys, err := redis.Keys(ctx, "property:*").Result()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
-			Success: false,
-			Message: "Failed to retrieve properties",
-			Error:   err.Error(),
-		})
-		return
-	}
-
-	var properties []models.Property
-	for _, key := range keys {
-		propertyJSON, err := redis.Get(ctx, key).Result()
-		if err != nil {
-			continue
-		}
-
-		var property models.Property
-		if err := property.FromJSON(propertyJSON); err != nil {
-			continue
-		}
-
-		properties = append(properties, property)
-	}
+// propertySortKeys are the sortable fields of property lists
+var propertySortKeys = listSortKeys{
+	"created_at": propertiesByCreatedKey,
+	"price":      propertiesByPriceKey,
+	"end_date":   propertiesByEndDateKey,
+}
 
-	c.JSON(http.StatusOK, models.PropertyResponse{
-		Success: true,
-		Message: "Properties retrieved successfully",
-		Data:    properties,
-	})
+// GetAllProperties retrieves properties a page at a time. Supports status,
+// type, owner_id, min_price, max_price, from/to (creation date) and attr.*
+// attribute filters and sorting by created_at, price or end_date. Without a
+// status only public listings are returned.
+func GetAllProperties(c *gin.Context) {
+	listProperties(c, c.Query("status"))
 }
 
 // GetProperty retrieves a specific property by ID
@@ -81,7 +64,8 @@ func GetProperty(c *gin.Context) {
 	})
 }
 
-// CreateProperty creates a new property
+// CreateProperty creates a new listing as a Draft, or submits it for review
+// right away when submit is true. Listings only go live after approval.
 func CreateProperty(c *gin.Context) {
 	var req models.CreatePropertyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -93,40 +77,35 @@ func CreateProperty(c *gin.Context) {
 		return
 	}
 
-	// Create new property
-	property := models.Property{
-		ID:            uuid.New().String(),
-		Title:         req.Title,
-		Location:      req.Location,
-		Description:   req.Description,
-		Type:          req.Type,
-		Area:          req.Area,
-		StartingPrice: req.StartingPrice,
-		CurrentPrice:  req.StartingPrice,
-		ImageURL:      req.ImageURL,
-		Features:      req.Features,
-		Status:        "Active",
-		EndDate:       req.EndDate,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
-		OwnerID:       req.OwnerID,
+	if err := validateCoordinates(req.Latitude, req.Longitude); err != nil {
+		c.JSON(http.StatusBadRequest, models.PropertyResponse{
+			Success: false,
+			Message: "Invalid request data",
+			Error:   err.Error(),
+		})
+		return
 	}
 
-	// Save to Redis
-	redis := config.GetRedisClient()
-	ctx := config.GetContext()
-
-	propertyJSON, err := property.ToJSON()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+	attributes, problems := models.ValidateAttributes(req.Type, req.Attributes)
+	if len(problems) > 0 {
+		c.JSON(http.StatusBadRequest, models.PropertyResponse{
 			Success: false,
-			Message: "Failed to encode property data",
-			Error:   err.Error(),
+			Message: "Invalid request data",
+			Error:   strings.Join(problems, "; "),
 		})
 		return
 	}
+	req.Attributes = attributes
+
+	property := newProperty(req)
 
-	if err := redis.Set(ctx, "property:"+property.ID, propertyJSON, 0).Err(); err != nil {
+	// Save to Redis together with the property indexes and its first
+	// version, queueing it for review when submitted
+	ctx := config.GetContext()
+	_, err := config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		return writeNewProperty(ctx, pipe, property, callerID(c))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
 			Success: false,
 			Message: "Failed to save property",
@@ -135,8 +114,7 @@ func CreateProperty(c *gin.Context) {
 		return
 	}
 
-	// Add to property list
-	redis.SAdd(ctx, "properties", property.ID)
+	recordAudit(c, "property.create", "property", property.ID, nil, property)
 
 	c.JSON(http.StatusCreated, models.PropertyResponse{
 		Success: true,
@@ -145,7 +123,62 @@ func CreateProperty(c *gin.Context) {
 	})
 }
 
-// UpdateProperty updates an existing property
+// newProperty builds a listing from a create request: a Draft, or
+// PendingReview when submit is set, placed on the map from its address when
+// no coordinates were given
+func newProperty(req models.CreatePropertyRequest) models.Property {
+	now := time.Now()
+	property := models.Property{
+		ID:            uuid.New().String(),
+		Title:         req.Title,
+		Location:      req.Location,
+		Description:   req.Description,
+		Type:          req.Type,
+		Area:          req.Area,
+		StartingPrice: req.StartingPrice,
+		CurrentPrice:  req.StartingPrice,
+		ImageURL:      req.ImageURL,
+		Features:      req.Features,
+		Status:        models.PropertyStatusDraft,
+		EndDate:       req.EndDate,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		OwnerID:       req.OwnerID,
+		Latitude:      req.Latitude,
+		Longitude:     req.Longitude,
+		Version:       1,
+		Attributes:    req.Attributes,
+	}
+
+	geocodeProperty(&property)
+
+	if req.Submit {
+		property.Status = models.PropertyStatusPendingReview
+		property.SubmittedAt = &property.CreatedAt
+	}
+	return property
+}
+
+// writeNewProperty queues the write of a new property, its indexes and its
+// first version, and of the submit review entry when it starts out pending
+// review
+func writeNewProperty(ctx context.Context, pipe redis.Pipeliner, property models.Property, actor string) error {
+	if err := writeProperty(ctx, pipe, nil, property); err != nil {
+		return err
+	}
+	if err := appendPropertyVersion(ctx, pipe, newPropertyVersion(property, nil, actor)); err != nil {
+		return err
+	}
+	if property.Status == models.PropertyStatusPendingReview {
+		return appendPropertyReview(ctx, pipe, newPropertyReview(property.ID, models.ReviewActionSubmit, "", actor, models.PropertyStatusDraft, property.Status))
+	}
+	return nil
+}
+
+// UpdateProperty updates an existing property and stores the change as a
+// new version. Title, features, status, coordinates and attributes are
+// locked while the property's auction is active; other changes are broadcast
+// to bidders.
 func UpdateProperty(c *gin.Context) {
 	propertyID := c.Param("id")
 	var req models.UpdatePropertyRequest
@@ -158,60 +191,85 @@ func UpdateProperty(c *gin.Context) {
 		return
 	}
 
-	redis := config.GetRedisClient()
+	if err := validateCoordinates(req.Latitude, req.Longitude); err != nil {
+		c.JSON(http.StatusBadRequest, models.PropertyResponse{
+			Success: false,
+			Message: "Invalid request data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
 	ctx := config.GetContext()
+	before, property, version, err := updatePropertyVersioned(ctx, propertyID, callerID(c), func(property *models.Property) error {
+		// Review statuses only change through the review endpoints, and a
+		// listing under review cannot be pushed live by editing its status
+		if req.Status != "" && (models.IsReviewStatus(req.Status) || models.IsReviewStatus(property.Status)) {
+			return errReviewStatusChange
+		}
 
-	// Get existing property
-	propertyJSON, err := redis.Get(ctx, "property:"+propertyID).Result()
-	if err != nil {
+		// Update fields
+		if req.Title != "" {
+			property.Title = req.Title
+		}
+		if req.Description != "" {
+			property.Description = req.Description
+		}
+		if req.ImageURL != "" {
+			property.ImageURL = req.ImageURL
+		}
+		if req.Features != nil {
+			property.Features = req.Features
+		}
+		if req.Status != "" {
+			property.Status = req.Status
+		}
+		if req.Latitude != nil {
+			property.Latitude = req.Latitude
+			property.Longitude = req.Longitude
+		}
+		if req.Attributes != nil {
+			attributes, problems := models.ValidateAttributes(property.Type, mergeAttributes(property.Attributes, req.Attributes))
+			if len(problems) > 0 {
+				return &attributeError{problems: problems}
+			}
+			property.Attributes = attributes
+		}
+		return nil
+	})
+
+	var lockErr *auctionLockError
+	var attrErr *attributeError
+	switch {
+	case isNotFound(err):
 		c.JSON(http.StatusNotFound, models.PropertyResponse{
 			Success: false,
 			Message: "Property not found",
 			Error:   err.Error(),
 		})
 		return
-	}
-
-	var property models.Property
-	if err := property.FromJSON(propertyJSON); err != nil {
-		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+	case errors.Is(err, errReviewStatusChange):
+		c.JSON(http.StatusConflict, models.PropertyResponse{
 			Success: false,
-			Message: "Failed to parse property data",
+			Message: "Listing status cannot be changed directly",
 			Error:   err.Error(),
 		})
 		return
-	}
-
-	// Update fields
-	if req.Title != "" {
-		property.Title = req.Title
-	}
-	if req.Description != "" {
-		property.Description = req.Description
-	}
-	if req.ImageURL != "" {
-		property.ImageURL = req.ImageURL
-	}
-	if req.Features != nil {
-		property.Features = req.Features
-	}
-	if req.Status != "" {
-		property.Status = req.Status
-	}
-	property.UpdatedAt = time.Now()
-
-	// Save updated property
-	updatedJSON, err := property.ToJSON()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+	case errors.As(err, &attrErr):
+		c.JSON(http.StatusBadRequest, models.PropertyResponse{
 			Success: false,
-			Message: "Failed to encode updated property data",
+			Message: "Invalid request data",
 			Error:   err.Error(),
 		})
 		return
-	}
-
-	if err := redis.Set(ctx, "property:"+propertyID, updatedJSON, 0).Err(); err != nil {
+	case errors.As(err, &lockErr):
+		c.JSON(http.StatusConflict, models.PropertyResponse{
+			Success: false,
+			Message: "Fields are locked while the auction is active",
+			Error:   err.Error(),
+		})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
 			Success: false,
 			Message: "Failed to update property",
@@ -220,6 +278,17 @@ func UpdateProperty(c *gin.Context) {
 		return
 	}
 
+	if version == nil {
+		c.JSON(http.StatusOK, models.PropertyResponse{
+			Success: true,
+			Message: "Property unchanged",
+			Data:    property,
+		})
+		return
+	}
+
+	recordAudit(c, "property.update", "property", propertyID, before, property)
+
 	c.JSON(http.StatusOK, models.PropertyResponse{
 		Success: true,
 		Message: "Property updated successfully",
@@ -233,9 +302,9 @@ func DeleteProperty(c *gin.Context) {
 	redis := config.GetRedisClient()
 	ctx := config.GetContext()
 
-	// Check if property exists
-	exists, err := redis.Exists(ctx, "property:"+propertyID).Result()
-	if err != nil || exists == 0 {
+	// Check if property exists (and keep a snapshot for the audit log)
+	propertyJSON, err := redis.Get(ctx, "property:"+propertyID).Result()
+	if err != nil {
 		c.JSON(http.StatusNotFound, models.PropertyResponse{
 			Success: false,
 			Message: "Property not found",
@@ -243,8 +312,18 @@ func DeleteProperty(c *gin.Context) {
 		return
 	}
 
-	// Delete property
-	if err := redis.Del(ctx, "property:"+propertyID).Err(); err != nil {
+	var property models.Property
+	if err := property.FromJSON(propertyJSON); err != nil {
+		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+			Success: false,
+			Message: "Failed to parse property data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Delete property and remove it from the property indexes
+	if err := deleteProperty(ctx, property); err != nil {
 		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
 			Success: false,
 			Message: "Failed to delete property",
@@ -253,8 +332,18 @@ func DeleteProperty(c *gin.Context) {
 		return
 	}
 
-	// Remove from property list
-	redis.SRem(ctx, "properties", propertyID)
+	// Remove uploaded photos and documents
+	if err := deletePropertyMedia(ctx, propertyID); err != nil {
+		log.Printf("Failed to delete media of property %s: %v", propertyID, err)
+	}
+	if err := deletePropertyWatchers(ctx, propertyID); err != nil {
+		log.Printf("Failed to delete watchers of property %s: %v", propertyID, err)
+	}
+	if err := deletePropertyInspections(ctx, propertyID); err != nil {
+		log.Printf("Failed to delete inspections of property %s: %v", propertyID, err)
+	}
+
+	recordAudit(c, "property.delete", "property", propertyID, json.RawMessage(propertyJSON), nil)
 
 	c.JSON(http.StatusOK, models.PropertyResponse{
 		Success: true,
@@ -262,46 +351,72 @@ func DeleteProperty(c *gin.Context) {
 	})
 }
 
-// GetPropertiesByStatus retrieves properties by status
+// GetPropertiesByStatus retrieves properties by status, paginated like GetAllProperties
 func GetPropertiesByStatus(c *gin.Context) {
 	status := c.Query("status")
 	if status == "" {
 		status = "Active"
 	}
 
-	redis := config.GetRedisClient()
-	ctx := config.GetContext()
+	listProperties(c, status)
+}
+
+// listProperties writes one page of properties matching the query filters
+func listProperties(c *gin.Context, status string) {
+	var minPrice, maxPrice *int64
+	var from, to *time.Time
 
-	keys, err := redis.Keys(ctx, "property:*").Result()
+	q, err := parseListQuery(c, propertySortKeys, "-created_at")
+	if err == nil {
+		minPrice, err = queryInt64(c, "min_price")
+	}
+	if err == nil {
+		maxPrice, err = queryInt64(c, "max_price")
+	}
+	if err == nil {
+		from, to, err = queryTimeRange(c)
+	}
+	var attributeFilters []models.AttributeFilter
+	if err == nil {
+		attributeFilters, err = queryAttributeFilters(c)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+		c.JSON(http.StatusBadRequest, models.PropertyResponse{
 			Success: false,
-			Message: "Failed to retrieve properties",
+			Message: "Invalid query parameters",
 			Error:   err.Error(),
 		})
 		return
 	}
 
-	var properties []models.Property
-	for _, key := range keys {
-		propertyJSON, err := redis.Get(ctx, key).Result()
-		if err != nil {
-			continue
-		}
-
-		var property models.Property
-		if err := property.FromJSON(propertyJSON); err != nil {
-			continue
-		}
-
-		if property.Status == status {
-			properties = append(properties, property)
-		}
+	propertyType := c.Query("type")
+	ownerID := c.Query("owner_id")
+	q.narrow("price", int64Score(minPrice), int64Score(maxPrice))
+	q.narrow("created_at", timeScore(from), timeScore(to))
+
+	properties, page, err := paginate(config.GetContext(), q, loadProperties,
+		func(property models.Property) string { return property.ID },
+		func(property models.Property) bool {
+			return ((status == "" && models.IsPublicStatus(property.Status)) || property.Status == status) &&
+				(propertyType == "" || property.Type == propertyType) &&
+				(ownerID == "" || property.OwnerID == ownerID) &&
+				inInt64Range(property.CurrentPrice, minPrice, maxPrice) &&
+				inTimeRange(property.CreatedAt, from, to) &&
+				models.MatchAttributes(property.Attributes, attributeFilters)
+		})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+			Success: false,
+			Message: "Failed to retrieve properties",
+			Error:   err.Error(),
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, models.PropertyResponse{
-		Success: true,
-		Message: "Properties retrieved successfully",
-		Data:    properties,
+		Success:    true,
+		Message:    "Properties retrieved successfully",
+		Data:       properties,
+		Pagination: page,
 	})
 }
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"erea-api/models"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const attributeQueryPrefix = "attr."
+
+// attributeError is returned when property attributes don't match the
+// schema of the property type
+type attributeError struct {
+	problems []string
+}
+
+func (e *attributeError) Error() string {
+	return strings.Join(e.problems, "; ")
+}
+
+// GetPropertyTypes lists the property types with the typed attributes each
+// accepts, for building listing forms and filters
+func GetPropertyTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, models.PropertyResponse{
+		Success: true,
+		Message: "Property types retrieved successfully",
+		Data:    models.PropertyTypeSchemas,
+	})
+}
+
+// queryAttributeFilters parses attribute filters from the query string:
+// attr.<name>=value for an exact match, and attr.<name>.min and
+// attr.<name>.max for numeric ranges, e.g. attr.rooms.min=3
+func queryAttributeFilters(c *gin.Context) ([]models.AttributeFilter, error) {
+	values := make(map[string]string)
+	for key := range c.Request.URL.Query() {
+		if strings.HasPrefix(key, attributeQueryPrefix) {
+			values[strings.TrimPrefix(key, attributeQueryPrefix)] = c.Query(key)
+		}
+	}
+	return parseAttributeFilters(values, attributeQueryPrefix)
+}
+
+// parseAttributeFilters parses attribute filters keyed by <name>, for an
+// exact match, or <name>.min and <name>.max. prefix is how the keys are
+// named in error messages.
+func parseAttributeFilters(values map[string]string, prefix string) ([]models.AttributeFilter, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var filters []models.AttributeFilter
+	byName := make(map[string]int)
+	for _, key := range keys {
+		name, bound, _ := strings.Cut(key, ".")
+		spec, ok := models.AttributeSpecOf(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown attribute %q", name)
+		}
+		i, ok := byName[name]
+		if !ok {
+			i = len(filters)
+			byName[name] = i
+			filters = append(filters, models.AttributeFilter{Name: name})
+		}
+
+		value := values[key]
+		switch bound {
+		case "":
+			equals, err := spec.ParseValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s%s %v", prefix, key, err)
+			}
+			filters[i].Equals = equals
+		case "min", "max":
+			if spec.Kind != models.AttributeKindInteger && spec.Kind != models.AttributeKindNumber {
+				return nil, fmt.Errorf("%s%s: %s is not numeric", prefix, key, name)
+			}
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s%s must be a number", prefix, key)
+			}
+			if bound == "min" {
+				filters[i].Min = &n
+			} else {
+				filters[i].Max = &n
+			}
+		default:
+			return nil, fmt.Errorf("unknown attribute filter %q, expected %s<name>, .min or .max", prefix+key, prefix)
+		}
+	}
+	return filters, nil
+}
+
+// mergeAttributes applies an attribute update to the current attributes:
+// given values replace the current ones and null values remove them
+func mergeAttributes(current, update map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(current)+len(update))
+	for name, value := range current {
+		merged[name] = value
+	}
+	for name, value := range update {
+		if value == nil {
+			delete(merged, name)
+		} else {
+			merged[name] = value
+		}
+	}
+	return merged
+}
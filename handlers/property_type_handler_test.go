@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"erea-api/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateAttributesNormalizesAndReportsProblems(t *testing.T) {
+	attributes, problems := models.ValidateAttributes(models.PropertyTypeApartment, map[string]interface{}{
+		"rooms":  3.0, // JSON numbers arrive as float64
+		"facing": "south",
+		"floor":  nil, // Null values are dropped
+	})
+	if len(problems) != 0 {
+		t.Fatalf("unexpected problems %v", problems)
+	}
+	if want := map[string]interface{}{"rooms": int64(3), "facing": "south"}; !reflect.DeepEqual(attributes, want) {
+		t.Fatalf("got %#v, want %#v", attributes, want)
+	}
+
+	_, problems = models.ValidateAttributes(models.PropertyTypeCommercial, map[string]interface{}{
+		"rooms":          2.0,
+		"floor":          2.5,
+		"build_year":     1800.0,
+		"parking_spaces": "many",
+	})
+	joined := strings.Join(problems, "; ")
+	for _, want := range []string{
+		"rooms is not an attribute of Commercial",
+		"floor must be a whole number",
+		"build_year must be at least 1900",
+		"parking_spaces must be a number",
+		"zoning is required",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected %q among %q", want, joined)
+		}
+	}
+
+	if _, problems := models.ValidateAttributes("Castle", nil); len(problems) != 1 {
+		t.Fatalf("expected an unknown type to be reported, got %v", problems)
+	}
+}
+
+func TestAttributeFiltersMatch(t *testing.T) {
+	filters, err := parseAttributeFilters(map[string]string{
+		"rooms.min": "2",
+		"rooms.max": "4",
+		"facing":    "South",
+	}, attributeQueryPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		attributes map[string]interface{}
+		want       bool
+	}{
+		{map[string]interface{}{"rooms": int64(3), "facing": "south"}, true},
+		{map[string]interface{}{"rooms": float64(4), "facing": "south"}, true},
+		{map[string]interface{}{"rooms": int64(5), "facing": "south"}, false},
+		{map[string]interface{}{"rooms": int64(3), "facing": "north"}, false},
+		{map[string]interface{}{"facing": "south"}, false}, // Missing attributes never match
+	}
+	for _, tc := range cases {
+		if got := models.MatchAttributes(tc.attributes, filters); got != tc.want {
+			t.Errorf("MatchAttributes(%v) = %v, want %v", tc.attributes, got, tc.want)
+		}
+	}
+
+	for _, bad := range []map[string]string{
+		{"moat": "true"},
+		{"facing.min": "1"},
+		{"rooms.min": "few"},
+		{"rooms.avg": "2"},
+	} {
+		if _, err := parseAttributeFilters(bad, attributeQueryPrefix); err == nil {
+			t.Errorf("expected %v to be refused", bad)
+		}
+	}
+}
+
+func TestMergeAttributes(t *testing.T) {
+	merged := mergeAttributes(
+		map[string]interface{}{"rooms": int64(3), "facing": "south"},
+		map[string]interface{}{"rooms": int64(4), "facing": nil, "floor": int64(2)},
+	)
+	if want := map[string]interface{}{"rooms": int64(4), "floor": int64(2)}; !reflect.DeepEqual(merged, want) {
+		t.Fatalf("got %#v, want %#v", merged, want)
+	}
+}
@@ -0,0 +1,398 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erea-api/config"
+	"erea-api/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	propertyVersionMaxRetries = 5
+	propertyVersionPageSize   = 50 // Versions read at a time when rebuilding a snapshot
+)
+
+// auctionLockedFields may not change while the property's auction is
+// active, so bidders keep bidding on what they saw. Other fields (such as
+// the description and image) may change, and every change is broadcast.
+var auctionLockedFields = map[string]bool{
+	"title":      true,
+	"features":   true,
+	"status":     true,
+	"latitude":   true,
+	"longitude":  true,
+	"attributes": true,
+}
+
+// errReviewStatusChange is returned when an update tries to set a review
+// status or change the status of a listing under review
+var errReviewStatusChange = errors.New("use the review endpoints to submit, approve, reject or request changes on a listing")
+
+// auctionLockError is returned when an update changes fields locked by an
+// active auction
+type auctionLockError struct {
+	fields []string
+}
+
+func (e *auctionLockError) Error() string {
+	return "cannot change " + strings.Join(e.fields, ", ") + " while the auction is active"
+}
+
+// propertyVersionsKey is the list of a property's versions, oldest first.
+// Version n is stored at index n-1.
+func propertyVersionsKey(propertyID string) string {
+	return "property_versions:" + propertyID
+}
+
+// newPropertyVersion creates the version record of property. before is the
+// previous version, or nil for a new property. Versions that only change the
+// current price, i.e. one per bid, keep their changes but no snapshot.
+func newPropertyVersion(property models.Property, before *models.Property, changedBy string) models.PropertyVersion {
+	if changedBy == "" {
+		changedBy = "anonymous"
+	}
+	version := models.PropertyVersion{
+		PropertyID: property.ID,
+		Version:    property.Version,
+		Changes:    []models.FieldChange{},
+		ChangedBy:  changedBy,
+		CreatedAt:  property.UpdatedAt,
+		Snapshot:   &property,
+	}
+	if before != nil {
+		version.Changes = models.DiffProperties(*before, property)
+		if len(version.Changes) == 1 && version.Changes[0].Field == "current_price" {
+			version.Snapshot = nil
+		}
+	}
+	return version
+}
+
+// propertyVersionSnapshot returns the property as of version, rebuilding it
+// from the latest earlier snapshot and the changes since when the version
+// has none
+func propertyVersionSnapshot(ctx context.Context, version models.PropertyVersion) (*models.Property, error) {
+	if version.Snapshot != nil {
+		return version.Snapshot, nil
+	}
+
+	// Walk back to the latest snapshot, a page of versions at a time
+	rdb := config.GetRedisClient()
+	later := []models.PropertyVersion{version}
+	for end := int64(version.Version - 2); end >= 0; end -= propertyVersionPageSize {
+		start := end - propertyVersionPageSize + 1
+		if start < 0 {
+			start = 0
+		}
+		records, err := rdb.LRange(ctx, propertyVersionsKey(version.PropertyID), start, end).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i := len(records) - 1; i >= 0; i-- {
+			var earlier models.PropertyVersion
+			if err := json.Unmarshal([]byte(records[i]), &earlier); err != nil {
+				return nil, err
+			}
+			if earlier.Snapshot == nil {
+				later = append(later, earlier)
+				continue
+			}
+
+			property := *earlier.Snapshot
+			for j := len(later) - 1; j >= 0; j-- {
+				var err error
+				if property, err = models.ApplyChanges(property, later[j].Changes); err != nil {
+					return nil, err
+				}
+			}
+			property.Version = version.Version
+			property.UpdatedAt = version.CreatedAt
+			return &property, nil
+		}
+	}
+	return nil, fmt.Errorf("no snapshot before version %d", version.Version)
+}
+
+// appendPropertyVersion queues a version record
+func appendPropertyVersion(ctx context.Context, pipe redis.Pipeliner, version models.PropertyVersion) error {
+	versionJSON, err := json.Marshal(version)
+	if err != nil {
+		return err
+	}
+	pipe.RPush(ctx, propertyVersionsKey(version.PropertyID), versionJSON)
+	return nil
+}
+
+// updatePropertyVersioned applies an update to a property and stores the
+// result as a new version. The property is watched so concurrent updates
+// get consecutive versions. It returns a nil version, and writes nothing,
+// when the update changes no fields.
+func updatePropertyVersioned(ctx context.Context, propertyID, changedBy string, apply func(*models.Property) error) (models.Property, models.Property, *models.PropertyVersion, error) {
+	return applyPropertyUpdate(ctx, propertyID, changedBy, propertyUpdate{
+		apply: func(_ *redis.Tx, property *models.Property) error {
+			return apply(property)
+		},
+	})
+}
+
+// propertyUpdate is a change to a property made by applyPropertyUpdate
+type propertyUpdate struct {
+	// apply changes the property. Keys it watches and reads through tx can't
+	// change before the update commits, as the property can't.
+	apply func(tx *redis.Tx, property *models.Property) error
+
+	// write, if set, queues records stored in the same transaction as the
+	// property, such as the bid that raised its price. It is called even
+	// when the property didn't change.
+	write func(pipe redis.Pipeliner, before, property models.Property) error
+
+	// auction marks changes made by the property's own auction, such as
+	// activating or closing it, which the auction lock doesn't apply to
+	auction bool
+}
+
+// applyPropertyUpdate is updatePropertyVersioned for updates that read or
+// write other records in the same transaction. Every change to a stored
+// property goes through it, so each one is a version and is broadcast to
+// the property's topic.
+func applyPropertyUpdate(ctx context.Context, propertyID, changedBy string, update propertyUpdate) (models.Property, models.Property, *models.PropertyVersion, error) {
+	rdb := config.GetRedisClient()
+	var before, property models.Property
+	var version *models.PropertyVersion
+
+	for attempt := 0; attempt < propertyVersionMaxRetries; attempt++ {
+		version = nil
+		err := rdb.Watch(ctx, func(tx *redis.Tx) error {
+			propertyJSON, err := tx.Get(ctx, "property:"+propertyID).Result()
+			if err != nil {
+				return err
+			}
+			if err := before.FromJSON(propertyJSON); err != nil {
+				return err
+			}
+
+			property = before
+			if err := update.apply(tx, &property); err != nil {
+				return err
+			}
+
+			changes := models.DiffProperties(before, property)
+			if len(changes) == 0 {
+				property = before
+				if update.write == nil {
+					return nil
+				}
+				_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+					return update.write(pipe, before, property)
+				})
+				return err
+			}
+			if !update.auction {
+				if err := checkAuctionLock(ctx, property, changes); err != nil {
+					return err
+				}
+			}
+
+			property.Version = before.Version + 1
+			property.UpdatedAt = time.Now()
+			v := newPropertyVersion(property, &before, changedBy)
+			version = &v
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				if err := writeProperty(ctx, pipe, &before, property); err != nil {
+					return err
+				}
+				if err := appendPropertyVersion(ctx, pipe, v); err != nil {
+					return err
+				}
+				if update.write != nil {
+					return update.write(pipe, before, property)
+				}
+				return nil
+			})
+			return err
+		}, "property:"+propertyID)
+
+		if !errors.Is(err, redis.TxFailedErr) {
+			// Let clients watching the property see what changed
+			if err == nil && version != nil {
+				BroadcastPropertyUpdate(property, *version)
+			}
+			return before, property, version, err
+		}
+	}
+	return before, property, nil, fmt.Errorf("property update conflicted with concurrent updates")
+}
+
+// checkAuctionLock rejects changes to locked fields while the property's
+// auction is active
+func checkAuctionLock(ctx context.Context, property models.Property, changes []models.FieldChange) error {
+	var locked []string
+	for _, change := range changes {
+		if auctionLockedFields[change.Field] {
+			locked = append(locked, change.Field)
+		}
+	}
+	if len(locked) == 0 {
+		return nil
+	}
+
+	active, err := hasActiveAuction(ctx, property.ID)
+	if err != nil {
+		return err
+	}
+	if active {
+		return &auctionLockError{fields: locked}
+	}
+	return nil
+}
+
+// hasActiveAuction reports whether the property's current auction is active
+func hasActiveAuction(ctx context.Context, propertyID string) (bool, error) {
+	rdb := config.GetRedisClient()
+	auctionID, err := rdb.Get(ctx, "property_auction:"+propertyID).Result()
+	if isNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return rdb.SIsMember(ctx, activeAuctionsKey, auctionID).Result()
+}
+
+// GetPropertyVersions lists a property's versions with their changes,
+// newest first. Supports limit and before_version for paging; snapshots are
+// only returned by GetPropertyVersion.
+func GetPropertyVersions(c *gin.Context) {
+	propertyID := c.Param("id")
+	redis := config.GetRedisClient()
+	ctx := config.GetContext()
+
+	limit, err := parseLimit(c)
+	beforeVersion := int64(0)
+	if value := c.Query("before_version"); err == nil && value != "" {
+		beforeVersion, err = strconv.ParseInt(value, 10, 64)
+		if err == nil && beforeVersion < 1 {
+			err = fmt.Errorf("before_version must be a positive integer")
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.PropertyResponse{
+			Success: false,
+			Message: "Invalid query parameters",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	exists, err := redis.Exists(ctx, "property:"+propertyID).Result()
+	if err == nil && exists == 0 {
+		c.JSON(http.StatusNotFound, models.PropertyResponse{
+			Success: false,
+			Message: "Property not found",
+		})
+		return
+	}
+
+	var count int64
+	if err == nil {
+		count, err = redis.LLen(ctx, propertyVersionsKey(propertyID)).Result()
+	}
+
+	// Version n is at index n-1, so the page ends just before before_version
+	end := count - 1
+	if beforeVersion > 0 && beforeVersion-2 < end {
+		end = beforeVersion - 2
+	}
+	start := end - int64(limit) + 1
+	if start < 0 {
+		start = 0
+	}
+
+	var records []string
+	if err == nil && end >= 0 {
+		records, err = redis.LRange(ctx, propertyVersionsKey(propertyID), start, end).Result()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+			Success: false,
+			Message: "Failed to retrieve property versions",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	versions := make([]models.PropertyVersion, 0, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		var version models.PropertyVersion
+		if json.Unmarshal([]byte(records[i]), &version) == nil {
+			version.Snapshot = nil
+			versions = append(versions, version)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.PropertyResponse{
+		Success: true,
+		Message: "Property versions retrieved successfully",
+		Data:    versions,
+		Pagination: &models.Pagination{
+			Limit:   limit,
+			Sort:    "-version",
+			HasMore: end >= 0 && start > 0,
+		},
+	})
+}
+
+// GetPropertyVersion retrieves one version of a property with its changes
+// and the property as it was at that version
+func GetPropertyVersion(c *gin.Context) {
+	propertyID := c.Param("id")
+	number, err := strconv.ParseInt(c.Param("version"), 10, 64)
+	if err != nil || number < 1 {
+		c.JSON(http.StatusBadRequest, models.PropertyResponse{
+			Success: false,
+			Message: "Invalid version",
+			Error:   "version must be a positive integer",
+		})
+		return
+	}
+
+	record, err := config.GetRedisClient().LIndex(config.GetContext(), propertyVersionsKey(propertyID), number-1).Result()
+	var version models.PropertyVersion
+	if err == nil {
+		err = json.Unmarshal([]byte(record), &version)
+	}
+	if isNotFound(err) || (err == nil && int64(version.Version) != number) {
+		c.JSON(http.StatusNotFound, models.PropertyResponse{
+			Success: false,
+			Message: "Property version not found",
+		})
+		return
+	}
+	if err == nil {
+		version.Snapshot, err = propertyVersionSnapshot(config.GetContext(), version)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+			Success: false,
+			Message: "Failed to retrieve property version",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PropertyResponse{
+		Success: true,
+		Message: "Property version retrieved successfully",
+		Data:    version,
+	})
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erea-api/config"
+	"erea-api/models"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// seedVersionedProperty stores property as its version 1
+func seedVersionedProperty(t *testing.T, property models.Property) {
+	t.Helper()
+	ctx := context.Background()
+	property.Version = 1
+	property.CreatedAt = time.Now()
+	property.UpdatedAt = property.CreatedAt
+	_, err := config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if err := writeProperty(ctx, pipe, nil, property); err != nil {
+			return err
+		}
+		return appendPropertyVersion(ctx, pipe, newPropertyVersion(property, nil, "owner"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPriceOnlyVersionsRebuildSnapshot(t *testing.T) {
+	mr := newTestRedis(t)
+	ctx := context.Background()
+	seedVersionedProperty(t, models.Property{ID: "p1", Title: "Listing", CurrentPrice: 100})
+
+	for _, price := range []int64{150, 200} {
+		_, _, version, err := updatePropertyVersioned(ctx, "p1", "bidder", func(property *models.Property) error {
+			property.CurrentPrice = price
+			return nil
+		})
+		if err != nil || version == nil {
+			t.Fatalf("raising the price: %v", err)
+		}
+	}
+
+	// Only the diff of price changes is stored
+	records, _ := mr.List(propertyVersionsKey("p1"))
+	if len(records) != 3 {
+		t.Fatalf("expected 3 versions, got %d", len(records))
+	}
+	var stored models.PropertyVersion
+	if err := json.Unmarshal([]byte(records[2]), &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Snapshot != nil || len(stored.Changes) != 1 {
+		t.Fatalf("expected a price-only version without a snapshot, got %s", records[2])
+	}
+
+	router := newTestRouter()
+	router.GET("/properties/:id/versions/:version", GetPropertyVersion)
+	w := performJSON(t, router, http.MethodGet, "/properties/p1/versions/3", nil)
+	var response struct {
+		Data models.PropertyVersion `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	snapshot := response.Data.Snapshot
+	if snapshot == nil || snapshot.CurrentPrice != 200 || snapshot.Title != "Listing" || snapshot.Version != 3 {
+		t.Fatalf("expected the snapshot rebuilt at version 3, got %+v", snapshot)
+	}
+}
+
+func TestAuctionLockRejectsLockedFields(t *testing.T) {
+	newTestRedis(t)
+	ctx := context.Background()
+	seedAuction(t, "p1", 100)
+
+	_, _, _, err := updatePropertyVersioned(ctx, "p1", "owner", func(property *models.Property) error {
+		property.Title = "Renamed"
+		return nil
+	})
+	var lockErr *auctionLockError
+	if !errors.As(err, &lockErr) || len(lockErr.fields) != 1 || lockErr.fields[0] != "title" {
+		t.Fatalf("expected the title locked by the auction, got %v", err)
+	}
+
+	// Unlocked fields may still change
+	_, _, version, err := updatePropertyVersioned(ctx, "p1", "owner", func(property *models.Property) error {
+		property.Description = "Now with a view"
+		return nil
+	})
+	if err != nil || version == nil {
+		t.Fatalf("changing the description: %v", err)
+	}
+}
+
+func TestPropertyVersionsAreBroadcast(t *testing.T) {
+	newTestRedis(t)
+	seedVersionedProperty(t, models.Property{ID: "p1", Title: "Listing", Status: models.PropertyStatusDraft})
+
+	watcher := newTestClient(hub, 8, propertyTopic("p1"))
+	hub.register(watcher)
+	defer hub.unregister(watcher)
+	for len(watcher.send) > 0 {
+		<-watcher.send
+	}
+
+	// A review decision creates a version outside UpdateProperty
+	if _, _, err := transitionProperty(context.Background(), "p1", submitDecision, "", "owner"); err != nil {
+		t.Fatalf("submitting the listing: %v", err)
+	}
+
+	for {
+		message, _ := receive(t, watcher)
+		if message.Type == "property_update" {
+			break
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"erea-api/config"
+	"erea-api/models"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	defaultPageSize   = 50
+	maxPageSize       = 200
+	maxListScanBatch  = 500
+	listDateLayout    = "2006-01-02"
+	listSortDescToken = "-"
+)
+
+// listSortKeys maps the sortable fields of one list endpoint to the sorted
+// set that holds its IDs scored by that field
+type listSortKeys map[string]string
+
+// listQuery is a parsed limit/cursor/sort request for a list endpoint. Pages
+// are read from a sorted set, so they stay stable while records are added.
+type listQuery struct {
+	Limit  int
+	Sort   string // As requested, e.g. "-created_at"
+	field  string
+	desc   bool
+	key    string
+	min    string
+	max    string
+	cursor *listCursor
+}
+
+// listCursor marks the last item of a page by its score and ID. It is
+// encoded opaquely so clients do not depend on its shape.
+type listCursor struct {
+	Sort  string  `json:"s"`
+	Score float64 `json:"v"`
+	ID    string  `json:"id"`
+}
+
+// parseListQuery reads limit, sort and cursor from the query string.
+// sort is a field name, prefixed with "-" for descending order.
+func parseListQuery(c *gin.Context, sorts listSortKeys, defaultSort string) (listQuery, error) {
+	q := listQuery{
+		Sort: c.DefaultQuery("sort", defaultSort),
+		min:  "-inf",
+		max:  "+inf",
+	}
+
+	limit, err := parseLimit(c)
+	if err != nil {
+		return q, err
+	}
+	q.Limit = limit
+
+	q.field = strings.TrimPrefix(q.Sort, listSortDescToken)
+	q.desc = q.field != q.Sort
+	key, ok := sorts[q.field]
+	if !ok {
+		fields := make([]string, 0, len(sorts))
+		for field := range sorts {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		return q, fmt.Errorf("sort must be one of %s (prefix with - for descending)", strings.Join(fields, ", "))
+	}
+	q.key = key
+
+	if cursorStr := c.Query("cursor"); cursorStr != "" {
+		cursor, err := decodeListCursor(cursorStr)
+		if err != nil {
+			return q, fmt.Errorf("invalid cursor")
+		}
+		if cursor.Sort != q.Sort {
+			return q, fmt.Errorf("cursor was issued for sort %q, not %q", cursor.Sort, q.Sort)
+		}
+		q.cursor = &cursor
+	}
+
+	return q, nil
+}
+
+// parseLimit reads the page size from the limit query parameter, capped at maxPageSize
+func parseLimit(c *gin.Context) (int, error) {
+	limitStr := c.Query("limit")
+	if limitStr == "" {
+		return defaultPageSize, nil
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("limit must be a positive integer")
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	return limit, nil
+}
+
+// narrow limits the scanned score range when a range filter applies to the
+// sort field. Filters must still be checked on each record.
+func (q *listQuery) narrow(field string, min, max *float64) {
+	if field != q.field {
+		return
+	}
+	if min != nil {
+		q.min = strconv.FormatFloat(*min, 'f', -1, 64)
+	}
+	if max != nil {
+		q.max = strconv.FormatFloat(*max, 'f', -1, 64)
+	}
+}
+
+// pagination describes the page for the response envelope
+func (q listQuery) pagination(next *listCursor) *models.Pagination {
+	page := &models.Pagination{Limit: q.Limit, Sort: q.Sort}
+	if next != nil {
+		page.NextCursor = next.encode()
+		page.HasMore = true
+	}
+	return page
+}
+
+// encode serializes the cursor for the next_cursor response field
+func (cursor listCursor) encode() string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeListCursor parses a cursor produced by listCursor.encode
+func decodeListCursor(value string) (listCursor, error) {
+	var cursor listCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
+
+// paginate walks the query's sorted set in sort order starting after the
+// cursor, loading records in growing batches and keeping those that pass
+// keep until a page is full. Members with equal scores are ordered by ID,
+// which the cursor uses to resume within a run of ties.
+func paginate[T any](ctx context.Context, q listQuery, load func(context.Context, []string) ([]T, error), idOf func(T) string, keep func(T) bool) ([]T, *models.Pagination, error) {
+	rdb := config.GetRedisClient()
+
+	min, max := q.min, q.max
+	if q.cursor != nil {
+		bound := strconv.FormatFloat(q.cursor.Score, 'f', -1, 64)
+		if q.desc {
+			max = bound
+		} else {
+			min = bound
+		}
+	}
+
+	items := []T{}
+	var scores []float64
+	batch := q.Limit + 1
+	var offset int64
+
+	for len(items) <= q.Limit {
+		opt := &redis.ZRangeBy{Min: min, Max: max, Offset: offset, Count: int64(batch)}
+		var members []redis.Z
+		var err error
+		if q.desc {
+			members, err = rdb.ZRevRangeByScoreWithScores(ctx, q.key, opt).Result()
+		} else {
+			members, err = rdb.ZRangeByScoreWithScores(ctx, q.key, opt).Result()
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		offset += int64(len(members))
+
+		ids := make([]string, 0, len(members))
+		idScores := make(map[string]float64, len(members))
+		for _, member := range members {
+			id, _ := member.Member.(string)
+			if q.cursor != nil && member.Score == q.cursor.Score {
+				// ZREVRANGEBYSCORE returns ties in reverse ID order
+				if (!q.desc && id <= q.cursor.ID) || (q.desc && id >= q.cursor.ID) {
+					continue
+				}
+			}
+			ids = append(ids, id)
+			idScores[id] = member.Score
+		}
+
+		records, err := load(ctx, ids)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, record := range records {
+			if !keep(record) {
+				continue
+			}
+			items = append(items, record)
+			scores = append(scores, idScores[idOf(record)])
+			if len(items) > q.Limit {
+				break
+			}
+		}
+
+		if len(members) < batch {
+			break
+		}
+		if batch < maxListScanBatch {
+			batch *= 2
+		}
+	}
+
+	if len(items) <= q.Limit {
+		return items, q.pagination(nil), nil
+	}
+
+	items = items[:q.Limit]
+	last := items[q.Limit-1]
+	return items, q.pagination(&listCursor{Sort: q.Sort, Score: scores[q.Limit-1], ID: idOf(last)}), nil
+}
+
+// queryInt64 parses an optional integer query parameter
+func queryInt64(c *gin.Context, name string) (*int64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an integer", name)
+	}
+	return &n, nil
+}
+
+// queryTimeRange parses the optional from and to query parameters as RFC
+// 3339 times or YYYY-MM-DD dates. A date-only "to" includes the whole day.
+func queryTimeRange(c *gin.Context) (*time.Time, *time.Time, error) {
+	from, _, err := queryTime(c, "from")
+	if err != nil {
+		return nil, nil, err
+	}
+	to, dateOnly, err := queryTime(c, "to")
+	if err != nil {
+		return nil, nil, err
+	}
+	if to != nil && dateOnly {
+		end := to.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		to = &end
+	}
+	return from, to, nil
+}
+
+// queryTime parses an optional time query parameter, reporting whether it
+// was given as a date without a time
+func queryTime(c *gin.Context, name string) (*time.Time, bool, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, false, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, false, nil
+	}
+	t, err := time.ParseInLocation(listDateLayout, value, time.Local)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s must be an RFC 3339 time or a YYYY-MM-DD date", name)
+	}
+	return &t, true, nil
+}
+
+// int64Score converts an optional integer filter to a sorted-set score
+func int64Score(n *int64) *float64 {
+	if n == nil {
+		return nil
+	}
+	score := float64(*n)
+	return &score
+}
+
+// timeScore converts an optional time filter to a sorted-set score
+func timeScore(t *time.Time) *float64 {
+	if t == nil {
+		return nil
+	}
+	score := float64(t.UnixNano())
+	return &score
+}
+
+// inInt64Range reports whether n lies within the optional inclusive bounds
+func inInt64Range(n int64, min, max *int64) bool {
+	return (min == nil || n >= *min) && (max == nil || n <= *max)
+}
+
+// inTimeRange reports whether t lies within the optional inclusive bounds
+func inTimeRange(t time.Time, from, to *time.Time) bool {
+	return (from == nil || !t.Before(*from)) && (to == nil || !t.After(*to))
+}
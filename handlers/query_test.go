@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erea-api/models"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type propertyPage struct {
+	Data       []models.Property  `json:"data"`
+	Pagination *models.Pagination `json:"pagination"`
+}
+
+func listTestProperties(t *testing.T, query url.Values) (int, propertyPage) {
+	t.Helper()
+	router := newTestRouter()
+	router.GET("/properties", GetAllProperties)
+	w := performJSON(t, router, http.MethodGet, "/properties?"+query.Encode(), nil)
+	var page propertyPage
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code, page
+}
+
+func TestPaginationCursorWalksTiesOnce(t *testing.T) {
+	newTestRedis(t)
+	ctx := context.Background()
+	created := time.Now()
+	prices := []int64{300, 100, 200, 200, 200, 100, 400}
+	for i, price := range prices {
+		property := models.Property{
+			ID:           fmt.Sprintf("p%d", i),
+			Title:        "Listing",
+			Type:         "Apartment",
+			CurrentPrice: price,
+			Status:       models.PropertyStatusActive,
+			CreatedAt:    created.Add(time.Duration(i) * time.Second),
+		}
+		if i%2 == 1 {
+			property.Type = "Villa"
+		}
+		if err := saveProperty(ctx, nil, property); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	query := url.Values{"sort": {"price"}, "limit": {"2"}}
+	seen := map[string]bool{}
+	var last int64
+	for pages := 0; ; pages++ {
+		if pages > len(prices) {
+			t.Fatal("paging didn't end")
+		}
+		code, page := listTestProperties(t, query)
+		if code != http.StatusOK {
+			t.Fatalf("page %d: status %d", pages, code)
+		}
+		for _, property := range page.Data {
+			if seen[property.ID] {
+				t.Fatalf("%s was listed twice", property.ID)
+			}
+			if property.CurrentPrice < last {
+				t.Fatalf("%s is out of price order", property.ID)
+			}
+			seen[property.ID] = true
+			last = property.CurrentPrice
+		}
+		if !page.Pagination.HasMore {
+			break
+		}
+		query.Set("cursor", page.Pagination.NextCursor)
+
+		// A listing added mid-walk below the cursor doesn't shift later pages
+		if pages == 0 {
+			cheap := models.Property{ID: "cheap", Title: "Listing", CurrentPrice: 1, Status: models.PropertyStatusActive}
+			if err := saveProperty(ctx, nil, cheap); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if len(seen) != len(prices) {
+		t.Fatalf("expected %d listings, saw %d", len(prices), len(seen))
+	}
+
+	// Filters apply across pages
+	code, page := listTestProperties(t, url.Values{"sort": {"-price"}, "type": {"Villa"}, "limit": {"10"}})
+	if code != http.StatusOK || len(page.Data) != 3 || page.Pagination.HasMore {
+		t.Fatalf("expected the 3 villas on one page, got %d: %+v", code, page)
+	}
+
+	// A cursor only works with the sort it was issued for
+	query.Set("sort", "-price")
+	if code, _ := listTestProperties(t, query); code != http.StatusBadRequest {
+		t.Fatalf("expected a cursor for another sort to be refused, got %d", code)
+	}
+	if code, _ := listTestProperties(t, url.Values{"cursor": {"not a cursor"}}); code != http.StatusBadRequest {
+		t.Fatalf("expected a malformed cursor to be refused, got %d", code)
+	}
+	if code, _ := listTestProperties(t, url.Values{"sort": {"title"}}); code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown sort to be refused, got %d", code)
+	}
+}
+
+func TestListPropertiesShowsPublicListingsByDefault(t *testing.T) {
+	newTestRedis(t)
+	ctx := context.Background()
+	for id, status := range map[string]string{
+		"active":   models.PropertyStatusActive,
+		"approved": models.PropertyStatusApproved,
+		"draft":    models.PropertyStatusDraft,
+		"pending":  models.PropertyStatusPendingReview,
+		"rejected": models.PropertyStatusRejected,
+	} {
+		if err := saveProperty(ctx, nil, models.Property{ID: id, Title: "Listing", Status: status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, page := listTestProperties(t, url.Values{})
+	if code != http.StatusOK || len(page.Data) != 2 {
+		t.Fatalf("expected the 2 public listings, got %d: %+v", code, page.Data)
+	}
+	for _, property := range page.Data {
+		if !models.IsPublicStatus(property.Status) {
+			t.Fatalf("listed %s listing %s", property.Status, property.ID)
+		}
+	}
+
+	code, page = listTestProperties(t, url.Values{"status": {models.PropertyStatusDraft}})
+	if code != http.StatusOK || len(page.Data) != 1 || page.Data[0].ID != "draft" {
+		t.Fatalf("expected the draft when asked for by status, got %d: %+v", code, page.Data)
+	}
+}
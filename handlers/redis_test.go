@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"erea-api/config"
+	"erea-api/middleware"
+	"erea-api/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// newTestRedis points config.RedisClient at a fresh in-memory Redis for
+// the duration of a test
+func newTestRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	previous := config.RedisClient
+	config.RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		config.RedisClient.Close()
+		config.RedisClient = previous
+	})
+	return mr
+}
+
+// newTestClient returns a client without a connection, following topics,
+// whose send buffer holds buffer messages
+func newTestClient(h *Hub, buffer int, topics ...string) *Client {
+	client := &Client{
+		id:     uuid.New().String(),
+		hub:    h,
+		send:   make(chan outboundMessage, buffer),
+		topics: make(map[string]bool),
+	}
+	for _, topic := range topics {
+		client.topics[topic] = true
+	}
+	return client
+}
+
+// performJSON serves a request with a JSON body (none if body is nil) on
+// router and returns the recorded response
+func performJSON(t *testing.T, router http.Handler, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	return performJSONWithKey(t, router, method, path, "", body)
+}
+
+// performJSONWithKey is performJSON authenticated with an API key, if one
+// is given
+func performJSONWithKey(t *testing.T, router http.Handler, method, path, apiKey string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	if body == nil {
+		reader = bytes.NewReader(nil)
+	} else {
+		bodyJSON, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(bodyJSON)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// newTestRouter returns a gin engine in test mode
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	return gin.New()
+}
+
+// issueTestKey stores an API key acting for userID with the given scopes
+// and returns its plaintext
+func issueTestKey(t *testing.T, userID string, scopes ...string) string {
+	t.Helper()
+	plaintext := middleware.APIKeyPrefix + uuid.New().String()
+	key := models.APIKey{
+		ID:        uuid.New().String(),
+		Hash:      middleware.HashAPIKey(plaintext),
+		Scopes:    scopes,
+		UserID:    userID,
+		CreatedAt: time.Now(),
+	}
+	keyJSON, err := key.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdb := config.GetRedisClient()
+	ctx := config.GetContext()
+	if err := rdb.Set(ctx, "api_key:"+key.ID, keyJSON, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rdb.Set(ctx, "api_key_hash:"+key.Hash, key.ID, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	return plaintext
+}
@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erea-api/config"
+	"erea-api/middleware"
+	"erea-api/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// reviewStateError is returned when a listing is not in a state the review
+// action applies to
+type reviewStateError struct {
+	status  string
+	allowed []string
+}
+
+func (e *reviewStateError) Error() string {
+	return fmt.Sprintf("listing is %s, expected one of: %s", e.status, strings.Join(e.allowed, ", "))
+}
+
+// reviewDecision describes how a review action moves a listing and what the
+// owner is told about it
+type reviewDecision struct {
+	action           string
+	from             []string
+	to               string
+	requireComment   bool
+	notificationType string
+	notification     string // Title format, given the listing title
+}
+
+var (
+	submitDecision = reviewDecision{
+		action: models.ReviewActionSubmit,
+		from:   []string{models.PropertyStatusDraft, models.PropertyStatusChangesRequested},
+		to:     models.PropertyStatusPendingReview,
+	}
+	approveDecision = reviewDecision{
+		action:           models.ReviewActionApprove,
+		from:             []string{models.PropertyStatusPendingReview},
+		to:               models.PropertyStatusApproved,
+		notificationType: models.NotificationListingApproved,
+		notification:     "Your listing %q was approved",
+	}
+	rejectDecision = reviewDecision{
+		action:           models.ReviewActionReject,
+		from:             []string{models.PropertyStatusPendingReview},
+		to:               models.PropertyStatusRejected,
+		requireComment:   true,
+		notificationType: models.NotificationListingRejected,
+		notification:     "Your listing %q was rejected",
+	}
+	requestChangesDecision = reviewDecision{
+		action:           models.ReviewActionRequestChanges,
+		from:             []string{models.PropertyStatusPendingReview},
+		to:               models.PropertyStatusChangesRequested,
+		requireComment:   true,
+		notificationType: models.NotificationListingChangesRequested,
+		notification:     "Changes were requested on your listing %q",
+	}
+)
+
+// propertyReviewsKey is the list of a listing's review history, oldest first
+func propertyReviewsKey(propertyID string) string {
+	return "property_reviews:" + propertyID
+}
+
+// SubmitPropertyForReview submits a draft, or a listing with requested
+// changes, to the admin review queue. Only the owner's API key and admins
+// may submit.
+func SubmitPropertyForReview(c *gin.Context) {
+	properties, err := loadProperties(config.GetContext(), []string{c.Param("id")})
+	if err == nil && len(properties) == 0 {
+		c.JSON(http.StatusNotFound, models.PropertyResponse{
+			Success: false,
+			Message: "Property not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+			Success: false,
+			Message: "Failed to update listing review",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	principal, ok := middleware.GetPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.PropertyResponse{
+			Success: false,
+			Message: "Authentication required",
+			Error:   "submitting a listing for review requires an API key",
+		})
+		return
+	}
+	if !actsForPropertyOwner(principal, properties[0]) {
+		c.JSON(http.StatusForbidden, models.PropertyResponse{
+			Success: false,
+			Message: "Access denied",
+			Error:   "requires the property owner's key or the " + models.ScopeAdmin + " scope",
+		})
+		return
+	}
+
+	reviewProperty(c, submitDecision)
+}
+
+// ApproveProperty approves a listing awaiting review, making it eligible
+// for an auction
+func ApproveProperty(c *gin.Context) {
+	reviewProperty(c, approveDecision)
+}
+
+// RejectProperty rejects a listing awaiting review. A comment is required.
+func RejectProperty(c *gin.Context) {
+	reviewProperty(c, rejectDecision)
+}
+
+// RequestPropertyChanges sends a listing awaiting review back to its owner
+// for changes. A comment is required.
+func RequestPropertyChanges(c *gin.Context) {
+	reviewProperty(c, requestChangesDecision)
+}
+
+// GetReviewQueue retrieves listings awaiting review, oldest submission
+// first. Supports type and owner_id filters and cursor pagination.
+func GetReviewQueue(c *gin.Context) {
+	q, err := parseListQuery(c, listSortKeys{"submitted_at": reviewQueueKey}, "submitted_at")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.PropertyResponse{
+			Success: false,
+			Message: "Invalid query parameters",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	propertyType := c.Query("type")
+	ownerID := c.Query("owner_id")
+
+	properties, page, err := paginate(config.GetContext(), q, loadProperties,
+		func(property models.Property) string { return property.ID },
+		func(property models.Property) bool {
+			return property.Status == models.PropertyStatusPendingReview &&
+				(propertyType == "" || property.Type == propertyType) &&
+				(ownerID == "" || property.OwnerID == ownerID)
+		})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+			Success: false,
+			Message: "Failed to retrieve review queue",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PropertyResponse{
+		Success:    true,
+		Message:    "Review queue retrieved successfully",
+		Data:       properties,
+		Pagination: page,
+	})
+}
+
+// GetPropertyReviews retrieves the review history of a listing, oldest first
+func GetPropertyReviews(c *gin.Context) {
+	propertyID := c.Param("id")
+	redis := config.GetRedisClient()
+	ctx := config.GetContext()
+
+	exists, err := redis.Exists(ctx, "property:"+propertyID).Result()
+	if err == nil && exists == 0 {
+		c.JSON(http.StatusNotFound, models.PropertyResponse{
+			Success: false,
+			Message: "Property not found",
+		})
+		return
+	}
+
+	var records []string
+	if err == nil {
+		records, err = redis.LRange(ctx, propertyReviewsKey(propertyID), 0, -1).Result()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+			Success: false,
+			Message: "Failed to retrieve review history",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	reviews := make([]models.PropertyReview, 0, len(records))
+	for _, record := range records {
+		var review models.PropertyReview
+		if json.Unmarshal([]byte(record), &review) == nil {
+			reviews = append(reviews, review)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.PropertyResponse{
+		Success: true,
+		Message: "Review history retrieved successfully",
+		Data:    reviews,
+	})
+}
+
+// reviewProperty applies a review action to the listing named by the :id
+// parameter, records it in the review history and audit log, and notifies
+// the owner of decisions
+func reviewProperty(c *gin.Context, decision reviewDecision) {
+	var req models.ReviewDecisionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.PropertyResponse{
+				Success: false,
+				Message: "Invalid request data",
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+	if decision.requireComment && req.Comment == "" {
+		c.JSON(http.StatusBadRequest, models.PropertyResponse{
+			Success: false,
+			Message: "Invalid request data",
+			Error:   "comment is required to " + strings.ReplaceAll(decision.action, "_", " "),
+		})
+		return
+	}
+
+	ctx := config.GetContext()
+	before, property, err := transitionProperty(ctx, c.Param("id"), decision, req.Comment, callerID(c))
+	var stateErr *reviewStateError
+	switch {
+	case isNotFound(err):
+		c.JSON(http.StatusNotFound, models.PropertyResponse{
+			Success: false,
+			Message: "Property not found",
+		})
+		return
+	case errors.As(err, &stateErr):
+		c.JSON(http.StatusConflict, models.PropertyResponse{
+			Success: false,
+			Message: "Listing cannot be " + reviewPastTense(decision.action),
+			Error:   err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+			Success: false,
+			Message: "Failed to update listing review",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	recordAudit(c, "property."+decision.action, "property", property.ID, before, property)
+
+	if decision.notificationType != "" {
+		message := fmt.Sprintf(decision.notification, property.Title)
+		if req.Comment != "" {
+			message += ": " + req.Comment
+		}
+		notifyUser(ctx, models.Notification{
+			UserID:     property.OwnerID,
+			Type:       decision.notificationType,
+			Title:      fmt.Sprintf(decision.notification, property.Title),
+			Message:    message,
+			EntityType: "property",
+			EntityID:   property.ID,
+		})
+	}
+	if decision.action == models.ReviewActionApprove {
+		notifySavedSearches(ctx, property)
+	}
+
+	c.JSON(http.StatusOK, models.PropertyResponse{
+		Success: true,
+		Message: "Listing " + reviewPastTense(decision.action) + " successfully",
+		Data:    property,
+	})
+}
+
+// transitionProperty moves a listing to the decision's target status if it
+// is in one of the decision's source states, as a new version. The listing
+// is watched, so concurrent decisions by two reviewers cannot both apply.
+func transitionProperty(ctx context.Context, propertyID string, decision reviewDecision, comment, actor string) (models.Property, models.Property, error) {
+	before, property, _, err := applyPropertyUpdate(ctx, propertyID, actor, propertyUpdate{
+		apply: func(_ *redis.Tx, property *models.Property) error {
+			allowed := false
+			for _, status := range decision.from {
+				allowed = allowed || property.Status == status
+			}
+			if !allowed {
+				return &reviewStateError{status: property.Status, allowed: decision.from}
+			}
+
+			now := time.Now()
+			property.Status = decision.to
+			switch decision.action {
+			case models.ReviewActionSubmit:
+				property.SubmittedAt = &now
+			case models.ReviewActionApprove:
+				property.ApprovedAt = &now
+				property.ReviewComment = comment
+			default:
+				property.ReviewComment = comment
+			}
+			return nil
+		},
+		write: func(pipe redis.Pipeliner, before, property models.Property) error {
+			review := newPropertyReview(property.ID, decision.action, comment, actor, before.Status, property.Status)
+			return appendPropertyReview(ctx, pipe, review)
+		},
+	})
+	return before, property, err
+}
+
+// newPropertyReview creates a review history entry. An empty actor is
+// recorded as anonymous.
+func newPropertyReview(propertyID, action, comment, actor, fromStatus, toStatus string) models.PropertyReview {
+	if actor == "" {
+		actor = "anonymous"
+	}
+	return models.PropertyReview{
+		ID:         uuid.New().String(),
+		PropertyID: propertyID,
+		Action:     action,
+		Comment:    comment,
+		Actor:      actor,
+		FromStatus: fromStatus,
+		ToStatus:   toStatus,
+		CreatedAt:  time.Now(),
+	}
+}
+
+// appendPropertyReview queues a review history entry
+func appendPropertyReview(ctx context.Context, pipe redis.Pipeliner, review models.PropertyReview) error {
+	reviewJSON, err := json.Marshal(review)
+	if err != nil {
+		return err
+	}
+	pipe.RPush(ctx, propertyReviewsKey(review.PropertyID), reviewJSON)
+	return nil
+}
+
+// reviewPastTense describes a review action for response messages
+func reviewPastTense(action string) string {
+	switch action {
+	case models.ReviewActionSubmit:
+		return "submitted for review"
+	case models.ReviewActionApprove:
+		return "approved"
+	case models.ReviewActionReject:
+		return "rejected"
+	default:
+		return "sent back for changes"
+	}
+}
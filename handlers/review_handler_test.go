@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erea-api/middleware"
+	"erea-api/models"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newReviewRouter() http.Handler {
+	router := newTestRouter()
+	router.Use(middleware.Authenticate())
+	router.POST("/properties/:id/submit", SubmitPropertyForReview)
+	router.POST("/admin/reviews/:id/approve", ApproveProperty)
+	router.POST("/admin/reviews/:id/reject", RejectProperty)
+	router.POST("/admin/reviews/:id/request-changes", RequestPropertyChanges)
+	return router
+}
+
+func TestReviewTransitions(t *testing.T) {
+	mr := newTestRedis(t)
+	property := models.Property{ID: "p1", Title: "Listing", OwnerID: "owner", Status: models.PropertyStatusDraft}
+	if err := saveProperty(context.Background(), nil, property); err != nil {
+		t.Fatal(err)
+	}
+	router := newReviewRouter()
+	ownerKey := issueTestKey(t, "owner")
+
+	steps := []struct {
+		path   string
+		body   interface{}
+		code   int
+		status string
+	}{
+		// A draft can't be approved before it is submitted
+		{"/admin/reviews/p1/approve", nil, http.StatusConflict, models.PropertyStatusDraft},
+		{"/properties/p1/submit", nil, http.StatusOK, models.PropertyStatusPendingReview},
+		// Asking for changes needs a comment
+		{"/admin/reviews/p1/request-changes", nil, http.StatusBadRequest, models.PropertyStatusPendingReview},
+		{"/admin/reviews/p1/request-changes", models.ReviewDecisionRequest{Comment: "Add photos"}, http.StatusOK, models.PropertyStatusChangesRequested},
+		{"/properties/p1/submit", nil, http.StatusOK, models.PropertyStatusPendingReview},
+		{"/admin/reviews/p1/approve", nil, http.StatusOK, models.PropertyStatusApproved},
+		// Decisions are final once approved
+		{"/admin/reviews/p1/reject", models.ReviewDecisionRequest{Comment: "Too late"}, http.StatusConflict, models.PropertyStatusApproved},
+		{"/properties/p1/submit", nil, http.StatusConflict, models.PropertyStatusApproved},
+	}
+	for _, step := range steps {
+		apiKey := ""
+		if strings.HasPrefix(step.path, "/properties/") {
+			apiKey = ownerKey
+		}
+		if w := performJSONWithKey(t, router, http.MethodPost, step.path, apiKey, step.body); w.Code != step.code {
+			t.Fatalf("POST %s: expected %d, got %d: %s", step.path, step.code, w.Code, w.Body.String())
+		}
+		properties, err := loadProperties(context.Background(), []string{"p1"})
+		if err != nil || len(properties) != 1 || properties[0].Status != step.status {
+			t.Fatalf("after POST %s: expected %s, got %+v (%v)", step.path, step.status, properties, err)
+		}
+	}
+
+	// Each applied decision is in the history, and the owner heard about
+	// the reviewers' ones
+	history, _ := mr.List(propertyReviewsKey("p1"))
+	if len(history) != 4 {
+		t.Fatalf("expected 4 review entries, got %d", len(history))
+	}
+	var last models.PropertyReview
+	if err := json.Unmarshal([]byte(history[3]), &last); err != nil || last.Action != models.ReviewActionApprove {
+		t.Fatalf("expected the approval last, got %+v (%v)", last, err)
+	}
+	if inbox, _ := mr.ZMembers(userNotificationsKey("owner")); len(inbox) != 2 {
+		t.Fatalf("expected 2 review notifications for the owner, got %v", inbox)
+	}
+	if queued, _ := mr.ZMembers(reviewQueueKey); len(queued) != 0 {
+		t.Fatalf("expected the approved listing to leave the review queue, got %v", queued)
+	}
+}
+
+func TestDemoListingCanBeApprovedAndAuctioned(t *testing.T) {
+	mr := newTestRedis(t)
+	router := newTestRouter()
+	router.POST("/demo/create", CreateDemoData)
+	if w := performJSON(t, router, http.MethodPost, "/demo/create", nil); w.Code != http.StatusCreated {
+		t.Fatalf("creating demo data: status %d", w.Code)
+	}
+
+	queued, _ := mr.ZMembers(reviewQueueKey)
+	if len(queued) != 1 {
+		t.Fatalf("expected one demo listing awaiting review, got %v", queued)
+	}
+	if w := performJSON(t, newReviewRouter(), http.MethodPost, "/admin/reviews/"+queued[0]+"/approve", nil); w.Code != http.StatusOK {
+		t.Fatalf("approving the demo listing: status %d: %s", w.Code, w.Body.String())
+	}
+	if code := createTestAuction(t, queued[0]); code != http.StatusCreated {
+		t.Fatalf("auctioning the demo listing: status %d", code)
+	}
+}
+
+func TestSubmitForReviewRequiresOwnerOrAdmin(t *testing.T) {
+	newTestRedis(t)
+	for _, id := range []string{"p1", "p2"} {
+		property := models.Property{ID: id, Title: "Listing", OwnerID: "owner", Status: models.PropertyStatusDraft}
+		if err := saveProperty(context.Background(), nil, property); err != nil {
+			t.Fatal(err)
+		}
+	}
+	router := newReviewRouter()
+
+	for _, tc := range []struct {
+		name   string
+		path   string
+		apiKey string
+		want   int
+	}{
+		{"anonymous", "/properties/p1/submit", "", http.StatusUnauthorized},
+		{"another user", "/properties/p1/submit", issueTestKey(t, "someone-else"), http.StatusForbidden},
+		{"missing listing", "/properties/missing/submit", issueTestKey(t, "owner"), http.StatusNotFound},
+		{"the owner", "/properties/p1/submit", issueTestKey(t, "owner"), http.StatusOK},
+		{"an admin", "/properties/p2/submit", issueTestKey(t, "", models.ScopeAdmin), http.StatusOK},
+	} {
+		if w := performJSONWithKey(t, router, http.MethodPost, tc.path, tc.apiKey, nil); w.Code != tc.want {
+			t.Fatalf("submit by %s: expected %d, got %d: %s", tc.name, tc.want, w.Code, w.Body.String())
+		}
+	}
+}
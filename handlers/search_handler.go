@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"erea-api/config"
+	"erea-api/models"
+	"erea-api/search"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	searchResultsTTL   = time.Minute
+	searchResultPrefix = "search:results:"
+)
+
+// searchTermKey is the sorted set of property IDs containing term, scored
+// by the term's weight in each property
+func searchTermKey(term string) string {
+	return "search:term:" + term
+}
+
+// propertySearchTerms returns the weighted search terms of a property
+func propertySearchTerms(property models.Property) map[string]float64 {
+	return search.Terms(search.Document{
+		Title:       property.Title,
+		Description: property.Description,
+		Location:    property.Location,
+		Features:    property.Features,
+	})
+}
+
+// indexPropertyText adds a property to the full-text index
+func indexPropertyText(ctx context.Context, pipe redis.Pipeliner, property models.Property) {
+	for term, weight := range propertySearchTerms(property) {
+		pipe.ZAdd(ctx, searchTermKey(term), &redis.Z{Score: weight, Member: property.ID})
+	}
+}
+
+// unindexPropertyText removes a property's terms from the full-text index.
+// The terms are derived from the stored property, so callers pass the
+// version that was indexed.
+func unindexPropertyText(ctx context.Context, pipe redis.Pipeliner, property models.Property) {
+	for term := range propertySearchTerms(property) {
+		pipe.ZRem(ctx, searchTermKey(term), property.ID)
+	}
+}
+
+// SearchProperties searches property titles, descriptions, locations and
+// features. Results are ranked by relevance and every query term must match.
+// Supports type, district, status and attr.* attribute filters, limit and
+// cursor pagination, and returns facet counts by type and district. Without
+// a status filter only public listings are found.
+func SearchProperties(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	terms := search.QueryTerms(query)
+	if len(terms) == 0 {
+		c.JSON(http.StatusBadRequest, models.PropertyResponse{
+			Success: false,
+			Message: "Search query is required",
+			Error:   "q must contain at least one searchable word",
+		})
+		return
+	}
+
+	ctx := config.GetContext()
+
+	resultsKey, err := storeSearchResults(ctx, terms)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+			Success: false,
+			Message: "Failed to search properties",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	q, err := parseListQuery(c, listSortKeys{"relevance": resultsKey}, "-relevance")
+	var attributeFilters []models.AttributeFilter
+	if err == nil {
+		attributeFilters, err = queryAttributeFilters(c)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.PropertyResponse{
+			Success: false,
+			Message: "Invalid query parameters",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	propertyType := c.Query("type")
+	district := c.Query("district")
+	status := c.Query("status")
+
+	keep := func(property models.Property) bool {
+		return (propertyType == "" || property.Type == propertyType) &&
+			(district == "" || strings.EqualFold(search.District(property.Location), district)) &&
+			((status == "" && models.IsPublicStatus(property.Status)) || property.Status == status) &&
+			models.MatchAttributes(property.Attributes, attributeFilters)
+	}
+
+	properties, page, err := paginate(ctx, q, loadProperties,
+		func(property models.Property) string { return property.ID }, keep)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+			Success: false,
+			Message: "Failed to search properties",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	result, err := searchFacets(ctx, resultsKey, keep)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.PropertyResponse{
+			Success: false,
+			Message: "Failed to count search facets",
+			Error:   err.Error(),
+		})
+		return
+	}
+	result.Query = query
+
+	// Attach relevance scores to the page
+	rdb := config.GetRedisClient()
+	scores := make([]*redis.FloatCmd, len(properties))
+	pipe := rdb.Pipeline()
+	for i, property := range properties {
+		scores[i] = pipe.ZScore(ctx, resultsKey, property.ID)
+	}
+	if len(properties) > 0 {
+		pipe.Exec(ctx)
+	}
+
+	result.Hits = make([]models.PropertySearchHit, len(properties))
+	for i, property := range properties {
+		result.Hits[i] = models.PropertySearchHit{Property: property, Score: scores[i].Val()}
+	}
+
+	c.JSON(http.StatusOK, models.PropertyResponse{
+		Success:    true,
+		Message:    "Search completed successfully",
+		Data:       result,
+		Pagination: page,
+	})
+}
+
+// storeSearchResults intersects the posting lists of every term into a
+// short-lived sorted set scored by summed term weight, and returns its key.
+// The key is derived from the terms, so paging through the same query
+// reads the same result set.
+func storeSearchResults(ctx context.Context, terms []string) (string, error) {
+	sorted := append([]string(nil), terms...)
+	sort.Strings(sorted)
+	digest := sha1.Sum([]byte(strings.Join(sorted, "\x00")))
+	resultsKey := searchResultPrefix + hex.EncodeToString(digest[:])
+
+	keys := make([]string, len(sorted))
+	for i, term := range sorted {
+		keys[i] = searchTermKey(term)
+	}
+
+	_, err := config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZInterStore(ctx, resultsKey, &redis.ZStore{Keys: keys, Aggregate: "SUM"})
+		pipe.Expire(ctx, resultsKey, searchResultsTTL)
+		return nil
+	})
+	return resultsKey, err
+}
+
+// searchFacets counts the matches in a result set that pass keep, in
+// total and by type and district
+func searchFacets(ctx context.Context, resultsKey string, keep func(models.Property) bool) (models.PropertySearchResult, error) {
+	rdb := config.GetRedisClient()
+	result := models.PropertySearchResult{
+		Facets: models.SearchFacets{
+			Type:     map[string]int{},
+			District: map[string]int{},
+		},
+	}
+
+	for start := int64(0); ; start += indexScanBatch {
+		ids, err := rdb.ZRange(ctx, resultsKey, start, start+indexScanBatch-1).Result()
+		if err != nil {
+			return result, err
+		}
+		properties, err := loadProperties(ctx, ids)
+		if err != nil {
+			return result, err
+		}
+
+		for _, property := range properties {
+			if !keep(property) {
+				continue
+			}
+			result.Total++
+			if property.Type != "" {
+				result.Facets.Type[property.Type]++
+			}
+			if district := search.District(property.Location); district != "" {
+				result.Facets.District[district]++
+			}
+		}
+		if len(ids) < indexScanBatch {
+			return result, nil
+		}
+	}
+}
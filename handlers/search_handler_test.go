@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erea-api/models"
+	"net/http"
+	"testing"
+)
+
+func searchTest(t *testing.T, query string) models.PropertySearchResult {
+	t.Helper()
+	router := newTestRouter()
+	router.GET("/search", SearchProperties)
+	w := performJSON(t, router, http.MethodGet, "/search?"+query, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /search?%s: status %d: %s", query, w.Code, w.Body.String())
+	}
+	var response struct {
+		Data models.PropertySearchResult `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response.Data
+}
+
+func TestSearchFindsPublicListingsAndCountsFilteredFacets(t *testing.T) {
+	newTestRedis(t)
+	ctx := context.Background()
+	for _, property := range []models.Property{
+		{ID: "p1", Title: "강남 아파트", Location: "Gangnam-gu, Seoul", Type: "Apartment", Status: models.PropertyStatusActive},
+		{ID: "p2", Title: "강남 오피스텔", Location: "Gangnam-gu, Seoul", Type: "Officetel", Status: models.PropertyStatusApproved},
+		{ID: "p3", Title: "강남 상가", Location: "Mapo-gu, Seoul", Type: "Commercial", Status: models.PropertyStatusClosed},
+		{ID: "p4", Title: "강남 빌라", Location: "Gangnam-gu, Seoul", Type: "Villa", Status: models.PropertyStatusDraft},
+		{ID: "p5", Title: "강남 주택", Location: "Gangnam-gu, Seoul", Type: "Villa", Status: models.PropertyStatusRejected},
+	} {
+		if err := saveProperty(ctx, nil, property); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := searchTest(t, "q=강남")
+	if result.Total != 3 || len(result.Hits) != 3 {
+		t.Fatalf("expected the 3 public listings, got total %d, hits %d", result.Total, len(result.Hits))
+	}
+	for _, hit := range result.Hits {
+		if !models.IsPublicStatus(hit.Status) {
+			t.Fatalf("search found %s listing %s", hit.Status, hit.ID)
+		}
+	}
+	if result.Facets.Type["Villa"] != 0 {
+		t.Fatalf("facets counted unpublished listings: %v", result.Facets.Type)
+	}
+
+	// Facets follow the filters
+	result = searchTest(t, "q=강남&district=Gangnam-gu")
+	if result.Total != 2 || result.Facets.District["Gangnam-gu"] != 2 || result.Facets.District["Mapo-gu"] != 0 {
+		t.Fatalf("expected 2 public matches in Gangnam-gu, got total %d, facets %v", result.Total, result.Facets.District)
+	}
+	if result.Facets.Type["Apartment"] != 1 || result.Facets.Type["Officetel"] != 1 || result.Facets.Type["Commercial"] != 0 {
+		t.Fatalf("unexpected type facets %v", result.Facets.Type)
+	}
+
+	// Unpublished listings are found when asked for by status
+	result = searchTest(t, "q=강남&status="+models.PropertyStatusDraft)
+	if result.Total != 1 || len(result.Hits) != 1 || result.Hits[0].ID != "p4" {
+		t.Fatalf("expected the draft, got %+v", result.Hits)
+	}
+}
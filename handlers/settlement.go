@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"erea-api/config"
+	"erea-api/models"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// settlementReminderKey marks a won auction whose settlement reminder for a
+// milestone was sent
+func settlementReminderKey(auctionID string, milestone time.Duration) string {
+	return fmt.Sprintf("settlement_reminder:%s:%d", auctionID, int64(milestone.Seconds()))
+}
+
+// notifySettlementDue tells the winner of a closed auction on their private
+// topic how much they owe and by when
+func notifySettlementDue(ctx context.Context, auction models.Auction, property models.Property) {
+	if auction.WinnerID == "" || auction.SettlementDue == nil {
+		return
+	}
+	notifyUser(ctx, models.Notification{
+		UserID:     auction.WinnerID,
+		Type:       models.NotificationSettlementDue,
+		Title:      fmt.Sprintf("You won the auction for %q", property.Title),
+		Message:    fmt.Sprintf("Settle your winning bid of %s by %s.", formatWon(auction.WinningBid), auction.SettlementDue.Format(noticeTimeLayout)),
+		EntityType: "auction",
+		EntityID:   auction.ID,
+	})
+}
+
+// RunSettlementReminders reminds auction winners to settle, checking every
+// SettlementReminderInterval for settlements due within one of
+// SettlementReminders until ctx is done. Each reminder is sent once, even
+// with several servers running it.
+func RunSettlementReminders(ctx context.Context) {
+	ticker := time.NewTicker(config.SettlementReminderInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := sendSettlementReminders(ctx, time.Now()); err != nil {
+			log.Printf("Failed to send settlement reminders: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendSettlementReminders notifies the winners of auctions whose settlement
+// passed a reminder milestone. Settlements past due are dropped from the
+// schedule index.
+func sendSettlementReminders(ctx context.Context, now time.Time) error {
+	if len(config.SettlementReminders) == 0 {
+		return nil
+	}
+	milestones := append([]time.Duration(nil), config.SettlementReminders...)
+	sort.Slice(milestones, func(i, j int) bool { return milestones[i] < milestones[j] })
+
+	rdb := config.GetRedisClient()
+	nowScore := strconv.FormatInt(now.UnixNano(), 10)
+	if err := rdb.ZRemRangeByScore(ctx, settlementsByDueKey, "-inf", nowScore).Err(); err != nil {
+		return err
+	}
+	ids, err := rdb.ZRangeByScore(ctx, settlementsByDueKey, &redis.ZRangeBy{
+		Min: "(" + nowScore,
+		Max: strconv.FormatInt(now.Add(milestones[len(milestones)-1]).UnixNano(), 10),
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	auctions, err := loadAuctions(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, auction := range auctions {
+		if auction.WinnerID == "" || auction.SettlementDue == nil || !auction.SettlementDue.After(now) {
+			continue
+		}
+		remaining := timeRemaining(*auction.SettlementDue, now)
+
+		// Send only the nearest milestone passed, so a settlement found
+		// late doesn't get every larger one at once
+		for _, milestone := range milestones {
+			if remaining > milestone {
+				continue
+			}
+			claimed, err := rdb.SetNX(ctx, settlementReminderKey(auction.ID, milestone), now.Unix(), remaining+milestone).Result()
+			if err != nil {
+				return err
+			}
+			if claimed {
+				sendSettlementReminder(ctx, auction, remaining)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// sendSettlementReminder reminds an auction's winner that settlement is
+// due in remaining
+func sendSettlementReminder(ctx context.Context, auction models.Auction, remaining time.Duration) {
+	title := "your won auction"
+	if properties, err := loadProperties(ctx, []string{auction.PropertyID}); err == nil && len(properties) > 0 {
+		title = fmt.Sprintf("%q", properties[0].Title)
+	}
+	notifyUser(ctx, models.Notification{
+		UserID:     auction.WinnerID,
+		Type:       models.NotificationSettlementReminder,
+		Title:      fmt.Sprintf("Settlement for %s is due in %s", title, remaining),
+		Message:    fmt.Sprintf("Settle your winning bid of %s by %s.", formatWon(auction.WinningBid), auction.SettlementDue.Format(noticeTimeLayout)),
+		EntityType: "auction",
+		EntityID:   auction.ID,
+	})
+}
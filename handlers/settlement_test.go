@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"erea-api/config"
+	"erea-api/models"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCloseAuctionSetsSettlementDue(t *testing.T) {
+	mr := newTestRedis(t)
+	auction := seedAuction(t, "p1", 100)
+	if code := placeTestBid(t, "p1", "alice", 150); code != http.StatusCreated {
+		t.Fatalf("bid: status %d", code)
+	}
+
+	router := newTestRouter()
+	router.PUT("/auctions/:id/close", CloseAuction)
+	if w := performJSON(t, router, http.MethodPut, "/auctions/"+auction.ID+"/close", nil); w.Code != http.StatusOK {
+		t.Fatalf("closing the auction: status %d", w.Code)
+	}
+
+	auctions, err := loadAuctions(context.Background(), []string{auction.ID})
+	if err != nil || len(auctions) != 1 {
+		t.Fatalf("loading auction: %v", err)
+	}
+	closed := auctions[0]
+	if closed.WinnerID != "alice" || closed.SettlementDue == nil {
+		t.Fatalf("expected alice to owe a settlement, got %+v", closed)
+	}
+	if due := closed.SettlementDue.Sub(closed.UpdatedAt); due != config.SettlementPeriod {
+		t.Fatalf("expected settlement due %s after closing, got %s", config.SettlementPeriod, due)
+	}
+	if due, _ := mr.ZMembers(settlementsByDueKey); len(due) != 1 || due[0] != auction.ID {
+		t.Fatalf("expected the auction in the settlement schedule, got %v", due)
+	}
+	if inbox, _ := mr.ZMembers(userNotificationsKey("alice")); len(inbox) != 1 {
+		t.Fatalf("expected a settlement.due notification for alice, got %v", inbox)
+	}
+}
+
+func TestSettlementRemindersSendNearestMilestoneOnce(t *testing.T) {
+	mr := newTestRedis(t)
+	previous := config.SettlementReminders
+	config.SettlementReminders = []time.Duration{24 * time.Hour, time.Hour}
+	t.Cleanup(func() { config.SettlementReminders = previous })
+
+	ctx := context.Background()
+	now := time.Now()
+	due := now.Add(30 * time.Minute)
+	auction := models.Auction{
+		ID:            "a1",
+		PropertyID:    "p1",
+		Status:        "Closed",
+		WinnerID:      "alice",
+		WinningBid:    150,
+		SettlementDue: &due,
+		UpdatedAt:     now,
+	}
+	if err := saveAuction(ctx, auction); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both milestones have passed; only the nearest is sent, and only once
+	for i := 0; i < 2; i++ {
+		if err := sendSettlementReminders(ctx, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if inbox, _ := mr.ZMembers(userNotificationsKey("alice")); len(inbox) != 1 {
+		t.Fatalf("expected one settlement reminder, got %v", inbox)
+	}
+	if !mr.Exists(settlementReminderKey("a1", time.Hour)) || mr.Exists(settlementReminderKey("a1", 24*time.Hour)) {
+		t.Fatal("expected only the 1h milestone to be claimed")
+	}
+
+	// Once the settlement is past due it leaves the schedule
+	if err := sendSettlementReminders(ctx, due.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if scheduled, _ := mr.ZMembers(settlementsByDueKey); len(scheduled) != 0 {
+		t.Fatalf("expected the past-due settlement to be dropped, got %v", scheduled)
+	}
+}
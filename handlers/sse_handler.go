@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"encoding/json"
+	"erea-api/config"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// StreamEvents streams the websocket hub's events as Server-Sent Events,
+// for clients whose network blocks websockets. Topics are chosen with the
+// same query parameters as HandleWebSocket and can't be changed once the
+// stream is open. Each event's data is the JSON message a websocket client
+// would get. Events carry an id listing the client's position in every
+// topic, which browsers send back as Last-Event-ID when they reconnect so
+// the events missed in between are replayed; last_event_id does the same
+// for clients that can't set headers. A keepalive comment is sent every
+// SSEKeepaliveInterval so proxies don't drop idle streams.
+func StreamEvents(c *gin.Context) {
+	principal, topics, ok := openStream(c)
+	if !ok {
+		return
+	}
+
+	var lastSeq map[string]int64
+	var err error
+	if id := c.GetHeader("Last-Event-ID"); id != "" {
+		lastSeq, err = parseEventID(id, topics)
+	} else if id := c.Query("last_event_id"); id != "" {
+		lastSeq, err = parseEventID(id, topics)
+	} else if list := c.Query("last_seq"); list != "" {
+		lastSeq, err = parseLastSeq(list, topics)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid Last-Event-ID",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// Start every topic the client has no position in from its newest
+	// event, read before registering so nothing published in between is
+	// lost: the replay below picks it up
+	ctx := c.Request.Context()
+	positions := make(map[string]int64, len(topics))
+	for _, topic := range topics {
+		if seq, ok := lastSeq[topic]; ok {
+			positions[topic] = seq
+			continue
+		}
+		seq, err := latestSeq(ctx, topic)
+		if err != nil {
+			log.Printf("Failed to load the latest event of %s: %v", topic, err)
+			continue
+		}
+		positions[topic] = seq
+	}
+
+	client := &Client{
+		id:        uuid.New().String(),
+		hub:       hub,
+		send:      make(chan outboundMessage, config.WSSendBuffer),
+		principal: principal,
+		topics:    make(map[string]bool),
+	}
+	for _, topic := range topics {
+		client.topics[topic] = true
+	}
+	hub.register(client)
+	defer hub.unregister(client)
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // Keep nginx from buffering the stream
+	c.Status(http.StatusOK)
+
+	stream := &eventStream{c: c, controller: http.NewResponseController(c.Writer), positions: positions}
+
+	// The server's time comes first, so clients can sync their countdowns
+	if err := stream.writeJSON(timeMessage("")); err != nil {
+		return
+	}
+
+	replayed, _, err := replayEvents(ctx, "", positions, stream.writeJSON)
+	if errors.Is(err, errReplayUnavailable) {
+		err = stream.writeJSON(WebSocketMessage{Type: replyError, Message: "Failed to load missed events"})
+	}
+	if err != nil {
+		return
+	}
+
+	keepalive := time.NewTicker(config.SSEKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case message, ok := <-client.send:
+			if !ok {
+				// The hub removed the client
+				return
+			}
+
+			if seenEvent(message.seq, replayed) {
+				continue
+			}
+			for topic := range message.seq {
+				delete(replayed, topic)
+			}
+			if err := stream.write(message.frames.json, message.seq); err != nil {
+				log.Printf("Event stream write error: %v", err)
+				return
+			}
+
+		case <-keepalive.C:
+			if err := stream.comment("keepalive"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// eventStream writes events to a Server-Sent Events response and tracks
+// the client's position in each topic for their ids
+type eventStream struct {
+	c          *gin.Context
+	controller *http.ResponseController
+	positions  map[string]int64
+}
+
+// write writes one event within WSWriteWait. Events with sequence numbers
+// advance the client's positions and get an id listing them.
+func (s *eventStream) write(data []byte, seq map[string]int64) error {
+	id := ""
+	if len(seq) > 0 {
+		for topic, n := range seq {
+			if position, ok := s.positions[topic]; ok && n > position {
+				s.positions[topic] = n
+			}
+		}
+		id = formatEventID(s.positions)
+	}
+	return s.flush(func() error {
+		return sse.Encode(s.c.Writer, sse.Event{Id: id, Data: string(data)})
+	})
+}
+
+// writeJSON encodes a message and writes it as an event
+func (s *eventStream) writeJSON(message WebSocketMessage) error {
+	messageJSON, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to marshal %s: %v", message.Type, err)
+		return nil
+	}
+	return s.write(messageJSON, message.Seq)
+}
+
+// comment writes a comment line, which clients ignore
+func (s *eventStream) comment(text string) error {
+	return s.flush(func() error {
+		_, err := fmt.Fprintf(s.c.Writer, ": %s\n\n", text)
+		return err
+	})
+}
+
+// flush runs write within WSWriteWait and flushes it to the client
+func (s *eventStream) flush(write func() error) error {
+	// Not every ResponseWriter supports deadlines; write without one then
+	err := s.controller.SetWriteDeadline(time.Now().Add(config.WSWriteWait))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	return s.controller.Flush()
+}
+
+// formatEventID encodes positions as an event id, e.g.
+// "auction:a1=12,property:p1=40"
+func formatEventID(positions map[string]int64) string {
+	topics := make([]string, 0, len(positions))
+	for topic := range positions {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	parts := make([]string, len(topics))
+	for i, topic := range topics {
+		parts[i] = topic + "=" + strconv.FormatInt(positions[topic], 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseEventID decodes an event id from formatEventID, keeping the topics
+// the stream is subscribed to
+func parseEventID(id string, topics []string) (map[string]int64, error) {
+	subscribed := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		subscribed[topic] = true
+	}
+
+	lastSeq := make(map[string]int64)
+	for _, part := range strings.Split(id, ",") {
+		topic, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		seq, err := strconv.ParseInt(value, 10, 64)
+		if !found || err != nil || seq < 0 {
+			return nil, fmt.Errorf("invalid event position %q, expected topic=seq", part)
+		}
+		if subscribed[topic] {
+			lastSeq[topic] = seq
+		}
+	}
+	return lastSeq, nil
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"erea-api/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent is an event or comment read from an event stream
+type sseEvent struct {
+	id      string
+	message WebSocketMessage
+	comment string
+}
+
+// openEventStream connects to an event stream, resuming from lastEventID
+// if set, and returns a channel of the events read from it
+func openEventStream(t *testing.T, lastEventID string) <-chan sseEvent {
+	t.Helper()
+	router := newTestRouter()
+	router.GET("/ws/events", StreamEvents)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/ws/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var event sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				events <- event
+				event = sseEvent{}
+			case strings.HasPrefix(line, ": "):
+				event.comment = strings.TrimPrefix(line, ": ")
+			case strings.HasPrefix(line, "id:"):
+				event.id = strings.TrimPrefix(line, "id:")
+			case strings.HasPrefix(line, "data:"):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &event.message)
+			}
+		}
+	}()
+	return events
+}
+
+// nextEvent returns the next event of a stream, skipping comments unless
+// wantComment is set
+func nextEvent(t *testing.T, events <-chan sseEvent, wantComment bool) sseEvent {
+	t.Helper()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatal("event stream closed")
+			}
+			if event.comment != "" && !wantComment {
+				continue
+			}
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event streamed")
+		}
+	}
+}
+
+func TestStreamEventsResumesFromLastEventID(t *testing.T) {
+	newTestRedis(t)
+	for _, text := range []string{"first", "second", "third"} {
+		broadcast(WebSocketMessage{Type: "test", Message: text}, topicAuctions)
+	}
+
+	events := openEventStream(t, formatEventID(map[string]int64{topicAuctions: 1}))
+	if event := nextEvent(t, events, false); event.message.Type != controlTime {
+		t.Fatalf("expected the server time first, got %+v", event.message)
+	}
+	for i, text := range []string{"second", "third"} {
+		event := nextEvent(t, events, false)
+		if event.message.Message != text || event.id != formatEventID(map[string]int64{topicAuctions: int64(i + 2)}) {
+			t.Fatalf("expected replayed %q, got %+v with id %q", text, event.message, event.id)
+		}
+	}
+
+	// Wait for the stream to register before publishing live
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.subscriberCount(topicAuctions) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stream didn't subscribe")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	broadcast(WebSocketMessage{Type: "test", Message: "live"}, topicAuctions)
+	if event := nextEvent(t, events, false); event.message.Message != "live" || event.id != formatEventID(map[string]int64{topicAuctions: 4}) {
+		t.Fatalf("expected live event 4, got %+v with id %q", event.message, event.id)
+	}
+}
+
+func TestStreamEventsSendsKeepalive(t *testing.T) {
+	newTestRedis(t)
+	interval := config.SSEKeepaliveInterval
+	config.SSEKeepaliveInterval = 20 * time.Millisecond
+	t.Cleanup(func() { config.SSEKeepaliveInterval = interval })
+
+	events := openEventStream(t, "")
+	nextEvent(t, events, false)
+	if event := nextEvent(t, events, true); event.comment != "keepalive" {
+		t.Fatalf("expected a keepalive comment, got %+v", event)
+	}
+}
+
+func TestParseEventIDKeepsSubscribedTopics(t *testing.T) {
+	lastSeq, err := parseEventID("auction:a1=12, auctions=40,property:p9=3", []string{"auction:a1", "auctions"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lastSeq) != 2 || lastSeq["auction:a1"] != 12 || lastSeq["auctions"] != 40 {
+		t.Fatalf("unexpected positions %v", lastSeq)
+	}
+	for _, id := range []string{"auctions", "auctions=-1", "auctions=x"} {
+		if _, err := parseEventID(id, []string{"auctions"}); err == nil {
+			t.Fatalf("expected %q to be rejected", id)
+		}
+	}
+}
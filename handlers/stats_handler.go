@@ -4,6 +4,8 @@ import (
 	"erea-api/config"
 	"erea-api/models"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -31,48 +33,26 @@ func GetDashboardStats(c *gin.Context) {
 	stats := DashboardStats{}
 
 	// Count properties
-	propertyKeys, err := redis.Keys(ctx, "property:*").Result()
-	if err == nil {
-		stats.TotalProperties = len(propertyKeys)
-
-		// Count active properties
-		for _, key := range propertyKeys {
-			propertyJSON, err := redis.Get(ctx, key).Result()
-			if err != nil {
-				continue
-			}
-
-			var property models.Property
-			if err := property.FromJSON(propertyJSON); err != nil {
-				continue
-			}
+	if totalProperties, err := redis.SCard(ctx, propertiesIndexKey).Result(); err == nil {
+		stats.TotalProperties = int(totalProperties)
+	}
 
-			if property.Status == "Active" {
-				stats.ActiveProperties++
-			}
-		}
+	// Count active properties
+	if activeProperties, err := redis.SCard(ctx, propertyStatusKey("Active")).Result(); err == nil {
+		stats.ActiveProperties = int(activeProperties)
 	}
 
 	// Count auctions
-	auctionKeys, err := redis.Keys(ctx, "auction:*").Result()
+	auctionIDs, err := redis.SMembers(ctx, auctionsIndexKey).Result()
 	if err == nil {
-		stats.TotalAuctions = len(auctionKeys)
+		stats.TotalAuctions = len(auctionIDs)
 
 		var totalVolume int64
 		var successfulAuctions int
 		var closedAuctions int
 
-		for _, key := range auctionKeys {
-			auctionJSON, err := redis.Get(ctx, key).Result()
-			if err != nil {
-				continue
-			}
-
-			var auction models.Auction
-			if err := auction.FromJSON(auctionJSON); err != nil {
-				continue
-			}
-
+		auctions, _ := loadAuctions(ctx, auctionIDs)
+		for _, auction := range auctions {
 			if auction.Status == "Active" {
 				stats.ActiveAuctions++
 			} else if auction.Status == "Closed" {
@@ -94,22 +74,25 @@ func GetDashboardStats(c *gin.Context) {
 	}
 
 	// Count bids
-	bidKeys, err := redis.Keys(ctx, "bid:*").Result()
-	if err == nil {
-		stats.TotalBids = len(bidKeys)
+	if totalBids, err := redis.ZCard(ctx, bidsByTimeKey).Result(); err == nil {
+		stats.TotalBids = int(totalBids)
 	}
 
 	// Count users
-	userKeys, err := redis.Keys(ctx, "user:*").Result()
-	if err == nil {
-		stats.TotalUsers = len(userKeys)
+	if totalUsers, err := redis.SCard(ctx, usersIndexKey).Result(); err == nil {
+		stats.TotalUsers = int(totalUsers)
 	}
 
-	// Simulate online users (random number for demo)
-	stats.OnlineUsers = stats.TotalUsers / 3
+	// Count signed-in users with a live connection on any instance
+	if onlineUsers, err := presenceCount(ctx, presenceUsersKey); err == nil {
+		stats.OnlineUsers = int(onlineUsers)
+	}
 
 	// Count recent transactions (last 24 hours)
-	stats.RecentTransactions = stats.TotalBids / 4
+	since := strconv.FormatInt(time.Now().Add(-24*time.Hour).UnixNano(), 10)
+	if recentBids, err := redis.ZCount(ctx, bidsByTimeKey, since, "+inf").Result(); err == nil {
+		stats.RecentTransactions = int(recentBids)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -146,8 +129,7 @@ func GetPropertyStats(c *gin.Context) {
 	}
 
 	// Get bid statistics for this property
-	propertyBidsKey := "property_bids:" + propertyID
-	bidIDs, err := redis.SMembers(ctx, propertyBidsKey).Result()
+	bidIDs, err := redis.SMembers(ctx, propertyBidsKey(propertyID)).Result()
 
 	bidCount := len(bidIDs)
 	var totalBidAmount int64
@@ -202,7 +184,17 @@ func GetUserStats(c *gin.Context) {
 	ctx := config.GetContext()
 
 	// Get all user bids
-	bidKeys, err := redis.Keys(ctx, "bid:*").Result()
+	bidIDs, err := redis.ZRange(ctx, userBidsKey(userID), 0, -1).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to retrieve user statistics",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	userBids, err := loadBids(ctx, bidIDs)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -212,50 +204,21 @@ func GetUserStats(c *gin.Context) {
 		return
 	}
 
-	var userBids []models.Bid
 	var totalBidAmount int64
 	var successfulBids int
 
-	for _, key := range bidKeys {
-		bidJSON, err := redis.Get(ctx, key).Result()
-		if err != nil {
-			continue
-		}
-
-		var bid models.Bid
-		if err := bid.FromJSON(bidJSON); err != nil {
-			continue
-		}
-
-		if bid.BidderID == userID {
-			userBids = append(userBids, bid)
-			totalBidAmount += bid.Amount
+	for _, bid := range userBids {
+		totalBidAmount += bid.Amount
 
-			if bid.Status == "Confirmed" {
-				successfulBids++
-			}
+		if bid.Status == "Confirmed" {
+			successfulBids++
 		}
 	}
 
 	// Check for won auctions
 	var wonAuctions int
-	auctionKeys, err := redis.Keys(ctx, "auction:*").Result()
-	if err == nil {
-		for _, key := range auctionKeys {
-			auctionJSON, err := redis.Get(ctx, key).Result()
-			if err != nil {
-				continue
-			}
-
-			var auction models.Auction
-			if err := auction.FromJSON(auctionJSON); err != nil {
-				continue
-			}
-
-			if auction.WinnerID == userID {
-				wonAuctions++
-			}
-		}
+	if won, err := redis.SCard(ctx, userWonAuctionsKey(userID)).Result(); err == nil {
+		wonAuctions = int(won)
 	}
 
 	var successRate float64
@@ -291,32 +254,24 @@ func GetRealtimeStats(c *gin.Context) {
 	ctx := config.GetContext()
 
 	// Get active auctions count
-	activeAuctionsCount, _ := redis.SCard(ctx, "active_auctions").Result()
+	activeAuctionsCount, _ := redis.SCard(ctx, activeAuctionsKey).Result()
 
 	// Get total properties count
-	propertyKeys, _ := redis.Keys(ctx, "property:*").Result()
-	totalProperties := len(propertyKeys)
+	totalProperties, _ := redis.SCard(ctx, propertiesIndexKey).Result()
 
-	// Get total bids today (simulate for demo)
-	bidKeys, _ := redis.Keys(ctx, "bid:*").Result()
-	totalBidsToday := len(bidKeys) / 4 // Simulate daily bids
+	// Get total bids today
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	totalBidsToday, _ := redis.ZCount(ctx, bidsByTimeKey, strconv.FormatInt(startOfDay.UnixNano(), 10), "+inf").Result()
+
+	// Count live connections and signed-in users on every instance
+	concurrentUsers, _ := presenceCount(ctx, presenceConnectionsKey)
+	onlineUsers, _ := presenceCount(ctx, presenceUsersKey)
 
 	// Get current highest bid
 	var currentHighestBid int64
-	for _, key := range bidKeys {
-		bidJSON, err := redis.Get(ctx, key).Result()
-		if err != nil {
-			continue
-		}
-
-		var bid models.Bid
-		if err := bid.FromJSON(bidJSON); err != nil {
-			continue
-		}
-
-		if bid.Amount > currentHighestBid && bid.Status == "Confirmed" {
-			currentHighestBid = bid.Amount
-		}
+	if highest, err := redis.ZRevRangeWithScores(ctx, bidsByAmountKey, 0, 0).Result(); err == nil && len(highest) > 0 {
+		currentHighestBid = int64(highest[0].Score)
 	}
 
 	realtimeStats := gin.H{
@@ -326,7 +281,8 @@ func GetRealtimeStats(c *gin.Context) {
 		"highest_bid_today":   currentHighestBid,
 		"platform_status":     "healthy",
 		"last_updated":        "now",
-		"concurrent_users":    activeAuctionsCount * 3, // Simulate concurrent users
+		"concurrent_users":    concurrentUsers, // Live connections, anonymous ones included
+		"online_users":        onlineUsers,
 	}
 
 	c.JSON(http.StatusOK, gin.H{
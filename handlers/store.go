@@ -0,0 +1,564 @@
+package handlers
+
+import (
+	"context"
+	"erea-api/config"
+	"erea-api/models"
+	"fmt"
+	"log"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Secondary index keys. Every write goes through a save*/delete* helper
+// that updates the record and its indexes in one MULTI transaction, so
+// request paths never need KEYS scans.
+const (
+	usersIndexKey            = "users"                    // Set of user IDs
+	usersByCreatedKey        = "users_by_created"         // Sorted set of user IDs by creation time
+	propertiesIndexKey       = "properties"               // Set of property IDs
+	propertiesByCreatedKey   = "properties_by_created"    // Sorted set of property IDs by creation time
+	propertiesByPriceKey     = "properties_by_price"      // Sorted set of property IDs by current price
+	propertiesByEndDateKey   = "properties_by_end_date"   // Sorted set of property IDs by end date
+	auctionsIndexKey         = "auctions"                 // Set of auction IDs
+	activeAuctionsKey        = "active_auctions"          // Set of active auction IDs
+	activeAuctionsByEndKey   = "active_auctions_by_end"   // Sorted set of active auction IDs by end time
+	activeAuctionsByStartKey = "active_auctions_by_start" // Sorted set of active auction IDs by start time
+	closedAuctionsKey        = "closed_auctions"          // Set of closed auction IDs
+	bidsByTimeKey            = "bids_by_time"             // Sorted set of bid IDs by creation time
+	bidsByAmountKey          = "bids_by_amount"           // Sorted set of confirmed bid IDs by amount
+	depositsByTimeKey        = "deposits_by_time"         // Sorted set of deposit IDs by creation time
+	depositsByAmountKey      = "deposits_by_amount"       // Sorted set of deposit IDs by amount
+	depositKeysKey           = "deposit_keys"             // Hash of deposit ID -> deposit record key
+	reviewQueueKey           = "review_queue"             // Sorted set of listing IDs awaiting review by submission time
+	settlementsByDueKey      = "settlements_by_due"       // Sorted set of won auction IDs by settlement due time
+	indexScanBatch           = 500
+	confirmedBidStatus       = "Confirmed"
+	activeAuctionStatus      = "Active"
+	closedAuctionStatus      = "Closed"
+)
+
+// propertyStatusKey is the set of property IDs with the given status
+func propertyStatusKey(status string) string {
+	return "property_status:" + status
+}
+
+// userBidsKey is the sorted set of a user's bid IDs by creation time
+func userBidsKey(userID string) string {
+	return "user_bids:" + userID
+}
+
+// userBidsByAmountKey is the sorted set of a user's bid IDs by amount
+func userBidsByAmountKey(userID string) string {
+	return "user_bids_by_amount:" + userID
+}
+
+// userWonAuctionsKey is the set of auction IDs a user won
+func userWonAuctionsKey(userID string) string {
+	return "user_won_auctions:" + userID
+}
+
+// propertyBidsKey is the set of bid IDs placed on a property
+func propertyBidsKey(propertyID string) string {
+	return "property_bids:" + propertyID
+}
+
+// depositKey is the record key of a deposit
+func depositKey(deposit models.Deposit) string {
+	return fmt.Sprintf("deposit:%s:%s:%s", deposit.ID, deposit.PropertyID, deposit.UserID)
+}
+
+// indexUser adds a user to the user index
+func indexUser(ctx context.Context, pipe redis.Pipeliner, user models.User) {
+	pipe.SAdd(ctx, usersIndexKey, user.ID)
+	pipe.ZAdd(ctx, usersByCreatedKey, &redis.Z{Score: float64(user.CreatedAt.UnixNano()), Member: user.ID})
+}
+
+// unindexUser removes a user from the user index
+func unindexUser(ctx context.Context, pipe redis.Pipeliner, userID string) {
+	pipe.SRem(ctx, usersIndexKey, userID)
+	pipe.ZRem(ctx, usersByCreatedKey, userID)
+}
+
+// indexProperty adds a property to its indexes, moving it between status
+// sets when old (the previously stored version) had a different status and
+// replacing old's full-text terms
+func indexProperty(ctx context.Context, pipe redis.Pipeliner, old *models.Property, property models.Property) {
+	pipe.SAdd(ctx, propertiesIndexKey, property.ID)
+	if old != nil && old.Status != property.Status {
+		pipe.SRem(ctx, propertyStatusKey(old.Status), property.ID)
+	}
+	if old != nil {
+		unindexPropertyText(ctx, pipe, *old)
+	}
+	indexPropertyText(ctx, pipe, property)
+	indexPropertyLocation(ctx, pipe, property)
+	pipe.SAdd(ctx, propertyStatusKey(property.Status), property.ID)
+	pipe.ZAdd(ctx, propertiesByCreatedKey, &redis.Z{Score: float64(property.CreatedAt.UnixNano()), Member: property.ID})
+	pipe.ZAdd(ctx, propertiesByPriceKey, &redis.Z{Score: float64(property.CurrentPrice), Member: property.ID})
+	pipe.ZAdd(ctx, propertiesByEndDateKey, &redis.Z{Score: float64(property.EndDate.UnixNano()), Member: property.ID})
+	if property.Status == models.PropertyStatusPendingReview && property.SubmittedAt != nil {
+		pipe.ZAdd(ctx, reviewQueueKey, &redis.Z{Score: float64(property.SubmittedAt.UnixNano()), Member: property.ID})
+	} else {
+		pipe.ZRem(ctx, reviewQueueKey, property.ID)
+	}
+}
+
+// unindexProperty removes a property from its indexes
+func unindexProperty(ctx context.Context, pipe redis.Pipeliner, property models.Property) {
+	pipe.SRem(ctx, propertiesIndexKey, property.ID)
+	pipe.SRem(ctx, propertyStatusKey(property.Status), property.ID)
+	pipe.ZRem(ctx, propertiesByCreatedKey, property.ID)
+	pipe.ZRem(ctx, propertiesByPriceKey, property.ID)
+	pipe.ZRem(ctx, propertiesByEndDateKey, property.ID)
+	unindexPropertyText(ctx, pipe, property)
+	pipe.ZRem(ctx, propertiesGeoKey, property.ID)
+	pipe.ZRem(ctx, reviewQueueKey, property.ID)
+}
+
+// indexBid adds a bid to its indexes. Only confirmed bids are ranked by amount.
+func indexBid(ctx context.Context, pipe redis.Pipeliner, bid models.Bid) {
+	created := float64(bid.CreatedAt.UnixNano())
+	pipe.SAdd(ctx, propertyBidsKey(bid.PropertyID), bid.ID)
+	pipe.ZAdd(ctx, bidsByTimeKey, &redis.Z{Score: created, Member: bid.ID})
+	pipe.ZAdd(ctx, userBidsKey(bid.BidderID), &redis.Z{Score: created, Member: bid.ID})
+	pipe.ZAdd(ctx, userBidsByAmountKey(bid.BidderID), &redis.Z{Score: float64(bid.Amount), Member: bid.ID})
+	if bid.Status == confirmedBidStatus {
+		pipe.ZAdd(ctx, bidsByAmountKey, &redis.Z{Score: float64(bid.Amount), Member: bid.ID})
+	} else {
+		pipe.ZRem(ctx, bidsByAmountKey, bid.ID)
+	}
+}
+
+// unindexBid removes a bid from its indexes
+func unindexBid(ctx context.Context, pipe redis.Pipeliner, bid models.Bid) {
+	pipe.SRem(ctx, propertyBidsKey(bid.PropertyID), bid.ID)
+	pipe.ZRem(ctx, bidsByTimeKey, bid.ID)
+	pipe.ZRem(ctx, userBidsKey(bid.BidderID), bid.ID)
+	pipe.ZRem(ctx, userBidsByAmountKey(bid.BidderID), bid.ID)
+	pipe.ZRem(ctx, bidsByAmountKey, bid.ID)
+}
+
+// indexDeposit adds a deposit to its indexes
+func indexDeposit(ctx context.Context, pipe redis.Pipeliner, deposit models.Deposit) {
+	pipe.HSet(ctx, depositKeysKey, deposit.ID, depositKey(deposit))
+	pipe.ZAdd(ctx, depositsByTimeKey, &redis.Z{Score: float64(deposit.CreatedAt.UnixNano()), Member: deposit.ID})
+	pipe.ZAdd(ctx, depositsByAmountKey, &redis.Z{Score: float64(deposit.Amount), Member: deposit.ID})
+	pipe.SAdd(ctx, "user_deposits:"+deposit.UserID, deposit.ID)
+	pipe.SAdd(ctx, "property_deposits:"+deposit.PropertyID, deposit.ID)
+}
+
+// indexAuction adds an auction to its indexes, keeping the active/closed
+// sets and the winner's won-auction set in step with its status
+func indexAuction(ctx context.Context, pipe redis.Pipeliner, auction models.Auction) {
+	pipe.SAdd(ctx, auctionsIndexKey, auction.ID)
+	pipe.Set(ctx, "property_auction:"+auction.PropertyID, auction.ID, 0)
+
+	switch auction.Status {
+	case activeAuctionStatus:
+		pipe.SAdd(ctx, activeAuctionsKey, auction.ID)
+		pipe.ZAdd(ctx, activeAuctionsByEndKey, &redis.Z{Score: float64(auction.EndTime.UnixNano()), Member: auction.ID})
+		pipe.ZAdd(ctx, activeAuctionsByStartKey, &redis.Z{Score: float64(auction.StartTime.UnixNano()), Member: auction.ID})
+		pipe.SRem(ctx, closedAuctionsKey, auction.ID)
+	case closedAuctionStatus:
+		unindexActiveAuction(ctx, pipe, auction.ID)
+		pipe.SAdd(ctx, closedAuctionsKey, auction.ID)
+	default:
+		unindexActiveAuction(ctx, pipe, auction.ID)
+		pipe.SRem(ctx, closedAuctionsKey, auction.ID)
+	}
+
+	if auction.WinnerID != "" {
+		pipe.SAdd(ctx, userWonAuctionsKey(auction.WinnerID), auction.ID)
+		if auction.SettlementDue != nil {
+			pipe.ZAdd(ctx, settlementsByDueKey, &redis.Z{Score: float64(auction.SettlementDue.UnixNano()), Member: auction.ID})
+		}
+	}
+}
+
+// unindexActiveAuction removes an auction from the active auction indexes
+func unindexActiveAuction(ctx context.Context, pipe redis.Pipeliner, auctionID string) {
+	pipe.SRem(ctx, activeAuctionsKey, auctionID)
+	pipe.ZRem(ctx, activeAuctionsByEndKey, auctionID)
+	pipe.ZRem(ctx, activeAuctionsByStartKey, auctionID)
+}
+
+// saveUser stores a user record together with its indexes
+func saveUser(ctx context.Context, user models.User) error {
+	userJSON, err := user.ToJSON()
+	if err != nil {
+		return err
+	}
+
+	_, err = config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, userKeyPrefix+user.ID, userJSON, 0)
+		indexUser(ctx, pipe, user)
+		return nil
+	})
+	return err
+}
+
+// deleteUser removes a user record and its index entries
+func deleteUser(ctx context.Context, userID string) error {
+	_, err := config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, userKeyPrefix+userID)
+		unindexUser(ctx, pipe, userID)
+		return nil
+	})
+	return err
+}
+
+// saveProperty stores a property record together with its indexes. old is
+// the previously stored version, or nil for a new property.
+func saveProperty(ctx context.Context, old *models.Property, property models.Property) error {
+	_, err := config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		return writeProperty(ctx, pipe, old, property)
+	})
+	return err
+}
+
+// writeProperty queues the write of a property record and its indexes, for
+// callers that store more in the same transaction
+func writeProperty(ctx context.Context, pipe redis.Pipeliner, old *models.Property, property models.Property) error {
+	propertyJSON, err := property.ToJSON()
+	if err != nil {
+		return err
+	}
+	pipe.Set(ctx, "property:"+property.ID, propertyJSON, 0)
+	indexProperty(ctx, pipe, old, property)
+	return nil
+}
+
+// deleteProperty removes a property record and its index entries
+func deleteProperty(ctx context.Context, property models.Property) error {
+	_, err := config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, "property:"+property.ID, propertyReviewsKey(property.ID), propertyVersionsKey(property.ID))
+		unindexProperty(ctx, pipe, property)
+		return nil
+	})
+	return err
+}
+
+// saveBid stores a bid record together with its indexes
+func saveBid(ctx context.Context, bid models.Bid) error {
+	_, err := config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		return writeBid(ctx, pipe, bid)
+	})
+	return err
+}
+
+// writeBid queues the write of a bid record and its indexes, for callers
+// that store more in the same transaction
+func writeBid(ctx context.Context, pipe redis.Pipeliner, bid models.Bid) error {
+	bidJSON, err := bid.ToJSON()
+	if err != nil {
+		return err
+	}
+	pipe.Set(ctx, "bid:"+bid.ID, bidJSON, 0)
+	indexBid(ctx, pipe, bid)
+	return nil
+}
+
+// saveDeposit stores a deposit record together with its indexes
+func saveDeposit(ctx context.Context, deposit models.Deposit) error {
+	depositJSON, err := deposit.ToJSON()
+	if err != nil {
+		return err
+	}
+
+	_, err = config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, depositKey(deposit), depositJSON, 0)
+		indexDeposit(ctx, pipe, deposit)
+		return nil
+	})
+	return err
+}
+
+// saveAuction stores an auction record together with its indexes
+func saveAuction(ctx context.Context, auction models.Auction) error {
+	_, err := config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		return writeAuction(ctx, pipe, auction)
+	})
+	return err
+}
+
+// writeAuction queues the write of an auction record and its indexes, for
+// callers that store more in the same transaction
+func writeAuction(ctx context.Context, pipe redis.Pipeliner, auction models.Auction) error {
+	auctionJSON, err := auction.ToJSON()
+	if err != nil {
+		return err
+	}
+	pipe.Set(ctx, "auction:"+auction.ID, auctionJSON, 0)
+	indexAuction(ctx, pipe, auction)
+	return nil
+}
+
+// loadRecords fetches the records stored under prefix+id for each ID in one
+// round trip, skipping IDs whose record is missing
+func loadRecords(ctx context.Context, prefix string, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = prefix + id
+	}
+
+	values, err := config.GetRedisClient().MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]string, 0, len(values))
+	for _, value := range values {
+		if str, ok := value.(string); ok {
+			records = append(records, str)
+		}
+	}
+	return records, nil
+}
+
+// loadUsers loads users by ID, preserving order and skipping missing records
+func loadUsers(ctx context.Context, ids []string) ([]models.User, error) {
+	records, err := loadRecords(ctx, userKeyPrefix, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	users := []models.User{}
+	for _, record := range records {
+		var user models.User
+		if user.FromJSON(record) == nil {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// loadProperties loads properties by ID, preserving order and skipping missing records
+func loadProperties(ctx context.Context, ids []string) ([]models.Property, error) {
+	records, err := loadRecords(ctx, "property:", ids)
+	if err != nil {
+		return nil, err
+	}
+
+	properties := []models.Property{}
+	for _, record := range records {
+		var property models.Property
+		if property.FromJSON(record) == nil {
+			properties = append(properties, property)
+		}
+	}
+	return properties, nil
+}
+
+// loadBids loads bids by ID, preserving order and skipping missing records
+func loadBids(ctx context.Context, ids []string) ([]models.Bid, error) {
+	records, err := loadRecords(ctx, "bid:", ids)
+	if err != nil {
+		return nil, err
+	}
+
+	bids := []models.Bid{}
+	for _, record := range records {
+		var bid models.Bid
+		if bid.FromJSON(record) == nil {
+			bids = append(bids, bid)
+		}
+	}
+	return bids, nil
+}
+
+// loadAuctions loads auctions by ID, preserving order and skipping missing records
+func loadAuctions(ctx context.Context, ids []string) ([]models.Auction, error) {
+	records, err := loadRecords(ctx, "auction:", ids)
+	if err != nil {
+		return nil, err
+	}
+
+	auctions := []models.Auction{}
+	for _, record := range records {
+		var auction models.Auction
+		if auction.FromJSON(record) == nil {
+			auctions = append(auctions, auction)
+		}
+	}
+	return auctions, nil
+}
+
+// loadDeposits loads deposits by ID through the deposit key index,
+// preserving order and skipping missing records
+func loadDeposits(ctx context.Context, ids []string) ([]models.Deposit, error) {
+	deposits := []models.Deposit{}
+	if len(ids) == 0 {
+		return deposits, nil
+	}
+
+	rdb := config.GetRedisClient()
+	keyValues, err := rdb.HMGet(ctx, depositKeysKey, ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, value := range keyValues {
+		if key, ok := value.(string); ok {
+			keys = append(keys, key)
+		}
+	}
+	records, err := loadRecords(ctx, "", keys)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		var deposit models.Deposit
+		if deposit.FromJSON(record) == nil {
+			deposits = append(deposits, deposit)
+		}
+	}
+	return deposits, nil
+}
+
+// isNotFound reports whether err means the requested record does not exist
+func isNotFound(err error) bool {
+	return err == redis.Nil
+}
+
+// getDepositByID loads a deposit through the deposit ID index
+func getDepositByID(ctx context.Context, depositID string) (models.Deposit, string, error) {
+	rdb := config.GetRedisClient()
+
+	var deposit models.Deposit
+	key, err := rdb.HGet(ctx, depositKeysKey, depositID).Result()
+	if err != nil {
+		return deposit, "", err
+	}
+
+	depositJSON, err := rdb.Get(ctx, key).Result()
+	if err != nil {
+		return deposit, key, err
+	}
+
+	err = deposit.FromJSON(depositJSON)
+	return deposit, key, err
+}
+
+// IndexRebuildResult counts the records indexed by RebuildIndexes
+type IndexRebuildResult struct {
+	Users      int `json:"users"`
+	Properties int `json:"properties"`
+	Auctions   int `json:"auctions"`
+	Bids       int `json:"bids"`
+	Deposits   int `json:"deposits"`
+}
+
+// RebuildIndexes backfills every secondary index from the stored records.
+// It uses SCAN, so it is safe to run against a live server, and is
+// idempotent. It is meant for the one-off backfill command, not request paths.
+func RebuildIndexes(ctx context.Context) (IndexRebuildResult, error) {
+	var result IndexRebuildResult
+	var err error
+
+	if result.Users, err = rebuildIndex(ctx, "user:*", func(pipe redis.Pipeliner, key, value string) bool {
+		var user models.User
+		if user.FromJSON(value) != nil || user.ID == "" {
+			return false
+		}
+		indexUser(ctx, pipe, user)
+		return true
+	}); err != nil {
+		return result, err
+	}
+
+	if result.Properties, err = rebuildIndex(ctx, "property:*", func(pipe redis.Pipeliner, key, value string) bool {
+		var property models.Property
+		if property.FromJSON(value) != nil || property.ID == "" {
+			return false
+		}
+		indexProperty(ctx, pipe, nil, property)
+		return true
+	}); err != nil {
+		return result, err
+	}
+
+	if result.Auctions, err = rebuildIndex(ctx, "auction:*", func(pipe redis.Pipeliner, key, value string) bool {
+		var auction models.Auction
+		if auction.FromJSON(value) != nil || auction.ID == "" {
+			return false
+		}
+		indexAuction(ctx, pipe, auction)
+		return true
+	}); err != nil {
+		return result, err
+	}
+
+	if result.Bids, err = rebuildIndex(ctx, "bid:*", func(pipe redis.Pipeliner, key, value string) bool {
+		var bid models.Bid
+		if bid.FromJSON(value) != nil || bid.ID == "" {
+			return false
+		}
+		indexBid(ctx, pipe, bid)
+		return true
+	}); err != nil {
+		return result, err
+	}
+
+	if result.Deposits, err = rebuildIndex(ctx, "deposit:*", func(pipe redis.Pipeliner, key, value string) bool {
+		var deposit models.Deposit
+		if deposit.FromJSON(value) != nil || deposit.ID == "" || depositKey(deposit) != key {
+			return false
+		}
+		indexDeposit(ctx, pipe, deposit)
+		return true
+	}); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// rebuildIndex scans string keys matching pattern and lets index add each
+// record to a pipeline, returning how many records were indexed
+func rebuildIndex(ctx context.Context, pattern string, index func(pipe redis.Pipeliner, key, value string) bool) (int, error) {
+	rdb := config.GetRedisClient()
+	var cursor uint64
+	var count int
+
+	for {
+		keys, next, err := rdb.ScanType(ctx, cursor, pattern, indexScanBatch, "string").Result()
+		if err != nil {
+			return count, err
+		}
+
+		if len(keys) > 0 {
+			values, err := rdb.MGet(ctx, keys...).Result()
+			if err != nil {
+				return count, err
+			}
+
+			pipe := rdb.TxPipeline()
+			for i, value := range values {
+				str, ok := value.(string)
+				if !ok {
+					continue
+				}
+				if index(pipe, keys[i], str) {
+					count++
+				} else {
+					log.Printf("Skipping unparseable record %s", keys[i])
+				}
+			}
+			if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+				return count, err
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return count, nil
+		}
+	}
+}
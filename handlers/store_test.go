@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"erea-api/models"
+	"testing"
+	"time"
+)
+
+func TestPropertyIndexesFollowUpdates(t *testing.T) {
+	mr := newTestRedis(t)
+	ctx := context.Background()
+	property := models.Property{ID: "p1", Title: "Gangnam apartment", Status: models.PropertyStatusApproved, CurrentPrice: 100}
+	if err := saveProperty(ctx, nil, property); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := property
+	updated.Status = models.PropertyStatusActive
+	updated.Title = "Mapo apartment"
+	updated.CurrentPrice = 200
+	if err := saveProperty(ctx, &property, updated); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := mr.SIsMember(propertyStatusKey(models.PropertyStatusApproved), "p1"); ok {
+		t.Fatal("the property stayed in its old status set")
+	}
+	if ok, _ := mr.SIsMember(propertyStatusKey(models.PropertyStatusActive), "p1"); !ok {
+		t.Fatal("the property is missing from its new status set")
+	}
+	if score, _ := mr.ZScore(propertiesByPriceKey, "p1"); score != 200 {
+		t.Fatalf("expected the price index at 200, got %v", score)
+	}
+	if mr.Exists(searchTermKey("gangnam")) {
+		t.Fatal("the old title's terms are still indexed")
+	}
+
+	if err := deleteProperty(ctx, updated); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{propertiesIndexKey, propertyStatusKey(models.PropertyStatusActive)} {
+		if ok, _ := mr.SIsMember(key, "p1"); ok {
+			t.Fatalf("the deleted property is still in %s", key)
+		}
+	}
+	if members, _ := mr.ZMembers(propertiesByPriceKey); len(members) != 0 {
+		t.Fatalf("the deleted property is still ranked by price: %v", members)
+	}
+}
+
+func TestRebuildIndexesBackfillsRecords(t *testing.T) {
+	mr := newTestRedis(t)
+	now := time.Now()
+
+	// Records written without their indexes, as before indexes existed
+	property := models.Property{ID: "p1", Title: "Listing", Status: models.PropertyStatusActive, CreatedAt: now}
+	auction := models.Auction{ID: "a1", PropertyID: "p1", Status: activeAuctionStatus, CreatedAt: now}
+	bid := models.Bid{ID: "b1", PropertyID: "p1", BidderID: "u1", Amount: 150, Status: confirmedBidStatus, CreatedAt: now}
+	for key, record := range map[string]interface{ ToJSON() (string, error) }{
+		"property:p1": &property,
+		"auction:a1":  &auction,
+		"bid:b1":      &bid,
+	} {
+		recordJSON, err := record.ToJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		mr.Set(key, recordJSON)
+	}
+	mr.Set("property:corrupt", "{")
+
+	result, err := RebuildIndexes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Properties != 1 || result.Auctions != 1 || result.Bids != 1 {
+		t.Fatalf("unexpected rebuild counts %+v", result)
+	}
+	if ok, _ := mr.SIsMember(propertyStatusKey(models.PropertyStatusActive), "p1"); !ok {
+		t.Fatal("the property wasn't indexed by status")
+	}
+	if ok, _ := mr.SIsMember(activeAuctionsKey, "a1"); !ok {
+		t.Fatal("the auction wasn't indexed as active")
+	}
+	if members, _ := mr.SMembers(propertyBidsKey("p1")); len(members) != 1 || members[0] != "b1" {
+		t.Fatalf("the bid wasn't indexed under its property: %v", members)
+	}
+
+	// Running it again changes nothing
+	if again, err := RebuildIndexes(context.Background()); err != nil || again != result {
+		t.Fatalf("expected the same result again, got %+v (%v)", again, err)
+	}
+}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"encoding/json"
 	"erea-api/config"
 	"erea-api/models"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
@@ -35,20 +37,8 @@ func CreateUser(c *gin.Context) {
 		UpdatedAt: time.Now(),
 	}
 
-	// Redis에 사용자 저장
-	userJSON, err := user.ToJSON()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.UserResponse{
-			Success: false,
-			Message: "사용자 데이터 변환 실패",
-			Error:   err.Error(),
-		})
-		return
-	}
-
-	key := userKeyPrefix + user.ID
-	err = config.GetRedisClient().Set(config.GetContext(), key, userJSON, 0).Err()
-	if err != nil {
+	// Redis에 사용자 저장 (사용자 목록 인덱스 포함)
+	if err := saveUser(config.GetContext(), user); err != nil {
 		c.JSON(http.StatusInternalServerError, models.UserResponse{
 			Success: false,
 			Message: "사용자 저장 실패",
@@ -57,16 +47,7 @@ func CreateUser(c *gin.Context) {
 		return
 	}
 
-	// 사용자 목록에 ID 추가 (검색을 위해)
-	err = config.GetRedisClient().SAdd(config.GetContext(), "users", user.ID).Err()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.UserResponse{
-			Success: false,
-			Message: "사용자 목록 업데이트 실패",
-			Error:   err.Error(),
-		})
-		return
-	}
+	recordAudit(c, "user.create", "user", user.ID, nil, user)
 
 	c.JSON(http.StatusCreated, models.UserResponse{
 		Success: true,
@@ -115,50 +96,47 @@ func GetUser(c *gin.Context) {
 	})
 }
 
-// GetAllUsers 모든 사용자를 조회합니다
+// userSortKeys 사용자 목록에서 정렬 가능한 필드
+var userSortKeys = listSortKeys{
+	"created_at": usersByCreatedKey,
+}
+
+// GetAllUsers 사용자 목록을 페이지 단위로 조회합니다 (from/to 가입일 필터, created_at 정렬 지원)
 func GetAllUsers(c *gin.Context) {
-	// 사용자 ID 목록 가져오기
-	userIDs, err := config.GetRedisClient().SMembers(config.GetContext(), "users").Result()
+	var from, to *time.Time
+
+	q, err := parseListQuery(c, userSortKeys, "-created_at")
+	if err == nil {
+		from, to, err = queryTimeRange(c)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.UserResponse{
+		c.JSON(http.StatusBadRequest, models.UserResponse{
 			Success: false,
-			Message: "사용자 목록 조회 실패",
+			Message: "잘못된 조회 조건",
 			Error:   err.Error(),
 		})
 		return
 	}
 
-	if len(userIDs) == 0 {
-		c.JSON(http.StatusOK, models.UserResponse{
-			Success: true,
-			Message: "등록된 사용자가 없습니다",
-			Data:    []models.User{},
+	q.narrow("created_at", timeScore(from), timeScore(to))
+
+	users, page, err := paginate(config.GetContext(), q, loadUsers,
+		func(user models.User) string { return user.ID },
+		func(user models.User) bool { return inTimeRange(user.CreatedAt, from, to) })
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.UserResponse{
+			Success: false,
+			Message: "사용자 목록 조회 실패",
+			Error:   err.Error(),
 		})
 		return
 	}
 
-	// 각 사용자 데이터 가져오기
-	var users []models.User
-	for _, userID := range userIDs {
-		key := userKeyPrefix + userID
-		userJSON, err := config.GetRedisClient().Get(config.GetContext(), key).Result()
-		if err != nil {
-			continue // 해당 사용자 데이터가 없으면 건너뛰기
-		}
-
-		var user models.User
-		err = user.FromJSON(userJSON)
-		if err != nil {
-			continue // JSON 변환 실패시 건너뛰기
-		}
-
-		users = append(users, user)
-	}
-
 	c.JSON(http.StatusOK, models.UserResponse{
-		Success: true,
-		Message: fmt.Sprintf("%d명의 사용자를 조회했습니다", len(users)),
-		Data:    users,
+		Success:    true,
+		Message:    fmt.Sprintf("%d명의 사용자를 조회했습니다", len(users)),
+		Data:       users,
+		Pagination: page,
 	})
 }
 
@@ -206,6 +184,8 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
+	before := user
+
 	// 업데이트할 필드만 변경
 	if req.Name != "" {
 		user.Name = req.Name
@@ -239,6 +219,8 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, "user.update", "user", user.ID, before, user)
+
 	c.JSON(http.StatusOK, models.UserResponse{
 		Success: true,
 		Message: "사용자 정보가 성공적으로 업데이트되었습니다",
@@ -259,9 +241,9 @@ func DeleteUser(c *gin.Context) {
 
 	key := userKeyPrefix + userID
 	
-	// 사용자 존재 확인
-	exists, err := config.GetRedisClient().Exists(config.GetContext(), key).Result()
-	if err != nil || exists == 0 {
+	// 사용자 존재 확인 (감사 로그용 스냅샷 포함)
+	userJSON, err := config.GetRedisClient().Get(config.GetContext(), key).Result()
+	if err != nil {
 		c.JSON(http.StatusNotFound, models.UserResponse{
 			Success: false,
 			Message: "사용자를 찾을 수 없습니다",
@@ -269,9 +251,8 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	// 사용자 데이터 삭제
-	err = config.GetRedisClient().Del(config.GetContext(), key).Err()
-	if err != nil {
+	// 사용자 데이터 삭제 (사용자 목록 인덱스 포함)
+	if err := deleteUser(config.GetContext(), userID); err != nil {
 		c.JSON(http.StatusInternalServerError, models.UserResponse{
 			Success: false,
 			Message: "사용자 삭제 실패",
@@ -280,17 +261,18 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	// 사용자 목록에서 ID 제거
-	err = config.GetRedisClient().SRem(config.GetContext(), "users", userID).Err()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.UserResponse{
-			Success: false,
-			Message: "사용자 목록 업데이트 실패",
-			Error:   err.Error(),
-		})
-		return
+	// 관심 목록과 저장된 검색 삭제
+	if err := deleteUserWatches(config.GetContext(), userID); err != nil {
+		log.Printf("사용자 %s의 관심 목록 삭제 실패: %v", userID, err)
 	}
 
+	// 예약된 임장 취소
+	if err := cancelUserInspections(config.GetContext(), userID); err != nil {
+		log.Printf("사용자 %s의 임장 예약 취소 실패: %v", userID, err)
+	}
+
+	recordAudit(c, "user.delete", "user", userID, json.RawMessage(userJSON), nil)
+
 	c.JSON(http.StatusOK, models.UserResponse{
 		Success: true,
 		Message: "사용자가 성공적으로 삭제되었습니다",
@@ -0,0 +1,612 @@
+package handlers
+
+import (
+	"context"
+	"erea-api/config"
+	"erea-api/models"
+	"erea-api/search"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	savedSearchKeyPrefix     = "saved_search:"
+	maxWatchlistSize         = 500
+	maxSavedSearchesPerUser  = 20
+	savedSearchAnyType       = "any"
+	noticeTimeLayout         = "2006-01-02 15:04 MST"
+	savedSearchAttributeName = "criteria.attributes."
+)
+
+// userWatchlistKey is the sorted set of the property IDs a user watches,
+// scored by when they were added
+func userWatchlistKey(userID string) string {
+	return "watchlist:" + userID
+}
+
+// propertyWatchersKey is the set of user IDs watching a property
+func propertyWatchersKey(propertyID string) string {
+	return "property_watchers:" + propertyID
+}
+
+// userSavedSearchesKey is the sorted set of a user's saved search IDs by
+// creation time
+func userSavedSearchesKey(userID string) string {
+	return "saved_searches:" + userID
+}
+
+// savedSearchesByTypeKey is the set of saved search IDs limited to one
+// property type, or to none for savedSearchAnyType
+func savedSearchesByTypeKey(propertyType string) string {
+	if propertyType == "" {
+		propertyType = savedSearchAnyType
+	}
+	return "saved_searches_by_type:" + propertyType
+}
+
+// closingNoticeKey marks an auction whose watchers were told it closes soon
+func closingNoticeKey(auctionID string) string {
+	return "closing_notice:" + auctionID
+}
+
+// GetWatchlist retrieves the properties a user watches, most recently added
+// first. Supports a status filter and cursor pagination.
+func GetWatchlist(c *gin.Context) {
+	userID := c.Param("id")
+	q, err := parseListQuery(c, listSortKeys{"watched_at": userWatchlistKey(userID)}, "-watched_at")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.WatchlistResponse{
+			Success: false,
+			Message: "Invalid query parameters",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	status := c.Query("status")
+	properties, page, err := paginate(config.GetContext(), q, loadProperties,
+		func(property models.Property) string { return property.ID },
+		func(property models.Property) bool {
+			return status == "" || property.Status == status
+		})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.WatchlistResponse{
+			Success: false,
+			Message: "Failed to retrieve watchlist",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.WatchlistResponse{
+		Success:    true,
+		Message:    "Watchlist retrieved successfully",
+		Data:       properties,
+		Pagination: page,
+	})
+}
+
+// WatchProperty adds a property to a user's watchlist. Watchers are
+// notified of new bids and when the auction is about to close.
+func WatchProperty(c *gin.Context) {
+	userID := c.Param("id")
+	var req models.WatchlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.WatchlistResponse{
+			Success: false,
+			Message: "Invalid request data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	redis := config.GetRedisClient()
+	ctx := config.GetContext()
+
+	properties, err := loadProperties(ctx, []string{req.PropertyID})
+	if err == nil && len(properties) == 0 {
+		c.JSON(http.StatusNotFound, models.WatchlistResponse{
+			Success: false,
+			Message: "Property not found",
+		})
+		return
+	}
+
+	var count int64
+	watched := false
+	if err == nil {
+		count, err = redis.ZCard(ctx, userWatchlistKey(userID)).Result()
+	}
+	if err == nil {
+		_, err = redis.ZScore(ctx, userWatchlistKey(userID), req.PropertyID).Result()
+		watched = err == nil
+		if isNotFound(err) {
+			err = nil
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.WatchlistResponse{
+			Success: false,
+			Message: "Failed to update watchlist",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if watched {
+		c.JSON(http.StatusOK, models.WatchlistResponse{
+			Success: true,
+			Message: "Property is already on the watchlist",
+			Data:    properties[0],
+		})
+		return
+	}
+	if count >= maxWatchlistSize {
+		c.JSON(http.StatusConflict, models.WatchlistResponse{
+			Success: false,
+			Message: "Watchlist is full",
+			Error:   fmt.Sprintf("a watchlist holds at most %d properties", maxWatchlistSize),
+		})
+		return
+	}
+
+	if err := addToWatchlist(ctx, userID, req.PropertyID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.WatchlistResponse{
+			Success: false,
+			Message: "Failed to update watchlist",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.WatchlistResponse{
+		Success: true,
+		Message: "Property added to watchlist",
+		Data:    properties[0],
+	})
+}
+
+// UnwatchProperty removes a property from a user's watchlist
+func UnwatchProperty(c *gin.Context) {
+	userID := c.Param("id")
+	propertyID := c.Param("propertyId")
+	ctx := config.GetContext()
+
+	var removed *redis.IntCmd
+	_, err := config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.ZRem(ctx, userWatchlistKey(userID), propertyID)
+		pipe.SRem(ctx, propertyWatchersKey(propertyID), userID)
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.WatchlistResponse{
+			Success: false,
+			Message: "Failed to update watchlist",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if removed.Val() == 0 {
+		c.JSON(http.StatusNotFound, models.WatchlistResponse{
+			Success: false,
+			Message: "Property is not on the watchlist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.WatchlistResponse{
+		Success: true,
+		Message: "Property removed from watchlist",
+	})
+}
+
+// GetSavedSearches retrieves a user's saved searches, newest first
+func GetSavedSearches(c *gin.Context) {
+	ctx := config.GetContext()
+	ids, err := config.GetRedisClient().ZRevRange(ctx, userSavedSearchesKey(c.Param("id")), 0, -1).Result()
+	var searches []models.SavedSearch
+	if err == nil {
+		searches, err = loadSavedSearches(ctx, ids)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.WatchlistResponse{
+			Success: false,
+			Message: "Failed to retrieve saved searches",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.WatchlistResponse{
+		Success: true,
+		Message: "Saved searches retrieved successfully",
+		Data:    searches,
+	})
+}
+
+// CreateSavedSearch saves search criteria for a user, who is then notified
+// when a matching listing is approved
+func CreateSavedSearch(c *gin.Context) {
+	userID := c.Param("id")
+	var req models.CreateSavedSearchRequest
+	err := c.ShouldBindJSON(&req)
+	if err == nil {
+		err = validateSearchCriteria(&req.Criteria)
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if err == nil && req.Name == "" {
+		err = fmt.Errorf("name is required")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.WatchlistResponse{
+			Success: false,
+			Message: "Invalid request data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	redis := config.GetRedisClient()
+	ctx := config.GetContext()
+
+	count, err := redis.ZCard(ctx, userSavedSearchesKey(userID)).Result()
+	if err == nil && count >= maxSavedSearchesPerUser {
+		c.JSON(http.StatusConflict, models.WatchlistResponse{
+			Success: false,
+			Message: "Too many saved searches",
+			Error:   fmt.Sprintf("a user can save at most %d searches", maxSavedSearchesPerUser),
+		})
+		return
+	}
+
+	savedSearch := models.SavedSearch{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      req.Name,
+		Criteria:  req.Criteria,
+		CreatedAt: time.Now(),
+	}
+
+	if err == nil {
+		err = saveSavedSearch(ctx, savedSearch)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.WatchlistResponse{
+			Success: false,
+			Message: "Failed to save search",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.WatchlistResponse{
+		Success: true,
+		Message: "Search saved successfully",
+		Data:    savedSearch,
+	})
+}
+
+// DeleteSavedSearch deletes one of a user's saved searches
+func DeleteSavedSearch(c *gin.Context) {
+	userID := c.Param("id")
+	ctx := config.GetContext()
+
+	searches, err := loadSavedSearches(ctx, []string{c.Param("searchId")})
+	if err == nil && (len(searches) == 0 || searches[0].UserID != userID) {
+		c.JSON(http.StatusNotFound, models.WatchlistResponse{
+			Success: false,
+			Message: "Saved search not found",
+		})
+		return
+	}
+	if err == nil {
+		err = deleteSavedSearches(ctx, searches)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.WatchlistResponse{
+			Success: false,
+			Message: "Failed to delete saved search",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.WatchlistResponse{
+		Success: true,
+		Message: "Saved search deleted successfully",
+	})
+}
+
+// validateSearchCriteria checks saved search criteria and trims them. At
+// least one criterion is required, so a saved search can't match every
+// listing.
+func validateSearchCriteria(criteria *models.SearchCriteria) error {
+	criteria.Query = strings.TrimSpace(criteria.Query)
+	criteria.Type = strings.TrimSpace(criteria.Type)
+	criteria.District = strings.TrimSpace(criteria.District)
+
+	if criteria.Query == "" && criteria.Type == "" && criteria.District == "" &&
+		criteria.MinPrice == nil && criteria.MaxPrice == nil && len(criteria.Attributes) == 0 {
+		return fmt.Errorf("criteria must include at least one of q, type, district, min_price, max_price or attributes")
+	}
+	if criteria.Query != "" && len(search.QueryTerms(criteria.Query)) == 0 {
+		return fmt.Errorf("criteria.q must contain at least one searchable word")
+	}
+	if _, ok := models.PropertyTypeSchemaOf(criteria.Type); criteria.Type != "" && !ok {
+		return fmt.Errorf("unknown property type %q", criteria.Type)
+	}
+	if criteria.MinPrice != nil && criteria.MaxPrice != nil && *criteria.MinPrice > *criteria.MaxPrice {
+		return fmt.Errorf("criteria.min_price must not exceed criteria.max_price")
+	}
+	_, err := parseAttributeFilters(criteria.Attributes, savedSearchAttributeName)
+	return err
+}
+
+// savedSearchMatches reports whether a listing matches saved criteria.
+// terms are the listing's search terms.
+func savedSearchMatches(criteria models.SearchCriteria, property models.Property, terms map[string]float64) bool {
+	if (criteria.Type != "" && property.Type != criteria.Type) ||
+		(criteria.District != "" && !strings.EqualFold(search.District(property.Location), criteria.District)) ||
+		!inInt64Range(property.CurrentPrice, criteria.MinPrice, criteria.MaxPrice) {
+		return false
+	}
+	for _, term := range search.QueryTerms(criteria.Query) {
+		if _, ok := terms[term]; !ok {
+			return false
+		}
+	}
+	filters, err := parseAttributeFilters(criteria.Attributes, savedSearchAttributeName)
+	return err == nil && models.MatchAttributes(property.Attributes, filters)
+}
+
+// notifySavedSearches notifies the users whose saved searches match a
+// listing that just went public, once per user. The owner isn't notified.
+func notifySavedSearches(ctx context.Context, property models.Property) {
+	ids, err := config.GetRedisClient().SUnion(ctx, savedSearchesByTypeKey(property.Type), savedSearchesByTypeKey("")).Result()
+	var searches []models.SavedSearch
+	if err == nil {
+		searches, err = loadSavedSearches(ctx, ids)
+	}
+	if err != nil {
+		log.Printf("Failed to load saved searches for property %s: %v", property.ID, err)
+		return
+	}
+
+	terms := propertySearchTerms(property)
+	notified := make(map[string]bool)
+	for _, savedSearch := range searches {
+		if savedSearch.UserID == property.OwnerID || notified[savedSearch.UserID] ||
+			!savedSearchMatches(savedSearch.Criteria, property, terms) {
+			continue
+		}
+		notified[savedSearch.UserID] = true
+		notifyUser(ctx, models.Notification{
+			UserID:     savedSearch.UserID,
+			Type:       models.NotificationSavedSearchMatch,
+			Title:      fmt.Sprintf("New listing matches %q", savedSearch.Name),
+			Message:    fmt.Sprintf("%s in %s, starting at %s", property.Title, property.Location, formatWon(property.StartingPrice)),
+			EntityType: "property",
+			EntityID:   property.ID,
+		})
+	}
+}
+
+// notifyWatchers sends a notification to every user watching a property
+// except the excluded ones, typically the user who caused it
+func notifyWatchers(ctx context.Context, propertyID string, notification models.Notification, exclude ...string) {
+	watchers, err := config.GetRedisClient().SMembers(ctx, propertyWatchersKey(propertyID)).Result()
+	if err != nil {
+		log.Printf("Failed to load watchers of property %s: %v", propertyID, err)
+		return
+	}
+	excluded := make(map[string]bool, len(exclude))
+	for _, userID := range exclude {
+		excluded[userID] = true
+	}
+	for _, userID := range watchers {
+		if !excluded[userID] {
+			notification.UserID = userID
+			notifyUser(ctx, notification)
+		}
+	}
+}
+
+// RunClosingNotifier tells watchers when an auction is about to close,
+// checking every ClosingNoticeInterval for auctions ending within
+// ClosingNoticeWindow until ctx is done. Each auction is announced once,
+// even with several servers running it.
+func RunClosingNotifier(ctx context.Context) {
+	ticker := time.NewTicker(config.ClosingNoticeInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := notifyClosingAuctions(ctx, time.Now()); err != nil {
+			log.Printf("Failed to send auction closing notices: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// notifyClosingAuctions notifies the watchers of active auctions ending
+// within ClosingNoticeWindow of now that haven't been announced yet
+func notifyClosingAuctions(ctx context.Context, now time.Time) error {
+	rdb := config.GetRedisClient()
+	ids, err := rdb.ZRangeByScore(ctx, activeAuctionsByEndKey, &redis.ZRangeBy{
+		Min: strconv.FormatInt(now.UnixNano(), 10),
+		Max: strconv.FormatInt(now.Add(config.ClosingNoticeWindow).UnixNano(), 10),
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	auctions, err := loadAuctions(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, auction := range auctions {
+		// Claim the notice, so only one server sends it
+		claimed, err := rdb.SetNX(ctx, closingNoticeKey(auction.ID), now.Unix(), auction.EndTime.Sub(now)+config.ClosingNoticeWindow).Result()
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		properties, err := loadProperties(ctx, []string{auction.PropertyID})
+		if err != nil {
+			return err
+		}
+		if len(properties) == 0 {
+			continue
+		}
+		notifyWatchers(ctx, auction.PropertyID, models.Notification{
+			Type:       models.NotificationWatchlistClosing,
+			Title:      fmt.Sprintf("Auction for %q closes soon", properties[0].Title),
+			Message:    fmt.Sprintf("Bidding closes at %s. The current price is %s.", auction.EndTime.Format(noticeTimeLayout), formatWon(properties[0].CurrentPrice)),
+			EntityType: "auction",
+			EntityID:   auction.ID,
+		})
+	}
+	return nil
+}
+
+// addToWatchlist adds a property to a user's watchlist
+func addToWatchlist(ctx context.Context, userID, propertyID string) error {
+	_, err := config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, userWatchlistKey(userID), &redis.Z{Score: float64(time.Now().UnixNano()), Member: propertyID})
+		pipe.SAdd(ctx, propertyWatchersKey(propertyID), userID)
+		return nil
+	})
+	return err
+}
+
+// saveSavedSearch stores a new saved search and indexes it by user and by
+// property type
+func saveSavedSearch(ctx context.Context, savedSearch models.SavedSearch) error {
+	searchJSON, err := savedSearch.ToJSON()
+	if err != nil {
+		return err
+	}
+
+	_, err = config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, savedSearchKeyPrefix+savedSearch.ID, searchJSON, 0)
+		pipe.ZAdd(ctx, userSavedSearchesKey(savedSearch.UserID), &redis.Z{Score: float64(savedSearch.CreatedAt.UnixNano()), Member: savedSearch.ID})
+		pipe.SAdd(ctx, savedSearchesByTypeKey(savedSearch.Criteria.Type), savedSearch.ID)
+		return nil
+	})
+	return err
+}
+
+// deletePropertyWatchers removes a deleted property from every watchlist
+func deletePropertyWatchers(ctx context.Context, propertyID string) error {
+	rdb := config.GetRedisClient()
+	watchers, err := rdb.SMembers(ctx, propertyWatchersKey(propertyID)).Result()
+	if err != nil {
+		return err
+	}
+
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, userID := range watchers {
+			pipe.ZRem(ctx, userWatchlistKey(userID), propertyID)
+		}
+		pipe.Del(ctx, propertyWatchersKey(propertyID))
+		return nil
+	})
+	return err
+}
+
+// deleteUserWatches removes a deleted user's watchlist and saved searches
+func deleteUserWatches(ctx context.Context, userID string) error {
+	rdb := config.GetRedisClient()
+	propertyIDs, err := rdb.ZRange(ctx, userWatchlistKey(userID), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	searchIDs, err := rdb.ZRange(ctx, userSavedSearchesKey(userID), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	searches, err := loadSavedSearches(ctx, searchIDs)
+	if err != nil {
+		return err
+	}
+	if err := deleteSavedSearches(ctx, searches); err != nil {
+		return err
+	}
+
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, propertyID := range propertyIDs {
+			pipe.SRem(ctx, propertyWatchersKey(propertyID), userID)
+		}
+		pipe.Del(ctx, userWatchlistKey(userID), userSavedSearchesKey(userID))
+		return nil
+	})
+	return err
+}
+
+// deleteSavedSearches removes saved search records and their index entries
+func deleteSavedSearches(ctx context.Context, searches []models.SavedSearch) error {
+	if len(searches) == 0 {
+		return nil
+	}
+	_, err := config.GetRedisClient().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, savedSearch := range searches {
+			pipe.Del(ctx, savedSearchKeyPrefix+savedSearch.ID)
+			pipe.ZRem(ctx, userSavedSearchesKey(savedSearch.UserID), savedSearch.ID)
+			pipe.SRem(ctx, savedSearchesByTypeKey(savedSearch.Criteria.Type), savedSearch.ID)
+		}
+		return nil
+	})
+	return err
+}
+
+// loadSavedSearches loads saved searches by ID, preserving order and
+// skipping missing records
+func loadSavedSearches(ctx context.Context, ids []string) ([]models.SavedSearch, error) {
+	records, err := loadRecords(ctx, savedSearchKeyPrefix, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	searches := []models.SavedSearch{}
+	for _, record := range records {
+		var savedSearch models.SavedSearch
+		if savedSearch.FromJSON(record) == nil {
+			searches = append(searches, savedSearch)
+		}
+	}
+	return searches, nil
+}
+
+// formatWon formats an amount in Korean won with thousands separators,
+// e.g. ₩650,000,000
+func formatWon(amount int64) string {
+	digits := strconv.FormatInt(amount, 10)
+	sign := ""
+	if amount < 0 {
+		sign, digits = "-", digits[1:]
+	}
+
+	var b strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	return sign + "₩" + b.String()
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"erea-api/config"
+	"erea-api/models"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func watchTestProperty(t *testing.T, userID, propertyID string) int {
+	t.Helper()
+	router := newTestRouter()
+	router.POST("/users/:id/watchlist", WatchProperty)
+	return performJSON(t, router, http.MethodPost, "/users/"+userID+"/watchlist", models.WatchlistRequest{PropertyID: propertyID}).Code
+}
+
+// notificationTypes returns the types of a user's notifications
+func notificationTypes(t *testing.T, userID string) []string {
+	t.Helper()
+	ctx := context.Background()
+	ids, err := config.GetRedisClient().ZRevRange(ctx, userNotificationsKey(userID), 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifications, err := loadNotifications(ctx, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := make([]string, len(notifications))
+	for i, notification := range notifications {
+		types[i] = notification.Type
+	}
+	return types
+}
+
+func TestWatchersHearOfBidsAndClosing(t *testing.T) {
+	newTestRedis(t)
+	auction := seedAuction(t, "p1", 100)
+
+	if code := watchTestProperty(t, "watcher", "p1"); code != http.StatusCreated {
+		t.Fatalf("watching: status %d", code)
+	}
+	if code := watchTestProperty(t, "watcher", "p1"); code != http.StatusOK {
+		t.Fatalf("watching again: expected 200, got %d", code)
+	}
+	if code := watchTestProperty(t, "watcher", "missing"); code != http.StatusNotFound {
+		t.Fatalf("watching a missing property: expected 404, got %d", code)
+	}
+	watchTestProperty(t, "bidder", "p1")
+
+	if code := placeTestBid(t, "p1", "bidder", 150); code != http.StatusCreated {
+		t.Fatalf("bid: status %d", code)
+	}
+	if types := notificationTypes(t, "watcher"); len(types) != 1 || types[0] != models.NotificationWatchlistBid {
+		t.Fatalf("expected a watchlist.bid notification, got %v", types)
+	}
+	if types := notificationTypes(t, "bidder"); len(types) != 0 {
+		t.Fatalf("the bidder was notified of their own bid: %v", types)
+	}
+
+	// The closing notice goes out once, when the auction enters the window
+	if err := notifyClosingAuctions(context.Background(), auction.EndTime.Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := notifyClosingAuctions(context.Background(), auction.EndTime.Add(-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if types := notificationTypes(t, "watcher"); len(types) != 2 || types[0] != models.NotificationWatchlistClosing {
+		t.Fatalf("expected one closing notice, got %v", types)
+	}
+}
+
+func TestSavedSearchesMatchApprovedListings(t *testing.T) {
+	newTestRedis(t)
+	router := newTestRouter()
+	router.POST("/users/:id/saved-searches", CreateSavedSearch)
+
+	maxPrice := int64(1000)
+	for userID, criteria := range map[string]models.SearchCriteria{
+		"fan":      {Query: "강남", Type: models.PropertyTypeApartment, MaxPrice: &maxPrice, Attributes: map[string]string{"rooms.min": "3"}},
+		"owner":    {Type: models.PropertyTypeApartment},
+		"too-rich": {Type: models.PropertyTypeApartment, Attributes: map[string]string{"rooms.min": "5"}},
+		"other":    {Type: models.PropertyTypeVilla},
+	} {
+		w := performJSON(t, router, http.MethodPost, "/users/"+userID+"/saved-searches", models.CreateSavedSearchRequest{Name: userID, Criteria: criteria})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("saving %s's search: status %d: %s", userID, w.Code, w.Body.String())
+		}
+	}
+	if w := performJSON(t, router, http.MethodPost, "/users/u/saved-searches", models.CreateSavedSearchRequest{Name: "all"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected a search without criteria to be refused, got %d", w.Code)
+	}
+
+	property := models.Property{
+		ID: "p1", Title: "강남역 아파트", Location: "Gangnam-gu, Seoul", Type: models.PropertyTypeApartment,
+		StartingPrice: 900, CurrentPrice: 900, OwnerID: "owner",
+		Attributes: map[string]interface{}{"rooms": int64(3)},
+	}
+	notifySavedSearches(context.Background(), property)
+
+	for userID, want := range map[string]int{"fan": 1, "owner": 0, "too-rich": 0, "other": 0} {
+		if types := notificationTypes(t, userID); len(types) != want {
+			t.Errorf("expected %d notifications for %s, got %v", want, userID, types)
+		}
+	}
+}
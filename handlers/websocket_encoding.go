@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"erea-api/config"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Subprotocols a websocket client can ask for in Sec-WebSocket-Protocol to
+// choose how messages are encoded. Clients that ask for none get JSON.
+const (
+	subprotocolJSON     = "erea.json"
+	subprotocolMsgpack  = "erea.msgpack"
+	subprotocolProtobuf = "erea.protobuf"
+)
+
+// encoding is the wire format of a client's messages
+type encoding int
+
+const (
+	encodingJSON     encoding = iota // Text frames, as WebSocketMessage marshals
+	encodingMsgpack                  // Binary frames, the same fields in MessagePack
+	encodingProtobuf                 // Binary frames, an erea.events.v1.Event (proto/events.proto)
+
+	encodingCount
+)
+
+func (e encoding) String() string {
+	switch e {
+	case encodingMsgpack:
+		return subprotocolMsgpack
+	case encodingProtobuf:
+		return subprotocolProtobuf
+	}
+	return subprotocolJSON
+}
+
+// messageType returns the websocket frame type of the encoding
+func (e encoding) messageType() int {
+	if e == encodingJSON {
+		return websocket.TextMessage
+	}
+	return websocket.BinaryMessage
+}
+
+// selectEncoding picks the first subprotocol the client asks for that the
+// server knows. Returns the subprotocol to confirm, "" if none.
+func selectEncoding(r *http.Request) (encoding, string) {
+	for _, protocol := range websocket.Subprotocols(r) {
+		for e := encoding(0); e < encodingCount; e++ {
+			if protocol == e.String() {
+				return e, protocol
+			}
+		}
+	}
+	return encodingJSON, ""
+}
+
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{}
+	h.WriteExt = true // str8 and bin types of the current spec
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	h.RawToString = true
+	return h
+}()
+
+// encode converts a JSON encoded message to the encoding
+func (e encoding) encode(message []byte) ([]byte, error) {
+	if e == encodingJSON {
+		return message, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(message))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	normalizeNumbers(fields)
+
+	if e == encodingMsgpack {
+		var encoded []byte
+		err := codec.NewEncoderBytes(&encoded, msgpackHandle).Encode(fields)
+		return encoded, err
+	}
+	return encodeProtobufEvent(fields)
+}
+
+// normalizeNumbers replaces the json.Numbers of a decoded JSON value with
+// int64s, or float64s for numbers that aren't integers, in place
+func normalizeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeNumbers(item)
+		}
+	}
+	return value
+}
+
+// encodeProtobufEvent encodes a message's fields as an Event:
+//
+//	message Event {
+//	  string type = 1;
+//	  string id = 2;
+//	  map<string, int64> seq = 3;
+//	  google.protobuf.Value data = 4;
+//	  string message = 5;
+//	}
+func encodeProtobufEvent(fields map[string]interface{}) ([]byte, error) {
+	var b []byte
+	appendString := func(number protowire.Number, key string) {
+		if s, _ := fields[key].(string); s != "" {
+			b = protowire.AppendTag(b, number, protowire.BytesType)
+			b = protowire.AppendString(b, s)
+		}
+	}
+
+	appendString(1, "type")
+	appendString(2, "id")
+
+	if seq, ok := fields["seq"].(map[string]interface{}); ok {
+		topics := make([]string, 0, len(seq))
+		for topic := range seq {
+			topics = append(topics, topic)
+		}
+		sort.Strings(topics)
+		for _, topic := range topics {
+			n, _ := seq[topic].(int64)
+			var entry []byte
+			entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+			entry = protowire.AppendString(entry, topic)
+			entry = protowire.AppendTag(entry, 2, protowire.VarintType)
+			entry = protowire.AppendVarint(entry, uint64(n))
+			b = protowire.AppendTag(b, 3, protowire.BytesType)
+			b = protowire.AppendBytes(b, entry)
+		}
+	}
+
+	if data, ok := fields["data"]; ok && data != nil {
+		value, err := structpb.NewValue(data)
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := proto.MarshalOptions{Deterministic: true}.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendBytes(b, encoded)
+	}
+
+	appendString(5, "message")
+	return b, nil
+}
+
+// decodeControl decodes a control message. Clients send JSON, or binary
+// MessagePack if they use it.
+func decodeControl(messageType int, data []byte, e encoding, control *ControlMessage) error {
+	if messageType == websocket.BinaryMessage && e == encodingMsgpack {
+		return codec.NewDecoderBytes(data, msgpackHandle).Decode(control)
+	}
+	return json.Unmarshal(data, control)
+}
+
+// preparedFrames holds a message's frame in each encoding, encoded and
+// compressed at most once however many clients it goes to
+type preparedFrames struct {
+	json []byte
+
+	once   [encodingCount]sync.Once
+	frames [encodingCount]*websocket.PreparedMessage
+	sizes  [encodingCount]int
+	errs   [encodingCount]error
+}
+
+func newPreparedFrames(message []byte) *preparedFrames {
+	return &preparedFrames{json: message}
+}
+
+// frame returns the message's frame in an encoding and its size before
+// compression
+func (f *preparedFrames) frame(e encoding) (*websocket.PreparedMessage, int, error) {
+	f.once[e].Do(func() {
+		data, err := e.encode(f.json)
+		if err != nil {
+			f.errs[e] = err
+			return
+		}
+		f.sizes[e] = len(data)
+		f.frames[e], f.errs[e] = websocket.NewPreparedMessage(e.messageType(), data)
+	})
+	return f.frames[e], f.sizes[e], f.errs[e]
+}
+
+// compress reports whether a message of size bytes is worth compressing
+func compress(size int) bool {
+	return config.WSCompression && int64(size) >= config.WSCompressionMinSize
+}
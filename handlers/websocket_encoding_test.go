@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// testEventJSON has an integer beyond float64 precision, a fraction and a
+// nested value, to check that numbers keep their kind in every encoding
+const testEventJSON = `{"type":"bid_update","id":"req-1","seq":{"auction:a1":42,"property:p1":7},` +
+	`"data":{"amount":9007199254740993,"ratio":0.25,"bidder":"alice","tags":["a",1]},"message":"New bid"}`
+
+// decodeTestJSON decodes a JSON message the way encode does before
+// converting it
+func decodeTestJSON(t *testing.T, message string) map[string]interface{} {
+	t.Helper()
+	decoder := json.NewDecoder(strings.NewReader(message))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		t.Fatal(err)
+	}
+	normalizeNumbers(fields)
+	return fields
+}
+
+// decodeTestEvent decodes a protobuf Event (proto/events.proto) into the
+// fields of the JSON message it was encoded from
+func decodeTestEvent(t *testing.T, b []byte) map[string]interface{} {
+	t.Helper()
+	fields := map[string]interface{}{}
+	for len(b) > 0 {
+		number, wireType, n := protowire.ConsumeTag(b)
+		if n < 0 || wireType != protowire.BytesType {
+			t.Fatalf("unexpected field %d of wire type %d", number, wireType)
+		}
+		b = b[n:]
+		value, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			t.Fatalf("truncated field %d", number)
+		}
+		b = b[n:]
+
+		switch number {
+		case 1:
+			fields["type"] = string(value)
+		case 2:
+			fields["id"] = string(value)
+		case 3:
+			seq, _ := fields["seq"].(map[string]interface{})
+			if seq == nil {
+				seq = map[string]interface{}{}
+				fields["seq"] = seq
+			}
+			var topic string
+			var position int64
+			for len(value) > 0 {
+				entryNumber, _, n := protowire.ConsumeTag(value)
+				value = value[n:]
+				if entryNumber == 1 {
+					s, n := protowire.ConsumeString(value)
+					topic, value = s, value[n:]
+				} else {
+					v, n := protowire.ConsumeVarint(value)
+					position, value = int64(v), value[n:]
+				}
+			}
+			seq[topic] = position
+		case 4:
+			var data structpb.Value
+			if err := proto.Unmarshal(value, &data); err != nil {
+				t.Fatal(err)
+			}
+			fields["data"] = data.AsInterface()
+		case 5:
+			fields["message"] = string(value)
+		default:
+			t.Fatalf("unknown field %d", number)
+		}
+	}
+	return fields
+}
+
+func TestEncodeMsgpackRoundTrip(t *testing.T) {
+	encoded, err := encodingMsgpack.encode([]byte(testEventJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := codec.NewDecoderBytes(encoded, msgpackHandle).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	data := decoded["data"].(map[string]interface{})
+	if amount, ok := data["amount"].(int64); !ok || amount != 9007199254740993 {
+		t.Fatalf("integer amount came back as %T %v", data["amount"], data["amount"])
+	}
+	if ratio, ok := data["ratio"].(float64); !ok || ratio != 0.25 {
+		t.Fatalf("fractional ratio came back as %T %v", data["ratio"], data["ratio"])
+	}
+	if want := decodeTestJSON(t, testEventJSON); !reflect.DeepEqual(decoded, want) {
+		t.Fatalf("got %v, want %v", decoded, want)
+	}
+}
+
+func TestEncodeProtobufRoundTrip(t *testing.T) {
+	encoded, err := encodingProtobuf.encode([]byte(testEventJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := decodeTestEvent(t, encoded)
+
+	// Sequence numbers are int64 fields and stay exact
+	if want := map[string]interface{}{"auction:a1": int64(42), "property:p1": int64(7)}; !reflect.DeepEqual(decoded["seq"], want) {
+		t.Fatalf("seq came back as %v", decoded["seq"])
+	}
+
+	// Data numbers are google.protobuf.Value doubles, as JSON numbers are
+	// to most readers
+	want := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(testEventJSON), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded["data"], want["data"]) {
+		t.Fatalf("data came back as %v, want %v", decoded["data"], want["data"])
+	}
+	for _, key := range []string{"type", "id", "message"} {
+		if decoded[key] != want[key] {
+			t.Fatalf("%s came back as %v, want %v", key, decoded[key], want[key])
+		}
+	}
+
+	// Empty fields are left out, as proto3 does
+	encoded, err = encodingProtobuf.encode([]byte(`{"type":"gap","data":null}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded := decodeTestEvent(t, encoded); !reflect.DeepEqual(decoded, map[string]interface{}{"type": "gap"}) {
+		t.Fatalf("got %v", decoded)
+	}
+}
+
+func TestEncodeJSONIsUnchanged(t *testing.T) {
+	encoded, err := encodingJSON.encode([]byte(testEventJSON))
+	if err != nil || string(encoded) != testEventJSON {
+		t.Fatalf("got %s (%v)", encoded, err)
+	}
+}
+
+func TestDecodeControl(t *testing.T) {
+	want := ControlMessage{Type: "resume", ID: "r1", Topics: []string{"auction:a1"}, LastSeq: map[string]int64{"auction:a1": 41}}
+
+	var packed []byte
+	if err := codec.NewEncoderBytes(&packed, msgpackHandle).Encode(want); err != nil {
+		t.Fatal(err)
+	}
+	var control ControlMessage
+	if err := decodeControl(websocket.BinaryMessage, packed, encodingMsgpack, &control); err != nil || !reflect.DeepEqual(control, want) {
+		t.Fatalf("msgpack control: got %+v (%v)", control, err)
+	}
+
+	// Text frames are JSON whatever the encoding
+	text, _ := json.Marshal(want)
+	for _, e := range []encoding{encodingJSON, encodingMsgpack, encodingProtobuf} {
+		control = ControlMessage{}
+		if err := decodeControl(websocket.TextMessage, text, e, &control); err != nil || !reflect.DeepEqual(control, want) {
+			t.Fatalf("JSON control over %s: got %+v (%v)", e, control, err)
+		}
+	}
+}
+
+func TestSelectEncoding(t *testing.T) {
+	cases := []struct {
+		header   string
+		encoding encoding
+		protocol string
+	}{
+		{"", encodingJSON, ""},
+		{"graphql-ws", encodingJSON, ""},
+		{subprotocolMsgpack, encodingMsgpack, subprotocolMsgpack},
+		{"graphql-ws, " + subprotocolProtobuf + ", " + subprotocolMsgpack, encodingProtobuf, subprotocolProtobuf},
+		{subprotocolJSON, encodingJSON, subprotocolJSON},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		if tc.header != "" {
+			req.Header.Set("Sec-WebSocket-Protocol", tc.header)
+		}
+		if e, protocol := selectEncoding(req); e != tc.encoding || protocol != tc.protocol {
+			t.Errorf("%q: got %s %q, want %s %q", tc.header, e, protocol, tc.encoding, tc.protocol)
+		}
+	}
+}
+
+func TestPreparedFramesEncodeOncePerEncoding(t *testing.T) {
+	frames := newPreparedFrames([]byte(testEventJSON))
+
+	var wg sync.WaitGroup
+	got := make([][encodingCount]*websocket.PreparedMessage, 8)
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for e := encoding(0); e < encodingCount; e++ {
+				got[i][e], _, _ = frames.frame(e)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for e := encoding(0); e < encodingCount; e++ {
+		if got[0][e] == nil {
+			t.Fatalf("no %s frame", e)
+		}
+		for i := range got {
+			if got[i][e] != got[0][e] {
+				t.Fatalf("%s was prepared more than once", e)
+			}
+		}
+	}
+
+	for e := encoding(0); e < encodingCount; e++ {
+		encoded, _ := e.encode([]byte(testEventJSON))
+		if _, size, err := frames.frame(e); err != nil || size != len(encoded) {
+			t.Fatalf("%s frame: size %d (%v), want %d", e, size, err, len(encoded))
+		}
+	}
+	if bytes.Equal(mustEncode(t, encodingMsgpack), mustEncode(t, encodingProtobuf)) {
+		t.Fatal("expected the binary encodings to differ")
+	}
+
+	// A message that can't be encoded fails in the binary encodings only
+	broken := newPreparedFrames([]byte("not json"))
+	if _, _, err := broken.frame(encodingJSON); err != nil {
+		t.Fatalf("JSON frame: %v", err)
+	}
+	if _, _, err := broken.frame(encodingMsgpack); err == nil {
+		t.Fatal("expected the msgpack frame to fail")
+	}
+}
+
+func mustEncode(t *testing.T, e encoding) []byte {
+	t.Helper()
+	encoded, err := e.encode([]byte(testEventJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
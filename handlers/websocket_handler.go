@@ -1,333 +1,1233 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"erea-api/config"
+	"erea-api/middleware"
 	"erea-api/models"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		// Allow connections from any origin for development
-		// In production, you should check the origin
+	CheckOrigin:       checkOrigin,
+	EnableCompression: config.WSCompression,
+}
+
+// checkOrigin allows browser connections from WSAllowedOrigins. Requests
+// without an Origin header don't come from a browser and are allowed. With
+// no allowlist configured only same-origin pages may connect; "*" allows
+// every origin, for development.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
 		return true
-	},
+	}
+	if len(config.WSAllowedOrigins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, allowed := range config.WSAllowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Topic kinds. A topic is "<kind>:<id>", e.g. property:123, except
+// topicAuctions which takes no ID.
+const (
+	topicProperty = "property" // Bids, auction and property updates of one property
+	topicAuction  = "auction"  // Bids and status updates of one auction
+	topicUser     = "user"     // Private events of one user, e.g. notifications
+	topicAuctions = "auctions" // Status updates of every auction
+)
+
+// maxClientTopics limits the topics one connection can subscribe to
+const maxClientTopics = 100
+
+// wsEventsChannel is the Redis pub/sub channel carrying events to every
+// instance's hub
+const wsEventsChannel = "ws:events"
+
+// relayRetryDelay is how long the relay waits before receiving again after
+// the pub/sub connection fails
+const relayRetryDelay = time.Second
+
+// Control message types sent by clients, and the replies to them
+const (
+	controlSubscribe   = "subscribe"
+	controlUnsubscribe = "unsubscribe"
+	controlResume      = "resume"
+	controlTime        = "time" // Also sent on connect
+	replyAck           = "ack"
+	replyError         = "error"
+	replyGap           = "gap"
+)
+
+// maxPendingResumes limits the resume messages of one connection waiting
+// to be replayed
+const maxPendingResumes = 8
+
+func propertyTopic(propertyID string) string {
+	return topicProperty + ":" + propertyID
+}
+
+func auctionTopic(auctionID string) string {
+	return topicAuction + ":" + auctionID
+}
+
+func userTopic(userID string) string {
+	return topicUser + ":" + userID
 }
 
 // Client represents a websocket client
 type Client struct {
-	conn       *websocket.Conn
-	send       chan []byte
-	propertyID string
-	userID     string
+	id   string
+	hub  *Hub
+	conn *websocket.Conn
+	send chan outboundMessage
+
+	// Wire format chosen by the client's subprotocol
+	encoding encoding
+
+	// Caller authenticated on upgrade, nil for anonymous connections
+	principal *middleware.Principal
+
+	// Resume requests, replayed by writePump before it sends queued events
+	resumes chan resumeRequest
+
+	// Subscribed topics, guarded by the hub's mutex
+	topics map[string]bool
+
+	// Set once send is closed, guarded by the hub's mutex
+	closed bool
+
+	// Close frame writePump sends once send is closed. Set before closing
+	// send, so writePump reads it after the close.
+	closeCode int
+	closeText string
 }
 
-// Hub maintains the set of active clients and broadcasts messages to them
+// Hub maintains the set of active clients and delivers messages to the
+// clients subscribed to each topic. Sends to a client happen under the
+// read lock and closing its send channel under the write lock, so a message
+// is never sent to a closed channel and a channel is closed once. A client
+// whose send buffer is full is evicted rather than slowing down everyone
+// else.
+//
+// Events reach hubs through Redis pub/sub, so clients get them whichever
+// API instance they are connected to. While a hub isn't subscribed it
+// delivers its own events directly.
 type Hub struct {
+	// Identifies the hub's own events on wsEventsChannel
+	id string
+
 	// Registered clients
 	clients map[*Client]bool
 
-	// Inbound messages from the clients
-	broadcast chan []byte
+	// Clients subscribed to each topic
+	topics map[string]map[*Client]bool
 
-	// Register requests from the clients
-	register chan *Client
+	// Whether the hub receives events from wsEventsChannel
+	relaying atomic.Bool
 
-	// Unregister requests from clients
-	unregister chan *Client
+	// Clients per signed-in user and viewers per property, for presence
+	users   map[string]int
+	viewers map[string]map[string]int
 
-	// Property-specific client groups
-	propertyClients map[string]map[*Client]bool
+	// Presence changes not yet written to Redis
+	presence []presenceOp
 
 	mutex sync.RWMutex
+
+	// Serializes presence writes, so they land in order
+	presenceMutex sync.Mutex
 }
 
 // Global hub instance
-var hub = &Hub{
-	broadcast:       make(chan []byte),
-	register:        make(chan *Client),
-	unregister:      make(chan *Client),
-	clients:         make(map[*Client]bool),
-	propertyClients: make(map[string]map[*Client]bool),
+var hub = newHub()
+
+func newHub() *Hub {
+	return &Hub{
+		id:      uuid.New().String(),
+		clients: make(map[*Client]bool),
+		topics:  make(map[string]map[*Client]bool),
+		users:   make(map[string]int),
+		viewers: make(map[string]map[string]int),
+	}
+}
+
+// relayedEvent is an event as published on wsEventsChannel
+type relayedEvent struct {
+	Topics  []string         `json:"topics"`
+	Seq     map[string]int64 `json:"seq,omitempty"`
+	Message json.RawMessage  `json:"message"`
+
+	// ID of the hub that already delivered the event to its own clients,
+	// because it wasn't subscribed when sending it
+	DeliveredBy string `json:"delivered_by,omitempty"`
+}
+
+// outboundMessage is a message queued for a client, shared with the other
+// clients it goes to, with the event's sequence numbers if it is one
+type outboundMessage struct {
+	frames *preparedFrames
+	seq    map[string]int64
+}
+
+// resumeRequest asks writePump to subscribe to topics and replay their
+// events after the given sequence numbers
+type resumeRequest struct {
+	id      string
+	lastSeq map[string]int64
 }
 
 // WebSocketMessage represents a websocket message
 type WebSocketMessage struct {
-	Type    string      `json:"type"`
-	Data    interface{} `json:"data"`
-	Message string      `json:"message"`
+	Type    string           `json:"type"`
+	ID      string           `json:"id,omitempty"`  // ID of the control message a reply answers
+	Seq     map[string]int64 `json:"seq,omitempty"` // Sequence number of an event in each of its topics
+	Data    interface{}      `json:"data"`
+	Message string           `json:"message"`
+}
+
+// ControlMessage is a message from a client managing its subscriptions
+type ControlMessage struct {
+	Type    string           `json:"type"`               // subscribe, unsubscribe or resume
+	ID      string           `json:"id,omitempty"`       // Echoed in the reply
+	Topics  []string         `json:"topics"`             // For subscribe and unsubscribe
+	LastSeq map[string]int64 `json:"last_seq,omitempty"` // For resume: last sequence number seen per topic
+}
+
+// SubscriptionAck is the data of an ack reply
+type SubscriptionAck struct {
+	Action        string   `json:"action"`
+	Topics        []string `json:"topics"`
+	Subscriptions []string `json:"subscriptions"`      // Every topic the connection is subscribed to
+	Replayed      int      `json:"replayed,omitempty"` // Events replayed by a resume
 }
 
 // BidUpdate represents a bid update message
 type BidUpdate struct {
-	PropertyID    string `json:"property_id"`
-	NewBid        int64  `json:"new_bid"`
-	BidderID      string `json:"bidder_id"`
-	BidCount      int    `json:"bid_count"`
-	TimeRemaining string `json:"time_remaining"`
+	PropertyID    string     `json:"property_id"`
+	AuctionID     string     `json:"auction_id,omitempty"`
+	NewBid        int64      `json:"new_bid"`
+	BidderID      string     `json:"bidder_id"`
+	BidCount      int        `json:"bid_count"`
+	EndsAt        *time.Time `json:"ends_at,omitempty"`
+	TimeRemaining string     `json:"time_remaining"`
 }
 
 // AuctionUpdate represents an auction update message
 type AuctionUpdate struct {
+	AuctionID  string `json:"auction_id"`
 	PropertyID string `json:"property_id"`
 	Status     string `json:"status"`
 	WinnerID   string `json:"winner_id,omitempty"`
 	WinningBid int64  `json:"winning_bid,omitempty"`
 }
 
-func init() {
-	go hub.run()
+// OutbidAlert is sent to a bidder whose leading bid was beaten
+type OutbidAlert struct {
+	PropertyID    string    `json:"property_id"`
+	AuctionID     string    `json:"auction_id,omitempty"`
+	PropertyTitle string    `json:"property_title"`
+	YourBid       int64     `json:"your_bid"`
+	NewBid        int64     `json:"new_bid"`
+	EndsAt        time.Time `json:"ends_at"`
+	TimeRemaining string    `json:"time_remaining"`
+}
+
+// ServerTime is the data of a time message, for clients to sync their
+// countdowns with the server's clock
+type ServerTime struct {
+	ServerTime time.Time `json:"server_time"`
+	UnixMillis int64     `json:"unix_ms"`
+}
+
+// PropertyUpdate represents a property update message with what changed
+type PropertyUpdate struct {
+	PropertyID string               `json:"property_id"`
+	Version    int                  `json:"version"`
+	Changes    []models.FieldChange `json:"changes"`
+	Property   models.Property      `json:"property"`
+}
+
+// register adds a client with its initial topics to the hub
+func (h *Hub) register(client *Client) {
+	h.mutex.Lock()
+	h.clients[client] = true
+	h.addConnection(client)
+	for topic := range client.topics {
+		h.index(client, topic)
+	}
+	count := len(h.clients)
+	h.mutex.Unlock()
+
+	log.Printf("Client connected. Total clients: %d", count)
+	h.flushPresence()
+}
+
+// unregister removes a client whose connection has closed
+func (h *Hub) unregister(client *Client) {
+	h.mutex.Lock()
+	h.remove(client, websocket.CloseNormalClosure, "")
+	count := len(h.clients)
+	h.mutex.Unlock()
+
+	log.Printf("Client disconnected. Total clients: %d", count)
+	h.flushPresence()
+}
+
+// evict removes clients too slow to keep up with their messages
+func (h *Hub) evict(clients []*Client) {
+	h.mutex.Lock()
+	for _, client := range clients {
+		if !client.closed {
+			log.Printf("Evicting slow websocket client after %d queued messages", cap(client.send))
+		}
+		h.remove(client, websocket.CloseTryAgainLater, "client too slow")
+	}
+	h.mutex.Unlock()
+
+	h.flushPresence()
+}
+
+// index adds a client to a topic's subscribers, and to the viewers of a
+// property it follows. The mutex must be held.
+func (h *Hub) index(client *Client, topic string) {
+	if h.topics[topic] == nil {
+		h.topics[topic] = make(map[*Client]bool)
+	}
+	if h.topics[topic][client] {
+		return
+	}
+	h.topics[topic][client] = true
+	if kind, id, _ := parseTopic(topic); kind == topicProperty {
+		h.addViewer(client, id)
+	}
+}
+
+// unindex removes a client from a topic's subscribers, and from the
+// viewers of a property it follows. The mutex must be held.
+func (h *Hub) unindex(client *Client, topic string) {
+	clients := h.topics[topic]
+	if !clients[client] {
+		return
+	}
+	delete(clients, client)
+	if len(clients) == 0 {
+		delete(h.topics, topic)
+	}
+	if kind, id, _ := parseTopic(topic); kind == topicProperty {
+		h.removeViewer(client, id)
+	}
+}
+
+// remove drops a client from the hub and closes its send channel, which
+// makes writePump close the connection with the given close code. Removing
+// a client twice is harmless. The mutex must be held.
+func (h *Hub) remove(client *Client, closeCode int, closeText string) {
+	for topic := range client.topics {
+		h.unindex(client, topic)
+	}
+	if h.clients[client] {
+		delete(h.clients, client)
+		h.removeConnection(client)
+	}
+	if !client.closed {
+		client.closed = true
+		client.closeCode = closeCode
+		client.closeText = closeText
+		close(client.send)
+	}
+}
+
+// fanOut publishes an event to every instance's hub through Redis. If the
+// hub isn't subscribed, or publishing fails, it delivers the event to its
+// own clients instead so they still get it. An event it delivered itself
+// is marked, so it isn't delivered again if the hub subscribes before the
+// event comes back.
+func (h *Hub) fanOut(ctx context.Context, message []byte, seq map[string]int64, topics ...string) {
+	event := relayedEvent{Topics: topics, Seq: seq, Message: message}
+	relaying := h.relaying.Load()
+	if !relaying {
+		h.publish(message, seq, topics...)
+		event.DeliveredBy = h.id
+	}
+
+	eventJSON, err := json.Marshal(event)
+	if err == nil {
+		err = config.GetRedisClient().Publish(ctx, wsEventsChannel, eventJSON).Err()
+	}
+	if err != nil {
+		log.Printf("Failed to publish websocket event: %v", err)
+		if relaying {
+			h.publish(message, seq, topics...)
+		}
+	}
 }
 
-// run handles hub operations
-func (h *Hub) run() {
+// relay delivers events published on wsEventsChannel by any instance to
+// the hub's clients until ctx is done
+func (h *Hub) relay(ctx context.Context, rdb *redis.Client) {
+	pubsub := rdb.Subscribe(ctx, wsEventsChannel)
+	defer pubsub.Close()
+	defer h.relaying.Store(false)
+
+	// Receive doesn't return when ctx is done, closing the subscription does
+	stop := context.AfterFunc(ctx, func() { pubsub.Close() })
+	defer stop()
+
 	for {
-		select {
-		case client := <-h.register:
-			h.mutex.Lock()
-			h.clients[client] = true
-			
-			// Add to property-specific group if specified
-			if client.propertyID != "" {
-				if h.propertyClients[client.propertyID] == nil {
-					h.propertyClients[client.propertyID] = make(map[*Client]bool)
-				}
-				h.propertyClients[client.propertyID][client] = true
+		received, err := pubsub.Receive(ctx)
+		if err != nil {
+			h.relaying.Store(false)
+			if ctx.Err() != nil {
+				return
 			}
-			h.mutex.Unlock()
-			
-			log.Printf("Client connected. Total clients: %d", len(h.clients))
-
-		case client := <-h.unregister:
-			h.mutex.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-				
-				// Remove from property-specific group
-				if client.propertyID != "" {
-					if clients, exists := h.propertyClients[client.propertyID]; exists {
-						delete(clients, client)
-						if len(clients) == 0 {
-							delete(h.propertyClients, client.propertyID)
-						}
-					}
-				}
+			log.Printf("Websocket relay error: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(relayRetryDelay):
 			}
-			h.mutex.Unlock()
-			
-			log.Printf("Client disconnected. Total clients: %d", len(h.clients))
-
-		case message := <-h.broadcast:
-			h.mutex.RLock()
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
-				}
+			continue
+		}
+
+		switch received := received.(type) {
+		case *redis.Subscription:
+			h.relaying.Store(received.Kind == "subscribe")
+		case *redis.Message:
+			var event relayedEvent
+			if err := json.Unmarshal([]byte(received.Payload), &event); err != nil {
+				log.Printf("Failed to decode websocket event: %v", err)
+				continue
 			}
-			h.mutex.RUnlock()
+			if event.DeliveredBy == h.id {
+				continue
+			}
+			h.publish(event.Message, event.Seq, event.Topics...)
 		}
 	}
 }
 
-// broadcastToProperty sends a message to all clients watching a specific property
-func (h *Hub) broadcastToProperty(propertyID string, message []byte) {
+// RunWebSocketRelay delivers events from every API instance to this
+// instance's websocket clients until ctx is done
+func RunWebSocketRelay(ctx context.Context) {
+	hub.relay(ctx, config.GetRedisClient())
+}
+
+// publish sends a message once to every client subscribed to any of the
+// topics. Clients too slow to keep up are dropped.
+func (h *Hub) publish(message []byte, seq map[string]int64, topics ...string) {
+	outbound := outboundMessage{frames: newPreparedFrames(message), seq: seq}
+
 	h.mutex.RLock()
-	defer h.mutex.RUnlock()
-	
-	if clients, exists := h.propertyClients[propertyID]; exists {
-		for client := range clients {
+	sent := make(map[*Client]bool)
+	var slow []*Client
+	for _, topic := range topics {
+		for client := range h.topics[topic] {
+			if sent[client] || client.closed {
+				continue
+			}
+			sent[client] = true
 			select {
-			case client.send <- message:
+			case client.send <- outbound:
 			default:
-				close(client.send)
-				delete(h.clients, client)
-				delete(clients, client)
+				slow = append(slow, client)
 			}
 		}
 	}
+	h.mutex.RUnlock()
+
+	if len(slow) > 0 {
+		h.evict(slow)
+	}
+}
+
+// sendTo sends a message to one client, evicting it if its buffer is full
+func (h *Hub) sendTo(client *Client, message []byte) {
+	h.mutex.RLock()
+	full := false
+	if !client.closed {
+		select {
+		case client.send <- outboundMessage{frames: newPreparedFrames(message)}:
+		default:
+			full = true
+		}
+	}
+	h.mutex.RUnlock()
+
+	if full {
+		h.evict([]*Client{client})
+	}
+}
+
+// subscribe adds topics to a client's subscriptions and returns all of
+// them, or an error if the client would exceed maxClientTopics
+func (h *Hub) subscribe(client *Client, topics []string) ([]string, error) {
+	defer h.flushPresence()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	added := 0
+	for _, topic := range topics {
+		if !client.topics[topic] {
+			added++
+		}
+	}
+	if len(client.topics)+added > maxClientTopics {
+		return nil, fmt.Errorf("a connection can subscribe to at most %d topics", maxClientTopics)
+	}
+
+	for _, topic := range topics {
+		client.topics[topic] = true
+		if !client.closed {
+			h.index(client, topic)
+		}
+	}
+	return sortedTopics(client.topics), nil
+}
+
+// unsubscribe removes topics from a client's subscriptions and returns the
+// remaining ones
+func (h *Hub) unsubscribe(client *Client, topics []string) []string {
+	defer h.flushPresence()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, topic := range topics {
+		delete(client.topics, topic)
+		h.unindex(client, topic)
+	}
+	return sortedTopics(client.topics)
+}
+
+// subscriberCount returns the number of clients subscribed to a topic
+func (h *Hub) subscriberCount(topic string) int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return len(h.topics[topic])
+}
+
+func sortedTopics(topics map[string]bool) []string {
+	list := make([]string, 0, len(topics))
+	for topic := range topics {
+		list = append(list, topic)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// parseTopic splits a topic into its kind and ID, checking its syntax
+func parseTopic(topic string) (string, string, error) {
+	kind, id, hasID := strings.Cut(topic, ":")
+	switch kind {
+	case topicAuctions:
+		if hasID {
+			return "", "", fmt.Errorf("topic %q takes no ID", topicAuctions)
+		}
+		return kind, "", nil
+	case topicProperty, topicAuction, topicUser:
+		if id == "" {
+			return "", "", fmt.Errorf("topic %q needs an ID, e.g. %s:<id>", topic, kind)
+		}
+		return kind, id, nil
+	}
+	return "", "", fmt.Errorf("unknown topic %q, expected property:<id>, auction:<id>, user:<id> or %s", topic, topicAuctions)
+}
+
+// authorizeTopics checks that the principal may follow the topics. User
+// topics are private to the user and admins; the others are public.
+// Malformed topics are left to validateTopics.
+func authorizeTopics(principal *middleware.Principal, topics []string) error {
+	for _, topic := range topics {
+		kind, id, err := parseTopic(topic)
+		if err != nil || kind != topicUser {
+			continue
+		}
+		if principal == nil {
+			return fmt.Errorf("topic %q is private, connect with the user's token", topic)
+		}
+		if principal.UserID != id && !principal.HasScope(models.ScopeAdmin) {
+			return fmt.Errorf("topic %q is private to its user", topic)
+		}
+	}
+	return nil
+}
+
+// streamPrincipal authenticates a websocket upgrade or event stream.
+// Browsers can't set headers on either, so besides the headers read by
+// middleware.Authenticate the token may be passed as access_token. Returns
+// nil for anonymous connections.
+func streamPrincipal(c *gin.Context) (*middleware.Principal, error) {
+	if principal, ok := middleware.GetPrincipal(c); ok {
+		return principal, nil
+	}
+	token := strings.TrimSpace(middleware.AccessToken(c))
+	if token == "" {
+		return nil, nil
+	}
+	return middleware.ResolvePrincipal(token)
+}
+
+// validateTopics checks that topics are well formed and name existing
+// properties, auctions and users
+func validateTopics(ctx context.Context, topics []string) error {
+	if len(topics) == 0 {
+		return fmt.Errorf("topics is required")
+	}
+
+	rdb := config.GetRedisClient()
+	for _, topic := range topics {
+		kind, id, err := parseTopic(topic)
+		if err != nil {
+			return err
+		}
+
+		key := ""
+		switch kind {
+		case topicProperty:
+			key = "property:" + id
+		case topicAuction:
+			key = "auction:" + id
+		case topicUser:
+			key = userKeyPrefix + id
+		default:
+			continue
+		}
+		exists, err := rdb.Exists(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if exists == 0 {
+			return fmt.Errorf("%s %q not found", kind, id)
+		}
+	}
+	return nil
+}
+
+// openStream checks the origin and credentials of a websocket or event
+// stream request and resolves the topics it asks for. If the request can't
+// be served it responds with an error and returns false.
+func openStream(c *gin.Context) (*middleware.Principal, []string, bool) {
+	if !checkOrigin(c.Request) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "Origin not allowed",
+		})
+		return nil, nil, false
+	}
+
+	principal, err := streamPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "Authentication failed",
+			"error":   err.Error(),
+		})
+		return nil, nil, false
+	}
+	if principal == nil && config.WSRequireAuth {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "Authentication required",
+		})
+		return nil, nil, false
+	}
+
+	var topics []string
+	if propertyID := c.Query("property_id"); propertyID != "" {
+		topics = append(topics, propertyTopic(propertyID))
+	}
+	if list := c.Query("topics"); list != "" {
+		requested := strings.Split(list, ",")
+		for i := range requested {
+			requested[i] = strings.TrimSpace(requested[i])
+		}
+		if err := authorizeTopics(principal, requested); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "Forbidden topics",
+				"error":   err.Error(),
+			})
+			return nil, nil, false
+		}
+		if err := validateTopics(config.GetContext(), requested); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Invalid topics",
+				"error":   err.Error(),
+			})
+			return nil, nil, false
+		}
+		topics = append(topics, requested...)
+	}
+	if len(topics) == 0 {
+		topics = append(topics, topicAuctions)
+	}
+	if len(topics) > maxClientTopics {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid topics",
+			"error":   fmt.Sprintf("a connection can subscribe to at most %d topics", maxClientTopics),
+		})
+		return nil, nil, false
+	}
+	return principal, topics, true
 }
 
-// HandleWebSocket handles websocket connections
+// HandleWebSocket handles websocket connections. Clients subscribe to
+// topics with the topics query parameter (comma separated) or subscribe
+// messages. property_id subscribes to that property; connections that name
+// no topics get every auction's status updates, as before topics existed.
+// last_seq lists the last sequence number the client saw of each topic, in
+// the same order, to replay the events it missed. User topics need the
+// user's token; anonymous connections are allowed unless WSRequireAuth.
+// The erea.msgpack and erea.protobuf subprotocols select binary encodings.
 func HandleWebSocket(c *gin.Context) {
-	propertyID := c.Query("property_id")
-	userID := c.Query("user_id")
+	principal, topics, ok := openStream(c)
+	if !ok {
+		return
+	}
 
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	var lastSeq map[string]int64
+	if list := c.Query("last_seq"); list != "" {
+		var err error
+		if lastSeq, err = parseLastSeq(list, topics); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Invalid last_seq",
+				"error":   err.Error(),
+			})
+			return
+		}
+	}
+
+	wireEncoding, protocol := selectEncoding(c.Request)
+	var header http.Header
+	if protocol != "" {
+		header = http.Header{"Sec-WebSocket-Protocol": {protocol}}
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, header)
 	if err != nil {
 		log.Printf("Failed to upgrade websocket: %v", err)
 		return
 	}
 
 	client := &Client{
-		conn:       conn,
-		send:       make(chan []byte, 256),
-		propertyID: propertyID,
-		userID:     userID,
+		id:        uuid.New().String(),
+		hub:       hub,
+		conn:      conn,
+		send:      make(chan outboundMessage, config.WSSendBuffer),
+		encoding:  wireEncoding,
+		resumes:   make(chan resumeRequest, maxPendingResumes),
+		principal: principal,
+		topics:    make(map[string]bool),
+	}
+	for _, topic := range topics {
+		client.topics[topic] = true
 	}
 
-	hub.register <- client
+	hub.register(client)
+	if lastSeq != nil {
+		client.resumes <- resumeRequest{lastSeq: lastSeq}
+	}
 
 	// Start goroutines for this client
 	go client.writePump()
 	go client.readPump()
 }
 
-// readPump pumps messages from the websocket connection to the hub
+// parseLastSeq pairs the comma separated sequence numbers of the last_seq
+// query parameter with the connection's topics
+func parseLastSeq(list string, topics []string) (map[string]int64, error) {
+	values := strings.Split(list, ",")
+	if len(values) != len(topics) {
+		return nil, fmt.Errorf("expected %d sequence numbers, one per topic, got %d", len(topics), len(values))
+	}
+
+	lastSeq := make(map[string]int64, len(topics))
+	for i, value := range values {
+		seq, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil || seq < 0 {
+			return nil, fmt.Errorf("invalid sequence number %q for %s", value, topics[i])
+		}
+		lastSeq[topics[i]] = seq
+	}
+	return lastSeq, nil
+}
+
+// readPump reads control messages from the websocket connection until it
+// closes. The connection is dropped when a message exceeds
+// WSMaxMessageSize or no pong arrives within WSPongWait.
 func (c *Client) readPump() {
 	defer func() {
-		hub.unregister <- c
+		c.hub.unregister(c)
 		c.conn.Close()
 	}()
 
+	c.conn.SetReadLimit(config.WSMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(config.WSPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(config.WSPongWait))
+	})
+
 	for {
-		_, _, err := c.conn.ReadMessage()
+		messageType, data, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("Websocket error: %v", err)
 			}
 			break
 		}
-		// Handle incoming messages if needed
+		c.handleControl(messageType, data)
 	}
 }
 
-// writePump pumps messages from the hub to the websocket connection
+// handleControl applies a subscribe or unsubscribe message and replies with
+// an ack or an error, or answers a time message with the server's time.
+// Resume messages are handed to writePump, which replies once it has
+// replayed the missed events.
+func (c *Client) handleControl(messageType int, data []byte) {
+	var control ControlMessage
+	if err := decodeControl(messageType, data, c.encoding, &control); err != nil {
+		message := "Invalid message: expected JSON"
+		if c.encoding == encodingMsgpack {
+			message += " or MessagePack"
+		}
+		c.reply(WebSocketMessage{Type: replyError, Message: message})
+		return
+	}
+
+	var subscriptions []string
+	var err error
+	switch control.Type {
+	case controlSubscribe:
+		err = authorizeTopics(c.principal, control.Topics)
+		if err == nil {
+			err = validateTopics(config.GetContext(), control.Topics)
+		}
+		if err == nil {
+			subscriptions, err = c.hub.subscribe(c, control.Topics)
+		}
+	case controlUnsubscribe:
+		if len(control.Topics) == 0 {
+			err = fmt.Errorf("topics is required")
+		}
+		for _, topic := range control.Topics {
+			if _, _, parseErr := parseTopic(topic); err == nil && parseErr != nil {
+				err = parseErr
+			}
+		}
+		if err == nil {
+			subscriptions = c.hub.unsubscribe(c, control.Topics)
+		}
+	case controlResume:
+		topics := make([]string, 0, len(control.LastSeq))
+		for topic, seq := range control.LastSeq {
+			if seq < 0 && err == nil {
+				err = fmt.Errorf("invalid sequence number %d for %s", seq, topic)
+			}
+			topics = append(topics, topic)
+		}
+		if len(topics) == 0 {
+			err = fmt.Errorf("last_seq is required")
+		}
+		if err == nil {
+			err = authorizeTopics(c.principal, topics)
+		}
+		if err == nil {
+			err = validateTopics(config.GetContext(), topics)
+		}
+		if err == nil {
+			select {
+			case c.resumes <- resumeRequest{id: control.ID, lastSeq: control.LastSeq}:
+				return
+			default:
+				err = fmt.Errorf("at most %d resumes can be pending", maxPendingResumes)
+			}
+		}
+	case controlTime:
+		c.reply(timeMessage(control.ID))
+		return
+	default:
+		err = fmt.Errorf("unknown message type %q, expected %s, %s, %s or %s", control.Type, controlSubscribe, controlUnsubscribe, controlResume, controlTime)
+	}
+
+	if err != nil {
+		c.reply(WebSocketMessage{Type: replyError, ID: control.ID, Message: err.Error()})
+		return
+	}
+	c.reply(WebSocketMessage{
+		Type: replyAck,
+		ID:   control.ID,
+		Data: SubscriptionAck{
+			Action:        control.Type,
+			Topics:        control.Topics,
+			Subscriptions: subscriptions,
+		},
+		Message: "Subscriptions updated",
+	})
+}
+
+// timeMessage returns a time message with the server's current time
+func timeMessage(id string) WebSocketMessage {
+	now := time.Now()
+	return WebSocketMessage{
+		Type:    controlTime,
+		ID:      id,
+		Data:    ServerTime{ServerTime: now.UTC(), UnixMillis: now.UnixMilli()},
+		Message: "Server time",
+	}
+}
+
+// reply sends a reply to a control message to this client
+func (c *Client) reply(message WebSocketMessage) {
+	messageJSON, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to marshal websocket reply: %v", err)
+		return
+	}
+	c.hub.sendTo(c, messageJSON)
+}
+
+// writePump sends the server's time, then pumps messages from the hub to
+// the websocket connection and pings the client every WSPingPeriod. Each
+// write must finish within WSWriteWait, so a stalled connection doesn't
+// hold the goroutine. Resume requests are replayed before queued messages,
+// and queued events already replayed are skipped.
 func (c *Client) writePump() {
-	defer c.conn.Close()
+	ticker := time.NewTicker(config.WSPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	// Newest sequence number replayed per topic, until a newer event is sent
+	replayed := make(map[string]int64)
+
+	// The server's time comes first, so clients can sync their countdowns
+	if err := c.writeJSON(timeMessage("")); err != nil {
+		return
+	}
 
 	for {
+		select {
+		case request := <-c.resumes:
+			if err := c.resume(request, replayed); err != nil {
+				log.Printf("Websocket write error: %v", err)
+				return
+			}
+			continue
+		default:
+		}
+
 		select {
 		case message, ok := <-c.send:
 			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				// The hub removed the client
+				c.conn.SetWriteDeadline(time.Now().Add(config.WSWriteWait))
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.closeText))
 				return
 			}
 
-			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			if seenEvent(message.seq, replayed) {
+				continue
+			}
+			for topic := range message.seq {
+				delete(replayed, topic)
+			}
+			if err := c.writeFrames(message.frames); err != nil {
+				log.Printf("Websocket write error: %v", err)
+				return
+			}
+
+		case request := <-c.resumes:
+			if err := c.resume(request, replayed); err != nil {
 				log.Printf("Websocket write error: %v", err)
 				return
 			}
+
+		case <-ticker.C:
+			if err := c.write(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// write writes one message to the connection within WSWriteWait. Only
+// writePump writes.
+func (c *Client) write(messageType int, data []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(config.WSWriteWait))
+	c.conn.EnableWriteCompression(compress(len(data)))
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// writeFrames writes a queued message in the client's encoding, which the
+// first client to need it encodes for every client sharing it
+func (c *Client) writeFrames(frames *preparedFrames) error {
+	frame, size, err := frames.frame(c.encoding)
+	if err != nil {
+		log.Printf("Failed to encode websocket message as %s: %v", c.encoding, err)
+		return nil
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(config.WSWriteWait))
+	c.conn.EnableWriteCompression(compress(size))
+	return c.conn.WritePreparedMessage(frame)
+}
+
+// writeJSON encodes a message in the client's encoding and writes it to the
+// connection
+func (c *Client) writeJSON(message WebSocketMessage) error {
+	messageJSON, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to marshal %s: %v", message.Type, err)
+		return nil
+	}
+	data, err := c.encoding.encode(messageJSON)
+	if err != nil {
+		log.Printf("Failed to encode %s as %s: %v", message.Type, c.encoding, err)
+		return nil
+	}
+	return c.write(c.encoding.messageType(), data)
+}
+
+// resume subscribes the client to the requested topics and writes their
+// events after the client's sequence numbers, a gap message for each topic
+// whose history no longer reaches back that far, then an ack. The newest
+// sequence numbers replayed are recorded in replayed. Only write errors
+// are returned; other failures are reported to the client.
+func (c *Client) resume(request resumeRequest, replayed map[string]int64) error {
+	topics := make([]string, 0, len(request.lastSeq))
+	for topic := range request.lastSeq {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	subscriptions, err := c.hub.subscribe(c, topics)
+	if err != nil {
+		return c.writeJSON(WebSocketMessage{Type: replyError, ID: request.id, Message: err.Error()})
+	}
+
+	positions, count, err := replayEvents(config.GetContext(), request.id, request.lastSeq, c.writeJSON)
+	for topic, seq := range positions {
+		replayed[topic] = seq
+	}
+	if errors.Is(err, errReplayUnavailable) {
+		return c.writeJSON(WebSocketMessage{Type: replyError, ID: request.id, Message: "Failed to load missed events"})
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.writeJSON(WebSocketMessage{
+		Type: replyAck,
+		ID:   request.id,
+		Data: SubscriptionAck{
+			Action:        controlResume,
+			Topics:        topics,
+			Subscriptions: subscriptions,
+			Replayed:      count,
+		},
+		Message: "Missed events replayed",
+	})
+}
+
+// broadcast encodes a message, numbers it in each topic's history and sends
+// it to the topics' subscribers on every instance
+func broadcast(message WebSocketMessage, topics ...string) {
+	ctx := config.GetContext()
+	messageJSON, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to marshal %s: %v", message.Type, err)
+		return
+	}
+
+	// Stored without sequence numbers; they're added back on replay
+	seq, err := appendEvent(ctx, messageJSON, topics)
+	if err != nil {
+		// Still deliver the event, it just can't be replayed
+		log.Printf("Failed to store %s for replay: %v", message.Type, err)
+	} else {
+		message.Seq = seq
+		if messageJSON, err = json.Marshal(message); err != nil {
+			log.Printf("Failed to marshal %s: %v", message.Type, err)
+			return
 		}
 	}
+	hub.fanOut(ctx, messageJSON, seq, topics...)
 }
 
-// BroadcastBidUpdate broadcasts a bid update to relevant clients
+// broadcastVolatile sends a message to the topics' subscribers on every
+// instance without numbering or storing it, for events like countdown ticks
+// that are stale by the time a client could resume
+func broadcastVolatile(message WebSocketMessage, topics ...string) {
+	messageJSON, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to marshal %s: %v", message.Type, err)
+		return
+	}
+	hub.fanOut(config.GetContext(), messageJSON, nil, topics...)
+}
+
+// BroadcastBidUpdate broadcasts a bid update to clients following the
+// property or its auction
 func BroadcastBidUpdate(propertyID string, bid models.Bid) {
 	redis := config.GetRedisClient()
 	ctx := config.GetContext()
 
 	// Get bid count for this property
-	propertyBidsKey := "property_bids:" + propertyID
-	bidCount, _ := redis.SCard(ctx, propertyBidsKey).Result()
-
-	// Get property to calculate time remaining
-	propertyJSON, err := redis.Get(ctx, "property:"+propertyID).Result()
-	timeRemaining := "Unknown"
-	if err == nil {
-		var property models.Property
-		if property.FromJSON(propertyJSON) == nil {
-			remaining := property.EndDate.Sub(property.CreatedAt)
-			timeRemaining = remaining.String()
-		}
-	}
+	bidCount, _ := redis.SCard(ctx, propertyBidsKey(propertyID)).Result()
 
 	update := BidUpdate{
 		PropertyID:    propertyID,
 		NewBid:        bid.Amount,
 		BidderID:      bid.BidderID,
 		BidCount:      int(bidCount),
-		TimeRemaining: timeRemaining,
+		TimeRemaining: "Unknown",
 	}
 
-	message := WebSocketMessage{
-		Type:    "bid_update",
-		Data:    update,
-		Message: "New bid placed",
+	// Time left until the auction ends, or the listing if it has no auction
+	var listingEnd time.Time
+	if properties, err := loadProperties(ctx, []string{propertyID}); err == nil && len(properties) > 0 {
+		listingEnd = properties[0].EndDate
+	}
+	auctionID, endsAt := auctionEnd(ctx, propertyID, listingEnd)
+	if !endsAt.IsZero() {
+		update.EndsAt = &endsAt
+		update.TimeRemaining = timeRemaining(endsAt, time.Now()).String()
 	}
 
-	// Convert to JSON
-	messageJSON, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Failed to marshal bid update: %v", err)
-		return
+	topics := []string{propertyTopic(propertyID)}
+	if auctionID != "" {
+		update.AuctionID = auctionID
+		topics = append(topics, auctionTopic(auctionID))
 	}
 
-	// Broadcast to property-specific clients
-	hub.broadcastToProperty(propertyID, messageJSON)
+	broadcast(WebSocketMessage{
+		Type:    "bid_update",
+		Data:    update,
+		Message: "New bid placed",
+	}, topics...)
 }
 
-// BroadcastAuctionUpdate broadcasts an auction status update
+// BroadcastAuctionUpdate broadcasts an auction status update to clients
+// following the auction, its property or every auction
 func BroadcastAuctionUpdate(auction models.Auction) {
 	update := AuctionUpdate{
+		AuctionID:  auction.ID,
 		PropertyID: auction.PropertyID,
 		Status:     auction.Status,
 		WinnerID:   auction.WinnerID,
 		WinningBid: auction.WinningBid,
 	}
 
-	message := WebSocketMessage{
+	broadcast(WebSocketMessage{
 		Type:    "auction_update",
 		Data:    update,
 		Message: "Auction status updated",
-	}
+	}, auctionTopic(auction.ID), propertyTopic(auction.PropertyID), topicAuctions)
+}
 
-	messageJSON, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Failed to marshal auction update: %v", err)
-		return
+// BroadcastPropertyUpdate broadcasts a new property version to clients
+// watching the property
+func BroadcastPropertyUpdate(property models.Property, version models.PropertyVersion) {
+	update := PropertyUpdate{
+		PropertyID: property.ID,
+		Version:    version.Version,
+		Changes:    version.Changes,
+		Property:   property,
 	}
 
-	// Broadcast to all clients
-	hub.broadcast <- messageJSON
-}
-
-// BroadcastPropertyUpdate broadcasts a property update
-func BroadcastPropertyUpdate(property models.Property) {
-	message := WebSocketMessage{
+	broadcast(WebSocketMessage{
 		Type:    "property_update",
-		Data:    property,
+		Data:    update,
 		Message: "Property updated",
-	}
+	}, propertyTopic(property.ID))
+}
 
-	messageJSON, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Failed to marshal property update: %v", err)
-		return
-	}
+// BroadcastNotification pushes a new notification to clients following the
+// user's topic
+func BroadcastNotification(notification models.Notification) {
+	broadcast(WebSocketMessage{
+		Type:    "notification",
+		Data:    notification,
+		Message: notification.Title,
+	}, userTopic(notification.UserID))
+}
+
+// BroadcastOutbid tells a bidder on their private topic that their leading
+// bid was beaten
+func BroadcastOutbid(userID string, alert OutbidAlert) {
+	broadcast(WebSocketMessage{
+		Type:    "outbid",
+		Data:    alert,
+		Message: "You have been outbid",
+	}, userTopic(userID))
+}
 
-	// Broadcast to property-specific clients
-	hub.broadcastToProperty(property.ID, messageJSON)
+// BroadcastDepositUpdate pushes a deposit's status to its user's private
+// topic
+func BroadcastDepositUpdate(deposit models.Deposit) {
+	broadcast(WebSocketMessage{
+		Type:    "deposit_update",
+		Data:    deposit,
+		Message: "Deposit " + strings.ToLower(deposit.Status),
+	}, userTopic(deposit.UserID))
 }
 
-// GetConnectedClients returns the number of connected clients
+// GetConnectedClients returns the number of clients connected to this
+// instance, or of the clients subscribed to a topic or property. For a
+// property it also returns how many people are watching it on every
+// instance.
 func GetConnectedClients(c *gin.Context) {
 	propertyID := c.Query("property_id")
-
-	hub.mutex.RLock()
-	defer hub.mutex.RUnlock()
+	topic := c.Query("topic")
+	if topic == "" && propertyID != "" {
+		topic = propertyTopic(propertyID)
+	}
 
 	var count int
-	if propertyID != "" {
-		if clients, exists := hub.propertyClients[propertyID]; exists {
-			count = len(clients)
-		}
+	if topic != "" {
+		count = hub.subscriberCount(topic)
 	} else {
+		hub.mutex.RLock()
 		count = len(hub.clients)
+		hub.mutex.RUnlock()
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success":          true,
+	response := gin.H{
+		"success":           true,
 		"connected_clients": count,
-		"property_id":      propertyID,
-	})
+		"property_id":       propertyID,
+		"topic":             topic,
+	}
+	if propertyID != "" {
+		watching, err := presenceCount(config.GetContext(), presencePropertyKey(propertyID))
+		if err == nil {
+			response["watching"] = watching
+		}
+	}
+	c.JSON(http.StatusOK, response)
 }
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erea-api/config"
+	"erea-api/middleware"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// startRelay runs a hub's relay until the test ends and waits until it is
+// subscribed
+func startRelay(t *testing.T, h *Hub) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.relay(ctx, config.GetRedisClient())
+	}()
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !h.relaying.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("relay didn't subscribe")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// receive returns the next message queued for a client and its sequence
+// numbers
+func receive(t *testing.T, client *Client) (WebSocketMessage, map[string]int64) {
+	t.Helper()
+	select {
+	case outbound := <-client.send:
+		var message WebSocketMessage
+		if err := json.Unmarshal(outbound.frames.json, &message); err != nil {
+			t.Fatalf("decoding message: %v", err)
+		}
+		return message, outbound.seq
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message delivered")
+	}
+	return WebSocketMessage{}, nil
+}
+
+// expectNoMore fails if a client gets another message shortly
+func expectNoMore(t *testing.T, client *Client) {
+	t.Helper()
+	select {
+	case outbound := <-client.send:
+		t.Fatalf("unexpected message %s", outbound.frames.json)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func fanOutTestEvent(t *testing.T, h *Hub, message string, topic string) {
+	t.Helper()
+	messageJSON, err := json.Marshal(WebSocketMessage{Type: "test", Message: message})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.fanOut(context.Background(), messageJSON, map[string]int64{topic: 1}, topic)
+}
+
+func TestFanOutReachesOtherHub(t *testing.T) {
+	newTestRedis(t)
+	a, b := newHub(), newHub()
+	startRelay(t, a)
+	startRelay(t, b)
+
+	topic := auctionTopic("a1")
+	onA := newTestClient(a, 4, topic)
+	onB := newTestClient(b, 4, topic)
+	a.register(onA)
+	b.register(onB)
+
+	fanOutTestEvent(t, a, "hello", topic)
+
+	for _, client := range []*Client{onA, onB} {
+		message, seq := receive(t, client)
+		if message.Message != "hello" || seq[topic] != 1 {
+			t.Fatalf("unexpected message %+v with seq %v", message, seq)
+		}
+		expectNoMore(t, client)
+	}
+}
+
+func TestFanOutDeliversOnceWhenRelayingStarts(t *testing.T) {
+	newTestRedis(t)
+	a, b := newHub(), newHub()
+	startRelay(t, a)
+	startRelay(t, b)
+
+	topic := auctionTopic("a1")
+	onA := newTestClient(a, 4, topic)
+	onB := newTestClient(b, 4, topic)
+	a.register(onA)
+	b.register(onB)
+
+	// The hub has subscribed but hasn't seen the confirmation yet, so it
+	// delivers the event itself and then receives it from Redis too
+	a.relaying.Store(false)
+	fanOutTestEvent(t, a, "once", topic)
+
+	for _, client := range []*Client{onA, onB} {
+		if message, _ := receive(t, client); message.Message != "once" {
+			t.Fatalf("unexpected message %+v", message)
+		}
+		expectNoMore(t, client)
+	}
+}
+
+func TestFanOutWithoutRelayDeliversLocally(t *testing.T) {
+	newTestRedis(t)
+	h := newHub()
+
+	topic := auctionTopic("a1")
+	client := newTestClient(h, 4, topic)
+	h.register(client)
+
+	fanOutTestEvent(t, h, "local", topic)
+	if message, _ := receive(t, client); message.Message != "local" {
+		t.Fatalf("unexpected message %+v", message)
+	}
+	expectNoMore(t, client)
+}
+
+func TestHubConcurrentClients(t *testing.T) {
+	newTestRedis(t)
+	h := newHub()
+
+	topics := []string{
+		topicAuctions,
+		auctionTopic("a1"),
+		auctionTopic("a2"),
+		propertyTopic("p1"),
+		propertyTopic("p2"),
+	}
+
+	const clientCount = 40
+	clients := make([]*Client, clientCount)
+	var readers sync.WaitGroup
+	for i := range clients {
+		// Every fourth client never reads, so publishing evicts it
+		slow := i%4 == 0
+		buffer := 64
+		if slow {
+			buffer = 1
+		}
+		clients[i] = newTestClient(h, buffer, topics[i%len(topics)])
+		if i%3 == 0 {
+			clients[i].principal = &middleware.Principal{ID: "u", Type: "user", UserID: fmt.Sprintf("user-%d", i%5)}
+		}
+		if slow {
+			continue
+		}
+		readers.Add(1)
+		go func(client *Client) {
+			defer readers.Done()
+			for range client.send {
+			}
+		}(clients[i])
+	}
+
+	stop := make(chan struct{})
+	var publishers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		publishers.Add(1)
+		go func(i int) {
+			defer publishers.Done()
+			for n := 0; ; n++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				topic := topics[(i+n)%len(topics)]
+				h.publish([]byte(`{"type":"test"}`), map[string]int64{topic: int64(n)}, topic)
+				if n%10 == 0 {
+					h.sendTo(clients[n%clientCount], []byte(`{"type":"direct"}`))
+				}
+			}
+		}(i)
+	}
+
+	var workers sync.WaitGroup
+	for i, client := range clients {
+		workers.Add(1)
+		go func(i int, client *Client) {
+			defer workers.Done()
+			h.register(client)
+			for n := 0; n < 20; n++ {
+				topic := topics[(i+n)%len(topics)]
+				if _, err := h.subscribe(client, []string{topic}); err != nil {
+					t.Errorf("subscribe: %v", err)
+				}
+				h.subscriberCount(topic)
+				h.unsubscribe(client, []string{topics[(i+n+2)%len(topics)]})
+			}
+			h.unregister(client)
+		}(i, client)
+	}
+
+	workers.Wait()
+	close(stop)
+	publishers.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		readers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a client's send channel was never closed")
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if len(h.clients) != 0 || len(h.topics) != 0 || len(h.users) != 0 || len(h.viewers) != 0 {
+		t.Fatalf("hub not empty after every client left: clients=%d topics=%v users=%v viewers=%v",
+			len(h.clients), h.topics, h.users, h.viewers)
+	}
+	for _, client := range clients {
+		if !client.closed {
+			t.Fatal("client left open")
+		}
+	}
+}
+
+func TestPublishEvictsSlowClient(t *testing.T) {
+	newTestRedis(t)
+	h := newHub()
+
+	topic := auctionTopic("a1")
+	slow := newTestClient(h, 1, topic)
+	fast := newTestClient(h, 4, topic)
+	h.register(slow)
+	h.register(fast)
+
+	h.publish([]byte(`{"type":"first"}`), nil, topic)
+	h.publish([]byte(`{"type":"second"}`), nil, topic)
+
+	if h.subscriberCount(topic) != 1 {
+		t.Fatalf("expected only the fast client left, got %d subscribers", h.subscriberCount(topic))
+	}
+	if slow.closeCode != websocket.CloseTryAgainLater {
+		t.Fatalf("expected the slow client closed with %d, got %d", websocket.CloseTryAgainLater, slow.closeCode)
+	}
+	if len(fast.send) != 2 {
+		t.Fatalf("expected both messages queued for the fast client, got %d", len(fast.send))
+	}
+
+	// Sending to an evicted client is dropped rather than panicking
+	h.publish([]byte(`{"type":"third"}`), nil, topic)
+	h.sendTo(slow, []byte(`{"type":"direct"}`))
+	h.unregister(slow)
+}
+
+func TestCheckOriginDefaultsToSameOrigin(t *testing.T) {
+	previous := config.WSAllowedOrigins
+	t.Cleanup(func() { config.WSAllowedOrigins = previous })
+
+	request := func(origin string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://api.erea.io/ws", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		return r
+	}
+
+	config.WSAllowedOrigins = nil
+	if !checkOrigin(request("https://api.erea.io")) {
+		t.Fatal("same-origin page was refused")
+	}
+	if checkOrigin(request("https://evil.example")) {
+		t.Fatal("cross-origin page was allowed without an allowlist")
+	}
+	if !checkOrigin(request("")) {
+		t.Fatal("non-browser client was refused")
+	}
+
+	config.WSAllowedOrigins = []string{"https://erea.io/"}
+	if !checkOrigin(request("https://erea.io")) || checkOrigin(request("https://api.erea.io")) {
+		t.Fatal("allowlist wasn't followed")
+	}
+
+	config.WSAllowedOrigins = []string{"*"}
+	if !checkOrigin(request("https://evil.example")) {
+		t.Fatal("* didn't allow every origin")
+	}
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erea-api/config"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Event history for resuming clients. Every event gets the next sequence
+// number of each topic it's published to, from a counter per topic that
+// never expires, and is kept in a capped stream per topic whose entry IDs
+// are "<seq>-0", so the events after a sequence number are one XRANGE. The
+// stream expires when the topic is quiet; the counter doesn't, so sequence
+// numbers never restart below a position clients already hold.
+const (
+	topicStreamPrefix = "ws_stream:" // ws_stream:{topic}
+	topicSeqPrefix    = "ws_seq:"    // ws_seq:{topic}
+	eventMessageField = "message"
+	eventSeqField     = "seq:" // seq:{topic}, one per topic of the event
+)
+
+// appendEventScript numbers an event in each of its topics and appends it
+// to the topics' streams, atomically so sequence numbers never go backwards
+// and events land in order. KEYS: the topics' streams, then their counters;
+// ARGV: message, max stream length, TTL in seconds, then the topics.
+// Returns the sequence numbers in topic order.
+var appendEventScript = redis.NewScript(`
+local n = #KEYS / 2
+local seqs = {}
+for i = 1, n do
+	local seq = redis.call('INCR', KEYS[n + i])
+	-- Counters start behind streams written before they were kept
+	local last = redis.call('XREVRANGE', KEYS[i], '+', '-', 'COUNT', 1)
+	if last[1] then
+		local head = tonumber(string.match(last[1][1], '^%d+'))
+		if seq <= head then
+			seq = head + 1
+			redis.call('SET', KEYS[n + i], seq)
+		end
+	end
+	seqs[i] = seq
+end
+
+local entry = {'message', ARGV[1]}
+for i = 1, n do
+	table.insert(entry, 'seq:' .. ARGV[3 + i])
+	table.insert(entry, seqs[i])
+end
+
+for i = 1, n do
+	redis.call('XADD', KEYS[i], 'MAXLEN', '~', ARGV[2], seqs[i] .. '-0', unpack(entry))
+	redis.call('EXPIRE', KEYS[i], ARGV[3])
+end
+
+return seqs
+`)
+
+// errReplayUnavailable is returned by replayEvents when a topic's history
+// can't be loaded
+var errReplayUnavailable = errors.New("failed to load missed events")
+
+func topicStreamKey(topic string) string {
+	return topicStreamPrefix + topic
+}
+
+func topicSeqKey(topic string) string {
+	return topicSeqPrefix + topic
+}
+
+// storedEvent is an event read back from a topic's stream
+type storedEvent struct {
+	message []byte
+	seq     map[string]int64
+}
+
+// GapNotice is the data of a gap message, sent on resume when events after
+// the client's last sequence number are no longer kept, or when that number
+// is ahead of the topic
+type GapNotice struct {
+	Topic     string `json:"topic"`
+	LastSeq   int64  `json:"last_seq"`   // The client's last sequence number
+	FirstSeq  int64  `json:"first_seq"`  // Oldest event kept, 0 if none
+	LatestSeq int64  `json:"latest_seq"` // Newest event, 0 if none
+}
+
+// appendEvent stores an encoded message in the streams of its topics and
+// returns its sequence number in each
+func appendEvent(ctx context.Context, message []byte, topics []string) (map[string]int64, error) {
+	keys := make([]string, 2*len(topics))
+	args := []interface{}{message, config.WSReplaySize, int64(config.WSReplayTTL.Seconds())}
+	for i, topic := range topics {
+		keys[i] = topicStreamKey(topic)
+		keys[len(topics)+i] = topicSeqKey(topic)
+		args = append(args, topic)
+	}
+
+	result, err := appendEventScript.Run(ctx, config.GetRedisClient(), keys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(result) != len(topics) {
+		return nil, fmt.Errorf("expected %d sequence numbers, got %d", len(topics), len(result))
+	}
+
+	seq := make(map[string]int64, len(topics))
+	for i, topic := range topics {
+		seq[topic] = result[i]
+	}
+	return seq, nil
+}
+
+// loadTopicEvents returns a topic's events after a sequence number, oldest
+// first, and a gap notice if some of the events after it are gone
+func loadTopicEvents(ctx context.Context, topic string, after int64) ([]storedEvent, *GapNotice, error) {
+	key := topicStreamKey(topic)
+	pipe := config.GetRedisClient().Pipeline()
+	oldestCmd := pipe.XRangeN(ctx, key, "-", "+", 1)
+	newestCmd := pipe.XRevRangeN(ctx, key, "+", "-", 1)
+	counterCmd := pipe.Get(ctx, topicSeqKey(topic))
+	entriesCmd := pipe.XRange(ctx, key, fmt.Sprintf("%d-0", after+1), "+")
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, nil, err
+	}
+
+	var first, latest int64
+	if oldest := oldestCmd.Val(); len(oldest) > 0 {
+		first = entrySeq(oldest[0].ID)
+	}
+	if newest := newestCmd.Val(); len(newest) > 0 {
+		latest = entrySeq(newest[0].ID)
+	}
+	if counter, err := counterCmd.Int64(); err == nil && counter > latest {
+		latest = counter
+	}
+
+	var gap *GapNotice
+	// The history expired or was trimmed past the client's position, or the
+	// client is ahead of the topic (e.g. its counter was lost)
+	if after > latest || (latest > after && (first == 0 || first > after+1)) {
+		gap = &GapNotice{Topic: topic, LastSeq: after, FirstSeq: first, LatestSeq: latest}
+	}
+
+	var events []storedEvent
+	for _, entry := range entriesCmd.Val() {
+		event := storedEvent{seq: make(map[string]int64)}
+		for field, value := range entry.Values {
+			text, _ := value.(string)
+			if field == eventMessageField {
+				event.message = []byte(text)
+			} else if strings.HasPrefix(field, eventSeqField) {
+				n, _ := strconv.ParseInt(text, 10, 64)
+				event.seq[strings.TrimPrefix(field, eventSeqField)] = n
+			}
+		}
+		if event.message == nil {
+			log.Printf("Skipping websocket event %s of %s without a message", entry.ID, topic)
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, gap, nil
+}
+
+// latestSeq returns the sequence number of a topic's newest event, 0 if it
+// has none
+func latestSeq(ctx context.Context, topic string) (int64, error) {
+	rdb := config.GetRedisClient()
+	counter, err := rdb.Get(ctx, topicSeqKey(topic)).Int64()
+	if err != nil && err != redis.Nil {
+		return 0, err
+	}
+	newest, err := rdb.XRevRangeN(ctx, topicStreamKey(topic), "+", "-", 1).Result()
+	if err != nil {
+		return 0, err
+	}
+	if len(newest) > 0 && entrySeq(newest[0].ID) > counter {
+		return entrySeq(newest[0].ID), nil
+	}
+	return counter, nil
+}
+
+// replayEvents writes the events of each topic after its sequence number in
+// lastSeq, oldest first and each once, after a gap message for each topic
+// whose history no longer reaches back that far. id is echoed in gap
+// messages. Returns the newest sequence number written per topic and how
+// many events were written, also when writing fails part way.
+func replayEvents(ctx context.Context, id string, lastSeq map[string]int64, write func(WebSocketMessage) error) (map[string]int64, int, error) {
+	topics := make([]string, 0, len(lastSeq))
+	positions := make(map[string]int64, len(lastSeq))
+	for topic, seq := range lastSeq {
+		topics = append(topics, topic)
+		positions[topic] = seq
+	}
+	sort.Strings(topics)
+
+	count := 0
+	for _, topic := range topics {
+		events, gap, err := loadTopicEvents(ctx, topic, lastSeq[topic])
+		if err != nil {
+			log.Printf("Failed to load websocket events of %s: %v", topic, err)
+			return positions, count, errReplayUnavailable
+		}
+
+		if gap != nil {
+			err := write(WebSocketMessage{
+				Type:    replyGap,
+				ID:      id,
+				Data:    gap,
+				Message: "Some events are no longer available, reload the current state",
+			})
+			if err != nil {
+				return positions, count, err
+			}
+
+			// Restart from the topic's head, so live events aren't taken
+			// for ones the client already has
+			if gap.LastSeq > gap.LatestSeq {
+				positions[topic] = gap.LatestSeq
+			}
+		}
+
+		for _, event := range events {
+			// Events of several topics are replayed once
+			if seenEvent(event.seq, positions) {
+				continue
+			}
+
+			var message WebSocketMessage
+			var data json.RawMessage
+			message.Data = &data
+			if err := json.Unmarshal(event.message, &message); err != nil {
+				log.Printf("Failed to decode websocket event of %s: %v", topic, err)
+				continue
+			}
+			message.Seq = event.seq
+			if err := write(message); err != nil {
+				return positions, count, err
+			}
+			count++
+
+			for eventTopic, seq := range event.seq {
+				if position, ok := positions[eventTopic]; ok && seq > position {
+					positions[eventTopic] = seq
+				}
+			}
+		}
+	}
+	return positions, count, nil
+}
+
+// entrySeq returns the sequence number of a stream entry ID
+func entrySeq(id string) int64 {
+	seq, _, _ := strings.Cut(id, "-")
+	n, _ := strconv.ParseInt(seq, 10, 64)
+	return n
+}
+
+// seenEvent reports whether an event is at or before a known position in
+// any of its topics, i.e. it was replayed or the client already had it
+func seenEvent(seq, positions map[string]int64) bool {
+	for topic, n := range seq {
+		if position, ok := positions[topic]; ok && n <= position {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"erea-api/config"
+	"testing"
+)
+
+func appendTestEvent(t *testing.T, topics ...string) map[string]int64 {
+	t.Helper()
+	seq, err := appendEvent(context.Background(), []byte(`{"type":"test"}`), topics)
+	if err != nil {
+		t.Fatalf("appendEvent: %v", err)
+	}
+	return seq
+}
+
+// replayTest replays a topic from lastSeq and returns the messages written
+// and the position the client continues from
+func replayTest(t *testing.T, topic string, lastSeq int64) ([]WebSocketMessage, int64) {
+	t.Helper()
+	var written []WebSocketMessage
+	positions, _, err := replayEvents(context.Background(), "1", map[string]int64{topic: lastSeq}, func(message WebSocketMessage) error {
+		written = append(written, message)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("replayEvents: %v", err)
+	}
+	return written, positions[topic]
+}
+
+func TestReplayResumesAfterLastSeq(t *testing.T) {
+	newTestRedis(t)
+	topic := auctionTopic("a1")
+	for i := 0; i < 3; i++ {
+		appendTestEvent(t, topic)
+	}
+
+	written, position := replayTest(t, topic, 1)
+	if len(written) != 2 || written[0].Seq[topic] != 2 || written[1].Seq[topic] != 3 {
+		t.Fatalf("expected events 2 and 3 replayed, got %+v", written)
+	}
+	if position != 3 {
+		t.Fatalf("expected to continue from 3, got %d", position)
+	}
+}
+
+func TestReplaySeqSurvivesStreamExpiry(t *testing.T) {
+	mr := newTestRedis(t)
+	topic := auctionTopic("a1")
+	for i := 0; i < 5; i++ {
+		appendTestEvent(t, topic)
+	}
+
+	// The topic goes quiet until its stream expires
+	mr.FastForward(config.WSReplayTTL + 1)
+	if mr.Exists(topicStreamKey(topic)) {
+		t.Fatal("expected the stream to expire")
+	}
+
+	if seq := appendTestEvent(t, topic); seq[topic] != 6 {
+		t.Fatalf("expected numbering to continue at 6 after expiry, got %d", seq[topic])
+	}
+
+	// A client that had event 5 gets event 6 and no gap
+	written, position := replayTest(t, topic, 5)
+	if len(written) != 1 || written[0].Type == replyGap || written[0].Seq[topic] != 6 {
+		t.Fatalf("expected event 6 replayed without a gap, got %+v", written)
+	}
+	if position != 6 {
+		t.Fatalf("expected to continue from 6, got %d", position)
+	}
+
+	// A client that had only event 3 is told events are gone
+	if written, _ := replayTest(t, topic, 3); len(written) == 0 || written[0].Type != replyGap {
+		t.Fatalf("expected a gap message first, got %+v", written)
+	}
+}
+
+func TestReplayResetsPositionAheadOfTopic(t *testing.T) {
+	newTestRedis(t)
+	topic := auctionTopic("a1")
+	appendTestEvent(t, topic)
+
+	written, position := replayTest(t, topic, 40)
+	if len(written) != 1 || written[0].Type != replyGap {
+		t.Fatalf("expected only a gap message, got %+v", written)
+	}
+	if position != 1 {
+		t.Fatalf("expected the position reset to the topic's head 1, got %d", position)
+	}
+
+	// Live events after the reset aren't taken for already seen ones
+	seq := appendTestEvent(t, topic)
+	if seenEvent(seq, map[string]int64{topic: position}) {
+		t.Fatalf("event %v dropped as already seen", seq)
+	}
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"encoding/json"
 	"erea-api/config"
+	"erea-api/handlers"
 	"erea-api/routes"
+	"erea-api/storage"
 	"log"
 	"time"
 )
@@ -63,10 +65,40 @@ func main() {
 	log.Println("Redis 연결을 초기화하는 중...")
 	config.InitRedis()
 
+	// 파일 저장소 초기화
+	log.Println("파일 저장소를 초기화하는 중...")
+	if err := storage.Init(); err != nil {
+		log.Fatalf("파일 저장소 초기화 실패: %v", err)
+	}
+
 	// 더미 데이터 삽입
 	// log.Println("더미 데이터를 삽입하는 중...")
 	// insertDummyData()
 
+	// 다른 서버 인스턴스의 WebSocket 이벤트 수신 시작
+	log.Println("WebSocket 이벤트 중계를 시작하는 중...")
+	go handlers.RunWebSocketRelay(config.GetContext())
+
+	// 접속자 현황(온라인 사용자, 매물별 시청자) 갱신 시작
+	log.Println("접속자 현황 갱신을 시작하는 중...")
+	go handlers.RunPresence(config.GetContext())
+
+	// 경매 마감 임박 알림 시작
+	log.Println("경매 마감 알림을 시작하는 중...")
+	go handlers.RunClosingNotifier(config.GetContext())
+
+	// 경매 카운트다운(tick, closing_soon) 이벤트 시작
+	log.Println("경매 카운트다운을 시작하는 중...")
+	go handlers.RunAuctionCountdown(config.GetContext())
+
+	// 낙찰 잔금 정산 알림 시작
+	log.Println("낙찰 정산 알림을 시작하는 중...")
+	go handlers.RunSettlementReminders(config.GetContext())
+
+	// 임장 예약 알림 시작
+	log.Println("임장 예약 알림을 시작하는 중...")
+	go handlers.RunInspectionReminders(config.GetContext())
+
 	// 라우터 설정
 	log.Println("라우터를 설정하는 중...")
 	router := routes.SetupRoutes()
@@ -0,0 +1,289 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"erea-api/config"
+	"erea-api/models"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyPrefix is prepended to every issued API key so keys are easy to
+// recognise in logs and secret scanners
+const APIKeyPrefix = "erea_"
+
+// principalContextKey is the gin context key holding the authenticated Principal
+const principalContextKey = "principal"
+
+// AccessTokenParam is the query parameter carrying a token where clients
+// can't set headers, such as browser websockets and EventSource
+const AccessTokenParam = "access_token"
+
+// accessTokenContextKey is the gin context key holding the token
+// StripAccessToken took out of the URL
+const accessTokenContextKey = "access_token"
+
+// lastUsedResolution limits how often last_used_at is written back to Redis
+const lastUsedResolution = time.Minute
+
+// Principal types
+const (
+	PrincipalAdmin  = "admin"
+	PrincipalAPIKey = "api_key"
+)
+
+var (
+	errInvalidAPIKey = errors.New("invalid API key")
+	errInactiveKey   = errors.New("API key is revoked or expired")
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	ID     string   `json:"id"`
+	Type   string   `json:"type"`
+	UserID string   `json:"user_id,omitempty"`
+	Scopes []string `json:"scopes"`
+}
+
+// HasScope reports whether the principal was granted the scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == models.ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate resolves the caller from an `Authorization: Bearer <token>`
+// or `X-API-Key` header. Requests without credentials pass through
+// anonymously; requests with invalid credentials are rejected.
+func Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := extractToken(c.Request)
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		principal, err := ResolvePrincipal(token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "Authentication failed",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.Set(principalContextKey, principal)
+		c.Next()
+	}
+}
+
+// StripAccessToken moves the access_token query parameter out of the
+// request URL into the context, so request logs never show the token. It
+// must run before gin.Logger, which reads the URL before the handlers run.
+func StripAccessToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token, ok := c.GetQuery(AccessTokenParam); ok {
+			c.Set(accessTokenContextKey, token)
+
+			// Drop the parameter, leaving the rest of the query as sent
+			var kept []string
+			for _, part := range strings.Split(c.Request.URL.RawQuery, "&") {
+				name, _, _ := strings.Cut(part, "=")
+				if name, err := url.QueryUnescape(name); err != nil || name != AccessTokenParam {
+					kept = append(kept, part)
+				}
+			}
+			c.Request.URL.RawQuery = strings.Join(kept, "&")
+			c.Request.RequestURI = c.Request.URL.RequestURI()
+		}
+		c.Next()
+	}
+}
+
+// AccessToken returns the access_token query parameter of the request,
+// including one StripAccessToken took out of the URL
+func AccessToken(c *gin.Context) string {
+	if token, ok := c.Get(accessTokenContextKey); ok {
+		return token.(string)
+	}
+	return c.Query(AccessTokenParam)
+}
+
+// RequireScope rejects requests whose principal lacks all of the given scopes
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "Authentication required",
+			})
+			return
+		}
+
+		for _, scope := range scopes {
+			if principal.HasScope(scope) {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "Insufficient scope",
+			"error":   "requires one of: " + strings.Join(scopes, ", "),
+		})
+	}
+}
+
+// RequireUser rejects requests whose principal neither acts for the user
+// named by the path parameter param nor has the admin scope
+func RequireUser(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "Authentication required",
+			})
+			return
+		}
+
+		if principal.HasScope(models.ScopeAdmin) || (principal.UserID != "" && principal.UserID == c.Param(param)) {
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "Access denied",
+			"error":   "requires a key acting for the user or the " + models.ScopeAdmin + " scope",
+		})
+	}
+}
+
+// GetPrincipal returns the authenticated principal of the request, if any
+func GetPrincipal(c *gin.Context) (*Principal, bool) {
+	value, exists := c.Get(principalContextKey)
+	if !exists {
+		return nil, false
+	}
+	principal, ok := value.(*Principal)
+	return principal, ok
+}
+
+// ResolvePrincipal authenticates a raw token, which is either the admin
+// bootstrap token or an issued API key
+func ResolvePrincipal(token string) (*Principal, error) {
+	if adminToken := config.GetAdminToken(); adminToken != "" &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+		return &Principal{
+			ID:     PrincipalAdmin,
+			Type:   PrincipalAdmin,
+			Scopes: []string{models.ScopeAdmin},
+		}, nil
+	}
+
+	if !strings.HasPrefix(token, APIKeyPrefix) {
+		return nil, errInvalidAPIKey
+	}
+
+	key, err := lookupAPIKey(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Principal{
+		ID:     key.ID,
+		Type:   PrincipalAPIKey,
+		UserID: key.UserID,
+		Scopes: key.Scopes,
+	}, nil
+}
+
+// HashAPIKey returns the hex-encoded SHA-256 hash under which a key is stored
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// lookupAPIKey loads an active API key by its plaintext value and records its use
+func lookupAPIKey(token string) (*models.APIKey, error) {
+	redis := config.GetRedisClient()
+	ctx := config.GetContext()
+
+	keyID, err := redis.Get(ctx, "api_key_hash:"+HashAPIKey(token)).Result()
+	if err != nil {
+		return nil, errInvalidAPIKey
+	}
+
+	keyJSON, err := redis.Get(ctx, "api_key:"+keyID).Result()
+	if err != nil {
+		return nil, errInvalidAPIKey
+	}
+
+	var key models.APIKey
+	if err := key.FromJSON(keyJSON); err != nil {
+		return nil, errInvalidAPIKey
+	}
+
+	now := time.Now()
+	if !key.IsActive(now) {
+		return nil, errInactiveKey
+	}
+
+	// Usage is kept apart from the record, so recording it can't undo a
+	// concurrent revocation
+	LoadAPIKeyLastUsed(&key)
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastUsedResolution {
+		key.LastUsedAt = &now
+		if err := redis.Set(ctx, APIKeyLastUsedKey(key.ID), now.Format(time.RFC3339Nano), 0).Err(); err != nil {
+			log.Printf("Failed to record API key usage: %v", err)
+		}
+	}
+
+	return &key, nil
+}
+
+// APIKeyLastUsedKey holds the time an API key was last used
+func APIKeyLastUsedKey(keyID string) string {
+	return "api_key_last_used:" + keyID
+}
+
+// LoadAPIKeyLastUsed sets the key's LastUsedAt from its usage record, when
+// that is newer than the time stored in the key's own record
+func LoadAPIKeyLastUsed(key *models.APIKey) {
+	value, err := config.GetRedisClient().Get(config.GetContext(), APIKeyLastUsedKey(key.ID)).Result()
+	if err != nil {
+		return
+	}
+	lastUsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return
+	}
+	if key.LastUsedAt == nil || lastUsed.After(*key.LastUsedAt) {
+		key.LastUsedAt = &lastUsed
+	}
+}
+
+// extractToken reads the credential from the Authorization or X-API-Key header
+func extractToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
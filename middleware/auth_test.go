@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"bytes"
+	"erea-api/config"
+	"erea-api/models"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// issueTestKey stores an API key with the given scopes and returns its
+// plaintext
+func issueTestKey(t *testing.T, id string, scopes ...string) string {
+	t.Helper()
+	return issueUserTestKey(t, id, "", scopes...)
+}
+
+// issueUserTestKey is issueTestKey for a key acting for userID
+func issueUserTestKey(t *testing.T, id, userID string, scopes ...string) string {
+	t.Helper()
+	plaintext := APIKeyPrefix + id + "_secret"
+	key := models.APIKey{
+		ID:        id,
+		Prefix:    APIKeyPrefix + id,
+		Hash:      HashAPIKey(plaintext),
+		UserID:    userID,
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	keyJSON, err := key.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdb, ctx := config.GetRedisClient(), config.GetContext()
+	if err := rdb.Set(ctx, "api_key:"+id, keyJSON, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rdb.Set(ctx, "api_key_hash:"+key.Hash, id, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	return plaintext
+}
+
+func TestRequireScope(t *testing.T) {
+	newTestRedis(t)
+	bidder := issueTestKey(t, "bidder", models.ScopeBidsWrite)
+	reader := issueTestKey(t, "reader", models.ScopeDocumentsRead)
+	admin := issueTestKey(t, "admin", models.ScopeAdmin)
+
+	router := newTestRouter()
+	router.POST("/bids", RequireScope(models.ScopeBidsWrite), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"unknown key", map[string]string{"X-API-Key": APIKeyPrefix + "nope_secret"}, http.StatusUnauthorized},
+		{"missing scope", map[string]string{"X-API-Key": reader}, http.StatusForbidden},
+		{"granted scope", map[string]string{"Authorization": "Bearer " + bidder}, http.StatusCreated},
+		{"admin", map[string]string{"X-API-Key": admin}, http.StatusCreated},
+	}
+	for _, tc := range cases {
+		if w := perform(router, http.MethodPost, "/bids", tc.headers); w.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.want, w.Code)
+		}
+	}
+}
+
+func TestRequireUser(t *testing.T) {
+	newTestRedis(t)
+	alice := issueUserTestKey(t, "alice", "alice")
+	bob := issueUserTestKey(t, "bob", "bob")
+	service := issueTestKey(t, "service", models.ScopeBidsWrite)
+	admin := issueTestKey(t, "admin", models.ScopeAdmin)
+
+	router := newTestRouter()
+	router.GET("/users/:id/watchlist", RequireUser("id"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"another user", map[string]string{"X-API-Key": bob}, http.StatusForbidden},
+		{"a key acting for no user", map[string]string{"X-API-Key": service}, http.StatusForbidden},
+		{"the user", map[string]string{"X-API-Key": alice}, http.StatusOK},
+		{"admin", map[string]string{"X-API-Key": admin}, http.StatusOK},
+	}
+	for _, tc := range cases {
+		if w := perform(router, http.MethodGet, "/users/alice/watchlist", tc.headers); w.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.want, w.Code)
+		}
+	}
+}
+
+func TestStripAccessTokenKeepsTokensOutOfLogs(t *testing.T) {
+	var logs bytes.Buffer
+	router := gin.New()
+	router.Use(StripAccessToken(), gin.LoggerWithWriter(&logs))
+	router.GET("/ws", func(c *gin.Context) {
+		c.String(http.StatusOK, AccessToken(c)+" "+c.Query("topics"))
+	})
+
+	w := perform(router, http.MethodGet, "/ws?topics=property:p1&access_token="+APIKeyPrefix+"secret", nil)
+	if got, want := w.Body.String(), APIKeyPrefix+"secret property:p1"; got != want {
+		t.Fatalf("handler saw %q, want %q", got, want)
+	}
+	if strings.Contains(logs.String(), "secret") || !strings.Contains(logs.String(), "/ws?topics=property:p1") {
+		t.Fatalf("unexpected request log %q", logs.String())
+	}
+}
+
+func TestRecordingUsageKeepsRevocation(t *testing.T) {
+	mr := newTestRedis(t)
+	plaintext := issueTestKey(t, "k1", models.ScopeBidsWrite)
+
+	key, err := lookupAPIKey(plaintext)
+	if err != nil {
+		t.Fatalf("lookupAPIKey: %v", err)
+	}
+	if key.LastUsedAt == nil || !mr.Exists(APIKeyLastUsedKey("k1")) {
+		t.Fatal("expected the key's use to be recorded")
+	}
+
+	// Revoke the stored record as RevokeAPIKey does, then use the key
+	// again; the usage write must not bring the record back
+	recordJSON, _ := mr.Get("api_key:k1")
+	var revoked models.APIKey
+	if err := revoked.FromJSON(recordJSON); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	revoked.RevokedAt = &now
+	revokedJSON, _ := revoked.ToJSON()
+	mr.Set("api_key:k1", revokedJSON)
+	mr.FastForward(2 * lastUsedResolution)
+
+	if _, err := lookupAPIKey(plaintext); err != errInactiveKey {
+		t.Fatalf("expected the revoked key to be rejected, got %v", err)
+	}
+	if stored, _ := mr.Get("api_key:k1"); stored != revokedJSON {
+		t.Fatalf("the key record was rewritten: %s", stored)
+	}
+
+	var loaded models.APIKey
+	loaded.ID = "k1"
+	LoadAPIKeyLastUsed(&loaded)
+	if loaded.LastUsedAt == nil {
+		t.Fatal("expected the last use to be loaded")
+	}
+}
@@ -0,0 +1,209 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"erea-api/config"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader is the request header carrying the client's idempotency key
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyMaxKeyLength bounds client-supplied keys
+const idempotencyMaxKeyLength = 255
+
+// idempotencyLockTTL bounds how long a crashed request can hold a key
+const idempotencyLockTTL = 2 * time.Minute
+
+// Idempotency record states
+const (
+	idempotencyProcessing = "processing"
+	idempotencyCompleted  = "completed"
+)
+
+// idempotencyRecord is the stored state of one idempotency key
+type idempotencyRecord struct {
+	State       string    `json:"state"`
+	Fingerprint string    `json:"fingerprint"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Body        []byte    `json:"body,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// responseRecorder tees the response body so it can be stored for replay
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency makes a handler safe to retry. The first response for an
+// Idempotency-Key is stored in Redis and replayed for repeats within
+// config.IdempotencyTTL. Reusing a key with a different request body is
+// rejected with 422, and a repeat arriving while the first request is still
+// running gets 409. Requests without the header are not affected.
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+		if idempotencyKey == "" {
+			c.Next()
+			return
+		}
+
+		if len(idempotencyKey) > idempotencyMaxKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Invalid Idempotency-Key",
+				"error":   fmt.Sprintf("key must be at most %d characters", idempotencyMaxKeyLength),
+			})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Failed to read request body",
+				"error":   err.Error(),
+			})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		redis := config.GetRedisClient()
+		ctx := config.GetContext()
+
+		key := idempotencyRedisKey(c, idempotencyKey)
+		fingerprint := requestFingerprint(c.Request.Method, c.FullPath(), body)
+
+		lock, _ := json.Marshal(idempotencyRecord{
+			State:       idempotencyProcessing,
+			Fingerprint: fingerprint,
+			CreatedAt:   time.Now(),
+		})
+
+		acquired, err := redis.SetNX(ctx, key, lock, idempotencyLockTTL).Result()
+		if err != nil {
+			// Fail open: process the request without idempotency protection
+			log.Printf("Idempotency store unavailable for %s: %v", key, err)
+			c.Next()
+			return
+		}
+
+		if !acquired {
+			replayIdempotentResponse(c, key, fingerprint)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			// Server errors are not final; let the client retry with the same key
+			redis.Del(ctx, key)
+			return
+		}
+
+		record, err := json.Marshal(idempotencyRecord{
+			State:       idempotencyCompleted,
+			Fingerprint: fingerprint,
+			StatusCode:  status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+			CreatedAt:   time.Now(),
+		})
+		if err == nil {
+			err = redis.Set(ctx, key, record, config.IdempotencyTTL).Err()
+		}
+		if err != nil {
+			log.Printf("Failed to store idempotent response for %s: %v", key, err)
+			redis.Del(ctx, key)
+		}
+	}
+}
+
+// replayIdempotentResponse answers a repeated key from the stored record
+func replayIdempotentResponse(c *gin.Context, key, fingerprint string) {
+	recordJSON, err := config.GetRedisClient().Get(config.GetContext(), key).Result()
+	if err != nil {
+		// The first request finished with a server error between SETNX and GET
+		c.Header("Retry-After", "1")
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "A request with this Idempotency-Key was just processed; retry",
+		})
+		return
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal([]byte(recordJSON), &record); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to parse stored idempotent response",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if record.Fingerprint != fingerprint {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"success": false,
+			"message": "Idempotency-Key was already used with a different request",
+		})
+		return
+	}
+
+	if record.State != idempotencyCompleted {
+		c.Header("Retry-After", "1")
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "A request with this Idempotency-Key is still being processed",
+		})
+		return
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(record.StatusCode, record.ContentType, record.Body)
+	c.Abort()
+}
+
+// idempotencyRedisKey scopes a client key to the route and caller so
+// authenticated clients cannot collide or read each other's responses.
+// Anonymous callers share one namespace because a retrying mobile client
+// may come back from a different IP.
+func idempotencyRedisKey(c *gin.Context, idempotencyKey string) string {
+	caller := "anonymous"
+	if principal, ok := GetPrincipal(c); ok {
+		caller = principal.Type + ":" + principal.ID
+	}
+	return fmt.Sprintf("idempotency:%s %s:%s:%s", c.Request.Method, c.FullPath(), caller, idempotencyKey)
+}
+
+// requestFingerprint identifies the request content bound to a key
+func requestFingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
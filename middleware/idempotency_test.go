@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newIdempotentRouter returns a router whose POST /bids goes through
+// Idempotency, answering with status and counting the calls that reach it
+func newIdempotentRouter(status *int, calls *int) *gin.Engine {
+	router := newTestRouter()
+	router.POST("/bids", Idempotency(), func(c *gin.Context) {
+		*calls++
+		c.JSON(*status, gin.H{"call": *calls})
+	})
+	return router
+}
+
+func postIdempotent(router http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/bids", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IdempotencyKeyHeader, key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysFirstResponse(t *testing.T) {
+	newTestRedis(t)
+	status, calls := http.StatusCreated, 0
+	router := newIdempotentRouter(&status, &calls)
+
+	first := postIdempotent(router, "k1", `{"amount":100}`)
+	second := postIdempotent(router, "k1", `{"amount":100}`)
+	if calls != 1 {
+		t.Fatalf("expected the handler to run once, ran %d times", calls)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Fatalf("replay differs: %d %s vs %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatal("replay wasn't marked")
+	}
+
+	// Another key is another request
+	if w := postIdempotent(router, "k2", `{"amount":100}`); w.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("expected a new key to run the handler, got %d after %d calls", w.Code, calls)
+	}
+}
+
+func TestIdempotencyRejectsReusedKeyWithOtherBody(t *testing.T) {
+	newTestRedis(t)
+	status, calls := http.StatusCreated, 0
+	router := newIdempotentRouter(&status, &calls)
+
+	postIdempotent(router, "k1", `{"amount":100}`)
+	if w := postIdempotent(router, "k1", `{"amount":200}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a reused key, got %d", w.Code)
+	}
+	if calls != 1 {
+		t.Fatalf("a mismatched request reached the handler")
+	}
+}
+
+func TestIdempotencyRetriesServerErrorsAndBlocksInFlight(t *testing.T) {
+	mr := newTestRedis(t)
+	status, calls := http.StatusInternalServerError, 0
+	router := newIdempotentRouter(&status, &calls)
+
+	postIdempotent(router, "k1", `{}`)
+	status = http.StatusCreated
+	if w := postIdempotent(router, "k1", `{}`); w.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("expected a retry after a server error to run, got %d after %d calls", w.Code, calls)
+	}
+
+	// A repeat while the first request is still running is told to retry
+	for _, key := range mr.Keys() {
+		if strings.HasSuffix(key, ":k1") {
+			mr.Set(key, `{"state":"processing","fingerprint":"`+requestFingerprint(http.MethodPost, "/bids", []byte(`{}`))+`"}`)
+		}
+	}
+	w := postIdempotent(router, "k1", `{}`)
+	if w.Code != http.StatusConflict || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 409 with Retry-After for an in-flight key, got %d", w.Code)
+	}
+}
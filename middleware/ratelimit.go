@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"erea-api/config"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// tokenBucketScript atomically refills and takes one token from a bucket.
+// KEYS[1] bucket hash; ARGV: capacity, refill rate (tokens/ms), now (ms).
+// Returns {allowed, remaining tokens, retry after ms, ms until full}.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or capacity
+local ts = tonumber(bucket[2]) or now
+if now > ts then
+	tokens = math.min(capacity, tokens + (now - ts) * rate)
+end
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate) + 1000)
+
+return {allowed, math.floor(tokens), retry, math.ceil((capacity - tokens) / rate)}
+`)
+
+// RateLimit limits requests per identity with a Redis-backed token bucket
+// so the limit holds across API instances. Authenticated callers are
+// limited per principal, anonymous callers per client IP. The client IP is
+// taken from X-Forwarded-For only when the engine trusts the connecting
+// proxy (config.TrustedProxies); otherwise any client could pick a new
+// identity per request by changing the header.
+func RateLimit(policy config.RateLimitPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := policy.Anonymous
+		identity := "ip:" + c.ClientIP()
+		if principal, ok := GetPrincipal(c); ok {
+			limit = policy.Authenticated
+			identity = principal.Type + ":" + principal.ID
+		}
+
+		key := fmt.Sprintf("rate_limit:%s:%s", policy.Name, identity)
+		ratePerMs := limit.Rate() / 1000
+		now := time.Now().UnixMilli()
+
+		result, err := tokenBucketScript.Run(config.GetContext(), config.GetRedisClient(),
+			[]string{key}, limit.Burst, ratePerMs, now).Int64Slice()
+		if err != nil || len(result) != 4 {
+			// Fail open: an unavailable limiter must not take the API down
+			log.Printf("Rate limiter unavailable for %s: %v", key, err)
+			c.Next()
+			return
+		}
+
+		allowed, remaining, retryAfterMs, resetMs := result[0], result[1], result[2], result[3]
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(msToSeconds(resetMs), 10))
+
+		if allowed == 0 {
+			retryAfter := msToSeconds(retryAfterMs)
+			c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"success": false,
+				"message": "Too many requests",
+				"error":   fmt.Sprintf("rate limit of %s exceeded, retry after %d seconds", limit, retryAfter),
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// msToSeconds rounds milliseconds up to whole seconds
+func msToSeconds(ms int64) int64 {
+	return int64(math.Ceil(float64(ms) / 1000))
+}
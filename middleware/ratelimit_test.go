@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"erea-api/config"
+	"erea-api/models"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newRateLimitedRouter serves GET /limited with the given limits, trusting
+// the given proxies
+func newRateLimitedRouter(t *testing.T, anonymous, authenticated int, proxies []string) *gin.Engine {
+	t.Helper()
+	router := newTestRouter()
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		t.Fatal(err)
+	}
+	policy := config.RateLimitPolicy{
+		Name:          "test",
+		Anonymous:     config.RateLimit{Requests: anonymous, Period: time.Hour, Burst: anonymous},
+		Authenticated: config.RateLimit{Requests: authenticated, Period: time.Hour, Burst: authenticated},
+	}
+	router.GET("/limited", RateLimit(policy), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func TestRateLimitRejectsOverBurst(t *testing.T) {
+	newTestRedis(t)
+	router := newRateLimitedRouter(t, 2, 5, nil)
+
+	for i := 0; i < 2; i++ {
+		if w := perform(router, http.MethodGet, "/limited", nil); w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, w.Code)
+		}
+	}
+	w := perform(router, http.MethodGet, "/limited", nil)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+
+	// An API key has its own, larger bucket
+	key := issueTestKey(t, "k1", models.ScopeBidsWrite)
+	for i := 0; i < 5; i++ {
+		if w := perform(router, http.MethodGet, "/limited", map[string]string{"X-API-Key": key}); w.Code != http.StatusOK {
+			t.Fatalf("authenticated request %d: expected 200, got %d", i, w.Code)
+		}
+	}
+	if w := perform(router, http.MethodGet, "/limited", map[string]string{"X-API-Key": key}); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the key's bucket to run out, got %d", w.Code)
+	}
+}
+
+func TestRateLimitIgnoresUntrustedForwardedFor(t *testing.T) {
+	newTestRedis(t)
+	router := newRateLimitedRouter(t, 1, 1, nil)
+
+	// Changing X-Forwarded-For doesn't make a new client
+	for i := 0; i < 3; i++ {
+		headers := map[string]string{"X-Forwarded-For": fmt.Sprintf("203.0.113.%d", i)}
+		w := perform(router, http.MethodGet, "/limited", headers)
+		if want := http.StatusTooManyRequests; i > 0 && w.Code != want {
+			t.Fatalf("request %d with a spoofed address: expected %d, got %d", i, want, w.Code)
+		}
+	}
+
+	// Behind a trusted proxy, the forwarded address is the client
+	trusted := newRateLimitedRouter(t, 1, 1, []string{"192.0.2.0/24"})
+	for i := 0; i < 2; i++ {
+		headers := map[string]string{"X-Forwarded-For": fmt.Sprintf("203.0.113.%d", i)}
+		if w := perform(trusted, http.MethodGet, "/limited", headers); w.Code != http.StatusOK {
+			t.Fatalf("forwarded client %d: expected 200, got %d", i, w.Code)
+		}
+	}
+}
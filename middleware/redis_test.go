@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"erea-api/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// newTestRedis points config.RedisClient at a fresh in-memory Redis for
+// the duration of a test
+func newTestRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	previous := config.RedisClient
+	config.RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		config.RedisClient.Close()
+		config.RedisClient = previous
+	})
+	return mr
+}
+
+// newTestRouter returns a gin engine in test mode that authenticates
+// requests
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Authenticate())
+	return r
+}
+
+// perform serves a request with the given headers on router
+func perform(router http.Handler, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
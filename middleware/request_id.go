@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the request ID in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDContextKey is the gin context key holding the request ID
+const requestIDContextKey = "request_id"
+
+// validRequestID limits client-supplied request IDs to safe, short values
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID assigns every request an ID, reusing a well-formed incoming
+// X-Request-ID so calls can be correlated across services
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		c.Set(requestIDContextKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// GetRequestID returns the ID assigned to the request by RequestID
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDContextKey)
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// API key scopes
+const (
+	ScopeAdmin           = "admin"            // Full access, including key management
+	ScopeBidsWrite       = "bids:write"       // Place bids and update bid status
+	ScopeDepositsWrite   = "deposits:write"   // Create deposits
+	ScopeDepositsConfirm = "deposits:confirm" // Confirm or fail deposits (ZK service callback)
+	ScopeEERCWrite       = "eerc:write"       // Mint and transfer EERC tokens
+	ScopeDocumentsRead   = "documents:read"   // Download restricted property documents
+	ScopeDocumentsWrite  = "documents:write"  // Upload and delete any property's photos and documents
+)
+
+// APIKeyScopes lists every scope that can be granted to an API key
+var APIKeyScopes = []string{
+	ScopeAdmin,
+	ScopeBidsWrite,
+	ScopeDepositsWrite,
+	ScopeDepositsConfirm,
+	ScopeEERCWrite,
+	ScopeDocumentsRead,
+	ScopeDocumentsWrite,
+}
+
+// APIKey represents an API key issued to a machine client.
+// Only the SHA-256 hash of the secret is stored; Prefix is kept for display.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	UserID     string     `json:"user_id,omitempty"` // User the key acts on behalf of
+	CreatedBy  string     `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// apiKeyRecord is the stored form of APIKey, which keeps the hash
+type apiKeyRecord struct {
+	APIKey
+	Hash string `json:"hash"`
+}
+
+// ToJSON converts APIKey struct to JSON string including the secret hash
+func (k *APIKey) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(apiKeyRecord{APIKey: *k, Hash: k.Hash})
+	if err != nil {
+		return "", err
+	}
+	return string(jsonData), nil
+}
+
+// FromJSON converts JSON string to APIKey struct
+func (k *APIKey) FromJSON(jsonStr string) error {
+	var record apiKeyRecord
+	if err := json.Unmarshal([]byte(jsonStr), &record); err != nil {
+		return err
+	}
+	*k = record.APIKey
+	k.Hash = record.Hash
+	return nil
+}
+
+// IsActive reports whether the key is neither revoked nor expired
+func (k *APIKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	if k.ExpiresAt != nil && now.After(*k.ExpiresAt) {
+		return false
+	}
+	return true
+}
+
+// CreateAPIKeyRequest represents a request to issue an API key
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	UserID    string     `json:"user_id,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreatedAPIKey is returned once at issuance and is the only time the
+// plaintext key is visible
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// APIKeyResponse represents API response for API key operations
+type APIKeyResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
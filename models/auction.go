@@ -5,21 +5,30 @@ import (
 	"time"
 )
 
+// Notification types for auction settlement
+const (
+	NotificationSettlementDue      = "settlement.due"
+	NotificationSettlementReminder = "settlement.reminder"
+)
+
 // Auction represents an auction session
 type Auction struct {
-	ID             string    `json:"id"`
-	PropertyID     string    `json:"property_id" binding:"required"`
-	Status         string    `json:"status"` // Active, Closed, Cancelled
-	StartTime      time.Time `json:"start_time"`
-	EndTime        time.Time `json:"end_time" binding:"required"`
-	MinIncrement   int64     `json:"min_increment"`   // Minimum bid increment
-	ReservePrice   int64     `json:"reserve_price"`   // Reserve price
-	CurrentHighest int64     `json:"current_highest"` // Current highest bid
-	BidCount       int       `json:"bid_count"`
-	WinnerID       string    `json:"winner_id,omitempty"`
-	WinningBid     int64     `json:"winning_bid,omitempty"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID             string     `json:"id"`
+	PropertyID     string     `json:"property_id" binding:"required"`
+	Status         string     `json:"status"` // Active, Closed, Cancelled
+	StartTime      time.Time  `json:"start_time"`
+	EndTime        time.Time  `json:"end_time" binding:"required"`
+	MinIncrement   int64      `json:"min_increment"`   // Minimum bid increment
+	ReservePrice   int64      `json:"reserve_price"`   // Reserve price
+	CurrentHighest int64      `json:"current_highest"` // Current highest bid
+	BidCount       int        `json:"bid_count"`
+	LeadingBidID   string     `json:"leading_bid_id,omitempty"` // Bid currently holding CurrentHighest
+	LeaderID       string     `json:"leader_id,omitempty"`      // Bidder of the leading bid
+	WinnerID       string     `json:"winner_id,omitempty"`
+	WinningBid     int64      `json:"winning_bid,omitempty"`
+	SettlementDue  *time.Time `json:"settlement_due,omitempty"` // Winner must settle the balance by then
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
 // ToJSON converts Auction struct to JSON string
@@ -56,8 +65,9 @@ type AuctionStats struct {
 
 // AuctionResponse represents API response for auction operations
 type AuctionResponse struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success    bool        `json:"success"`
+	Message    string      `json:"message"`
+	Data       interface{} `json:"data,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
 }
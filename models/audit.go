@@ -0,0 +1,74 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// AuditGenesisHash is the previous hash of the first audit entry
+const AuditGenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// AuditEntry represents one append-only audit log record. Each entry embeds
+// the hash of its predecessor, so altering or removing any entry breaks the chain.
+type AuditEntry struct {
+	Seq        int64           `json:"seq"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Actor      string          `json:"actor"`
+	ActorType  string          `json:"actor_type"` // admin, api_key, anonymous, system
+	Action     string          `json:"action"`     // e.g. bid.create, deposit.update_status
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	ClientIP   string          `json:"client_ip,omitempty"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// ToJSON converts AuditEntry struct to JSON string
+func (a *AuditEntry) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(a)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonData), nil
+}
+
+// FromJSON converts JSON string to AuditEntry struct
+func (a *AuditEntry) FromJSON(jsonStr string) error {
+	return json.Unmarshal([]byte(jsonStr), a)
+}
+
+// ComputeHash returns the SHA-256 hash of the entry with its Hash field
+// cleared. PrevHash is part of the hashed content, which links the chain.
+func (a *AuditEntry) ComputeHash() (string, error) {
+	unsigned := *a
+	unsigned.Hash = ""
+	jsonData, err := json.Marshal(unsigned)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(jsonData)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditVerification represents the result of verifying the audit chain
+type AuditVerification struct {
+	Valid       bool      `json:"valid"`
+	EntryCount  int64     `json:"entry_count"`
+	HeadHash    string    `json:"head_hash"`
+	BrokenAtSeq int64     `json:"broken_at_seq,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	VerifiedAt  time.Time `json:"verified_at"`
+}
+
+// AuditResponse represents API response for audit log operations
+type AuditResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
@@ -5,6 +5,11 @@ import (
 	"time"
 )
 
+// Notification types for bids
+const (
+	NotificationBidOutbid = "bid.outbid"
+)
+
 // Bid represents a bid in the auction system
 type Bid struct {
 	ID           string    `json:"id"`
@@ -44,10 +49,11 @@ type CreateBidRequest struct {
 
 // BidResponse represents API response for bid operations
 type BidResponse struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success    bool        `json:"success"`
+	Message    string      `json:"message"`
+	Data       interface{} `json:"data,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
 }
 
 // BidHistory represents bid history for a property
@@ -50,9 +50,10 @@ type DepositResponse struct {
 
 // DepositListResponse represents response for multiple deposits
 type DepositListResponse struct {
-	Success  bool      `json:"success"`
-	Message  string    `json:"message"`
-	Data     []Deposit `json:"data,omitempty"`
-	Total    int       `json:"total"`
-	Error    string    `json:"error,omitempty"`
+	Success    bool        `json:"success"`
+	Message    string      `json:"message"`
+	Data       []Deposit   `json:"data,omitempty"`
+	Total      int         `json:"total"`
+	Error      string      `json:"error,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
 }
@@ -0,0 +1,26 @@
+package models
+
+// ImportRowError lists the problems found in one spreadsheet row
+type ImportRowError struct {
+	Row    int      `json:"row"` // Spreadsheet row number; the header is row 1
+	Errors []string `json:"errors"`
+}
+
+// ImportedProperty is a listing created from, or in a dry run previewed
+// from, one spreadsheet row
+type ImportedProperty struct {
+	Row      int      `json:"row"`
+	Property Property `json:"property"`
+	Auction  *Auction `json:"auction,omitempty"`
+}
+
+// PropertyImportResult reports the outcome of a bulk property import
+type PropertyImportResult struct {
+	DryRun            bool               `json:"dry_run"`
+	Rows              int                `json:"rows"`
+	ValidRows         int                `json:"valid_rows"`
+	PropertiesCreated int                `json:"properties_created"`
+	AuctionsCreated   int                `json:"auctions_created"`
+	Errors            []ImportRowError   `json:"errors"`
+	Properties        []ImportedProperty `json:"properties"`
+}
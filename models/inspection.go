@@ -0,0 +1,116 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Inspection slot and booking statuses
+const (
+	InspectionSlotOpen      = "Open"
+	InspectionSlotCancelled = "Cancelled"
+
+	InspectionBookingBooked    = "Booked"
+	InspectionBookingCancelled = "Cancelled"
+)
+
+// Notification types for inspections
+const (
+	NotificationInspectionBooked    = "inspection.booked"
+	NotificationInspectionCancelled = "inspection.cancelled"
+	NotificationInspectionReminder  = "inspection.reminder"
+)
+
+// InspectionSlot is a time the seller opens a property for site inspection
+// (임장). Up to Capacity users can book it.
+type InspectionSlot struct {
+	ID         string    `json:"id"`
+	PropertyID string    `json:"property_id"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	Capacity   int       `json:"capacity"`
+	Booked     int       `json:"booked"`
+	Note       string    `json:"note,omitempty"` // e.g. where to meet
+	Status     string    `json:"status"`         // Open, Cancelled
+	CreatedBy  string    `json:"created_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ToJSON converts InspectionSlot struct to JSON string
+func (s *InspectionSlot) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonData), nil
+}
+
+// FromJSON converts JSON string to InspectionSlot struct
+func (s *InspectionSlot) FromJSON(jsonStr string) error {
+	return json.Unmarshal([]byte(jsonStr), s)
+}
+
+// Overlaps reports whether the slot's time range overlaps [start, end)
+func (s *InspectionSlot) Overlaps(start, end time.Time) bool {
+	return s.StartTime.Before(end) && start.Before(s.EndTime)
+}
+
+// InspectionBooking is a user's place in an inspection slot. The slot's
+// times are copied so a user's bookings can be checked for conflicts
+// without loading every slot.
+type InspectionBooking struct {
+	ID          string     `json:"id"`
+	SlotID      string     `json:"slot_id"`
+	PropertyID  string     `json:"property_id"`
+	UserID      string     `json:"user_id"`
+	StartTime   time.Time  `json:"start_time"`
+	EndTime     time.Time  `json:"end_time"`
+	Status      string     `json:"status"` // Booked, Cancelled
+	CreatedAt   time.Time  `json:"created_at"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+	User        *User      `json:"user,omitempty"` // Set in the owner's view
+}
+
+// ToJSON converts InspectionBooking struct to JSON string
+func (b *InspectionBooking) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(b)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonData), nil
+}
+
+// FromJSON converts JSON string to InspectionBooking struct
+func (b *InspectionBooking) FromJSON(jsonStr string) error {
+	return json.Unmarshal([]byte(jsonStr), b)
+}
+
+// CreateInspectionSlotRequest represents a request to publish an inspection slot
+type CreateInspectionSlotRequest struct {
+	StartTime time.Time `json:"start_time" binding:"required"`
+	EndTime   time.Time `json:"end_time" binding:"required"`
+	Capacity  int       `json:"capacity" binding:"required,min=1,max=50"`
+	Note      string    `json:"note" binding:"max=500"`
+}
+
+// BookInspectionRequest represents a request to book an inspection slot
+type BookInspectionRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
+// InspectionSlotBookings is a slot with its active bookings, for the
+// property owner
+type InspectionSlotBookings struct {
+	Slot     InspectionSlot      `json:"slot"`
+	Bookings []InspectionBooking `json:"bookings"`
+}
+
+// InspectionResponse represents API response for inspection operations
+type InspectionResponse struct {
+	Success    bool        `json:"success"`
+	Message    string      `json:"message"`
+	Data       interface{} `json:"data,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Media kinds
+const (
+	MediaKindPhoto    = "photo"
+	MediaKindDocument = "document"
+)
+
+// Legal document types
+const (
+	DocumentTypeRegistryCertificate = "registry_certificate" // 등기부등본
+	DocumentTypeAppraisalReport     = "appraisal_report"     // 감정평가서
+	DocumentTypeOther               = "other"
+)
+
+// DocumentTypes lists the accepted document types
+var DocumentTypes = []string{
+	DocumentTypeRegistryCertificate,
+	DocumentTypeAppraisalReport,
+	DocumentTypeOther,
+}
+
+// PropertyMedia is an uploaded photo or document of a property. The file
+// itself lives in blob storage under BlobKey.
+type PropertyMedia struct {
+	ID           string    `json:"id"`
+	PropertyID   string    `json:"property_id"`
+	Kind         string    `json:"kind"`                    // photo, document
+	DocumentType string    `json:"document_type,omitempty"` // registry_certificate, appraisal_report, other
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width,omitempty"` // Photos only
+	Height       int       `json:"height,omitempty"`
+	Restricted   bool      `json:"restricted"` // Download requires documents:read or the owner's key
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	BlobKey      string    `json:"blob_key"`
+	ThumbnailKey string    `json:"thumbnail_key,omitempty"`
+	UploadedBy   string    `json:"uploaded_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ToJSON converts PropertyMedia struct to JSON string
+func (m *PropertyMedia) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonData), nil
+}
+
+// FromJSON converts JSON string to PropertyMedia struct
+func (m *PropertyMedia) FromJSON(jsonStr string) error {
+	return json.Unmarshal([]byte(jsonStr), m)
+}
+
+// MediaResponse represents API response for media operations
+type MediaResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
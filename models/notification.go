@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Notification types
+const (
+	NotificationListingApproved         = "listing.approved"
+	NotificationListingRejected         = "listing.rejected"
+	NotificationListingChangesRequested = "listing.changes_requested"
+)
+
+// Notification is a message to a user about something that happened to
+// their listings, bids or watched properties
+type Notification struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Type       string     `json:"type"`
+	Title      string     `json:"title"`
+	Message    string     `json:"message"`
+	EntityType string     `json:"entity_type,omitempty"`
+	EntityID   string     `json:"entity_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReadAt     *time.Time `json:"read_at,omitempty"`
+}
+
+// ToJSON converts Notification struct to JSON string
+func (n *Notification) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(n)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonData), nil
+}
+
+// FromJSON converts JSON string to Notification struct
+func (n *Notification) FromJSON(jsonStr string) error {
+	return json.Unmarshal([]byte(jsonStr), n)
+}
+
+// NotificationList is one page of a user's notifications
+type NotificationList struct {
+	UnreadCount   int64          `json:"unread_count"`
+	Notifications []Notification `json:"notifications"`
+}
+
+// NotificationResponse represents API response for notification operations
+type NotificationResponse struct {
+	Success    bool        `json:"success"`
+	Message    string      `json:"message"`
+	Data       interface{} `json:"data,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// Pagination describes one page of a list response. Pass NextCursor back as
+// the cursor query parameter (with the same sort) to fetch the next page.
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	Sort       string `json:"sort"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
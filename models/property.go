@@ -5,23 +5,62 @@ import (
 	"time"
 )
 
+// Property statuses. New listings start as Draft and go through review
+// (PendingReview, then Approved, ChangesRequested or Rejected); an approved
+// listing becomes Active when its auction is created.
+const (
+	PropertyStatusDraft            = "Draft"
+	PropertyStatusPendingReview    = "PendingReview"
+	PropertyStatusChangesRequested = "ChangesRequested"
+	PropertyStatusRejected         = "Rejected"
+	PropertyStatusApproved         = "Approved"
+	PropertyStatusActive           = "Active"
+	PropertyStatusPending          = "Pending"
+	PropertyStatusClosed           = "Closed"
+)
+
+// IsReviewStatus reports whether status belongs to the listing review
+// workflow, which only the review endpoints may set
+func IsReviewStatus(status string) bool {
+	switch status {
+	case PropertyStatusDraft, PropertyStatusPendingReview, PropertyStatusChangesRequested,
+		PropertyStatusRejected, PropertyStatusApproved:
+		return true
+	}
+	return false
+}
+
+// IsPublicStatus reports whether listings in status are shown to everyone:
+// approved listings and those that went to auction. Listings still in
+// review, or rejected, are only shown when asked for by status.
+func IsPublicStatus(status string) bool {
+	return status == PropertyStatusApproved || !IsReviewStatus(status)
+}
+
 // Property represents a real estate property in the auction system
 type Property struct {
-	ID            string    `json:"id"`
-	Title         string    `json:"title" binding:"required"`
-	Location      string    `json:"location" binding:"required"`
-	Description   string    `json:"description"`
-	Type          string    `json:"type" binding:"required"` // Apartment, Officetel, Commercial, Villa
-	Area          float64   `json:"area" binding:"required,min=0"`
-	StartingPrice int64     `json:"starting_price" binding:"required,min=0"`
-	CurrentPrice  int64     `json:"current_price"`
-	ImageURL      string    `json:"image_url"`
-	Features      []string  `json:"features"`
-	Status        string    `json:"status"` // Active, Closed, Pending
-	EndDate       time.Time `json:"end_date" binding:"required"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
-	OwnerID       string    `json:"owner_id"`
+	ID            string                 `json:"id"`
+	Title         string                 `json:"title" binding:"required"`
+	Location      string                 `json:"location" binding:"required"`
+	Description   string                 `json:"description"`
+	Type          string                 `json:"type" binding:"required"` // Apartment, Officetel, Commercial, Villa, Condominium
+	Area          float64                `json:"area" binding:"required,min=0"`
+	StartingPrice int64                  `json:"starting_price" binding:"required,min=0"`
+	CurrentPrice  int64                  `json:"current_price"`
+	ImageURL      string                 `json:"image_url"`
+	Features      []string               `json:"features"`
+	Status        string                 `json:"status"` // Draft, PendingReview, ChangesRequested, Rejected, Approved, Active, Closed, Pending
+	EndDate       time.Time              `json:"end_date" binding:"required"`
+	CreatedAt     time.Time              `json:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+	OwnerID       string                 `json:"owner_id"`
+	Latitude      *float64               `json:"latitude,omitempty"` // Set on create or geocoded from Location
+	Longitude     *float64               `json:"longitude,omitempty"`
+	SubmittedAt   *time.Time             `json:"submitted_at,omitempty"` // Last submission for review
+	ApprovedAt    *time.Time             `json:"approved_at,omitempty"`
+	ReviewComment string                 `json:"review_comment,omitempty"` // Comment of the latest review decision
+	Version       int                    `json:"version"`                  // Incremented by every update, see PropertyVersion
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`     // Typed attributes, see PropertyTypeSchemas
 }
 
 // ToJSON converts Property struct to JSON string
@@ -40,31 +79,39 @@ func (p *Property) FromJSON(jsonStr string) error {
 
 // CreatePropertyRequest represents a request to create a new property
 type CreatePropertyRequest struct {
-	Title         string    `json:"title" binding:"required"`
-	Location      string    `json:"location" binding:"required"`
-	Description   string    `json:"description"`
-	Type          string    `json:"type" binding:"required"`
-	Area          float64   `json:"area" binding:"required,min=0"`
-	StartingPrice int64     `json:"starting_price" binding:"required,min=0"`
-	ImageURL      string    `json:"image_url"`
-	Features      []string  `json:"features"`
-	EndDate       time.Time `json:"end_date" binding:"required"`
-	OwnerID       string    `json:"owner_id" binding:"required"`
+	Title         string                 `json:"title" binding:"required"`
+	Location      string                 `json:"location" binding:"required"`
+	Description   string                 `json:"description"`
+	Type          string                 `json:"type" binding:"required,oneof=Apartment Officetel Commercial Villa Condominium"`
+	Area          float64                `json:"area" binding:"required,min=0"`
+	StartingPrice int64                  `json:"starting_price" binding:"required,min=0"`
+	ImageURL      string                 `json:"image_url"`
+	Features      []string               `json:"features"`
+	EndDate       time.Time              `json:"end_date" binding:"required"`
+	OwnerID       string                 `json:"owner_id" binding:"required"`
+	Latitude      *float64               `json:"latitude" binding:"omitempty,gte=-90,lte=90"`
+	Longitude     *float64               `json:"longitude" binding:"omitempty,gte=-180,lte=180"`
+	Submit        bool                   `json:"submit"` // Submit for review right away instead of saving a draft
+	Attributes    map[string]interface{} `json:"attributes"`
 }
 
 // UpdatePropertyRequest represents a request to update property
 type UpdatePropertyRequest struct {
-	Title       string   `json:"title,omitempty"`
-	Description string   `json:"description,omitempty"`
-	ImageURL    string   `json:"image_url,omitempty"`
-	Features    []string `json:"features,omitempty"`
-	Status      string   `json:"status,omitempty"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	ImageURL    string                 `json:"image_url,omitempty"`
+	Features    []string               `json:"features,omitempty"`
+	Status      string                 `json:"status,omitempty"`
+	Latitude    *float64               `json:"latitude,omitempty" binding:"omitempty,gte=-90,lte=90"`
+	Longitude   *float64               `json:"longitude,omitempty" binding:"omitempty,gte=-180,lte=180"`
+	Attributes  map[string]interface{} `json:"attributes,omitempty"` // Merged into the current attributes; null removes one
 }
 
 // PropertyResponse represents API response for property operations
 type PropertyResponse struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success    bool        `json:"success"`
+	Message    string      `json:"message"`
+	Data       interface{} `json:"data,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
 }
@@ -0,0 +1,344 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Property types
+const (
+	PropertyTypeApartment   = "Apartment"
+	PropertyTypeOfficetel   = "Officetel"
+	PropertyTypeCommercial  = "Commercial"
+	PropertyTypeVilla       = "Villa"
+	PropertyTypeCondominium = "Condominium"
+)
+
+// Attribute kinds. Enum attributes take one of the spec's values.
+const (
+	AttributeKindInteger = "integer"
+	AttributeKindNumber  = "number"
+	AttributeKindBoolean = "boolean"
+	AttributeKindEnum    = "enum"
+)
+
+// AttributeSpec describes one typed attribute of a property type
+type AttributeSpec struct {
+	Name        string   `json:"name"`
+	Kind        string   `json:"kind"`
+	Required    bool     `json:"required"`
+	Min         *float64 `json:"min,omitempty"`
+	Max         *float64 `json:"max,omitempty"`
+	Values      []string `json:"values,omitempty"` // Allowed values of enum attributes
+	Unit        string   `json:"unit,omitempty"`
+	Description string   `json:"description"`
+}
+
+// PropertyTypeSchema lists the attributes a property type may have
+type PropertyTypeSchema struct {
+	Type       string          `json:"type"`
+	Attributes []AttributeSpec `json:"attributes"`
+}
+
+// Attributes shared by several property types. An attribute has the same
+// kind and range wherever it appears, so filters work across types.
+var (
+	floorAttribute = AttributeSpec{
+		Name: "floor", Kind: AttributeKindInteger, Min: bound(-10), Max: bound(200),
+		Description: "Floor of the unit; negative for basement floors",
+	}
+	totalFloorsAttribute = AttributeSpec{
+		Name: "total_floors", Kind: AttributeKindInteger, Min: bound(1), Max: bound(200),
+		Description: "Floors above ground in the building",
+	}
+	roomsAttribute = AttributeSpec{
+		Name: "rooms", Kind: AttributeKindInteger, Min: bound(0), Max: bound(50),
+		Description: "Number of rooms",
+	}
+	bathroomsAttribute = AttributeSpec{
+		Name: "bathrooms", Kind: AttributeKindInteger, Min: bound(0), Max: bound(50),
+		Description: "Number of bathrooms",
+	}
+	buildYearAttribute = AttributeSpec{
+		Name: "build_year", Kind: AttributeKindInteger, Min: bound(1900), Max: bound(2100),
+		Description: "Year the building was completed",
+	}
+	parkingSpacesAttribute = AttributeSpec{
+		Name: "parking_spaces", Kind: AttributeKindInteger, Min: bound(0), Max: bound(10000),
+		Description: "Parking spaces that come with the property",
+	}
+	facingAttribute = AttributeSpec{
+		Name: "facing", Kind: AttributeKindEnum,
+		Values:      []string{"north", "northeast", "east", "southeast", "south", "southwest", "west", "northwest"},
+		Description: "Direction the main windows face",
+	}
+)
+
+// PropertyTypeSchemas lists the property types and their attributes
+var PropertyTypeSchemas = []PropertyTypeSchema{
+	{
+		Type: PropertyTypeApartment,
+		Attributes: []AttributeSpec{
+			floorAttribute, totalFloorsAttribute, roomsAttribute, bathroomsAttribute,
+			buildYearAttribute, parkingSpacesAttribute, facingAttribute,
+		},
+	},
+	{
+		Type: PropertyTypeOfficetel,
+		Attributes: []AttributeSpec{
+			floorAttribute, totalFloorsAttribute, roomsAttribute, bathroomsAttribute,
+			buildYearAttribute, parkingSpacesAttribute, facingAttribute,
+			{
+				Name: "residential_use", Kind: AttributeKindBoolean,
+				Description: "Registered for residential rather than business use",
+			},
+		},
+	},
+	{
+		Type: PropertyTypeCommercial,
+		Attributes: []AttributeSpec{
+			{
+				Name: "zoning", Kind: AttributeKindEnum, Required: true,
+				Values:      []string{"residential", "commercial", "industrial", "green", "management"},
+				Description: "Land use zone (용도지역) of the site",
+			},
+			floorAttribute, totalFloorsAttribute, buildYearAttribute, parkingSpacesAttribute,
+		},
+	},
+	{
+		Type: PropertyTypeVilla,
+		Attributes: []AttributeSpec{
+			{
+				Name: "land_area", Kind: AttributeKindNumber, Required: true, Min: bound(0), Max: bound(10000000),
+				Unit: "m²", Description: "Area of the plot the villa stands on",
+			},
+			totalFloorsAttribute, roomsAttribute, bathroomsAttribute, buildYearAttribute,
+			parkingSpacesAttribute, facingAttribute,
+		},
+	},
+	{
+		Type: PropertyTypeCondominium,
+		Attributes: []AttributeSpec{
+			floorAttribute, totalFloorsAttribute, roomsAttribute, bathroomsAttribute,
+			buildYearAttribute, parkingSpacesAttribute, facingAttribute,
+		},
+	},
+}
+
+func bound(n float64) *float64 {
+	return &n
+}
+
+// PropertyTypeSchemaOf returns the schema of a property type
+func PropertyTypeSchemaOf(propertyType string) (PropertyTypeSchema, bool) {
+	for _, schema := range PropertyTypeSchemas {
+		if schema.Type == propertyType {
+			return schema, true
+		}
+	}
+	return PropertyTypeSchema{}, false
+}
+
+// Spec returns the spec of one of the type's attributes
+func (s PropertyTypeSchema) Spec(name string) (AttributeSpec, bool) {
+	for _, spec := range s.Attributes {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return AttributeSpec{}, false
+}
+
+// AttributeSpecOf returns the spec of an attribute of any property type
+func AttributeSpecOf(name string) (AttributeSpec, bool) {
+	for _, schema := range PropertyTypeSchemas {
+		if spec, ok := schema.Spec(name); ok {
+			return spec, true
+		}
+	}
+	return AttributeSpec{}, false
+}
+
+// AttributeNames lists the attributes of every property type, sorted
+func AttributeNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, schema := range PropertyTypeSchemas {
+		for _, spec := range schema.Attributes {
+			if !seen[spec.Name] {
+				seen[spec.Name] = true
+				names = append(names, spec.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateAttributes checks attributes against the schema of propertyType.
+// It returns them normalized, with whole numbers as integers and null
+// values dropped, or every problem found.
+func ValidateAttributes(propertyType string, attributes map[string]interface{}) (map[string]interface{}, []string) {
+	schema, ok := PropertyTypeSchemaOf(propertyType)
+	if !ok {
+		return nil, []string{fmt.Sprintf("unknown property type %q", propertyType)}
+	}
+
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	normalized := make(map[string]interface{}, len(attributes))
+	for _, name := range names {
+		value := attributes[name]
+		if value == nil {
+			continue
+		}
+		spec, ok := schema.Spec(name)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("attributes.%s is not an attribute of %s properties", name, propertyType))
+			continue
+		}
+		value, err := spec.check(value)
+		if err != nil {
+			problems = append(problems, "attributes."+name+" "+err.Error())
+			continue
+		}
+		normalized[name] = value
+	}
+
+	for _, spec := range schema.Attributes {
+		if _, ok := normalized[spec.Name]; !ok && spec.Required {
+			problems = append(problems, fmt.Sprintf("attributes.%s is required for %s properties", spec.Name, propertyType))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	if len(normalized) == 0 {
+		return nil, nil
+	}
+	return normalized, nil
+}
+
+// ParseValue reads an attribute value from text, as given in query strings
+// and spreadsheet cells
+func (s AttributeSpec) ParseValue(text string) (interface{}, error) {
+	text = strings.TrimSpace(text)
+	switch s.Kind {
+	case AttributeKindInteger, AttributeKindNumber:
+		n, err := strconv.ParseFloat(strings.ReplaceAll(text, ",", ""), 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a number")
+		}
+		return s.check(n)
+	case AttributeKindBoolean:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return b, nil
+	default:
+		return s.check(strings.ToLower(text))
+	}
+}
+
+// check validates a decoded JSON value against the spec and normalizes it
+func (s AttributeSpec) check(value interface{}) (interface{}, error) {
+	switch s.Kind {
+	case AttributeKindInteger, AttributeKindNumber:
+		n, ok := attributeNumber(value)
+		if !ok || math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, fmt.Errorf("must be a number")
+		}
+		if s.Min != nil && n < *s.Min {
+			return nil, fmt.Errorf("must be at least %g", *s.Min)
+		}
+		if s.Max != nil && n > *s.Max {
+			return nil, fmt.Errorf("must be at most %g", *s.Max)
+		}
+		if s.Kind == AttributeKindInteger {
+			if n != math.Trunc(n) {
+				return nil, fmt.Errorf("must be a whole number")
+			}
+			return int64(n), nil
+		}
+		return n, nil
+	case AttributeKindBoolean:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("must be true or false")
+	default:
+		text, _ := value.(string)
+		for _, allowed := range s.Values {
+			if text == allowed {
+				return text, nil
+			}
+		}
+		return nil, fmt.Errorf("must be one of: %s", strings.Join(s.Values, ", "))
+	}
+}
+
+// attributeNumber converts a numeric attribute value to float64
+func attributeNumber(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// AttributeFilter matches properties whose attribute equals Equals, or for
+// numeric attributes lies within the inclusive Min and Max bounds.
+// Properties without the attribute never match.
+type AttributeFilter struct {
+	Name   string
+	Equals interface{}
+	Min    *float64
+	Max    *float64
+}
+
+// Matches reports whether a property's attributes satisfy the filter
+func (f AttributeFilter) Matches(attributes map[string]interface{}) bool {
+	value, ok := attributes[f.Name]
+	if !ok || value == nil {
+		return false
+	}
+
+	n, isNumber := attributeNumber(value)
+	if f.Equals != nil {
+		if want, ok := attributeNumber(f.Equals); ok {
+			if !isNumber || n != want {
+				return false
+			}
+		} else if value != f.Equals {
+			return false
+		}
+	}
+	if f.Min != nil || f.Max != nil {
+		return isNumber && (f.Min == nil || n >= *f.Min) && (f.Max == nil || n <= *f.Max)
+	}
+	return true
+}
+
+// MatchAttributes reports whether attributes satisfy every filter
+func MatchAttributes(attributes map[string]interface{}, filters []AttributeFilter) bool {
+	for _, filter := range filters {
+		if !filter.Matches(attributes) {
+			return false
+		}
+	}
+	return true
+}
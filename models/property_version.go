@@ -0,0 +1,99 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// propertyVersionIgnoredFields change on every write and are not part of a diff
+var propertyVersionIgnoredFields = map[string]bool{
+	"updated_at": true,
+	"version":    true,
+}
+
+// FieldChange is one changed field between two property versions. Old and
+// New hold the JSON values; null means the field was absent or empty.
+type FieldChange struct {
+	Field string          `json:"field"`
+	Old   json.RawMessage `json:"old"`
+	New   json.RawMessage `json:"new"`
+}
+
+// PropertyVersion is a stored revision of a property. Version 1 is the
+// property as created; later versions record what an update changed.
+type PropertyVersion struct {
+	PropertyID string        `json:"property_id"`
+	Version    int           `json:"version"`
+	Changes    []FieldChange `json:"changes"`
+	ChangedBy  string        `json:"changed_by"`
+	CreatedAt  time.Time     `json:"created_at"`
+	Snapshot   *Property     `json:"snapshot,omitempty"` // The property as of this version, not stored for current_price-only changes
+}
+
+// DiffProperties returns the fields that differ between two versions of a
+// property, compared by their JSON values and sorted by field name
+func DiffProperties(before, after Property) []FieldChange {
+	oldFields, err := propertyFields(before)
+	if err != nil {
+		return nil
+	}
+	newFields, err := propertyFields(after)
+	if err != nil {
+		return nil
+	}
+
+	names := make(map[string]bool)
+	for name := range oldFields {
+		names[name] = true
+	}
+	for name := range newFields {
+		names[name] = true
+	}
+
+	changes := []FieldChange{}
+	for name := range names {
+		if propertyVersionIgnoredFields[name] || bytes.Equal(oldFields[name], newFields[name]) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: name, Old: oldFields[name], New: newFields[name]})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// propertyFields encodes a property as a map of JSON field values
+func propertyFields(property Property) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(property)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+// ApplyChanges returns property with the New value of each change set, e.g.
+// to rebuild a later version from an earlier snapshot
+func ApplyChanges(property Property, changes []FieldChange) (Property, error) {
+	fields, err := propertyFields(property)
+	if err != nil {
+		return property, err
+	}
+	for _, change := range changes {
+		if len(change.New) == 0 || bytes.Equal(change.New, []byte("null")) {
+			delete(fields, change.Field)
+		} else {
+			fields[change.Field] = change.New
+		}
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return property, err
+	}
+	var changed Property
+	err = json.Unmarshal(data, &changed)
+	return changed, err
+}
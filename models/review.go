@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Listing review actions
+const (
+	ReviewActionSubmit         = "submit"
+	ReviewActionApprove        = "approve"
+	ReviewActionReject         = "reject"
+	ReviewActionRequestChanges = "request_changes"
+)
+
+// PropertyReview is one step in a listing's review history
+type PropertyReview struct {
+	ID         string    `json:"id"`
+	PropertyID string    `json:"property_id"`
+	Action     string    `json:"action"` // submit, approve, reject, request_changes
+	Comment    string    `json:"comment,omitempty"`
+	Actor      string    `json:"actor"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ReviewDecisionRequest represents a reviewer's decision on a listing.
+// A comment is required when rejecting or requesting changes.
+type ReviewDecisionRequest struct {
+	Comment string `json:"comment" binding:"max=2000"`
+}
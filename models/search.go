@@ -0,0 +1,30 @@
+package models
+
+// PropertySearchHit is a property matched by a search with its relevance score
+type PropertySearchHit struct {
+	Property
+	Score float64 `json:"score"`
+}
+
+// SearchFacets counts the properties matching a search by type and district
+type SearchFacets struct {
+	Type     map[string]int `json:"type"`
+	District map[string]int `json:"district"`
+}
+
+// PropertySearchResult represents one page of property search results.
+// Total and Facets cover every match that passes the filters, not just the
+// page.
+type PropertySearchResult struct {
+	Query  string              `json:"query"`
+	Total  int                 `json:"total"`
+	Facets SearchFacets        `json:"facets"`
+	Hits   []PropertySearchHit `json:"hits"`
+}
+
+// PropertyGeoHit is a property found by a map search with its distance from
+// the search center
+type PropertyGeoHit struct {
+	Property
+	DistanceKm float64 `json:"distance_km"`
+}
@@ -31,10 +31,11 @@ func (u *User) FromJSON(jsonStr string) error {
 
 // UserResponse API 응답용 구조체
 type UserResponse struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success    bool        `json:"success"`
+	Message    string      `json:"message"`
+	Data       interface{} `json:"data,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
 }
 
 // CreateUserRequest 사용자 생성 요청 구조체
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Notification types for watched properties and saved searches
+const (
+	NotificationWatchlistBid     = "watchlist.bid"
+	NotificationWatchlistClosing = "watchlist.closing"
+	NotificationSavedSearchMatch = "saved_search.match"
+)
+
+// WatchlistRequest represents a request to watch a property
+type WatchlistRequest struct {
+	PropertyID string `json:"property_id" binding:"required"`
+}
+
+// SearchCriteria describes the listings a saved search matches. Every
+// given criterion must match.
+type SearchCriteria struct {
+	Query      string            `json:"q,omitempty"` // Every word must appear, as in property search
+	Type       string            `json:"type,omitempty"`
+	District   string            `json:"district,omitempty"`
+	MinPrice   *int64            `json:"min_price,omitempty"`
+	MaxPrice   *int64            `json:"max_price,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"` // Attribute filters like the attr.* query parameters, e.g. {"rooms.min": "3"}
+}
+
+// SavedSearch is a user's search criteria. The user is notified when a new
+// listing matching it is approved.
+type SavedSearch struct {
+	ID        string         `json:"id"`
+	UserID    string         `json:"user_id"`
+	Name      string         `json:"name"`
+	Criteria  SearchCriteria `json:"criteria"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// ToJSON converts SavedSearch struct to JSON string
+func (s *SavedSearch) ToJSON() (string, error) {
+	jsonData, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonData), nil
+}
+
+// FromJSON converts JSON string to SavedSearch struct
+func (s *SavedSearch) FromJSON(jsonStr string) error {
+	return json.Unmarshal([]byte(jsonStr), s)
+}
+
+// CreateSavedSearchRequest represents a request to save search criteria
+type CreateSavedSearchRequest struct {
+	Name     string         `json:"name" binding:"required,max=100"`
+	Criteria SearchCriteria `json:"criteria"`
+}
+
+// WatchlistResponse represents API response for watchlist and saved search
+// operations
+type WatchlistResponse struct {
+	Success    bool        `json:"success"`
+	Message    string      `json:"message"`
+	Data       interface{} `json:"data,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
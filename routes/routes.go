@@ -1,7 +1,11 @@
 package routes
 
 import (
+	"erea-api/config"
 	"erea-api/handlers"
+	"erea-api/middleware"
+	"erea-api/models"
+	"log"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -12,7 +16,15 @@ import (
 func SetupRoutes() *gin.Engine {
 	// Gin 엔진 생성 (릴리즈 모드)
 	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
+	r := gin.New()
+
+	// 쿼리의 access_token은 요청 로그에 남지 않도록 로거보다 먼저 URL에서 제거
+	r.Use(middleware.StripAccessToken(), gin.Logger(), gin.Recovery())
+
+	// 클라이언트 IP를 전달하는 프록시 설정 (익명 요청의 rate limit 기준, 기본값은 아무 프록시도 믿지 않음)
+	if err := r.SetTrustedProxies(config.TrustedProxies); err != nil {
+		log.Fatalf("TRUSTED_PROXIES 설정 오류: %v", err)
+	}
 
 	// CORS 미들웨어 설정
 	r.Use(cors.New(cors.Config{
@@ -26,6 +38,12 @@ func SetupRoutes() *gin.Engine {
 		MaxAge:           12 * time.Hour,
 	}))
 
+	// 요청 ID 미들웨어 (감사 로그 및 서비스 간 추적용)
+	r.Use(middleware.RequestID())
+
+	// 인증 미들웨어 (API 키 / 관리자 토큰, 자격 증명이 없으면 익명으로 통과)
+	r.Use(middleware.Authenticate())
+
 	// 헬스 체크 엔드포인트
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -35,7 +53,7 @@ func SetupRoutes() *gin.Engine {
 	})
 
 	// API v1 그룹
-	v1 := r.Group("/api/v1")
+	v1 := r.Group("/api/v1", middleware.RateLimit(config.DefaultRateLimit))
 	{
 		// 사용자 관련 엔드포인트
 		users := v1.Group("/users")
@@ -46,7 +64,22 @@ func SetupRoutes() *gin.Engine {
 			users.PUT("/:id", handlers.UpdateUser)     // 사용자 정보 업데이트
 			users.DELETE("/:id", handlers.DeleteUser)  // 사용자 삭제
 			users.GET("/:id/bids", handlers.GetUserBids)  // 사용자 입찰 내역
-			users.GET("/:id/stats", handlers.GetUserStats) // 사용자 통계
+			users.GET("/:id/stats", middleware.RateLimit(config.StatsRateLimit), handlers.GetUserStats) // 사용자 통계
+
+			// 알림, 관심 매물, 저장된 검색은 본인 키 또는 admin만 접근
+			requireUser := middleware.RequireUser("id")
+			users.GET("/:id/notifications", requireUser, handlers.GetUserNotifications)                      // 사용자 알림 조회
+			users.PUT("/:id/notifications/read", requireUser, handlers.MarkAllNotificationsRead)             // 모든 알림 읽음 처리
+			users.PUT("/:id/notifications/:notificationId/read", requireUser, handlers.MarkNotificationRead) // 알림 읽음 처리
+			users.GET("/:id/watchlist", requireUser, handlers.GetWatchlist)                                  // 관심 매물 조회
+			users.POST("/:id/watchlist", requireUser, handlers.WatchProperty)                                // 관심 매물 추가
+			users.DELETE("/:id/watchlist/:propertyId", requireUser, handlers.UnwatchProperty)                // 관심 매물 삭제
+			users.GET("/:id/saved-searches", requireUser, handlers.GetSavedSearches)                         // 저장된 검색 조회
+			users.POST("/:id/saved-searches", requireUser, handlers.CreateSavedSearch)                       // 검색 조건 저장
+			users.DELETE("/:id/saved-searches/:searchId", requireUser, handlers.DeleteSavedSearch)           // 저장된 검색 삭제
+
+			users.GET("/:id/inspections", handlers.GetUserInspections)                    // 임장 예약 내역
+			users.DELETE("/:id/inspections/:bookingId", handlers.CancelInspectionBooking) // 임장 예약 취소
 		}
 
 		// 부동산 속성 관련 엔드포인트
@@ -55,31 +88,56 @@ func SetupRoutes() *gin.Engine {
 			properties.POST("/", handlers.CreateProperty)         // 부동산 생성
 			properties.GET("/", handlers.GetAllProperties)        // 모든 부동산 조회
 			properties.GET("/status", handlers.GetPropertiesByStatus) // 상태별 부동산 조회
+			properties.GET("/types", handlers.GetPropertyTypes)   // 부동산 유형별 속성 스키마 조회
+			properties.GET("/search", middleware.RateLimit(config.SearchRateLimit), handlers.SearchProperties) // 부동산 전문 검색
+			properties.GET("/nearby", middleware.RateLimit(config.SearchRateLimit), handlers.GetNearbyProperties) // 반경 내 부동산 검색
+			properties.GET("/within", middleware.RateLimit(config.SearchRateLimit), handlers.GetPropertiesInBounds) // 지도 영역 내 부동산 검색
 			properties.GET("/:id", handlers.GetProperty)          // 특정 부동산 조회
 			properties.PUT("/:id", handlers.UpdateProperty)       // 부동산 정보 업데이트
 			properties.DELETE("/:id", handlers.DeleteProperty)    // 부동산 삭제
 			properties.GET("/:id/auction", handlers.GetPropertyAuction) // 부동산 경매 정보
 			properties.GET("/:id/bids", handlers.GetBidHistory)         // 부동산 입찰 내역
-			properties.GET("/:id/stats", handlers.GetPropertyStats)     // 부동산 통계
+			properties.GET("/:id/stats", middleware.RateLimit(config.StatsRateLimit), handlers.GetPropertyStats) // 부동산 통계
+			properties.POST("/:id/photos", handlers.UploadPropertyPhotos)     // 부동산 사진 업로드 (소유자 또는 documents:write)
+			properties.POST("/:id/documents", handlers.UploadPropertyDocument) // 부동산 법률 문서 업로드 (소유자 또는 documents:write)
+			properties.GET("/:id/media", handlers.GetPropertyMedia)           // 부동산 사진/문서 목록
+			properties.POST("/:id/submit", handlers.SubmitPropertyForReview)  // 매물 심사 요청
+			properties.GET("/:id/reviews", handlers.GetPropertyReviews)       // 매물 심사 이력
+			properties.GET("/:id/versions", handlers.GetPropertyVersions)     // 부동산 변경 이력
+			properties.GET("/:id/versions/:version", handlers.GetPropertyVersion) // 특정 버전 조회
+
+			properties.POST("/:id/inspections", handlers.CreateInspectionSlot)            // 임장 시간대 등록
+			properties.GET("/:id/inspections", handlers.GetInspectionSlots)               // 임장 시간대 조회
+			properties.GET("/:id/inspections/bookings", handlers.GetInspectionBookings)   // 임장 예약자 조회 (소유자 전용)
+			properties.DELETE("/:id/inspections/:slotId", handlers.CancelInspectionSlot)  // 임장 시간대 취소
+			properties.POST("/:id/inspections/:slotId/bookings", handlers.BookInspection) // 임장 예약
+		}
+
+		// 업로드 파일 관련 엔드포인트
+		media := v1.Group("/media")
+		{
+			media.GET("/:id/download", handlers.DownloadMedia)      // 파일 다운로드 (제한 문서는 권한 필요)
+			media.GET("/:id/thumbnail", handlers.GetMediaThumbnail) // 사진 썸네일
+			media.DELETE("/:id", handlers.DeleteMedia)              // 파일 삭제 (소유자 또는 documents:write)
 		}
 
 		// 입찰 관련 엔드포인트
 		bids := v1.Group("/bids")
 		{
-			bids.POST("/", handlers.PlaceBid)           // 입찰하기
+			bids.POST("/", middleware.RequireScope(models.ScopeBidsWrite), middleware.RateLimit(config.BidRateLimit), middleware.Idempotency(), handlers.PlaceBid) // 입찰하기
 			bids.GET("/", handlers.GetTopBids)          // 상위 입찰 조회
 			bids.GET("/:id", handlers.GetBid)           // 특정 입찰 조회
-			bids.PUT("/:id/status", handlers.UpdateBidStatus) // 입찰 상태 업데이트
+			bids.PUT("/:id/status", middleware.RequireScope(models.ScopeBidsWrite), handlers.UpdateBidStatus) // 입찰 상태 업데이트
 		}
 
 		// 보증금 관련 엔드포인트
 		deposits := v1.Group("/deposits")
 		{
-			deposits.POST("/", handlers.CreateDeposit)           // 보증금 납부
+			deposits.POST("/", middleware.RequireScope(models.ScopeDepositsWrite), middleware.Idempotency(), handlers.CreateDeposit) // 보증금 납부
 			deposits.GET("/", handlers.GetAllDeposits)           // 모든 보증금 조회
 			deposits.GET("/user/:userId", handlers.GetUserDeposits) // 사용자별 보증금 조회
 			deposits.GET("/:id", handlers.GetDeposit)            // 특정 보증금 조회
-			deposits.PUT("/:id/status", handlers.UpdateDepositStatus) // 보증금 상태 업데이트
+			deposits.PUT("/:id/status", middleware.RequireScope(models.ScopeDepositsConfirm), handlers.UpdateDepositStatus) // 보증금 상태 업데이트
 		}
 
 		// 경매 관련 엔드포인트
@@ -89,11 +147,11 @@ func SetupRoutes() *gin.Engine {
 			auctions.GET("/", handlers.GetActiveAuctions)  // 활성 경매 조회
 			auctions.GET("/:id", handlers.GetAuction)      // 특정 경매 조회
 			auctions.PUT("/:id/close", handlers.CloseAuction) // 경매 종료
-			auctions.GET("/stats", handlers.GetAuctionStats)  // 경매 통계
+			auctions.GET("/stats", middleware.RateLimit(config.StatsRateLimit), handlers.GetAuctionStats) // 경매 통계
 		}
 
 		// 통계 및 대시보드 엔드포인트
-		stats := v1.Group("/stats")
+		stats := v1.Group("/stats", middleware.RateLimit(config.StatsRateLimit))
 		{
 			stats.GET("/dashboard", handlers.GetDashboardStats) // 대시보드 통계
 			stats.GET("/realtime", handlers.GetRealtimeStats)   // 실시간 통계
@@ -104,13 +162,14 @@ func SetupRoutes() *gin.Engine {
 		{
 			ws.GET("/auction", handlers.HandleWebSocket)         // WebSocket 연결
 			ws.GET("/clients", handlers.GetConnectedClients)     // 연결된 클라이언트 수
+			ws.GET("/events", handlers.StreamEvents)             // SSE 이벤트 스트림 (WebSocket이 막힌 환경용)
 		}
 
 		// EERC 토큰 관련 엔드포인트
-		eerc := v1.Group("/eerc")
+		eerc := v1.Group("/eerc", middleware.RequireScope(models.ScopeEERCWrite))
 		{
-			eerc.POST("/mint", handlers.MintEERCTokens)       // EERC 토큰 민팅 (ZK proof 포함)
-			eerc.POST("/transfer", handlers.TransferEERCTokens) // EERC 토큰 전송 (ZK proof 포함)
+			eerc.POST("/mint", middleware.Idempotency(), handlers.MintEERCTokens)         // EERC 토큰 민팅 (ZK proof 포함)
+			eerc.POST("/transfer", middleware.Idempotency(), handlers.TransferEERCTokens) // EERC 토큰 전송 (ZK proof 포함)
 		}
 
 		// 데모 데이터 엔드포인트
@@ -120,6 +179,23 @@ func SetupRoutes() *gin.Engine {
 			demo.DELETE("/clear", handlers.ClearDemoData)    // 데모 데이터 삭제
 			demo.GET("/status", handlers.GetDemoStatus)      // 데모 데이터 상태 확인
 		}
+
+		// 관리자 엔드포인트
+		admin := v1.Group("/admin", middleware.RequireScope(models.ScopeAdmin))
+		{
+			admin.POST("/api-keys", handlers.CreateAPIKey)       // API 키 발급
+			admin.GET("/api-keys", handlers.GetAPIKeys)          // API 키 목록 조회
+			admin.GET("/api-keys/:id", handlers.GetAPIKey)       // 특정 API 키 조회
+			admin.DELETE("/api-keys/:id", handlers.RevokeAPIKey) // API 키 폐기
+			admin.GET("/audit", handlers.GetAuditLog)            // 감사 로그 조회
+			admin.GET("/audit/verify", handlers.VerifyAuditLog)  // 감사 로그 해시 체인 검증
+			admin.GET("/reviews", handlers.GetReviewQueue)                          // 매물 심사 대기열
+			admin.POST("/reviews/:id/approve", handlers.ApproveProperty)            // 매물 승인
+			admin.POST("/reviews/:id/reject", handlers.RejectProperty)              // 매물 반려
+			admin.POST("/reviews/:id/request-changes", handlers.RequestPropertyChanges) // 매물 보완 요청
+			admin.POST("/properties/import", handlers.ImportProperties)             // 매물 일괄 가져오기 (CSV/XLSX)
+			admin.GET("/properties/export", handlers.ExportProperties)              // 매물 및 경매 결과 내보내기
+		}
 	}
 
 	return r
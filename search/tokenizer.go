@@ -0,0 +1,241 @@
+// Package search tokenizes property text for the full-text index.
+//
+// Korean has no spaces between a noun and its particles ("강남구에"), so
+// Hangul is indexed as syllable bigrams plus single syllables, and as
+// bigrams of initial consonants (초성) so "ㄱㄴ" finds "강남". Latin words
+// are lowercased and indexed with their prefixes for search-as-you-type.
+package search
+
+import (
+	"erea-api/geo"
+	"strings"
+	"unicode"
+)
+
+// Field weights used when scoring index terms
+const (
+	TitleWeight       = 5.0
+	LocationWeight    = 3.0
+	FeatureWeight     = 2.0
+	DescriptionWeight = 1.0
+)
+
+// Relative weights of the term kinds produced for one word
+const (
+	wordWeight     = 1.0
+	bigramWeight   = 1.0
+	prefixWeight   = 0.5
+	choseongWeight = 0.5
+	unigramWeight  = 0.3
+)
+
+const (
+	minPrefixLength = 2
+	maxPrefixLength = 10
+	hangulBase      = 0xAC00
+	hangulLast      = 0xD7A3
+	jamoFirst       = 0x3131
+	jamoLast        = 0x3163
+	choseongStride  = 21 * 28
+)
+
+// choseong are the compatibility jamo for the 19 initial consonants, in
+// Unicode syllable order
+var choseong = []rune("ㄱㄲㄴㄷㄸㄹㅁㅂㅃㅅㅆㅇㅈㅉㅊㅋㅌㅍㅎ")
+
+// particles are common Korean postpositions (조사), longest first, that are
+// stripped from the end of query words
+var particles = []string{"에서는", "에서", "에게", "으로", "부터", "까지", "은", "는", "이", "가", "을", "를", "의", "에", "로", "와", "과", "도", "만"}
+
+// stopWords are Latin words too common to be worth indexing
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "at": true, "by": true, "for": true, "in": true,
+	"of": true, "on": true, "or": true, "the": true, "to": true, "with": true,
+}
+
+// Document is the searchable text of a property
+type Document struct {
+	Title       string
+	Description string
+	Location    string
+	Features    []string
+}
+
+// Terms returns the weighted index terms of a document. A term's weight is
+// the sum over every occurrence of its field weight times its kind weight.
+func Terms(doc Document) map[string]float64 {
+	terms := make(map[string]float64)
+	addTerms(terms, doc.Title, TitleWeight)
+	addTerms(terms, doc.Location, LocationWeight)
+	for _, feature := range doc.Features {
+		addTerms(terms, feature, FeatureWeight)
+	}
+	addTerms(terms, doc.Description, DescriptionWeight)
+	return terms
+}
+
+// QueryTerms returns the terms a document must contain to match query.
+// Each Hangul word has a trailing particle removed and contributes its
+// syllable bigrams (or the syllable itself when it is one syllable long), so
+// "강남역에서" matches text containing both "강남" and "남역".
+func QueryTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	for _, w := range segment(query) {
+		switch w.kind {
+		case hangulWord, jamoWord:
+			if w.kind == jamoWord && len(w.runes) < 2 {
+				continue // A single initial consonant matches almost everything
+			}
+			if w.kind == hangulWord {
+				w.runes = stripParticle(w.runes)
+			}
+			if len(w.runes) == 1 {
+				add(string(w.runes))
+				continue
+			}
+			for i := 0; i+1 < len(w.runes); i++ {
+				add(string(w.runes[i : i+2]))
+			}
+		case latinWord:
+			word := string(w.runes)
+			if !stopWords[word] {
+				add(word)
+			}
+		}
+	}
+	return terms
+}
+
+// stripParticle removes a trailing particle from a Hangul word, keeping at
+// least two syllables so nouns such as "국가" are left alone
+func stripParticle(runes []rune) []rune {
+	for _, particle := range particles {
+		suffix := []rune(particle)
+		stem := len(runes) - len(suffix)
+		if stem >= 2 && string(runes[stem:]) == particle {
+			return runes[:stem]
+		}
+	}
+	return runes
+}
+
+// District returns the si/gun/gu of a Korean address such as
+// "Gangnam-gu, Seoul" or "서울특별시 강남구 역삼동" as written, preferring
+// the most specific level. Only names in the geocoding table count, so
+// words that merely end in 구 or 시 (입구, 도시) don't. It returns "" when
+// the location names none.
+func District(location string) string {
+	parts := strings.FieldsFunc(location, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+
+	for _, level := range []geo.Level{geo.LevelDistrict, geo.LevelCity} {
+		for _, part := range parts {
+			if _, ok := geo.LookupLevel(part, level); ok {
+				return part
+			}
+		}
+	}
+	return ""
+}
+
+// addTerms tokenizes text and adds its terms with the field weight applied
+func addTerms(terms map[string]float64, text string, fieldWeight float64) {
+	for _, w := range segment(text) {
+		switch w.kind {
+		case hangulWord:
+			if len(w.runes) > 1 {
+				terms[string(w.runes)] += wordWeight * fieldWeight
+				for i := 0; i+1 < len(w.runes); i++ {
+					terms[string(w.runes[i:i+2])] += bigramWeight * fieldWeight
+				}
+			}
+			for _, r := range w.runes {
+				terms[string(r)] += unigramWeight * fieldWeight
+			}
+
+			initials := make([]rune, len(w.runes))
+			for i, r := range w.runes {
+				initials[i] = choseong[(r-hangulBase)/choseongStride]
+			}
+			for i := 0; i+1 < len(initials); i++ {
+				terms[string(initials[i:i+2])] += choseongWeight * fieldWeight
+			}
+
+		case latinWord:
+			word := string(w.runes)
+			if stopWords[word] {
+				continue
+			}
+			terms[word] += wordWeight * fieldWeight
+			for n := minPrefixLength; n < len(w.runes) && n <= maxPrefixLength; n++ {
+				terms[string(w.runes[:n])] += prefixWeight * fieldWeight
+			}
+		}
+	}
+}
+
+type wordKind int
+
+const (
+	separator wordKind = iota
+	hangulWord
+	jamoWord
+	latinWord
+)
+
+// word is a run of runes of one kind
+type word struct {
+	kind  wordKind
+	runes []rune
+}
+
+// segment splits text into runs of Hangul syllables, bare jamo and other
+// letters/digits, lowercasing the latter
+func segment(text string) []word {
+	var words []word
+	var current word
+
+	flush := func() {
+		if current.kind != separator && len(current.runes) > 0 {
+			words = append(words, current)
+		}
+		current = word{}
+	}
+
+	for _, r := range text {
+		kind := runeKind(r)
+		if kind != current.kind {
+			flush()
+			current.kind = kind
+		}
+		if kind != separator {
+			current.runes = append(current.runes, unicode.ToLower(r))
+		}
+	}
+	flush()
+
+	return words
+}
+
+// runeKind classifies a rune for segmentation
+func runeKind(r rune) wordKind {
+	switch {
+	case r >= hangulBase && r <= hangulLast:
+		return hangulWord
+	case r >= jamoFirst && r <= jamoLast:
+		return jamoWord
+	case unicode.IsLetter(r) || unicode.IsDigit(r):
+		return latinWord
+	default:
+		return separator
+	}
+}
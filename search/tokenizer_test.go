@@ -0,0 +1,79 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+// matches reports whether a document contains every term of query, as the
+// index intersection does
+func matches(doc Document, query string) bool {
+	terms := Terms(doc)
+	queryTerms := QueryTerms(query)
+	if len(queryTerms) == 0 {
+		return false
+	}
+	for _, term := range queryTerms {
+		if _, ok := terms[term]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func TestQueryTermsStripParticles(t *testing.T) {
+	if got, want := QueryTerms("강남역에서"), []string{"강남", "남역"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("QueryTerms(강남역에서) = %v, want %v", got, want)
+	}
+	// Two-syllable nouns ending like a particle are left alone
+	if got, want := QueryTerms("국가"), []string{"국가"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("QueryTerms(국가) = %v, want %v", got, want)
+	}
+	// Lone initial consonants and stop words are dropped
+	if got := QueryTerms("ㄱ the"); len(got) != 0 {
+		t.Fatalf("expected no terms, got %v", got)
+	}
+}
+
+func TestKoreanMatching(t *testing.T) {
+	doc := Document{
+		Title:    "강남역 신축 아파트",
+		Location: "Sinsa-dong, Gangnam-gu, Seoul",
+		Features: []string{"Near Subway Station"},
+	}
+	for _, query := range []string{"강남역에서", "강남", "ㄱㄴ", "신축", "gang", "GANGNAM", "subway"} {
+		if !matches(doc, query) {
+			t.Errorf("%q didn't match %q", query, doc.Title)
+		}
+	}
+	for _, query := range []string{"역삼", "ㅇㅅ", "busan"} {
+		if matches(doc, query) {
+			t.Errorf("%q matched %q", query, doc.Title)
+		}
+	}
+}
+
+func TestTermsWeighFields(t *testing.T) {
+	// A two-syllable word is both the word and its only bigram
+	terms := Terms(Document{Title: "강남", Description: "강남"})
+	if want := (TitleWeight + DescriptionWeight) * (wordWeight + bigramWeight); terms["강남"] != want {
+		t.Fatalf("weight of 강남 = %v, want %v", terms["강남"], want)
+	}
+}
+
+func TestDistrict(t *testing.T) {
+	cases := map[string]string{
+		"Sinsa-dong, Gangnam-gu, Seoul, South Korea":      "Gangnam-gu",
+		"서울특별시 강남구 역삼동":                                   "강남구",
+		"Jeongja-dong, Bundang-gu, Seongnam-si, Gyeonggi": "Bundang-gu",
+		"Seogwipo-si, Jeju-do":                            "Seogwipo-si",
+		"Seoul":                                           "",
+		"홍대입구 근처, 서울 마포구":                                 "마포구",
+		"역삼역 8번 출구, 신도시":                                  "",
+	}
+	for location, want := range cases {
+		if got := District(location); got != want {
+			t.Errorf("District(%q) = %q, want %q", location, got, want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Package storage keeps uploaded files (property photos and documents) in a
+// pluggable blob store. Records in Redis refer to blobs by key; the backend
+// is chosen with STORAGE_BACKEND.
+package storage
+
+import (
+	"context"
+	"erea-api/config"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrNotFound is returned when a blob does not exist
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore stores opaque blobs under slash-separated keys such as
+// "properties/{id}/{media_id}.jpg"
+type BlobStore interface {
+	// Put stores data under key, replacing any existing blob
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Open returns a reader for the blob, or ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Store is the configured blob store
+var Store BlobStore
+
+// Init creates the blob store selected by the storage configuration
+func Init() error {
+	store, err := newStore()
+	if err != nil {
+		return err
+	}
+	Store = store
+	return nil
+}
+
+// GetStore returns the blob store, creating it on first use when Init was
+// not called at startup
+func GetStore() (BlobStore, error) {
+	if Store == nil {
+		if err := Init(); err != nil {
+			return nil, err
+		}
+	}
+	return Store, nil
+}
+
+func newStore() (BlobStore, error) {
+	switch strings.ToLower(config.StorageBackend) {
+	case "local":
+		return NewLocalStore(config.StorageLocalDir)
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:  config.StorageS3Endpoint,
+			Bucket:    config.StorageS3Bucket,
+			Region:    config.StorageS3Region,
+			AccessKey: config.StorageS3AccessKey,
+			SecretKey: config.StorageS3SecretKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", config.StorageBackend)
+	}
+}
+
+// validKey reports whether key is a relative, slash-separated path without
+// empty, "." or ".." segments, so it cannot escape the store
+func validKey(key string) bool {
+	if key == "" || strings.ContainsAny(key, "\\\x00") {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}